@@ -0,0 +1,180 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// Wildcard is the principal of every identity and the pattern of every resource
+const Wildcard = "*"
+
+// Authorizer checks the calls against the acls of the shard
+type Authorizer struct {
+	// superusers skip the acls, like the identity the nodes use to call each other
+	superusers map[string]bool
+}
+
+func NewAuthorizer(superusers ...string) *Authorizer {
+	a := &Authorizer{superusers: map[string]bool{}}
+	for _, name := range superusers {
+		a.superusers[name] = true
+	}
+	return a
+}
+
+// PatternMatches reports if the pattern of an acl covers the resource name
+func PatternMatches(pattern string, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, Wildcard); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return pattern == name
+}
+
+func aclMatches(acl *pb.Acl, principal string, resource pb.AclResource, name string) bool {
+	return acl.Resource == resource && (acl.Principal == Wildcard || acl.Principal == principal) && PatternMatches(acl.Pattern, name)
+}
+
+// ValidateAcl checks that the acl can be stored, the wildcard is only allowed at the end of the pattern
+func ValidateAcl(acl *pb.Acl) error {
+	if acl.Principal == "" {
+		return errors.New("the acl has no principal")
+	}
+	if _, ok := pb.AclResource_name[int32(acl.Resource)]; !ok {
+		return fmt.Errorf("unknown resource %d", acl.Resource)
+	}
+	if _, ok := pb.AclOperation_name[int32(acl.Operation)]; !ok {
+		return fmt.Errorf("unknown operation %d", acl.Operation)
+	}
+	if acl.Pattern == "" {
+		return errors.New("the acl has no pattern")
+	}
+	if acl.Resource == pb.AclResource_CLUSTER && acl.Pattern != Wildcard {
+		return errors.New("the pattern of a cluster acl is *")
+	}
+	if strings.Contains(strings.TrimSuffix(acl.Pattern, Wildcard), Wildcard) {
+		return fmt.Errorf("the pattern %q has a wildcard before its end", acl.Pattern)
+	}
+	return nil
+}
+
+// Authorize checks that the caller may do the operation on the resource, calls without an identity are allowed since
+// their service isn't authenticated
+func (r RpcInterface) Authorize(ctx context.Context, resource pb.AclResource, name string, operation pb.AclOperation) error {
+	if r.Authorizer == nil {
+		return nil
+	}
+	return r.Authorizer.Check(ctx, func() ([]*pb.Acl, error) {
+		return r.NodeState.GetAcls("")
+	}, resource, name, operation)
+}
+
+// Check checks the caller against the acls, they are only read for the callers that aren't superusers
+func (a *Authorizer) Check(ctx context.Context, getAcls func() ([]*pb.Acl, error), resource pb.AclResource, name string, operation pb.AclOperation) error {
+	identity := auth.FromContext(ctx)
+	if identity == nil || a.superusers[identity.Name] {
+		return nil
+	}
+	acls, err := getAcls()
+	if err != nil {
+		return err
+	}
+	for _, acl := range acls {
+		if aclMatches(acl, identity.Name, resource, name) && (acl.Operation == operation || acl.Operation == pb.AclOperation_ADMIN) {
+			return nil
+		}
+	}
+	resourceName := strings.ToLower(resource.String())
+	if resource != pb.AclResource_CLUSTER {
+		resourceName += " " + name
+	}
+	return status.Errorf(codes.PermissionDenied, "%s is not allowed to %s the %s", identity.Name,
+		strings.ToLower(operation.String()), resourceName)
+}
+
+// visible returns a filter of the resources the caller has any operation on
+func (r RpcInterface) visible(ctx context.Context) (func(resource pb.AclResource, name string) bool, error) {
+	if r.Authorizer == nil {
+		return func(pb.AclResource, string) bool { return true }, nil
+	}
+	return r.Authorizer.Visible(ctx, func() ([]*pb.Acl, error) {
+		return r.NodeState.GetAcls("")
+	})
+}
+
+// Visible returns a filter of the resources the caller has any operation on, the superusers see everything
+func (a *Authorizer) Visible(ctx context.Context, getAcls func() ([]*pb.Acl, error)) (func(resource pb.AclResource, name string) bool, error) {
+	identity := auth.FromContext(ctx)
+	if identity == nil || a.superusers[identity.Name] {
+		return func(pb.AclResource, string) bool { return true }, nil
+	}
+	acls, err := getAcls()
+	if err != nil {
+		return nil, err
+	}
+	return func(resource pb.AclResource, name string) bool {
+		for _, acl := range acls {
+			if aclMatches(acl, identity.Name, resource, name) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func (r RpcInterface) PutAcls(ctx context.Context, req *pb.PutAclsRequest) (*pb.PutAclsResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	for _, acl := range req.GetAcls() {
+		if err := ValidateAcl(acl); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.PutAcls); forwarded {
+		return res, err
+	}
+	res, index, err := apply[*pb.PutAclsResponse](r, &pb.WriteOperation{
+		Operation: &pb.WriteOperation_PutAcls{PutAcls: &pb.PutAcls{Acls: req.GetAcls()}},
+		Code:      pb.Operation_PUT_ACLS,
+	})
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	res.LastIndex = index
+	return res, nil
+}
+
+func (r RpcInterface) DeleteAcls(ctx context.Context, req *pb.DeleteAclsRequest) (*pb.DeleteAclsResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.DeleteAcls); forwarded {
+		return res, err
+	}
+	res, index, err := apply[*pb.DeleteAclsResponse](r, &pb.WriteOperation{
+		Operation: &pb.WriteOperation_DeleteAcls{DeleteAcls: &pb.DeleteAcls{Acls: req.GetAcls()}},
+		Code:      pb.Operation_DELETE_ACLS,
+	})
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	res.LastIndex = index
+	return res, nil
+}
+
+func (r RpcInterface) ListAcls(ctx context.Context, req *pb.ListAclsRequest) (*pb.ListAclsResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	acls, err := r.NodeState.GetAcls(req.GetPrincipal())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ListAclsResponse{Acls: acls}, nil
+}
@@ -5,62 +5,120 @@ import (
 	"errors"
 	"github.com/Kapperchino/jet-stream/application/fsm"
 	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/config"
 	"github.com/Kapperchino/jet-stream/util"
 	"github.com/hashicorp/raft"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"time"
 )
 
 type RpcInterface struct {
 	NodeState *fsm.NodeState
 	Raft      *raft.Raft
+	// Forwarder is used to forward writes to the leader, writes on followers are rejected with a redirect if nil
+	Forwarder *Forwarder
+	// Authorizer checks the acls of the callers, every caller is allowed if nil
+	Authorizer *Authorizer
+	// Quotas throttle the publishes, they aren't limited if nil
+	Quotas *QuotaLimiter
 	pb.UnimplementedMessageServiceServer
 }
 
-func PublishMessagesInternal(r RpcInterface, req *pb.PublishMessageRequest) ([]*pb.Message, error) {
+// PublishMessagesInternal writes the messages through raft, the trace context of ctx goes with the write so the fsm
+// continues it
+func PublishMessagesInternal(ctx context.Context, r RpcInterface, req *pb.PublishMessageRequest) ([]*pb.Message, uint64, error) {
+	ctx, span := util.Tracer().Start(ctx, "raft.Apply", trace.WithAttributes(
+		attribute.String("jet.topic", req.GetTopic()),
+		attribute.Int64("jet.partition", int64(req.GetPartition())),
+		attribute.Int("jet.messages", len(req.GetMessages())),
+	))
+	defer span.End()
 	input := &pb.WriteOperation{
 		Operation: &pb.WriteOperation_Publish{
 			Publish: &pb.Publish{
-				Topic:     req.GetTopic(),
-				Partition: req.GetPartition(),
-				Messages:  req.GetMessages(),
+				Topic:        req.GetTopic(),
+				Partition:    req.GetPartition(),
+				Messages:     req.GetMessages(),
+				TraceContext: util.InjectTraceContext(ctx, nil),
 			},
 		},
 		Code: pb.Operation_PUBLISH,
 	}
 	val, _ := util.SerializeMessage(input)
-	res := r.Raft.Apply(val, time.Second)
+	res := r.Raft.Apply(val, config.Current().ApplyTimeout)
 	if err := res.Error(); err != nil {
-		return nil, err
+		span.SetStatus(codes.Error, err.Error())
+		return nil, 0, err
 	}
+	span.SetAttributes(attribute.Int64("jet.raft_index", int64(res.Index())))
 	err, isErr := res.Response().(error)
 	if isErr {
-		return nil, err
+		span.SetStatus(codes.Error, err.Error())
+		return nil, 0, err
 	}
 	response, isValid := res.Response().([]*pb.Message)
 	if !isValid {
-		return nil, errors.New("unknown data type")
+		return nil, 0, errors.New("unknown data type")
 	}
-	return response, nil
+	return response, res.Index(), nil
 }
 
 func (r RpcInterface) PublishMessages(ctx context.Context, req *pb.PublishMessageRequest) (*pb.PublishMessageResponse, error) {
-	messages, err := PublishMessagesInternal(r, req)
-	res := &pb.PublishMessageResponse{Messages: messages}
-	r.Raft.LastIndex()
+	if err := r.Authorize(ctx, pb.AclResource_TOPIC, req.GetTopic(), pb.AclOperation_WRITE); err != nil {
+		return nil, err
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.PublishMessages); forwarded {
+		return res, err
+	}
+	throttle, err := r.chargeQuotas(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	return res, nil
+	start := time.Now()
+	messages, index, err := PublishMessagesInternal(ctx, r, req)
+	r.recordPublish(req, start, err)
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	return &pb.PublishMessageResponse{Messages: messages, LastIndex: index, ThrottleMs: uint32(throttle.Milliseconds())}, nil
 }
 
 func ConsumeInternal(r RpcInterface, req *pb.ConsumeRequest) (*pb.ConsumeResponse, error) {
-	return r.NodeState.Consume(req)
+	err := waitForConsistency(r, req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.NodeState.Consume(req)
+	if err != nil {
+		return nil, err
+	}
+	res.LastIndex = r.Raft.AppliedIndex()
+	return res, nil
 }
 
-func (r RpcInterface) Consume(_ context.Context, req *pb.ConsumeRequest) (*pb.ConsumeResponse, error) {
+func (r RpcInterface) Consume(ctx context.Context, req *pb.ConsumeRequest) (*pb.ConsumeResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_TOPIC, req.GetTopic(), pb.AclOperation_READ); err != nil {
+		return nil, err
+	}
+	if err := r.Authorize(ctx, pb.AclResource_GROUP, req.GetGroupId(), pb.AclOperation_READ); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	_, span := util.Tracer().Start(ctx, "jet.Read", trace.WithAttributes(
+		attribute.String("jet.topic", req.GetTopic()),
+		attribute.String("jet.group", req.GetGroupId()),
+		attribute.String("jet.consistency", req.GetConsistency().String()),
+	))
 	res, err := ConsumeInternal(r, req)
 	if err != nil {
-		return nil, err
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	r.recordConsume(req, res, start, err)
+	if err != nil {
+		return nil, r.toStatusError(err)
 	}
 	return res, nil
 }
@@ -69,7 +127,10 @@ func GetConsumerGroupsInternal(r RpcInterface, req *pb.GetConsumerGroupsRequest)
 	return r.NodeState.GetConsumerGroups(req.Topic)
 }
 
-func (r RpcInterface) GetConsumerGroups(_ context.Context, req *pb.GetConsumerGroupsRequest) (*pb.GetConsumerGroupsResponse, error) {
+func (r RpcInterface) GetConsumerGroups(ctx context.Context, req *pb.GetConsumerGroupsRequest) (*pb.GetConsumerGroupsResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_TOPIC, req.GetTopic(), pb.AclOperation_READ); err != nil {
+		return nil, err
+	}
 	res, err := GetConsumerGroupsInternal(r, req)
 	if err != nil {
 		return nil, err
@@ -78,14 +139,46 @@ func (r RpcInterface) GetConsumerGroups(_ context.Context, req *pb.GetConsumerGr
 }
 
 func GetMetaInternal(r RpcInterface, req *pb.GetMetaRequest) (*pb.GetMetaResponse, error) {
-	return r.NodeState.GetMeta()
+	res, err := r.NodeState.GetMeta()
+	if err != nil || !req.GetWithSizes() {
+		return res, err
+	}
+	loads, err := r.NodeState.PartitionLoads()
+	if err != nil {
+		return nil, err
+	}
+	//the offset of the stored partition isn't kept up to date by the publishes, the stats kept with the messages are
+	for _, load := range loads {
+		if partition := res.Topics[load.Topic].GetPartitions()[load.Partition]; partition != nil {
+			partition.Bytes = load.Bytes
+			if load.LastOffset > partition.Offset {
+				partition.Offset = load.LastOffset
+			}
+		}
+	}
+	return res, nil
 }
 
-func (r RpcInterface) GetMeta(_ context.Context, req *pb.GetMetaRequest) (*pb.GetMetaResponse, error) {
+// GetMeta only returns the topics and consumer groups the caller has an acl on
+func (r RpcInterface) GetMeta(ctx context.Context, req *pb.GetMetaRequest) (*pb.GetMetaResponse, error) {
 	res, err := GetMetaInternal(r, req)
 	if err != nil {
 		return nil, err
 	}
+	visible, err := r.visible(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for name := range res.Topics {
+		if !visible(pb.AclResource_TOPIC, name) {
+			delete(res.Topics, name)
+		}
+	}
+	for id := range res.ConsumerGroups {
+		if !visible(pb.AclResource_GROUP, id) {
+			delete(res.ConsumerGroups, id)
+		}
+	}
 	return res, nil
 }
 
@@ -100,7 +193,7 @@ func CreateTopicInternal(r RpcInterface, req *pb.CreateTopicRequest) (*pb.Create
 		Code: pb.Operation_CREATE_TOPIC,
 	}
 	val, _ := util.SerializeMessage(input)
-	res := r.Raft.Apply(val, time.Second)
+	res := r.Raft.Apply(val, config.Current().ApplyTimeout)
 	if err := res.Error(); err != nil {
 		return nil, err
 	}
@@ -115,18 +208,33 @@ func CreateTopicInternal(r RpcInterface, req *pb.CreateTopicRequest) (*pb.Create
 	return response, nil
 }
 
-func (r RpcInterface) CreateTopic(_ context.Context, req *pb.CreateTopicRequest) (*pb.CreateTopicResponse, error) {
+func (r RpcInterface) CreateTopic(ctx context.Context, req *pb.CreateTopicRequest) (*pb.CreateTopicResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_TOPIC, req.GetTopic(), pb.AclOperation_CREATE); err != nil {
+		return nil, err
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.CreateTopic); forwarded {
+		return res, err
+	}
 	res, err := CreateTopicInternal(r, req)
 	if err != nil {
-		return nil, err
+		return nil, r.toStatusError(err)
 	}
 	return res, nil
 }
 
-func (r RpcInterface) AckConsume(_ context.Context, req *pb.AckConsumeRequest) (*pb.AckConsumeResponse, error) {
+func (r RpcInterface) AckConsume(ctx context.Context, req *pb.AckConsumeRequest) (*pb.AckConsumeResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_TOPIC, req.GetTopic(), pb.AclOperation_READ); err != nil {
+		return nil, err
+	}
+	if err := r.Authorize(ctx, pb.AclResource_GROUP, req.GetGroupId(), pb.AclOperation_READ); err != nil {
+		return nil, err
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.AckConsume); forwarded {
+		return res, err
+	}
 	res, err := AckConsumeInternal(r, req)
 	if err != nil {
-		return nil, err
+		return nil, r.toStatusError(err)
 	}
 	return res, nil
 }
@@ -143,7 +251,7 @@ func AckConsumeInternal(r RpcInterface, req *pb.AckConsumeRequest) (*pb.AckConsu
 		Code: pb.Operation_ACK,
 	}
 	val, _ := util.SerializeMessage(input)
-	res := r.Raft.Apply(val, time.Second)
+	res := r.Raft.Apply(val, config.Current().ApplyTimeout)
 	if err := res.Error(); err != nil {
 		return nil, err
 	}
@@ -158,10 +266,19 @@ func AckConsumeInternal(r RpcInterface, req *pb.AckConsumeRequest) (*pb.AckConsu
 	return response, nil
 }
 
-func (r RpcInterface) CreateConsumerGroup(_ context.Context, req *pb.CreateConsumerGroupRequest) (*pb.CreateConsumerGroupResponse, error) {
+func (r RpcInterface) CreateConsumerGroup(ctx context.Context, req *pb.CreateConsumerGroupRequest) (*pb.CreateConsumerGroupResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_TOPIC, req.GetTopic(), pb.AclOperation_READ); err != nil {
+		return nil, err
+	}
+	if err := r.Authorize(ctx, pb.AclResource_GROUP, req.GetId(), pb.AclOperation_CREATE); err != nil {
+		return nil, err
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.CreateConsumerGroup); forwarded {
+		return res, err
+	}
 	res, err := CreateConsumerGroupInternal(r, req)
 	if err != nil {
-		return nil, err
+		return nil, r.toStatusError(err)
 	}
 	return res, nil
 }
@@ -177,7 +294,7 @@ func CreateConsumerGroupInternal(r RpcInterface, req *pb.CreateConsumerGroupRequ
 		Code: pb.Operation_CREATE_CONSUMER_GROUP,
 	}
 	val, _ := util.SerializeMessage(input)
-	res := r.Raft.Apply(val, time.Second)
+	res := r.Raft.Apply(val, config.Current().ApplyTimeout)
 	if err := res.Error(); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,58 @@
+package application
+
+import (
+	"errors"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/hashicorp/raft"
+	"time"
+)
+
+const (
+	readIndexTimeout      = 5 * time.Second
+	readIndexPollInterval = 5 * time.Millisecond
+)
+
+var ErrIndexNotReached = errors.New("timed out waiting for the node to reach the requested index")
+
+// waitForConsistency blocks until this node is allowed to serve the read with the requested consistency
+func waitForConsistency(r RpcInterface, req *pb.ConsumeRequest) error {
+	switch req.GetConsistency() {
+	case pb.ReadConsistency_LEADER:
+		if r.Raft.State() != raft.Leader {
+			return raft.ErrNotLeader
+		}
+	case pb.ReadConsistency_LINEARIZABLE:
+		//make sure we are still the leader, then wait for everything committed before the read to be applied
+		if err := r.Raft.VerifyLeader().Error(); err != nil {
+			return err
+		}
+		if err := r.Raft.Barrier(readIndexTimeout).Error(); err != nil {
+			return err
+		}
+	}
+	if req.GetMinIndex() == 0 {
+		return nil
+	}
+	return waitForIndex(r.Raft, req.GetMinIndex(), readIndexTimeout)
+}
+
+// waitForIndex waits until the fsm has applied the given raft index
+func waitForIndex(r *raft.Raft, index uint64, timeout time.Duration) error {
+	if r.AppliedIndex() >= index {
+		return nil
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(readIndexPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-deadline.C:
+			return ErrIndexNotReached
+		case <-ticker.C:
+			if r.AppliedIndex() >= index {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package application
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/cluster"
+	"github.com/Kapperchino/jet-stream/controller"
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/hashicorp/raft"
+	"strconv"
+	"time"
+)
+
+const (
+	heartbeatInterval = time.Second
+	// loadInterval is how often the partitions are scanned for the load sent with the heartbeats
+	loadInterval = 10 * time.Second
+)
+
+// loadReporter keeps the last partition scan, the publish rate is the offset growth between two scans
+type loadReporter struct {
+	scannedAt time.Time
+	loads     []*controllerPb.PartitionLoad
+}
+
+func (l *loadReporter) report(r RpcInterface) []*controllerPb.PartitionLoad {
+	if time.Since(l.scannedAt) < loadInterval {
+		return l.loads
+	}
+	partitions, err := r.NodeState.PartitionLoads()
+	if err != nil {
+		r.NodeState.Logger.Warn().Err(err).Msgf("Error scanning the partition load")
+		return l.loads
+	}
+	previous := map[string]*controllerPb.PartitionLoad{}
+	for _, load := range l.loads {
+		previous[load.Topic+"/"+strconv.FormatUint(load.Partition, 10)] = load
+	}
+	elapsed := time.Since(l.scannedAt).Seconds()
+	loads := make([]*controllerPb.PartitionLoad, 0, len(partitions))
+	for _, partition := range partitions {
+		load := &controllerPb.PartitionLoad{
+			Topic:      partition.Topic,
+			Partition:  partition.Partition,
+			Bytes:      partition.Bytes,
+			LastOffset: partition.LastOffset,
+		}
+		if old := previous[load.Topic+"/"+strconv.FormatUint(load.Partition, 10)]; old != nil && load.LastOffset >= old.LastOffset {
+			load.PublishRate = float64(load.LastOffset-old.LastOffset) / elapsed
+		}
+		loads = append(loads, load)
+	}
+	l.scannedAt = time.Now()
+	l.loads = loads
+	return loads
+}
+
+// SyncWithController makes the shard leader report to the controller and create the topics and consumer groups the
+// controller placed on this shard, runs until stop is closed
+func SyncWithController(r RpcInterface, client *controller.Client, stop <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	load := &loadReporter{}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if r.Raft.State() != raft.Leader || r.NodeState.ShardState == nil {
+			continue
+		}
+		if err := heartbeat(r, client, load.report(r)); err != nil {
+			r.NodeState.Logger.Warn().Err(err).Msgf("Error syncing with the controller")
+		}
+	}
+}
+
+func heartbeat(r RpcInterface, client *controller.Client, load []*controllerPb.PartitionLoad) error {
+	meta, err := r.NodeState.GetMeta()
+	if err != nil {
+		return err
+	}
+	req := &controllerPb.ShardHeartbeatRequest{
+		Shard:  shardRecord(r.NodeState.ShardState.ShardInfo),
+		Topics: map[string]*controllerPb.PartitionList{},
+		Load:   load,
+	}
+	for name, topic := range meta.Topics {
+		list := &controllerPb.PartitionList{}
+		for num := range topic.Partitions {
+			list.Partitions = append(list.Partitions, num)
+		}
+		req.Topics[name] = list
+	}
+	for id := range meta.ConsumerGroups {
+		req.ConsumerGroups = append(req.ConsumerGroups, id)
+	}
+	acls, err := r.NodeState.GetAcls("")
+	if err != nil {
+		return err
+	}
+	for _, acl := range acls {
+		req.Acls = append(req.Acls, &controllerPb.ShardAcl{
+			Principal: acl.Principal,
+			Resource:  int32(acl.Resource),
+			Pattern:   acl.Pattern,
+			Operation: int32(acl.Operation),
+		})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatInterval)
+	defer cancel()
+	res, err := controller.Call(ctx, client, req, controllerPb.ControllerServiceClient.ShardHeartbeat)
+	if err != nil {
+		return err
+	}
+	//topics first, the consumer groups need them
+	for name, list := range res.Topics {
+		_, err := CreateTopicInternal(r, &pb.CreateTopicRequest{Topic: name, Partitions: list.Partitions})
+		if err != nil {
+			return err
+		}
+	}
+	for _, group := range res.ConsumerGroups {
+		_, err := CreateConsumerGroupInternal(r, &pb.CreateConsumerGroupRequest{Topic: group.Topic, Id: group.Id})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func shardRecord(info *cluster.ShardInfo) *controllerPb.ShardRecord {
+	record := &controllerPb.ShardRecord{
+		ShardId:  info.GetShardId(),
+		LeaderId: info.Leader,
+		Members:  map[string]string{},
+	}
+	info.MemberMap.ForEach(func(nodeId string, member *cluster.MemberInfo) bool {
+		record.Members[nodeId] = member.Address
+		return true
+	})
+	return record
+}
@@ -0,0 +1,227 @@
+package application
+
+import (
+	"context"
+	"errors"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/auth"
+	"github.com/Kapperchino/jet-stream/config"
+	"github.com/Kapperchino/jet-stream/leader-rpc/rafterrors"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+const (
+	// forwardedKey is set on writes forwarded by a follower, the receiver won't forward them a second time
+	forwardedKey = "jet-forwarded-by"
+	// forwardedForKey is the principal that sent a forwarded write to the follower, the leader charges its quotas
+	forwardedForKey = "jet-forwarded-for"
+)
+
+// ErrForwarderClosed is returned once the node closed its forwarder, the writes get a redirect instead
+var ErrForwarderClosed = errors.New("the forwarder is closed")
+
+// Forwarder keeps connections to shard leaders so followers can forward writes to them, the shards hosted by a node
+// share it. A connection is closed once no shard has its address as leader anymore or when a call on it finds the
+// leader unavailable, the calls still running on it finish first
+type Forwarder struct {
+	conns map[string]*forwardConn
+	// leaders are the addresses the writes of each shard were last forwarded to
+	leaders map[string]string
+	// dialOptions carry the transport credentials of the node
+	dialOptions []grpc.DialOption
+	closed      bool
+	mutex       sync.Mutex
+}
+
+type forwardConn struct {
+	conn *grpc.ClientConn
+	// calls are the forwarded writes in flight on the connection
+	calls   int
+	evicted bool
+}
+
+func NewForwarder(dialOptions ...grpc.DialOption) *Forwarder {
+	return &Forwarder{
+		conns:       map[string]*forwardConn{},
+		leaders:     map[string]string{},
+		dialOptions: dialOptions,
+	}
+}
+
+// getClient returns a client of the leader at address, release has to be called with the result of the call
+func (f *Forwarder) getClient(address string, shardId string) (pb.MessageServiceClient, func(err error), error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return nil, nil, ErrForwarderClosed
+	}
+	if previous := f.leaders[shardId]; previous != address {
+		f.leaders[shardId] = address
+		f.evictUnused(previous)
+	}
+	conn := f.conns[address]
+	if conn == nil {
+		maxSize := config.DefaultMaxMessageSize
+		clientConn, err := grpc.Dial(address, append([]grpc.DialOption{grpc.WithInsecure(),
+			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxSize), grpc.MaxCallSendMsgSize(maxSize))}, f.dialOptions...)...)
+		if err != nil {
+			return nil, nil, err
+		}
+		conn = &forwardConn{conn: clientConn}
+		f.conns[address] = conn
+	}
+	conn.calls++
+	release := func(err error) {
+		f.release(address, conn, err)
+	}
+	return pb.NewMessageServiceClient(util.ShardConn(conn.conn, shardId)), release, nil
+}
+
+func (f *Forwarder) release(address string, conn *forwardConn, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	conn.calls--
+	//the next write dials again instead of waiting for the connection to recover
+	if status.Code(err) == codes.Unavailable && f.conns[address] == conn {
+		delete(f.conns, address)
+		conn.evicted = true
+	}
+	if conn.evicted && conn.calls == 0 {
+		_ = conn.conn.Close()
+	}
+}
+
+// evictUnused closes the connection to address when it isn't the leader of any shard anymore
+func (f *Forwarder) evictUnused(address string) {
+	conn := f.conns[address]
+	if conn == nil {
+		return
+	}
+	for _, leader := range f.leaders {
+		if leader == address {
+			return
+		}
+	}
+	delete(f.conns, address)
+	conn.evicted = true
+	if conn.calls == 0 {
+		_ = conn.conn.Close()
+	}
+}
+
+// Close closes the connections, the writes forwarded after it get a redirect
+func (f *Forwarder) Close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.closed = true
+	for address, conn := range f.conns {
+		_ = conn.conn.Close()
+		delete(f.conns, address)
+	}
+}
+
+// forwardToLeader sends the write to the shard leader when this node is a follower, forwarded is false when the
+// write should be applied locally
+func forwardToLeader[Req any, Res any](ctx context.Context, r RpcInterface, req Req,
+	call func(pb.MessageServiceClient, context.Context, Req, ...grpc.CallOption) (Res, error)) (res Res, forwarded bool, err error) {
+	if r.Raft.State() == raft.Leader {
+		return res, false, nil
+	}
+	_, address := r.leader()
+	if r.Forwarder == nil || address == "" || isForwarded(ctx) {
+		return res, true, r.notLeaderError()
+	}
+	client, release, err := r.Forwarder.getClient(address, r.shardId())
+	if err != nil {
+		return res, true, r.notLeaderError()
+	}
+	pairs := []string{forwardedKey, r.nodeId()}
+	if identity := auth.FromContext(ctx); identity != nil {
+		pairs = append(pairs, forwardedForKey, identity.Name)
+	}
+	if clientId := util.ClientIdFromContext(ctx); clientId != "" {
+		pairs = append(pairs, util.ClientIdKey, clientId)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, pairs...)
+	res, err = call(client, ctx, req)
+	release(err)
+	return res, true, err
+}
+
+func isForwarded(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(forwardedKey)) > 0
+}
+
+// forwardedFor is the principal a follower forwarded the write for, empty when its caller had no identity
+func forwardedFor(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get(forwardedForKey)) == 0 {
+		return ""
+	}
+	return md.Get(forwardedForKey)[0]
+}
+
+// leader returns the id and address of the shard leader as known by the cluster state
+func (r RpcInterface) leader() (string, string) {
+	shardState := r.NodeState.ShardState
+	if shardState == nil {
+		_, id := r.Raft.LeaderWithID()
+		return string(id), ""
+	}
+	leaderId := shardState.ShardInfo.Leader
+	member := shardState.ShardInfo.MemberMap.Get(leaderId)
+	if member == nil {
+		return leaderId, ""
+	}
+	return leaderId, member.Address
+}
+
+func (r RpcInterface) nodeId() string {
+	if r.NodeState.ShardState == nil {
+		return ""
+	}
+	return r.NodeState.ShardState.MemberInfo.NodeId
+}
+
+func (r RpcInterface) shardId() string {
+	if r.NodeState.ShardState == nil {
+		return ""
+	}
+	return r.NodeState.ShardState.ShardInfo.GetShardId()
+}
+
+// notLeaderError is a retriable status carrying a NotLeader detail so clients can redirect to the leader
+func (r RpcInterface) notLeaderError() error {
+	leaderId, address := r.leader()
+	st, err := status.New(rafterrors.RetriableCode(raft.ErrNotLeader), raft.ErrNotLeader.Error()).WithDetails(&pb.NotLeader{
+		ShardId:       r.shardId(),
+		LeaderId:      leaderId,
+		LeaderAddress: address,
+	})
+	if err != nil {
+		return rafterrors.MarkRetriable(raft.ErrNotLeader)
+	}
+	return st.Err()
+}
+
+// toStatusError maps raft errors to grpc status codes, other errors are returned as is
+func (r RpcInterface) toStatusError(err error) error {
+	//a leader handing its leadership over doesn't take writes, the clients wait for the new one
+	if errors.Is(err, raft.ErrNotLeader) || errors.Is(err, raft.ErrLeadershipLost) || errors.Is(err, raft.ErrLeadershipTransferInProgress) {
+		return r.notLeaderError()
+	}
+	if isPartitionMoving(err) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	if rafterrors.RetriableCode(err) == codes.Unknown {
+		return err
+	}
+	return rafterrors.MarkRetriable(err)
+}
@@ -0,0 +1,126 @@
+package fsm
+
+import (
+	"fmt"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/dgraph-io/badger/v3"
+	"sort"
+)
+
+const aclPrefix = "Acl-"
+
+// aclKey identifies an acl, putting the same one twice keeps a single copy
+func aclKey(acl *pb.Acl) []byte {
+	return []byte(fmt.Sprintf("%s%s\x00%d\x00%s\x00%d", aclPrefix, acl.Principal, acl.Resource, acl.Pattern, acl.Operation))
+}
+
+// PutAcls write operation, done in fsm
+func (f *NodeState) PutAcls(req *pb.PutAcls) (interface{}, error) {
+	acls, err := f.loadAcls()
+	if err != nil {
+		return nil, err
+	}
+	err = f.MetaStore.Update(func(tx *badger.Txn) error {
+		for _, acl := range req.GetAcls() {
+			buf, err := util.SerializeMessage(acl)
+			if err != nil {
+				return err
+			}
+			if err := tx.Set(aclKey(acl), buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error with local store, %w", err)
+	}
+	f.aclMutex.Lock()
+	defer f.aclMutex.Unlock()
+	for _, acl := range req.GetAcls() {
+		acls[string(aclKey(acl))] = acl
+	}
+	f.Logger.Info().Msgf("Added %d acls", len(req.GetAcls()))
+	return &pb.PutAclsResponse{}, nil
+}
+
+// DeleteAcls write operation, done in fsm
+func (f *NodeState) DeleteAcls(req *pb.DeleteAcls) (interface{}, error) {
+	acls, err := f.loadAcls()
+	if err != nil {
+		return nil, err
+	}
+	err = f.MetaStore.Update(func(tx *badger.Txn) error {
+		for _, acl := range req.GetAcls() {
+			if err := tx.Delete(aclKey(acl)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error with local store, %w", err)
+	}
+	f.aclMutex.Lock()
+	defer f.aclMutex.Unlock()
+	for _, acl := range req.GetAcls() {
+		delete(acls, string(aclKey(acl)))
+	}
+	f.Logger.Info().Msgf("Deleted %d acls", len(req.GetAcls()))
+	return &pb.DeleteAclsResponse{}, nil
+}
+
+// GetAcls returns the acls of the principal sorted by principal and resource, every acl when it is empty
+func (f *NodeState) GetAcls(principal string) ([]*pb.Acl, error) {
+	acls, err := f.loadAcls()
+	if err != nil {
+		return nil, err
+	}
+	f.aclMutex.RLock()
+	res := make([]*pb.Acl, 0, len(acls))
+	for _, acl := range acls {
+		if principal == "" || acl.Principal == principal {
+			res = append(res, acl)
+		}
+	}
+	f.aclMutex.RUnlock()
+	sort.Slice(res, func(i, j int) bool {
+		return string(aclKey(res[i])) < string(aclKey(res[j]))
+	})
+	return res, nil
+}
+
+// loadAcls reads the acls of the store once, then they are kept in memory by the writes
+func (f *NodeState) loadAcls() (map[string]*pb.Acl, error) {
+	f.aclMutex.Lock()
+	defer f.aclMutex.Unlock()
+	if f.acls != nil {
+		return f.acls, nil
+	}
+	acls := map[string]*pb.Acl{}
+	err := f.MetaStore.View(func(tx *badger.Txn) error {
+		it := tx.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(aclPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			err := it.Item().Value(func(v []byte) error {
+				acl := &pb.Acl{}
+				if err := util.DeserializeMessage(v, acl); err != nil {
+					return err
+				}
+				acls[string(aclKey(acl))] = acl
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error with local store, %w", err)
+	}
+	f.acls = acls
+	return acls, nil
+}
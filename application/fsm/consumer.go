@@ -3,10 +3,12 @@ package fsm
 import (
 	"fmt"
 	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/config"
 	"github.com/Kapperchino/jet-stream/util"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/google/uuid"
 	_ "github.com/rs/zerolog/log"
+	"strings"
 )
 
 // CreateConsumerGroup write operation, done in fsm
@@ -20,6 +22,7 @@ func (f *NodeState) CreateConsumerGroup(req *pb.CreateConsumerGroup) (interface{
 		group := &pb.ConsumerGroup{
 			Id:        req.Id,
 			Consumers: make(map[string]*pb.Consumer),
+			Topic:     topic.Name,
 		}
 		for num, _ := range topic.Partitions {
 			consumer := &pb.Consumer{
@@ -71,7 +74,7 @@ func (f *NodeState) Consume(req *pb.ConsumeRequest) (*pb.ConsumeResponse, error)
 	}
 	err = f.MessageStore.View(func(tx *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
-		opts.PrefetchSize = 100
+		opts.PrefetchSize = config.Current().ConsumeChunk
 		it := tx.NewIterator(opts)
 
 		defer it.Close()
@@ -136,6 +139,10 @@ func (f *NodeState) GetConsumerGroups(topic string) (*pb.GetConsumerGroupsRespon
 				if err != nil {
 					return err
 				}
+				//the groups created before they kept their topic have it in their key
+				if group.Topic == "" {
+					group.Topic = strings.TrimSuffix(strings.TrimPrefix(string(item.Key()), string(prefix)), "-"+group.GetId())
+				}
 				groups[group.GetId()] = &group
 				return nil
 			})
@@ -167,6 +174,10 @@ func (f *NodeState) getAllConsumerGroups() (map[string]*pb.ConsumerGroup, error)
 				if err != nil {
 					return err
 				}
+				//the groups created before they kept their topic have it in their key
+				if group.Topic == "" {
+					group.Topic = strings.TrimSuffix(strings.TrimPrefix(string(item.Key()), string(prefix)), "-"+group.GetId())
+				}
 				groups[group.GetId()] = &group
 				return nil
 			})
@@ -7,7 +7,7 @@ import (
 	"github.com/dgraph-io/badger/v3"
 	"github.com/hashicorp/raft"
 	"github.com/rs/zerolog"
-	"io"
+	"sync"
 )
 
 type NodeState struct {
@@ -15,7 +15,14 @@ type NodeState struct {
 	MessageStore *badger.DB
 	HandlerMap   []func(f *NodeState, op *pb.WriteOperation, l *raft.Log) interface{}
 	ShardState   *cluster.ShardState
+	ClusterState *cluster.ClusterState
 	Logger       *zerolog.Logger
+	// acls caches the acls of the MetaStore, nil until they are first read
+	acls     map[string]*pb.Acl
+	aclMutex sync.RWMutex
+	// quotas caches the quotas of the MetaStore the same way
+	quotas     map[string]*pb.Quota
+	quotaMutex sync.RWMutex
 }
 
 var _ raft.FSM = &NodeState{}
@@ -29,31 +36,3 @@ func (f *NodeState) Apply(l *raft.Log) interface{} {
 	}
 	return f.HandlerMap[operation.Code](f, operation, l)
 }
-
-func (f *NodeState) Snapshot() (raft.FSMSnapshot, error) {
-	//// Make sure that any future calls to f.Apply() don't change the snapshot.
-	//return &snapshot{cloneWords(f.words)}, nil
-	return nil, nil
-}
-
-func (f *NodeState) Restore(r io.ReadCloser) error {
-	//b, err := io.ReadAll(r)
-	//if err != nil {
-	//	return err
-	//}
-	//words := strings.Split(string(b), "\n")
-	//copy(f.words[:], words)
-	return nil
-}
-
-func (f *NodeState) Persist(sink raft.SnapshotSink) error {
-	//_, err := sink.Write([]byte(strings.Join(s.words, "\n")))
-	//if err != nil {
-	//	sink.Cancel()
-	//	return fmt.Errorf("sink.Write(): %v", err)
-	//}
-	return sink.Close()
-}
-
-func (f *NodeState) Release() {
-}
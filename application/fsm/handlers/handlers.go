@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"context"
 	"github.com/Kapperchino/jet-stream/application/fsm"
 	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
 	"github.com/hashicorp/raft"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func InitHandlers() []func(f *fsm.NodeState, op *pb.WriteOperation, l *raft.Log) interface{} {
@@ -14,6 +19,13 @@ func InitHandlers() []func(f *fsm.NodeState, op *pb.WriteOperation, l *raft.Log)
 	handlerMap[pb.Operation_ADD_MEMBER] = HandleAddMember
 	handlerMap[pb.Operation_REMOVE_MEMBER] = HandleRemoveMember
 	handlerMap[pb.Operation_CREATE_CONSUMER_GROUP] = HandleCreateConsumerGroup
+	handlerMap[pb.Operation_IMPORT_PARTITION] = HandleImportPartition
+	handlerMap[pb.Operation_FENCE_PARTITION] = HandleFencePartition
+	handlerMap[pb.Operation_DELETE_PARTITION] = HandleDeletePartition
+	handlerMap[pb.Operation_PUT_ACLS] = HandlePutAcls
+	handlerMap[pb.Operation_DELETE_ACLS] = HandleDeleteAcls
+	handlerMap[pb.Operation_PUT_QUOTAS] = HandlePutQuotas
+	handlerMap[pb.Operation_DELETE_QUOTAS] = HandleDeleteQuotas
 	return handlerMap
 }
 
@@ -44,9 +56,19 @@ func HandleAddMember(f *fsm.NodeState, op *pb.WriteOperation, l *raft.Log) inter
 	return res
 }
 
+// HandlePublish continues the trace of the publish on every member applying it
 func HandlePublish(f *fsm.NodeState, op *pb.WriteOperation, l *raft.Log) interface{} {
-	res, err := f.Publish(op.GetPublish(), l.Index)
+	publish := op.GetPublish()
+	ctx, span := util.Tracer().Start(util.ExtractTraceContext(context.Background(), publish.GetTraceContext()), "fsm.Publish",
+		trace.WithAttributes(
+			attribute.String("jet.topic", publish.GetTopic()),
+			attribute.Int64("jet.partition", int64(publish.GetPartition())),
+			attribute.Int64("jet.raft_index", int64(l.Index)),
+		))
+	defer span.End()
+	res, err := f.Publish(ctx, publish, l.Index)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		f.Logger.Error().Err(err)
 		return err
 	}
@@ -70,3 +92,66 @@ func HandleCreateConsumerGroup(f *fsm.NodeState, op *pb.WriteOperation, l *raft.
 	}
 	return res
 }
+
+func HandleImportPartition(f *fsm.NodeState, op *pb.WriteOperation, l *raft.Log) interface{} {
+	res, err := f.ImportPartition(op.GetImportPartition())
+	if err != nil {
+		f.Logger.Error().Err(err)
+		return err
+	}
+	return res
+}
+
+func HandleFencePartition(f *fsm.NodeState, op *pb.WriteOperation, l *raft.Log) interface{} {
+	res, err := f.FencePartition(op.GetFencePartition())
+	if err != nil {
+		f.Logger.Error().Err(err)
+		return err
+	}
+	return res
+}
+
+func HandleDeletePartition(f *fsm.NodeState, op *pb.WriteOperation, l *raft.Log) interface{} {
+	res, err := f.DeletePartition(op.GetDeletePartition())
+	if err != nil {
+		f.Logger.Error().Err(err)
+		return err
+	}
+	return res
+}
+
+func HandlePutAcls(f *fsm.NodeState, op *pb.WriteOperation, l *raft.Log) interface{} {
+	res, err := f.PutAcls(op.GetPutAcls())
+	if err != nil {
+		f.Logger.Error().Err(err)
+		return err
+	}
+	return res
+}
+
+func HandleDeleteAcls(f *fsm.NodeState, op *pb.WriteOperation, l *raft.Log) interface{} {
+	res, err := f.DeleteAcls(op.GetDeleteAcls())
+	if err != nil {
+		f.Logger.Error().Err(err)
+		return err
+	}
+	return res
+}
+
+func HandlePutQuotas(f *fsm.NodeState, op *pb.WriteOperation, l *raft.Log) interface{} {
+	res, err := f.PutQuotas(op.GetPutQuotas())
+	if err != nil {
+		f.Logger.Error().Err(err)
+		return err
+	}
+	return res
+}
+
+func HandleDeleteQuotas(f *fsm.NodeState, op *pb.WriteOperation, l *raft.Log) interface{} {
+	res, err := f.DeleteQuotas(op.GetDeleteQuotas())
+	if err != nil {
+		f.Logger.Error().Err(err)
+		return err
+	}
+	return res
+}
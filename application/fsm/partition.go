@@ -0,0 +1,366 @@
+package fsm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPartitionNotFound = errors.New("partition is not hosted by this shard")
+	ErrPartitionFenced   = errors.New("partition is fenced while it is being moved")
+)
+
+// ExportPartition reads up to limit messages after fromOffset, offsets of a partition have no gaps
+func (f *NodeState) ExportPartition(req *pb.ExportPartitionRequest) (*pb.ExportPartitionResponse, error) {
+	topic, err := f.getTopic(req.GetTopic())
+	if err != nil {
+		return nil, err
+	}
+	if topic.Partitions[req.GetPartition()] == nil {
+		return nil, ErrPartitionNotFound
+	}
+	res := &pb.ExportPartitionResponse{ConsumerOffsets: map[string]uint64{}}
+	err = f.MessageStore.View(func(tx *badger.Txn) error {
+		for offset := req.GetFromOffset() + 1; offset <= req.GetFromOffset()+req.GetLimit(); offset++ {
+			item, err := tx.Get(makeKey(req.GetTopic(), req.GetPartition(), offset))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				break
+			} else if err != nil {
+				return err
+			}
+			err = item.Value(func(v []byte) error {
+				var message pb.Message
+				if err := util.DeserializeMessage(v, &message); err != nil {
+					return err
+				}
+				res.Messages = append(res.Messages, &message)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		stats, err := loadStats(tx, req.GetTopic(), req.GetPartition())
+		if err != nil {
+			return err
+		}
+		res.LastOffset = stats.LastOffset
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error with local store %w", err)
+	}
+	groups, err := f.topicConsumerGroups(req.GetTopic())
+	if err != nil {
+		return nil, err
+	}
+	for id, group := range groups {
+		for _, consumer := range group.Consumers {
+			if consumer.Partition == req.GetPartition() {
+				res.ConsumerOffsets[id] = consumer.Offset
+			}
+		}
+	}
+	return res, nil
+}
+
+// loadStats reads the stats of the partition. Partitions written before the stats were kept have none, their keys are
+// scanned instead and the next write stores the result
+func loadStats(tx *badger.Txn, topic string, partition uint64) (*pb.PartitionStats, error) {
+	stats := &pb.PartitionStats{}
+	item, err := tx.Get(makeStatsKey(topic, partition))
+	if err == nil {
+		err = item.Value(func(v []byte) error {
+			return util.DeserializeMessage(v, stats)
+		})
+		return stats, err
+	} else if !errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, err
+	}
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := tx.NewIterator(opts)
+	defer it.Close()
+	prefix := makePrefix(topic, partition)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		key := it.Item().Key()
+		if len(key) != len(prefix)+8 {
+			continue
+		}
+		stats.Messages++
+		stats.Bytes += uint64(len(key)) + uint64(it.Item().ValueSize())
+		if offset := util.BytesToULong(key[len(prefix):]); offset > stats.LastOffset {
+			stats.LastOffset = offset
+		}
+	}
+	return stats, nil
+}
+
+func saveStats(tx *badger.Txn, topic string, partition uint64, stats *pb.PartitionStats) error {
+	buf, err := util.SerializeMessage(stats)
+	if err != nil {
+		return err
+	}
+	return tx.Set(makeStatsKey(topic, partition), buf)
+}
+
+// ImportPartition write operation, done in fsm. Messages keep their offsets and the sequence is moved past them so
+// publishes continue where the source left off
+func (f *NodeState) ImportPartition(req *pb.ImportPartition) (interface{}, error) {
+	topic, err := f.getTopic(req.GetTopic())
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		topic = &pb.Topic{Name: req.GetTopic(), Partitions: map[uint64]*pb.Partition{}}
+	} else if err != nil {
+		return nil, err
+	}
+	if topic.Partitions[req.GetPartition()] == nil {
+		//nobody should write to the copy before the placement is switched to this shard
+		partition := f.CreatePartition(req.GetPartition(), req.GetTopic())
+		partition.Fenced = true
+		topic.Partitions[req.GetPartition()] = partition
+		if err := f.saveTopic(topic); err != nil {
+			return nil, err
+		}
+	}
+	maxOffset := uint64(0)
+	err = f.MessageStore.Update(func(tx *badger.Txn) error {
+		stats, err := loadStats(tx, req.GetTopic(), req.GetPartition())
+		if err != nil {
+			return err
+		}
+		for _, message := range req.GetMessages() {
+			buf, err := util.SerializeMessage(message)
+			if err != nil {
+				return err
+			}
+			key := makeKey(req.GetTopic(), req.GetPartition(), message.Offset)
+			//a batch imported again, after a retry or a replay of the log, replaces the messages it already wrote
+			if item, err := tx.Get(key); err == nil {
+				stats.Messages--
+				stats.Bytes -= uint64(len(key)) + uint64(item.ValueSize())
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+			if err := tx.Set(key, buf); err != nil {
+				return err
+			}
+			stats.Messages++
+			stats.Bytes += uint64(len(key)) + uint64(len(buf))
+			if message.Offset > maxOffset {
+				maxOffset = message.Offset
+			}
+		}
+		if maxOffset > stats.LastOffset {
+			stats.LastOffset = maxOffset
+		}
+		if err := saveStats(tx, req.GetTopic(), req.GetPartition(), stats); err != nil {
+			return err
+		}
+		return advanceSequence(tx, makeSeqKey(req.GetTopic(), req.GetPartition()), maxOffset)
+	})
+	if err != nil {
+		f.Logger.Err(err).Msgf("Error importing into partition %v of topic %s", req.GetPartition(), req.GetTopic())
+		return nil, fmt.Errorf("error with local store %w", err)
+	}
+	for id, offset := range req.GetConsumerOffsets() {
+		if err := f.setConsumerOffset(req.GetTopic(), id, req.GetPartition(), offset); err != nil {
+			return nil, err
+		}
+	}
+	f.Logger.Debug().Msgf("Imported %v messages to partition %v topic %s", len(req.GetMessages()), req.GetPartition(), req.GetTopic())
+	return &pb.ImportPartitionResponse{}, nil
+}
+
+// advanceSequence sets the badger sequence so the next offset handed out is after offset, the sequence stores the
+// next value big endian
+func advanceSequence(tx *badger.Txn, key []byte, offset uint64) error {
+	item, err := tx.Get(key)
+	if err == nil {
+		var next uint64
+		err = item.Value(func(v []byte) error {
+			next = binary.BigEndian.Uint64(v)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if next >= offset {
+			return nil
+		}
+	} else if !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, offset)
+	return tx.Set(key, buf)
+}
+
+// setConsumerOffset points the consumer of the group on the partition at offset, creating the group if needed
+func (f *NodeState) setConsumerOffset(topic string, id string, partition uint64, offset uint64) error {
+	group, err := f.getConsumerGroup(id, topic)
+	if err != nil {
+		return err
+	}
+	if group.Consumers == nil {
+		group = &pb.ConsumerGroup{Id: id, Consumers: map[string]*pb.Consumer{}}
+	}
+	var consumer *pb.Consumer
+	for _, item := range group.Consumers {
+		if item.Partition == partition {
+			consumer = item
+		}
+	}
+	if consumer == nil {
+		consumer = &pb.Consumer{Id: uuid.NewString(), Partition: partition}
+		group.Consumers[consumer.Id] = consumer
+	}
+	consumer.Offset = offset
+	return f.saveConsumerGroup(topic, group)
+}
+
+// FencePartition write operation, done in fsm
+func (f *NodeState) FencePartition(req *pb.FencePartition) (interface{}, error) {
+	topic, err := f.getTopic(req.GetTopic())
+	if err != nil {
+		return nil, err
+	}
+	partition := topic.Partitions[req.GetPartition()]
+	if partition == nil {
+		return nil, ErrPartitionNotFound
+	}
+	partition.Fenced = req.GetFenced()
+	if err := f.saveTopic(topic); err != nil {
+		return nil, err
+	}
+	f.Logger.Info().Msgf("Set fenced to %v on partition %v of topic %s", req.GetFenced(), req.GetPartition(), req.GetTopic())
+	return &pb.FencePartitionResponse{}, nil
+}
+
+// DeletePartition write operation, done in fsm. Removes the messages, the sequence and the consumers of the partition
+func (f *NodeState) DeletePartition(req *pb.DeletePartition) (interface{}, error) {
+	topic, err := f.getTopic(req.GetTopic())
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return &pb.DeletePartitionResponse{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if topic.Partitions[req.GetPartition()] == nil {
+		//already deleted
+		return &pb.DeletePartitionResponse{}, nil
+	}
+	err = f.MessageStore.DropPrefix(makePrefix(req.GetTopic(), req.GetPartition()), makeSeqKey(req.GetTopic(), req.GetPartition()),
+		makeStatsKey(req.GetTopic(), req.GetPartition()))
+	if err != nil {
+		return nil, fmt.Errorf("error with local store %w", err)
+	}
+	groups, err := f.topicConsumerGroups(req.GetTopic())
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		for id, consumer := range group.Consumers {
+			if consumer.Partition == req.GetPartition() {
+				delete(group.Consumers, id)
+			}
+		}
+		if err := f.saveConsumerGroup(req.GetTopic(), group); err != nil {
+			return nil, err
+		}
+	}
+	delete(topic.Partitions, req.GetPartition())
+	if err := f.saveTopic(topic); err != nil {
+		return nil, err
+	}
+	f.Logger.Info().Msgf("Deleted partition %v of topic %s", req.GetPartition(), req.GetTopic())
+	return &pb.DeletePartitionResponse{}, nil
+}
+
+// topicConsumerGroups returns the groups of exactly this topic, unlike GetConsumerGroups it skips topics that only
+// share the prefix
+func (f *NodeState) topicConsumerGroups(topic string) (map[string]*pb.ConsumerGroup, error) {
+	groups := map[string]*pb.ConsumerGroup{}
+	prefix := []byte("ConsumerGroup-" + topic + "-")
+	err := f.MetaStore.View(func(tx *badger.Txn) error {
+		it := tx.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			err := item.Value(func(v []byte) error {
+				var group pb.ConsumerGroup
+				if err := util.DeserializeMessage(v, &group); err != nil {
+					return err
+				}
+				if string(item.Key()) == string(prefix)+group.Id {
+					groups[group.Id] = &group
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error with local store, %s", err)
+	}
+	return groups, nil
+}
+
+func (f *NodeState) saveTopic(topic *pb.Topic) error {
+	buf, err := util.SerializeMessage(topic)
+	if err != nil {
+		return fmt.Errorf("error encoding Topic, %w", err)
+	}
+	return f.MetaStore.Update(func(tx *badger.Txn) error {
+		return tx.Set([]byte("Topic-"+topic.Name), buf)
+	})
+}
+
+func (f *NodeState) saveConsumerGroup(topic string, group *pb.ConsumerGroup) error {
+	buf, err := util.SerializeMessage(group)
+	if err != nil {
+		return fmt.Errorf("error encoding consumer group, %w", err)
+	}
+	return f.MetaStore.Update(func(tx *badger.Txn) error {
+		return tx.Set([]byte("ConsumerGroup-"+topic+"-"+group.Id), buf)
+	})
+}
+
+// PartitionLoad is the size of a partition as stored on this node
+type PartitionLoad struct {
+	Topic      string
+	Partition  uint64
+	Bytes      uint64
+	LastOffset uint64
+}
+
+// PartitionLoads reads the stats of every hosted partition
+func (f *NodeState) PartitionLoads() ([]PartitionLoad, error) {
+	topics, err := f.getTopics()
+	if err != nil {
+		return nil, err
+	}
+	var res []PartitionLoad
+	err = f.MessageStore.View(func(tx *badger.Txn) error {
+		for name, topic := range topics {
+			for num := range topic.Partitions {
+				stats, err := loadStats(tx, name, num)
+				if err != nil {
+					return err
+				}
+				res = append(res, PartitionLoad{Topic: name, Partition: num, Bytes: stats.Bytes, LastOffset: stats.LastOffset})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error with local store %w", err)
+	}
+	return res, nil
+}
@@ -1,22 +1,36 @@
 package fsm
 
 import (
+	"context"
 	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
 	"github.com/Kapperchino/jet-stream/util"
 	"github.com/dgraph-io/badger/v3"
+	"go.opentelemetry.io/otel/codes"
 )
 
-func (f *NodeState) Publish(req *pb.Publish, raftIndex uint64) (interface{}, error) {
+// Publish stores the messages with their headers, the badger commit is traced as a child of ctx
+func (f *NodeState) Publish(ctx context.Context, req *pb.Publish, raftIndex uint64) (interface{}, error) {
 	curTopic, err := f.getTopic(req.GetTopic())
 	if err != nil {
 		return nil, err
 	}
+	partition := curTopic.Partitions[req.GetPartition()]
+	if partition == nil {
+		return nil, ErrPartitionNotFound
+	} else if partition.Fenced {
+		return nil, ErrPartitionFenced
+	}
 	var res []*pb.Message
 	newOffset := uint64(0)
 	key := makeSeqKey(req.Topic, req.GetPartition())
 	seq, err := f.MessageStore.GetSequence(key, 1000)
 	defer seq.Release()
+	_, commit := util.Tracer().Start(ctx, "badger.Commit")
 	err = f.MessageStore.Update(func(tx *badger.Txn) error {
+		stats, err := loadStats(tx, req.GetTopic(), req.GetPartition())
+		if err != nil {
+			return err
+		}
 		for _, m := range req.GetMessages() {
 			offset, _ := seq.Next()
 			offset++
@@ -28,6 +42,7 @@ func (f *NodeState) Publish(req *pb.Publish, raftIndex uint64) (interface{}, err
 				Partition: req.GetPartition(),
 				Offset:    offset,
 				RaftIndex: raftIndex,
+				Headers:   m.GetHeaders(),
 			}
 			byteProto, err := util.SerializeMessage(newMsg)
 			if err != nil {
@@ -41,16 +56,24 @@ func (f *NodeState) Publish(req *pb.Publish, raftIndex uint64) (interface{}, err
 				return err
 			}
 			res = append(res, newMsg)
+			stats.Messages++
+			stats.Bytes += uint64(len(msgKey)) + uint64(len(byteProto))
+		}
+		if newOffset > stats.LastOffset {
+			stats.LastOffset = newOffset
 		}
-		return nil
+		return saveStats(tx, req.GetTopic(), req.GetPartition(), stats)
 	})
+	if err != nil {
+		commit.SetStatus(codes.Error, err.Error())
+	}
+	commit.End()
 	if err != nil {
 		f.Logger.Error().Msg("Error Writing to topic")
 		f.Logger.Err(err)
 		return nil, err
 	}
 	f.Logger.Debug().Msgf("Publish %v messages to partition %v topic %s", len(req.Messages), req.Partition, req.Topic)
-	partition := curTopic.Partitions[req.Partition]
 	partition.Offset = newOffset
 	return res, nil
 }
@@ -0,0 +1,138 @@
+package fsm
+
+import (
+	"fmt"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/dgraph-io/badger/v3"
+	"sort"
+)
+
+const quotaPrefix = "Quota-"
+
+// QuotaKey identifies the quota of an entity, putting a quota replaces the previous one of the entity
+func QuotaKey(entity pb.QuotaEntity, name string) string {
+	return fmt.Sprintf("%d\x00%s", entity, name)
+}
+
+// PutQuotas write operation, done in fsm
+func (f *NodeState) PutQuotas(req *pb.PutQuotas) (interface{}, error) {
+	quotas, err := f.loadQuotas()
+	if err != nil {
+		return nil, err
+	}
+	err = f.MetaStore.Update(func(tx *badger.Txn) error {
+		for _, quota := range req.GetQuotas() {
+			buf, err := util.SerializeMessage(quota)
+			if err != nil {
+				return err
+			}
+			if err := tx.Set([]byte(quotaPrefix+QuotaKey(quota.Entity, quota.Name)), buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error with local store, %w", err)
+	}
+	f.quotaMutex.Lock()
+	defer f.quotaMutex.Unlock()
+	for _, quota := range req.GetQuotas() {
+		quotas[QuotaKey(quota.Entity, quota.Name)] = quota
+	}
+	f.Logger.Info().Msgf("Set %d quotas", len(req.GetQuotas()))
+	return &pb.PutQuotasResponse{}, nil
+}
+
+// DeleteQuotas write operation, done in fsm
+func (f *NodeState) DeleteQuotas(req *pb.DeleteQuotas) (interface{}, error) {
+	quotas, err := f.loadQuotas()
+	if err != nil {
+		return nil, err
+	}
+	err = f.MetaStore.Update(func(tx *badger.Txn) error {
+		for _, quota := range req.GetQuotas() {
+			if err := tx.Delete([]byte(quotaPrefix + QuotaKey(quota.Entity, quota.Name))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error with local store, %w", err)
+	}
+	f.quotaMutex.Lock()
+	defer f.quotaMutex.Unlock()
+	for _, quota := range req.GetQuotas() {
+		delete(quotas, QuotaKey(quota.Entity, quota.Name))
+	}
+	f.Logger.Info().Msgf("Deleted %d quotas", len(req.GetQuotas()))
+	return &pb.DeleteQuotasResponse{}, nil
+}
+
+// GetQuota returns the quota of the entity, or the default one of its kind, nil when it isn't limited
+func (f *NodeState) GetQuota(entity pb.QuotaEntity, name string) (*pb.Quota, error) {
+	quotas, err := f.loadQuotas()
+	if err != nil {
+		return nil, err
+	}
+	f.quotaMutex.RLock()
+	defer f.quotaMutex.RUnlock()
+	if quota, ok := quotas[QuotaKey(entity, name)]; ok {
+		return quota, nil
+	}
+	return quotas[QuotaKey(entity, "*")], nil
+}
+
+// GetQuotas returns every quota sorted by entity and name
+func (f *NodeState) GetQuotas() ([]*pb.Quota, error) {
+	quotas, err := f.loadQuotas()
+	if err != nil {
+		return nil, err
+	}
+	f.quotaMutex.RLock()
+	res := make([]*pb.Quota, 0, len(quotas))
+	for _, quota := range quotas {
+		res = append(res, quota)
+	}
+	f.quotaMutex.RUnlock()
+	sort.Slice(res, func(i, j int) bool {
+		return QuotaKey(res[i].Entity, res[i].Name) < QuotaKey(res[j].Entity, res[j].Name)
+	})
+	return res, nil
+}
+
+// loadQuotas reads the quotas of the store once, then they are kept in memory by the writes
+func (f *NodeState) loadQuotas() (map[string]*pb.Quota, error) {
+	f.quotaMutex.Lock()
+	defer f.quotaMutex.Unlock()
+	if f.quotas != nil {
+		return f.quotas, nil
+	}
+	quotas := map[string]*pb.Quota{}
+	err := f.MetaStore.View(func(tx *badger.Txn) error {
+		it := tx.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(quotaPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			err := it.Item().Value(func(v []byte) error {
+				quota := &pb.Quota{}
+				if err := util.DeserializeMessage(v, quota); err != nil {
+					return err
+				}
+				quotas[QuotaKey(quota.Entity, quota.Name)] = quota
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error with local store, %w", err)
+	}
+	f.quotas = quotas
+	return quotas, nil
+}
@@ -7,9 +7,10 @@ import (
 
 func (f *NodeState) AddMember(req *pb.AddMember) (interface{}, error) {
 	f.ShardState.ShardInfo.MemberMap.Set(req.NodeId, &cluster.MemberInfo{
-		NodeId:   req.NodeId,
-		IsLeader: false,
-		Address:  req.Address,
+		NodeId:      req.NodeId,
+		IsLeader:    false,
+		Address:     req.Address,
+		ReadReplica: req.ReadReplica,
 	})
 	return nil, nil
 }
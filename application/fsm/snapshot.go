@@ -0,0 +1,124 @@
+package fsm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/hashicorp/raft"
+	"io"
+)
+
+// snapshot holds a read transaction on each store, opened between two applies so the stores are persisted as they
+// were at the index of the snapshot while the next entries are applied
+type snapshot struct {
+	txns []*badger.Txn
+}
+
+// Snapshot copies the Meta and Messages stores, a node installing it replaces its stores with them
+func (f *NodeState) Snapshot() (raft.FSMSnapshot, error) {
+	return &snapshot{txns: []*badger.Txn{f.MetaStore.NewTransaction(false), f.MessageStore.NewTransaction(false)}}, nil
+}
+
+// Persist writes the keys of each store as length prefixed keys and values, an empty key ends a store
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	w := bufio.NewWriter(sink)
+	for _, txn := range s.txns {
+		if err := writeStore(w, txn); err != nil {
+			sink.Cancel()
+			return fmt.Errorf("sink.Write(): %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		sink.Cancel()
+		return fmt.Errorf("sink.Write(): %v", err)
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {
+	for _, txn := range s.txns {
+		txn.Discard()
+	}
+}
+
+func writeStore(w io.Writer, txn *badger.Txn) error {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		if err := writeRecord(w, item.Key()); err != nil {
+			return err
+		}
+		err := item.Value(func(val []byte) error {
+			return writeRecord(w, val)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return writeRecord(w, nil)
+}
+
+func writeRecord(w io.Writer, data []byte) error {
+	var size [binary.MaxVarintLen64]byte
+	if _, err := w.Write(size[:binary.PutUvarint(size[:], uint64(len(data)))]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// Restore drops the stores and loads the ones of the snapshot, the cached acls and quotas are read again after
+func (f *NodeState) Restore(r io.ReadCloser) error {
+	defer r.Close()
+	reader := bufio.NewReader(r)
+	for _, store := range []*badger.DB{f.MetaStore, f.MessageStore} {
+		if err := store.DropAll(); err != nil {
+			return err
+		}
+		if err := readStore(reader, store); err != nil {
+			return fmt.Errorf("reading the snapshot: %w", err)
+		}
+	}
+	f.aclMutex.Lock()
+	f.acls = nil
+	f.aclMutex.Unlock()
+	f.quotaMutex.Lock()
+	f.quotas = nil
+	f.quotaMutex.Unlock()
+	return nil
+}
+
+func readStore(r *bufio.Reader, store *badger.DB) error {
+	batch := store.NewWriteBatch()
+	defer batch.Cancel()
+	for {
+		key, err := readRecord(r)
+		if err != nil {
+			return err
+		}
+		if len(key) == 0 {
+			return batch.Flush()
+		}
+		val, err := readRecord(r)
+		if err != nil {
+			return err
+		}
+		if err := batch.Set(key, val); err != nil {
+			return err
+		}
+	}
+}
+
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -36,6 +36,9 @@ func (f *NodeState) CreateTopic(req *pb.CreateTopic) (interface{}, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error saving Topic")
 	}
+	if f.ClusterState != nil {
+		f.ClusterState.PublishTopicCreated(newTopic.Name)
+	}
 	return new(pb.CreateTopicResponse), nil
 }
 
@@ -61,7 +64,7 @@ func (f *NodeState) getTopic(topicName string) (*pb.Topic, error) {
 	if &curTopic == nil {
 		return nil, fmt.Errorf("topic does not exist, %w", err)
 	} else if err != nil {
-		return nil, fmt.Errorf("error with local store, %s", err)
+		return nil, fmt.Errorf("error with local store, %w", err)
 	}
 	return &curTopic, nil
 }
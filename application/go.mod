@@ -4,32 +4,39 @@ go 1.20
 
 require (
 	github.com/Kapperchino/jet-stream/application/proto v0.0.0-20230228034331-c4dbf6d65a5e
+	github.com/Kapperchino/jet-stream/auth v0.0.0-20230225202306-4020fc0a51bf
 	github.com/Kapperchino/jet-stream/cluster v0.0.0-20230228034331-c4dbf6d65a5e
+	github.com/Kapperchino/jet-stream/controller v0.0.0-20230225202306-4020fc0a51bf
+	github.com/Kapperchino/jet-stream/controller/proto v0.0.0-20230225202306-4020fc0a51bf
 	github.com/Kapperchino/jet-stream/factory v0.0.0-20230225202306-4020fc0a51bf
+	github.com/Kapperchino/jet-stream/leader-rpc v0.0.0-20230225202306-4020fc0a51bf
 	github.com/Kapperchino/jet-stream/util v0.0.0-20230225202306-4020fc0a51bf
+	github.com/armon/go-metrics v0.0.0-20190430140413-ec5e00d3c878
 	github.com/dgraph-io/badger/v3 v3.2103.5
 	github.com/google/uuid v1.3.0
 	github.com/grpc-ecosystem/go-grpc-middleware v1.3.0
 	github.com/hashicorp/raft v1.3.11
 	github.com/planetscale/vtprotobuf v0.4.0
 	github.com/rs/zerolog v1.29.0
-	github.com/stretchr/testify v1.8.1
+	github.com/stretchr/testify v1.8.2
+	go.opentelemetry.io/otel v1.14.0
+	go.opentelemetry.io/otel/trace v1.14.0
 	google.golang.org/grpc v1.53.0
 )
 
 require (
 	github.com/Kapperchino/jet-stream/cluster/proto v0.0.0-20230228034331-c4dbf6d65a5e // indirect
 	github.com/Kapperchino/jet-stream/config v0.0.0-20230225202306-4020fc0a51bf // indirect
-	github.com/Kapperchino/jet-stream/leader-rpc v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/raftadmin v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/transport v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/alphadose/haxmap v1.2.0 // indirect
-	github.com/armon/go-metrics v0.0.0-20190430140413-ec5e00d3c878 // indirect
 	github.com/cespare/xxhash v1.1.0 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dgraph-io/ristretto v0.1.1 // indirect
 	github.com/dustin/go-humanize v1.0.0 // indirect
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/golang/glog v1.0.0 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
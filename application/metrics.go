@@ -0,0 +1,52 @@
+package application
+
+import (
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/armon/go-metrics"
+	"strconv"
+	"time"
+)
+
+// recordPublish counts the messages and bytes written to the partition and the latency of the raft write, the
+// publishes forwarded to the leader are recorded by it
+func (r RpcInterface) recordPublish(req *pb.PublishMessageRequest, start time.Time, err error) {
+	labels := []metrics.Label{
+		{Name: "shard", Value: r.shardId()},
+		{Name: "topic", Value: req.GetTopic()},
+		{Name: "partition", Value: strconv.FormatUint(req.GetPartition(), 10)},
+	}
+	metrics.MeasureSinceWithLabels([]string{"jet", "publish", "latency"}, start, labels)
+	if err != nil {
+		metrics.IncrCounterWithLabels([]string{"jet", "publish", "errors"}, 1, labels)
+		return
+	}
+	size := 0
+	for _, message := range req.GetMessages() {
+		size += len(message.Key) + len(message.Val)
+	}
+	metrics.IncrCounterWithLabels([]string{"jet", "publish", "requests"}, 1, labels)
+	metrics.IncrCounterWithLabels([]string{"jet", "publish", "messages"}, float32(len(req.GetMessages())), labels)
+	metrics.IncrCounterWithLabels([]string{"jet", "publish", "bytes"}, float32(size), labels)
+}
+
+// recordConsume counts the messages and bytes read from each partition, a consume reads every partition of the topic
+// so its latency is only labeled with the topic
+func (r RpcInterface) recordConsume(req *pb.ConsumeRequest, res *pb.ConsumeResponse, start time.Time, err error) {
+	shard := metrics.Label{Name: "shard", Value: r.shardId()}
+	topic := metrics.Label{Name: "topic", Value: req.GetTopic()}
+	metrics.MeasureSinceWithLabels([]string{"jet", "consume", "latency"}, start, []metrics.Label{shard, topic})
+	if err != nil {
+		metrics.IncrCounterWithLabels([]string{"jet", "consume", "errors"}, 1, []metrics.Label{shard, topic})
+		return
+	}
+	metrics.IncrCounterWithLabels([]string{"jet", "consume", "requests"}, 1, []metrics.Label{shard, topic})
+	for partition, messages := range res.GetMessages() {
+		size := 0
+		for _, message := range messages.GetMessages() {
+			size += len(message.Key) + len(message.Payload)
+		}
+		labels := []metrics.Label{shard, topic, {Name: "partition", Value: strconv.FormatUint(partition, 10)}}
+		metrics.IncrCounterWithLabels([]string{"jet", "consume", "messages"}, float32(len(messages.GetMessages())), labels)
+		metrics.IncrCounterWithLabels([]string{"jet", "consume", "bytes"}, float32(size), labels)
+	}
+}
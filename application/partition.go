@@ -0,0 +1,146 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"github.com/Kapperchino/jet-stream/application/fsm"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/config"
+	"github.com/Kapperchino/jet-stream/util"
+)
+
+// ExportPartition is served by the leader so a fenced partition can't have writes the export misses
+func (r RpcInterface) ExportPartition(ctx context.Context, req *pb.ExportPartitionRequest) (*pb.ExportPartitionResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.ExportPartition); forwarded {
+		return res, err
+	}
+	res, err := r.NodeState.ExportPartition(req)
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	return res, nil
+}
+
+func ImportPartitionInternal(r RpcInterface, req *pb.ImportPartitionRequest) (*pb.ImportPartitionResponse, error) {
+	res, index, err := apply[*pb.ImportPartitionResponse](r, &pb.WriteOperation{
+		Operation: &pb.WriteOperation_ImportPartition{
+			ImportPartition: &pb.ImportPartition{
+				Topic:           req.GetTopic(),
+				Partition:       req.GetPartition(),
+				Messages:        req.GetMessages(),
+				ConsumerOffsets: req.GetConsumerOffsets(),
+			},
+		},
+		Code: pb.Operation_IMPORT_PARTITION,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.LastIndex = index
+	return res, nil
+}
+
+func (r RpcInterface) ImportPartition(ctx context.Context, req *pb.ImportPartitionRequest) (*pb.ImportPartitionResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.ImportPartition); forwarded {
+		return res, err
+	}
+	res, err := ImportPartitionInternal(r, req)
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	return res, nil
+}
+
+func FencePartitionInternal(r RpcInterface, req *pb.FencePartitionRequest) (*pb.FencePartitionResponse, error) {
+	res, index, err := apply[*pb.FencePartitionResponse](r, &pb.WriteOperation{
+		Operation: &pb.WriteOperation_FencePartition{
+			FencePartition: &pb.FencePartition{
+				Topic:     req.GetTopic(),
+				Partition: req.GetPartition(),
+				Fenced:    req.GetFenced(),
+			},
+		},
+		Code: pb.Operation_FENCE_PARTITION,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.LastIndex = index
+	return res, nil
+}
+
+func (r RpcInterface) FencePartition(ctx context.Context, req *pb.FencePartitionRequest) (*pb.FencePartitionResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.FencePartition); forwarded {
+		return res, err
+	}
+	res, err := FencePartitionInternal(r, req)
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	return res, nil
+}
+
+func DeletePartitionInternal(r RpcInterface, req *pb.DeletePartitionRequest) (*pb.DeletePartitionResponse, error) {
+	res, index, err := apply[*pb.DeletePartitionResponse](r, &pb.WriteOperation{
+		Operation: &pb.WriteOperation_DeletePartition{
+			DeletePartition: &pb.DeletePartition{
+				Topic:     req.GetTopic(),
+				Partition: req.GetPartition(),
+			},
+		},
+		Code: pb.Operation_DELETE_PARTITION,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.LastIndex = index
+	return res, nil
+}
+
+func (r RpcInterface) DeletePartition(ctx context.Context, req *pb.DeletePartitionRequest) (*pb.DeletePartitionResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_TOPIC, req.GetTopic(), pb.AclOperation_DELETE); err != nil {
+		return nil, err
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.DeletePartition); forwarded {
+		return res, err
+	}
+	res, err := DeletePartitionInternal(r, req)
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	return res, nil
+}
+
+// apply commits the operation through raft and returns the fsm response with the index of the entry
+func apply[Res any](r RpcInterface, input *pb.WriteOperation) (Res, uint64, error) {
+	var empty Res
+	val, _ := util.SerializeMessage(input)
+	res := r.Raft.Apply(val, config.Current().ApplyTimeout)
+	if err := res.Error(); err != nil {
+		return empty, 0, err
+	}
+	err, isErr := res.Response().(error)
+	if isErr {
+		return empty, 0, err
+	}
+	response, isValid := res.Response().(Res)
+	if !isValid {
+		return empty, 0, errors.New("unknown data type")
+	}
+	return response, res.Index(), nil
+}
+
+// isPartitionMoving is true for writes to a partition that is fenced or was moved to another shard, the client
+// should refresh the placement and retry
+func isPartitionMoving(err error) bool {
+	return errors.Is(err, fsm.ErrPartitionFenced) || errors.Is(err, fsm.ErrPartitionNotFound)
+}
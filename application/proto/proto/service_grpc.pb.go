@@ -27,6 +27,16 @@ const (
 	MessageService_CreateTopic_FullMethodName         = "/message.MessageService/CreateTopic"
 	MessageService_AckConsume_FullMethodName          = "/message.MessageService/AckConsume"
 	MessageService_GetMeta_FullMethodName             = "/message.MessageService/GetMeta"
+	MessageService_ExportPartition_FullMethodName     = "/message.MessageService/ExportPartition"
+	MessageService_ImportPartition_FullMethodName     = "/message.MessageService/ImportPartition"
+	MessageService_FencePartition_FullMethodName      = "/message.MessageService/FencePartition"
+	MessageService_DeletePartition_FullMethodName     = "/message.MessageService/DeletePartition"
+	MessageService_PutAcls_FullMethodName             = "/message.MessageService/PutAcls"
+	MessageService_DeleteAcls_FullMethodName          = "/message.MessageService/DeleteAcls"
+	MessageService_ListAcls_FullMethodName            = "/message.MessageService/ListAcls"
+	MessageService_PutQuotas_FullMethodName           = "/message.MessageService/PutQuotas"
+	MessageService_DeleteQuotas_FullMethodName        = "/message.MessageService/DeleteQuotas"
+	MessageService_ListQuotas_FullMethodName          = "/message.MessageService/ListQuotas"
 )
 
 // MessageServiceClient is the client API for MessageService service.
@@ -41,6 +51,19 @@ type MessageServiceClient interface {
 	CreateTopic(ctx context.Context, in *CreateTopicRequest, opts ...grpc.CallOption) (*CreateTopicResponse, error)
 	AckConsume(ctx context.Context, in *AckConsumeRequest, opts ...grpc.CallOption) (*AckConsumeResponse, error)
 	GetMeta(ctx context.Context, in *GetMetaRequest, opts ...grpc.CallOption) (*GetMetaResponse, error)
+	// used by the controller to move a partition between shards
+	ExportPartition(ctx context.Context, in *ExportPartitionRequest, opts ...grpc.CallOption) (*ExportPartitionResponse, error)
+	ImportPartition(ctx context.Context, in *ImportPartitionRequest, opts ...grpc.CallOption) (*ImportPartitionResponse, error)
+	FencePartition(ctx context.Context, in *FencePartitionRequest, opts ...grpc.CallOption) (*FencePartitionResponse, error)
+	DeletePartition(ctx context.Context, in *DeletePartitionRequest, opts ...grpc.CallOption) (*DeletePartitionResponse, error)
+	// access control lists of the shard, need the admin operation on the cluster
+	PutAcls(ctx context.Context, in *PutAclsRequest, opts ...grpc.CallOption) (*PutAclsResponse, error)
+	DeleteAcls(ctx context.Context, in *DeleteAclsRequest, opts ...grpc.CallOption) (*DeleteAclsResponse, error)
+	ListAcls(ctx context.Context, in *ListAclsRequest, opts ...grpc.CallOption) (*ListAclsResponse, error)
+	// publish quotas of the shard, need the admin operation on the cluster
+	PutQuotas(ctx context.Context, in *PutQuotasRequest, opts ...grpc.CallOption) (*PutQuotasResponse, error)
+	DeleteQuotas(ctx context.Context, in *DeleteQuotasRequest, opts ...grpc.CallOption) (*DeleteQuotasResponse, error)
+	ListQuotas(ctx context.Context, in *ListQuotasRequest, opts ...grpc.CallOption) (*ListQuotasResponse, error)
 }
 
 type messageServiceClient struct {
@@ -123,6 +146,96 @@ func (c *messageServiceClient) GetMeta(ctx context.Context, in *GetMetaRequest,
 	return out, nil
 }
 
+func (c *messageServiceClient) ExportPartition(ctx context.Context, in *ExportPartitionRequest, opts ...grpc.CallOption) (*ExportPartitionResponse, error) {
+	out := new(ExportPartitionResponse)
+	err := c.cc.Invoke(ctx, MessageService_ExportPartition_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messageServiceClient) ImportPartition(ctx context.Context, in *ImportPartitionRequest, opts ...grpc.CallOption) (*ImportPartitionResponse, error) {
+	out := new(ImportPartitionResponse)
+	err := c.cc.Invoke(ctx, MessageService_ImportPartition_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messageServiceClient) FencePartition(ctx context.Context, in *FencePartitionRequest, opts ...grpc.CallOption) (*FencePartitionResponse, error) {
+	out := new(FencePartitionResponse)
+	err := c.cc.Invoke(ctx, MessageService_FencePartition_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messageServiceClient) DeletePartition(ctx context.Context, in *DeletePartitionRequest, opts ...grpc.CallOption) (*DeletePartitionResponse, error) {
+	out := new(DeletePartitionResponse)
+	err := c.cc.Invoke(ctx, MessageService_DeletePartition_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messageServiceClient) PutAcls(ctx context.Context, in *PutAclsRequest, opts ...grpc.CallOption) (*PutAclsResponse, error) {
+	out := new(PutAclsResponse)
+	err := c.cc.Invoke(ctx, MessageService_PutAcls_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messageServiceClient) DeleteAcls(ctx context.Context, in *DeleteAclsRequest, opts ...grpc.CallOption) (*DeleteAclsResponse, error) {
+	out := new(DeleteAclsResponse)
+	err := c.cc.Invoke(ctx, MessageService_DeleteAcls_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messageServiceClient) ListAcls(ctx context.Context, in *ListAclsRequest, opts ...grpc.CallOption) (*ListAclsResponse, error) {
+	out := new(ListAclsResponse)
+	err := c.cc.Invoke(ctx, MessageService_ListAcls_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messageServiceClient) PutQuotas(ctx context.Context, in *PutQuotasRequest, opts ...grpc.CallOption) (*PutQuotasResponse, error) {
+	out := new(PutQuotasResponse)
+	err := c.cc.Invoke(ctx, MessageService_PutQuotas_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messageServiceClient) DeleteQuotas(ctx context.Context, in *DeleteQuotasRequest, opts ...grpc.CallOption) (*DeleteQuotasResponse, error) {
+	out := new(DeleteQuotasResponse)
+	err := c.cc.Invoke(ctx, MessageService_DeleteQuotas_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messageServiceClient) ListQuotas(ctx context.Context, in *ListQuotasRequest, opts ...grpc.CallOption) (*ListQuotasResponse, error) {
+	out := new(ListQuotasResponse)
+	err := c.cc.Invoke(ctx, MessageService_ListQuotas_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MessageServiceServer is the server API for MessageService service.
 // All implementations must embed UnimplementedMessageServiceServer
 // for forward compatibility
@@ -135,6 +248,19 @@ type MessageServiceServer interface {
 	CreateTopic(context.Context, *CreateTopicRequest) (*CreateTopicResponse, error)
 	AckConsume(context.Context, *AckConsumeRequest) (*AckConsumeResponse, error)
 	GetMeta(context.Context, *GetMetaRequest) (*GetMetaResponse, error)
+	// used by the controller to move a partition between shards
+	ExportPartition(context.Context, *ExportPartitionRequest) (*ExportPartitionResponse, error)
+	ImportPartition(context.Context, *ImportPartitionRequest) (*ImportPartitionResponse, error)
+	FencePartition(context.Context, *FencePartitionRequest) (*FencePartitionResponse, error)
+	DeletePartition(context.Context, *DeletePartitionRequest) (*DeletePartitionResponse, error)
+	// access control lists of the shard, need the admin operation on the cluster
+	PutAcls(context.Context, *PutAclsRequest) (*PutAclsResponse, error)
+	DeleteAcls(context.Context, *DeleteAclsRequest) (*DeleteAclsResponse, error)
+	ListAcls(context.Context, *ListAclsRequest) (*ListAclsResponse, error)
+	// publish quotas of the shard, need the admin operation on the cluster
+	PutQuotas(context.Context, *PutQuotasRequest) (*PutQuotasResponse, error)
+	DeleteQuotas(context.Context, *DeleteQuotasRequest) (*DeleteQuotasResponse, error)
+	ListQuotas(context.Context, *ListQuotasRequest) (*ListQuotasResponse, error)
 	mustEmbedUnimplementedMessageServiceServer()
 }
 
@@ -166,6 +292,36 @@ func (UnimplementedMessageServiceServer) AckConsume(context.Context, *AckConsume
 func (UnimplementedMessageServiceServer) GetMeta(context.Context, *GetMetaRequest) (*GetMetaResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetMeta not implemented")
 }
+func (UnimplementedMessageServiceServer) ExportPartition(context.Context, *ExportPartitionRequest) (*ExportPartitionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportPartition not implemented")
+}
+func (UnimplementedMessageServiceServer) ImportPartition(context.Context, *ImportPartitionRequest) (*ImportPartitionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportPartition not implemented")
+}
+func (UnimplementedMessageServiceServer) FencePartition(context.Context, *FencePartitionRequest) (*FencePartitionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FencePartition not implemented")
+}
+func (UnimplementedMessageServiceServer) DeletePartition(context.Context, *DeletePartitionRequest) (*DeletePartitionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePartition not implemented")
+}
+func (UnimplementedMessageServiceServer) PutAcls(context.Context, *PutAclsRequest) (*PutAclsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutAcls not implemented")
+}
+func (UnimplementedMessageServiceServer) DeleteAcls(context.Context, *DeleteAclsRequest) (*DeleteAclsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAcls not implemented")
+}
+func (UnimplementedMessageServiceServer) ListAcls(context.Context, *ListAclsRequest) (*ListAclsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAcls not implemented")
+}
+func (UnimplementedMessageServiceServer) PutQuotas(context.Context, *PutQuotasRequest) (*PutQuotasResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutQuotas not implemented")
+}
+func (UnimplementedMessageServiceServer) DeleteQuotas(context.Context, *DeleteQuotasRequest) (*DeleteQuotasResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteQuotas not implemented")
+}
+func (UnimplementedMessageServiceServer) ListQuotas(context.Context, *ListQuotasRequest) (*ListQuotasResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListQuotas not implemented")
+}
 func (UnimplementedMessageServiceServer) mustEmbedUnimplementedMessageServiceServer() {}
 
 // UnsafeMessageServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -323,6 +479,186 @@ func _MessageService_GetMeta_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MessageService_ExportPartition_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportPartitionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessageServiceServer).ExportPartition(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessageService_ExportPartition_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessageServiceServer).ExportPartition(ctx, req.(*ExportPartitionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessageService_ImportPartition_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportPartitionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessageServiceServer).ImportPartition(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessageService_ImportPartition_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessageServiceServer).ImportPartition(ctx, req.(*ImportPartitionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessageService_FencePartition_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FencePartitionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessageServiceServer).FencePartition(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessageService_FencePartition_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessageServiceServer).FencePartition(ctx, req.(*FencePartitionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessageService_DeletePartition_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePartitionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessageServiceServer).DeletePartition(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessageService_DeletePartition_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessageServiceServer).DeletePartition(ctx, req.(*DeletePartitionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessageService_PutAcls_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutAclsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessageServiceServer).PutAcls(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessageService_PutAcls_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessageServiceServer).PutAcls(ctx, req.(*PutAclsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessageService_DeleteAcls_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAclsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessageServiceServer).DeleteAcls(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessageService_DeleteAcls_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessageServiceServer).DeleteAcls(ctx, req.(*DeleteAclsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessageService_ListAcls_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAclsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessageServiceServer).ListAcls(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessageService_ListAcls_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessageServiceServer).ListAcls(ctx, req.(*ListAclsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessageService_PutQuotas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutQuotasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessageServiceServer).PutQuotas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessageService_PutQuotas_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessageServiceServer).PutQuotas(ctx, req.(*PutQuotasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessageService_DeleteQuotas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteQuotasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessageServiceServer).DeleteQuotas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessageService_DeleteQuotas_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessageServiceServer).DeleteQuotas(ctx, req.(*DeleteQuotasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessageService_ListQuotas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListQuotasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessageServiceServer).ListQuotas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessageService_ListQuotas_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessageServiceServer).ListQuotas(ctx, req.(*ListQuotasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MessageService_ServiceDesc is the grpc.ServiceDesc for MessageService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -362,6 +698,46 @@ var MessageService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetMeta",
 			Handler:    _MessageService_GetMeta_Handler,
 		},
+		{
+			MethodName: "ExportPartition",
+			Handler:    _MessageService_ExportPartition_Handler,
+		},
+		{
+			MethodName: "ImportPartition",
+			Handler:    _MessageService_ImportPartition_Handler,
+		},
+		{
+			MethodName: "FencePartition",
+			Handler:    _MessageService_FencePartition_Handler,
+		},
+		{
+			MethodName: "DeletePartition",
+			Handler:    _MessageService_DeletePartition_Handler,
+		},
+		{
+			MethodName: "PutAcls",
+			Handler:    _MessageService_PutAcls_Handler,
+		},
+		{
+			MethodName: "DeleteAcls",
+			Handler:    _MessageService_DeleteAcls_Handler,
+		},
+		{
+			MethodName: "ListAcls",
+			Handler:    _MessageService_ListAcls_Handler,
+		},
+		{
+			MethodName: "PutQuotas",
+			Handler:    _MessageService_PutQuotas_Handler,
+		},
+		{
+			MethodName: "DeleteQuotas",
+			Handler:    _MessageService_DeleteQuotas_Handler,
+		},
+		{
+			MethodName: "ListQuotas",
+			Handler:    _MessageService_ListQuotas_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "service.proto",
@@ -0,0 +1,282 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"github.com/Kapperchino/jet-stream/application/fsm"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/auth"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/armon/go-metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// quotaBurst is how much of its quota a caller can use at once after being idle
+	quotaBurst = time.Second
+	// bucketIdleTimeout drops the buckets of the callers that stopped publishing, they are full again by then
+	bucketIdleTimeout = time.Minute
+)
+
+// QuotaLimiter keeps the token buckets of the quotas of a shard, the default quota of an entity gives each entity its
+// own bucket. Only the leader charges them, the writes are forwarded to it before
+type QuotaLimiter struct {
+	buckets   map[string]*bucket
+	lastPrune time.Time
+	// nodes are the identities of the nodes, the principal a write was forwarded for is only taken from them
+	nodes map[string]bool
+	mutex sync.Mutex
+}
+
+func NewQuotaLimiter(nodes ...string) *QuotaLimiter {
+	q := &QuotaLimiter{
+		buckets:   map[string]*bucket{},
+		lastPrune: time.Now(),
+		nodes:     map[string]bool{},
+	}
+	for _, name := range nodes {
+		q.nodes[name] = true
+	}
+	return q
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds what the rate earned since the last publish, at most a burst of it
+func (b *bucket) refill(rate float64, now time.Time) {
+	burst := rate * quotaBurst.Seconds()
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+rate*now.Sub(b.last).Seconds())
+	}
+	b.last = now
+}
+
+// debt is how long the bucket needs to be out of debt
+func (b *bucket) debt(rate float64) time.Duration {
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// quotaUse is what a publish costs against one rate of a quota
+type quotaUse struct {
+	entity pb.QuotaEntity
+	name   string
+	unit   string
+	rate   float64
+	cost   float64
+}
+
+func (u quotaUse) key() string {
+	return fsm.QuotaKey(u.entity, u.name) + "\x00" + u.unit
+}
+
+// take charges the uses to their buckets. A caller still in debt isn't charged and is rejected with the delay until it
+// may send again, otherwise the delay is the hint for the next publish. The use with the longest delay is returned
+func (q *QuotaLimiter) take(uses []quotaUse) (time.Duration, *quotaUse, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	now := time.Now()
+	q.prune(now)
+	var delay time.Duration
+	var longest *quotaUse
+	buckets := make([]*bucket, len(uses))
+	for i := range uses {
+		b := q.buckets[uses[i].key()]
+		if b == nil {
+			b = &bucket{}
+			q.buckets[uses[i].key()] = b
+		}
+		b.refill(uses[i].rate, now)
+		buckets[i] = b
+		if d := b.debt(uses[i].rate); d > delay {
+			delay, longest = d, &uses[i]
+		}
+	}
+	if delay > 0 {
+		return delay, longest, true
+	}
+	for i, b := range buckets {
+		b.tokens -= uses[i].cost
+		if d := b.debt(uses[i].rate); d > delay {
+			delay, longest = d, &uses[i]
+		}
+	}
+	return delay, longest, false
+}
+
+func (q *QuotaLimiter) prune(now time.Time) {
+	if now.Sub(q.lastPrune) < bucketIdleTimeout {
+		return
+	}
+	q.lastPrune = now
+	for key, b := range q.buckets {
+		if now.Sub(b.last) > bucketIdleTimeout {
+			delete(q.buckets, key)
+		}
+	}
+}
+
+// ValidateQuota checks that the quota can be stored
+func ValidateQuota(quota *pb.Quota) error {
+	if _, ok := pb.QuotaEntity_name[int32(quota.Entity)]; !ok {
+		return errors.New("unknown quota entity")
+	}
+	if quota.Name == "" {
+		return errors.New("the quota has no name")
+	}
+	if quota.BytesPerSecond < 0 || quota.RequestsPerSecond < 0 {
+		return errors.New("the rates of a quota can't be negative")
+	}
+	return nil
+}
+
+// chargeQuotas charges the publish to the quotas of its principal, client id and topic, and returns the delay hint. It
+// is called by the leader, the follower that forwarded a write sends the principal and the client id of its caller
+func (r RpcInterface) chargeQuotas(ctx context.Context, req *pb.PublishMessageRequest) (time.Duration, error) {
+	if r.Quotas == nil {
+		return 0, nil
+	}
+	size := 0
+	for _, message := range req.GetMessages() {
+		size += len(message.Key) + len(message.Val)
+	}
+	var uses []quotaUse
+	charge := func(entity pb.QuotaEntity, name string) error {
+		if name == "" {
+			return nil
+		}
+		quota, err := r.NodeState.GetQuota(entity, name)
+		if err != nil || quota == nil {
+			return err
+		}
+		if quota.BytesPerSecond > 0 {
+			uses = append(uses, quotaUse{entity: entity, name: name, unit: "bytes", rate: quota.BytesPerSecond, cost: float64(size)})
+		}
+		if quota.RequestsPerSecond > 0 {
+			uses = append(uses, quotaUse{entity: entity, name: name, unit: "requests", rate: quota.RequestsPerSecond, cost: 1})
+		}
+		return nil
+	}
+	if err := charge(pb.QuotaEntity_PRINCIPAL_QUOTA, r.Quotas.principal(ctx)); err != nil {
+		return 0, err
+	}
+	if err := charge(pb.QuotaEntity_CLIENT_QUOTA, util.ClientIdFromContext(ctx)); err != nil {
+		return 0, err
+	}
+	if err := charge(pb.QuotaEntity_TOPIC_QUOTA, req.GetTopic()); err != nil {
+		return 0, err
+	}
+	if len(uses) == 0 {
+		return 0, nil
+	}
+	delay, longest, rejected := r.Quotas.take(uses)
+	shard := metrics.Label{Name: "shard", Value: r.shardId()}
+	if !rejected {
+		for _, use := range uses {
+			metrics.IncrCounterWithLabels([]string{"jet", "quota", "usage"}, float32(use.cost), []metrics.Label{shard,
+				{Name: "entity", Value: quotaEntityName(use.entity)}, {Name: "name", Value: use.name}, {Name: "unit", Value: use.unit}})
+		}
+	}
+	if delay == 0 {
+		return 0, nil
+	}
+	labels := []metrics.Label{shard, {Name: "entity", Value: quotaEntityName(longest.entity)}, {Name: "name", Value: longest.name}}
+	metrics.AddSampleWithLabels([]string{"jet", "quota", "throttle_ms"}, float32(delay.Milliseconds()), labels)
+	if rejected {
+		metrics.IncrCounterWithLabels([]string{"jet", "quota", "rejected"}, 1, labels)
+		return 0, throttledError(delay, longest)
+	}
+	metrics.IncrCounterWithLabels([]string{"jet", "quota", "throttled"}, 1, labels)
+	return delay, nil
+}
+
+// principal is the identity a publish is charged to. A write forwarded by a node is charged to the principal it was
+// forwarded for, the header is ignored when the caller isn't a node since any client can set it
+func (q *QuotaLimiter) principal(ctx context.Context) string {
+	identity := auth.FromContext(ctx)
+	if identity == nil {
+		return ""
+	}
+	if q.nodes[identity.Name] && isForwarded(ctx) {
+		return forwardedFor(ctx)
+	}
+	return identity.Name
+}
+
+func quotaEntityName(entity pb.QuotaEntity) string {
+	return strings.ToLower(strings.TrimSuffix(entity.String(), "_QUOTA"))
+}
+
+// throttledError is a resource exhausted status carrying the delay the caller has to wait
+func throttledError(delay time.Duration, use *quotaUse) error {
+	st, err := status.Newf(codes.ResourceExhausted, "the %s quota of %s %s is exhausted, retry in %v", use.unit,
+		quotaEntityName(use.entity), use.name, delay).WithDetails(&pb.Throttled{DelayMs: uint32(delay.Milliseconds()) + 1})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return st.Err()
+}
+
+func (r RpcInterface) PutQuotas(ctx context.Context, req *pb.PutQuotasRequest) (*pb.PutQuotasResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	for _, quota := range req.GetQuotas() {
+		if err := ValidateQuota(quota); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.PutQuotas); forwarded {
+		return res, err
+	}
+	res, index, err := apply[*pb.PutQuotasResponse](r, &pb.WriteOperation{
+		Operation: &pb.WriteOperation_PutQuotas{PutQuotas: &pb.PutQuotas{Quotas: req.GetQuotas()}},
+		Code:      pb.Operation_PUT_QUOTAS,
+	})
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	res.LastIndex = index
+	return res, nil
+}
+
+func (r RpcInterface) DeleteQuotas(ctx context.Context, req *pb.DeleteQuotasRequest) (*pb.DeleteQuotasResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	if res, forwarded, err := forwardToLeader(ctx, r, req, pb.MessageServiceClient.DeleteQuotas); forwarded {
+		return res, err
+	}
+	res, index, err := apply[*pb.DeleteQuotasResponse](r, &pb.WriteOperation{
+		Operation: &pb.WriteOperation_DeleteQuotas{DeleteQuotas: &pb.DeleteQuotas{Quotas: req.GetQuotas()}},
+		Code:      pb.Operation_DELETE_QUOTAS,
+	})
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	res.LastIndex = index
+	return res, nil
+}
+
+func (r RpcInterface) ListQuotas(ctx context.Context, _ *pb.ListQuotasRequest) (*pb.ListQuotasResponse, error) {
+	if err := r.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	quotas, err := r.NodeState.GetQuotas()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ListQuotasResponse{Quotas: quotas}, nil
+}
@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"os"
+	"strings"
+)
+
+const (
+	MethodToken = "token"
+	MethodJWT   = "jwt"
+	MethodMTLS  = "mtls"
+
+	// authorizationKey is the metadata key of the bearer token
+	authorizationKey = "authorization"
+	bearerPrefix     = "Bearer "
+)
+
+// DefaultServices are the grpc services that need an identity, the health checks and the reflection are left open
+var DefaultServices = []string{
+	"message.MessageService",
+	"cluster.ClusterMetaService",
+	"cluster.NodeService",
+	"controller.ControllerService",
+	"admin.RaftAdmin",
+}
+
+// NodeServices are only called by the nodes, the caller needs one of the node identities. The raft transport shares the
+// port of the clients, any caller could rewrite the logs of the shards through it
+var NodeServices = []string{
+	"transport.RaftTransport",
+}
+
+// Identity is who made a call
+type Identity struct {
+	// Name is the name of the static token, the subject of the jwt or the common name of the client certificate
+	Name   string
+	Method string
+}
+
+func (i *Identity) String() string {
+	return fmt.Sprintf("%s:%s", i.Method, i.Name)
+}
+
+type identityKey struct{}
+
+// FromContext returns the identity of the call, nil when the service isn't protected
+func FromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// Config picks how the calls are authenticated, a call is accepted by the first method that knows its credentials
+type Config struct {
+	// Tokens maps static bearer tokens to the name of their identity
+	Tokens map[string]string
+	// JWKSFile holds the keys the jwt bearer tokens are signed with, it is read again when it changes
+	JWKSFile string
+	// Issuer and Audience are checked on the jwt when set
+	Issuer   string
+	Audience string
+	// MTLS accepts the verified client certificate of a tls connection as the identity
+	MTLS bool
+	// Services are the protected grpc services, DefaultServices when empty
+	Services []string
+	// Nodes are the identities allowed on the NodeServices, nobody calls them when empty
+	Nodes []string
+}
+
+// Authenticator checks the credentials of the calls to the protected services
+type Authenticator struct {
+	config   *Config
+	jwks     *jwksFile
+	services map[string]bool
+	nodes    map[string]bool
+}
+
+func New(config *Config) (*Authenticator, error) {
+	if len(config.Tokens) == 0 && config.JWKSFile == "" && !config.MTLS {
+		return nil, errors.New("authentication needs static tokens, a jwks file or mutual tls")
+	}
+	a := &Authenticator{
+		config:   config,
+		services: map[string]bool{},
+		nodes:    map[string]bool{},
+	}
+	services := config.Services
+	if len(services) == 0 {
+		services = DefaultServices
+	}
+	for _, service := range services {
+		a.services[service] = true
+	}
+	for _, name := range config.Nodes {
+		a.nodes[name] = true
+	}
+	if config.JWKSFile != "" {
+		a.jwks = &jwksFile{file: config.JWKSFile}
+		if _, err := a.jwks.load(); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// ServerOptions install the interceptors on a grpc server
+func (a *Authenticator) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(a.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(a.StreamInterceptor),
+	}
+}
+
+func (a *Authenticator) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.check(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *Authenticator) StreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.check(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &identityStream{ServerStream: stream, ctx: ctx})
+}
+
+// check adds the identity of the caller to the context when the method is protected
+func (a *Authenticator) check(ctx context.Context, fullMethod string) (context.Context, error) {
+	service := serviceName(fullMethod)
+	nodeService := isNodeService(service)
+	if !a.services[service] && !nodeService {
+		return ctx, nil
+	}
+	identity, err := a.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if nodeService && !a.nodes[identity.Name] {
+		return nil, status.Errorf(codes.PermissionDenied, "%s is only called by the nodes", service)
+	}
+	return context.WithValue(ctx, identityKey{}, identity), nil
+}
+
+func isNodeService(service string) bool {
+	for _, item := range NodeServices {
+		if item == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate finds the identity of the call from its bearer token or its client certificate
+func (a *Authenticator) Authenticate(ctx context.Context) (*Identity, error) {
+	if token := bearerToken(ctx); token != "" {
+		for key, name := range a.config.Tokens {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+				return &Identity{Name: name, Method: MethodToken}, nil
+			}
+		}
+		if a.jwks == nil {
+			return nil, status.Error(codes.Unauthenticated, "unknown token")
+		}
+		subject, err := a.verifyJWT(token)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+		}
+		return &Identity{Name: subject, Method: MethodJWT}, nil
+	}
+	if a.config.MTLS {
+		if name := certificateName(ctx); name != "" {
+			return &Identity{Name: name, Method: MethodMTLS}, nil
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "the call has no credentials")
+}
+
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, value := range md.Get(authorizationKey) {
+		if strings.HasPrefix(value, bearerPrefix) {
+			return strings.TrimPrefix(value, bearerPrefix)
+		}
+	}
+	return ""
+}
+
+// certificateName is the common name of the verified client certificate, or its first dns name
+func certificateName(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	cert := info.State.VerifiedChains[0][0]
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return ""
+}
+
+func serviceName(fullMethod string) string {
+	service, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service
+}
+
+// identityStream carries the context with the identity to the stream handler
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+// LoadTokens reads static tokens from a file with one name:token per line
+func LoadTokens(file string) (map[string]string, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	tokens := map[string]string{}
+	for i, line := range strings.Split(string(buf), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, token, ok := strings.Cut(line, ":")
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("line %d of the token file %s is not name:token", i+1, file)
+		}
+		tokens[token] = name
+	}
+	return tokens, nil
+}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// TokenCredentials send a static token or a jwt as the bearer token of every call
+type TokenCredentials struct {
+	Token string
+	// AllowInsecure sends the token over plaintext connections, only for clusters without tls
+	AllowInsecure bool
+}
+
+var _ credentials.PerRPCCredentials = TokenCredentials{}
+
+func (t TokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{authorizationKey: bearerPrefix + t.Token}, nil
+}
+
+func (t TokenCredentials) RequireTransportSecurity() bool {
+	return !t.AllowInsecure
+}
+
+// DialOption sends the token with the calls of the connection
+func (t TokenCredentials) DialOption() grpc.DialOption {
+	return grpc.WithPerRPCCredentials(t)
+}
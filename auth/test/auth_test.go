@@ -0,0 +1,254 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/Kapperchino/jet-stream/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const publishMethod = "/message.MessageService/PublishMessages"
+
+type AuthTest struct {
+	suite.Suite
+	jwks          string
+	rsaKey        *rsa.PrivateKey
+	ecKey         *ecdsa.PrivateKey
+	authenticator *auth.Authenticator
+}
+
+func (suite *AuthTest) SetupTest() {
+	var err error
+	suite.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(suite.T(), err)
+	suite.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(suite.T(), err)
+	suite.jwks = filepath.Join(suite.T().TempDir(), "jwks.json")
+	suite.writeJWKS(map[string]interface{}{"rsa": &suite.rsaKey.PublicKey, "ec": &suite.ecKey.PublicKey})
+	suite.authenticator, err = auth.New(&auth.Config{
+		Tokens:   map[string]string{"secret-token": "publisher"},
+		JWKSFile: suite.jwks,
+		Issuer:   "jet-issuer",
+		Audience: "jet",
+		MTLS:     true,
+	})
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *AuthTest) writeJWKS(keys map[string]interface{}) {
+	var set []map[string]string
+	for kid, key := range keys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			set = append(set, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			set = append(set, map[string]string{
+				"kty": "EC",
+				"kid": kid,
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+				"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+	}
+	buf, err := json.Marshal(map[string]interface{}{"keys": set})
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), os.WriteFile(suite.jwks, buf, 0600))
+}
+
+func (suite *AuthTest) sign(method jwt.SigningMethod, kid string, key interface{}, claims jwt.RegisteredClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	assert.Nil(suite.T(), err)
+	return signed
+}
+
+func validClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "consumer",
+		Issuer:    "jet-issuer",
+		Audience:  jwt.ClaimStrings{"jet"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+// call runs the unary interceptor and returns the identity the handler saw
+func (suite *AuthTest) call(ctx context.Context, method string) (*auth.Identity, error) {
+	var identity *auth.Identity
+	_, err := suite.authenticator.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			identity = auth.FromContext(ctx)
+			return nil, nil
+		})
+	return identity, err
+}
+
+func (suite *AuthTest) assertUnauthenticated(ctx context.Context) {
+	_, err := suite.call(ctx, publishMethod)
+	assert.Equal(suite.T(), codes.Unauthenticated, status.Code(err))
+}
+
+func (suite *AuthTest) TestStaticToken() {
+	identity, err := suite.call(withToken("secret-token"), publishMethod)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), &auth.Identity{Name: "publisher", Method: auth.MethodToken}, identity)
+	suite.assertUnauthenticated(withToken("wrong-token"))
+	suite.assertUnauthenticated(context.Background())
+}
+
+func (suite *AuthTest) TestJWT() {
+	for _, token := range []string{
+		suite.sign(jwt.SigningMethodRS256, "rsa", suite.rsaKey, validClaims()),
+		suite.sign(jwt.SigningMethodES256, "ec", suite.ecKey, validClaims()),
+	} {
+		identity, err := suite.call(withToken(token), publishMethod)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), &auth.Identity{Name: "consumer", Method: auth.MethodJWT}, identity)
+	}
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	suite.assertUnauthenticated(withToken(suite.sign(jwt.SigningMethodRS256, "rsa", suite.rsaKey, expired)))
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+	suite.assertUnauthenticated(withToken(suite.sign(jwt.SigningMethodRS256, "rsa", suite.rsaKey, noExpiry)))
+	otherIssuer := validClaims()
+	otherIssuer.Issuer = "someone-else"
+	suite.assertUnauthenticated(withToken(suite.sign(jwt.SigningMethodRS256, "rsa", suite.rsaKey, otherIssuer)))
+	otherAudience := validClaims()
+	otherAudience.Audience = jwt.ClaimStrings{"other"}
+	suite.assertUnauthenticated(withToken(suite.sign(jwt.SigningMethodRS256, "rsa", suite.rsaKey, otherAudience)))
+	//signed by a key of the set but with the id of another one
+	suite.assertUnauthenticated(withToken(suite.sign(jwt.SigningMethodES256, "rsa", suite.ecKey, validClaims())))
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.Nil(suite.T(), err)
+	suite.assertUnauthenticated(withToken(unsigned))
+}
+
+func (suite *AuthTest) TestJWKSReload() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(suite.T(), err)
+	token := suite.sign(jwt.SigningMethodRS256, "rotated", key, validClaims())
+	suite.assertUnauthenticated(withToken(token))
+
+	suite.writeJWKS(map[string]interface{}{"rotated": &key.PublicKey})
+	later := time.Now().Add(time.Minute)
+	assert.Nil(suite.T(), os.Chtimes(suite.jwks, later, later))
+	_, err = suite.call(withToken(token), publishMethod)
+	assert.Nil(suite.T(), err)
+	//the keys that were removed from the file are not accepted anymore
+	suite.assertUnauthenticated(withToken(suite.sign(jwt.SigningMethodRS256, "rsa", suite.rsaKey, validClaims())))
+}
+
+func (suite *AuthTest) TestMTLS() {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "nodeA"}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+	}})
+	identity, err := suite.call(ctx, publishMethod)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), &auth.Identity{Name: "nodeA", Method: auth.MethodMTLS}, identity)
+
+	//a certificate that wasn't verified is not an identity
+	ctx = peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+	}})
+	suite.assertUnauthenticated(ctx)
+}
+
+func (suite *AuthTest) TestOpenServices() {
+	identity, err := suite.call(context.Background(), "/grpc.health.v1.Health/Check")
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), identity)
+	_, err = suite.call(context.Background(), "/admin.RaftAdmin/Shutdown")
+	assert.Equal(suite.T(), codes.Unauthenticated, status.Code(err))
+}
+
+// the raft transport needs a node identity, an authenticated client isn't enough
+func (suite *AuthTest) TestNodeServices() {
+	const method = "/transport.RaftTransport/AppendEntries"
+	_, err := suite.call(context.Background(), method)
+	assert.Equal(suite.T(), codes.Unauthenticated, status.Code(err))
+	_, err = suite.call(withToken("secret-token"), method)
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+
+	authenticator, err := auth.New(&auth.Config{
+		Tokens: map[string]string{"secret-token": "publisher", "node-token": "node"},
+		Nodes:  []string{"node"},
+	})
+	assert.Nil(suite.T(), err)
+	suite.authenticator = authenticator
+	identity, err := suite.call(withToken("node-token"), method)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "node", identity.Name)
+	_, err = suite.call(withToken("secret-token"), method)
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+}
+
+func (suite *AuthTest) TestStream() {
+	var identity *auth.Identity
+	err := suite.authenticator.StreamInterceptor(nil, &fakeStream{ctx: withToken("secret-token")},
+		&grpc.StreamServerInfo{FullMethod: "/cluster.ClusterMetaService/WatchCluster"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			identity = auth.FromContext(stream.Context())
+			return nil
+		})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "publisher", identity.Name)
+}
+
+func (suite *AuthTest) TestLoadTokens() {
+	file := filepath.Join(suite.T().TempDir(), "tokens")
+	assert.Nil(suite.T(), os.WriteFile(file, []byte("# publishers\npublisher:abc\n\nconsumer:def:ghi\n"), 0600))
+	tokens, err := auth.LoadTokens(file)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), map[string]string{"abc": "publisher", "def:ghi": "consumer"}, tokens)
+
+	assert.Nil(suite.T(), os.WriteFile(file, []byte("no-token\n"), 0600))
+	_, err = auth.LoadTokens(file)
+	assert.NotNil(suite.T(), err)
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (f *fakeStream) Context() context.Context {
+	return f.ctx
+}
+
+func TestAuth(t *testing.T) {
+	suite.Run(t, new(AuthTest))
+}
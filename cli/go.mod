@@ -5,6 +5,8 @@ go 1.20
 require (
 	github.com/Kapperchino/jet-stream/application/proto v0.0.0-20230228034331-c4dbf6d65a5e
 	github.com/Kapperchino/jet-stream/client v0.0.0-20230414212817-ff61deea9198
+	github.com/Kapperchino/jet-stream/cluster/proto v0.0.0-20230228034331-c4dbf6d65a5e
+	github.com/Kapperchino/jet-stream/controller/proto v0.0.0-20230225202306-4020fc0a51bf
 	github.com/Kapperchino/jet-stream/factory v0.0.0-20230225202306-4020fc0a51bf
 	github.com/Kapperchino/jet-stream/util v0.0.0-20230225202306-4020fc0a51bf
 	github.com/rs/zerolog v1.29.0
@@ -15,8 +17,8 @@ require (
 require (
 	github.com/Kapperchino/jet-stream/application v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/cluster v0.0.0-20230225202306-4020fc0a51bf // indirect
-	github.com/Kapperchino/jet-stream/cluster/proto v0.0.0-20230228034331-c4dbf6d65a5e // indirect
 	github.com/Kapperchino/jet-stream/config v0.0.0-20230225202306-4020fc0a51bf // indirect
+	github.com/Kapperchino/jet-stream/controller v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/leader-rpc v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/raftadmin v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/transport v0.0.0-20230225202306-4020fc0a51bf // indirect
@@ -0,0 +1,147 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/urfave/cli/v2"
+	"strings"
+)
+
+type Acl struct {
+	client *client.JetClient
+}
+
+// acls builds one acl per operation from the flags
+func (a *Acl) acls(cCtx *cli.Context) ([]*proto.Acl, error) {
+	principal := cCtx.String("principal")
+	if principal == "" {
+		return nil, errors.New("--principal is needed")
+	}
+	var resource proto.AclResource
+	var pattern string
+	set := 0
+	if cCtx.IsSet("topic") {
+		resource, pattern = proto.AclResource_TOPIC, cCtx.String("topic")
+		set++
+	}
+	if cCtx.IsSet("group") {
+		resource, pattern = proto.AclResource_GROUP, cCtx.String("group")
+		set++
+	}
+	if cCtx.Bool("cluster") {
+		resource, pattern = proto.AclResource_CLUSTER, "*"
+		set++
+	}
+	if set != 1 {
+		return nil, errors.New("one of --topic, --group or --cluster is needed")
+	}
+	operations := cCtx.StringSlice("operation")
+	if len(operations) == 0 {
+		return nil, errors.New("--operation is needed")
+	}
+	var res []*proto.Acl
+	for _, name := range operations {
+		operation, ok := proto.AclOperation_value[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown operation %q, it is one of read, write, create, delete or admin", name)
+		}
+		res = append(res, &proto.Acl{
+			Principal: principal,
+			Resource:  resource,
+			Pattern:   pattern,
+			Operation: proto.AclOperation(operation),
+		})
+	}
+	return res, nil
+}
+
+func (a *Acl) addAction(cCtx *cli.Context) error {
+	acls, err := a.acls(cCtx)
+	if err != nil {
+		return err
+	}
+	if err := a.client.PutAcls(acls); err != nil {
+		return err
+	}
+	fmt.Printf("added %d acls to every shard\n", len(acls))
+	return nil
+}
+
+func (a *Acl) removeAction(cCtx *cli.Context) error {
+	acls, err := a.acls(cCtx)
+	if err != nil {
+		return err
+	}
+	if err := a.client.DeleteAcls(acls); err != nil {
+		return err
+	}
+	fmt.Printf("removed %d acls from every shard\n", len(acls))
+	return nil
+}
+
+func (a *Acl) listAction(cCtx *cli.Context) error {
+	acls, err := a.client.ListAcls(cCtx.String("principal"))
+	for _, acl := range acls {
+		fmt.Printf("%-20s %-8s %-30s %s\n", acl.Principal, strings.ToLower(acl.Resource.String()), acl.Pattern,
+			strings.ToLower(acl.Operation.String()))
+	}
+	return err
+}
+
+func (a *Acl) GetCommand() *cli.Command {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:  "principal",
+			Usage: "identity the acl applies to, * for anyone authenticated",
+		},
+		&cli.StringFlag{
+			Name:  "topic",
+			Usage: "topic name, prefix ending with * or * for every topic",
+		},
+		&cli.StringFlag{
+			Name:  "group",
+			Usage: "consumer group id, prefix ending with * or * for every group",
+		},
+		&cli.BoolFlag{
+			Name:  "cluster",
+			Usage: "the acl is on the cluster, admin on it manages the acls and the raft groups",
+		},
+		&cli.StringSliceFlag{
+			Name:    "operation",
+			Aliases: []string{"o"},
+			Usage:   "read, write, create, delete or admin, admin grants every operation",
+		},
+	}
+	return &cli.Command{
+		Name:  "acl",
+		Usage: "Access control lists, they are checked when the nodes start with --auth_acl",
+		Subcommands: []*cli.Command{
+			{
+				Flags:  flags,
+				Name:   "add",
+				Usage:  "allow the principal the operations on the resource",
+				Action: a.addAction,
+			},
+			{
+				Flags:  flags,
+				Name:   "remove",
+				Usage:  "remove acls added with the same flags",
+				Action: a.removeAction,
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "principal",
+						Usage: "only print the acls of the principal",
+					},
+				},
+				Name:    "list",
+				Aliases: []string{"l"},
+				Usage:   "print the acls of every shard",
+				Action:  a.listAction,
+			},
+		},
+	}
+}
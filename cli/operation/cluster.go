@@ -0,0 +1,268 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/client"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/urfave/cli/v2"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Cluster struct {
+	client *client.JetClient
+}
+
+func (c *Cluster) infoAction(*cli.Context) error {
+	info, err := c.client.GetClusterInfo()
+	if err != nil {
+		return err
+	}
+	nodes := map[string]bool{}
+	for _, shard := range info.GetShardMap() {
+		for nodeId := range shard.GetMemberAddressMap() {
+			nodes[nodeId] = true
+		}
+	}
+	controllers := "none"
+	if len(info.GetControllers()) > 0 {
+		controllers = strings.Join(info.GetControllers(), ", ")
+	}
+	fmt.Printf("shards:      %d\n", len(info.GetShardMap()))
+	fmt.Printf("nodes:       %d\n", len(nodes))
+	fmt.Printf("controllers: %s\n", controllers)
+	return nil
+}
+
+func (c *Cluster) shardsAction(*cli.Context) error {
+	info, err := c.client.GetClusterInfo()
+	if err != nil {
+		return err
+	}
+	t := newTable("SHARD", "LEADER", "TERM", "VOTERS", "READ REPLICAS")
+	for _, shardId := range sortedKeys(info.GetShardMap()) {
+		shard := info.GetShardMap()[shardId]
+		var voters, replicas []string
+		for _, nodeId := range sortedKeys(shard.GetMemberAddressMap()) {
+			if shard.GetMemberAddressMap()[nodeId].GetRole() == clusterPb.MemberRole_READ_REPLICA {
+				replicas = append(replicas, nodeId)
+			} else {
+				voters = append(voters, nodeId)
+			}
+		}
+		t.row(shardId, orNone(shard.GetLeaderId()), strconv.FormatUint(shard.GetTerm(), 10), orNone(strings.Join(voters, ",")),
+			orNone(strings.Join(replicas, ",")))
+	}
+	return t.flush()
+}
+
+func (c *Cluster) membersAction(cCtx *cli.Context) error {
+	if !cCtx.IsSet("shard") {
+		return errors.New("--shard is needed")
+	}
+	members, err := c.client.GetShardMembers(cCtx.String("shard"))
+	if err != nil {
+		return err
+	}
+	t := newTable("NODE", "ADDRESS", "ROLE", "SUFFRAGE", "STATE")
+	for _, member := range members {
+		state := "follower"
+		if member.Leader {
+			state = "leader"
+		}
+		t.row(member.NodeId, member.Address, strings.ToLower(member.Role.String()), orNone(strings.ToLower(member.Suffrage)), state)
+	}
+	return t.flush()
+}
+
+func (c *Cluster) leaderAction(cCtx *cli.Context) error {
+	if !cCtx.IsSet("shard") {
+		return errors.New("--shard is needed")
+	}
+	leader, err := c.client.GetShardLeader(cCtx.String("shard"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %s\n", leader.GetNodeId(), leader.GetAddress())
+	return nil
+}
+
+func (c *Cluster) transferLeadershipAction(cCtx *cli.Context) error {
+	if !cCtx.IsSet("shard") {
+		return errors.New("--shard is needed")
+	}
+	if err := c.client.TransferLeadership(cCtx.String("shard"), cCtx.String("node")); err != nil {
+		return err
+	}
+	leader, err := c.client.GetShardLeader(cCtx.String("shard"))
+	if err != nil {
+		fmt.Printf("transferred the leadership of shard %s\n", cCtx.String("shard"))
+		return nil
+	}
+	fmt.Printf("transferred the leadership of shard %s, %s leads it\n", cCtx.String("shard"), leader.GetNodeId())
+	return nil
+}
+
+func (c *Cluster) addVoterAction(cCtx *cli.Context) error {
+	if !cCtx.IsSet("shard") || !cCtx.IsSet("node") || !cCtx.IsSet("address") {
+		return errors.New("adding a voter needs --shard, --node and --address")
+	}
+	if err := c.client.AddVoter(cCtx.String("shard"), cCtx.String("node"), cCtx.String("address")); err != nil {
+		return err
+	}
+	fmt.Printf("added %s to shard %s as a voter\n", cCtx.String("node"), cCtx.String("shard"))
+	return nil
+}
+
+func (c *Cluster) removeVoterAction(cCtx *cli.Context) error {
+	if !cCtx.IsSet("shard") || !cCtx.IsSet("node") {
+		return errors.New("removing a voter needs --shard and --node")
+	}
+	if err := c.client.RemoveVoter(cCtx.String("shard"), cCtx.String("node"), cCtx.Bool("force")); err != nil {
+		return err
+	}
+	fmt.Printf("removed %s from shard %s\n", cCtx.String("node"), cCtx.String("shard"))
+	return nil
+}
+
+func (c *Cluster) snapshotAction(cCtx *cli.Context) error {
+	if !cCtx.IsSet("shard") {
+		return errors.New("--shard is needed")
+	}
+	if err := c.client.Snapshot(cCtx.String("shard"), cCtx.String("node")); err != nil {
+		return err
+	}
+	fmt.Printf("took a snapshot of shard %s\n", cCtx.String("shard"))
+	return nil
+}
+
+func (c *Cluster) statsAction(cCtx *cli.Context) error {
+	if !cCtx.IsSet("shard") {
+		return errors.New("--shard is needed")
+	}
+	stats, err := c.client.GetRaftStats(cCtx.String("shard"), cCtx.String("node"))
+	if err != nil {
+		return err
+	}
+	t := newTable("STAT", "VALUE")
+	for _, key := range sortedKeys(stats) {
+		t.row(key, stats[key])
+	}
+	return t.flush()
+}
+
+func sortedKeys[V any](items map[string]V) []string {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func (c *Cluster) GetCommand() *cli.Command {
+	shard := &cli.StringFlag{
+		Name:  "shard",
+		Usage: "id of the shard",
+	}
+	return &cli.Command{
+		Name:  "cluster",
+		Usage: "Shards and raft groups of the cluster",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "info",
+				Usage:  "print the number of shards and nodes and the controllers",
+				Action: c.infoAction,
+			},
+			{
+				Name:   "shards",
+				Usage:  "print the shards with their leader and members",
+				Action: c.shardsAction,
+			},
+			{
+				Flags:  []cli.Flag{shard},
+				Name:   "members",
+				Usage:  "print the members of a shard with their role and place in the raft configuration",
+				Action: c.membersAction,
+			},
+			{
+				Flags:  []cli.Flag{shard},
+				Name:   "leader",
+				Usage:  "print the leader of a shard",
+				Action: c.leaderAction,
+			},
+			{
+				Flags: []cli.Flag{shard,
+					&cli.StringFlag{
+						Name:  "node",
+						Usage: "id of the new leader, the most up-to-date voter when not given",
+					},
+				},
+				Name:   "transfer-leadership",
+				Usage:  "hand the leadership of a shard to another voter",
+				Action: c.transferLeadershipAction,
+			},
+			{
+				Flags: []cli.Flag{shard,
+					&cli.StringFlag{
+						Name:  "node",
+						Usage: "id of the node",
+					},
+					&cli.StringFlag{
+						Name:  "address",
+						Usage: "grpc address of the node",
+					},
+				},
+				Name:   "add-voter",
+				Usage:  "add a node to the raft group of a shard as a voter",
+				Action: c.addVoterAction,
+			},
+			{
+				Flags: []cli.Flag{shard,
+					&cli.StringFlag{
+						Name:  "node",
+						Usage: "id of the node",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "remove it even if the voters left reachable aren't a quorum of the shard",
+					},
+				},
+				Name:   "remove-voter",
+				Usage:  "remove a node from the raft group and the shard info of a shard",
+				Action: c.removeVoterAction,
+			},
+			{
+				Flags: []cli.Flag{shard,
+					&cli.StringFlag{
+						Name:  "node",
+						Usage: "id of the node taking the snapshot, the leader when not given",
+					},
+				},
+				Name:   "snapshot",
+				Usage:  "take a raft snapshot of a shard",
+				Action: c.snapshotAction,
+			},
+			{
+				Flags: []cli.Flag{shard,
+					&cli.StringFlag{
+						Name:  "node",
+						Usage: "id of the node, the leader when not given",
+					},
+				},
+				Name:   "stats",
+				Usage:  "print the raft stats of a shard",
+				Action: c.statsAction,
+			},
+		},
+	}
+}
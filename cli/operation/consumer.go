@@ -8,6 +8,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 	"strconv"
+	"strings"
 )
 
 type Consumer struct {
@@ -17,7 +18,11 @@ type Consumer struct {
 func (p *Consumer) consume(cCtx *cli.Context) ([]*proto.Message, error) {
 	topic := cCtx.String("Topic")
 	id := cCtx.String("id")
-	msgs, err := p.client.ConsumeMessage(topic, id)
+	consistency, ok := proto.ReadConsistency_value[strings.ToUpper(cCtx.String("consistency"))]
+	if !ok {
+		return nil, fmt.Errorf("unknown consistency %s", cCtx.String("consistency"))
+	}
+	msgs, err := p.client.ConsumeMessageWithConsistency(topic, id, proto.ReadConsistency(consistency))
 	if err != nil {
 		log.Fatal().Err(err)
 	}
@@ -34,7 +39,10 @@ func (p *Consumer) createConsumer(cCtx *cli.Context) (*proto.CreateConsumerGroup
 }
 
 func (p *Consumer) consumeAction(cCtx *cli.Context) error {
-	res, _ := p.consume(cCtx)
+	res, err := p.consume(cCtx)
+	if err != nil {
+		return err
+	}
 	longest := 0
 	for _, message := range res {
 		longest = util.Max(longest, len(message.Key))
@@ -81,6 +89,11 @@ func (p *Consumer) GetCommand() *cli.Command {
 						Usage:   "size of the batches to get",
 						Value:   20,
 					},
+					&cli.StringFlag{
+						Name:  "consistency",
+						Usage: "read consistency, one of follower, leader or linearizable",
+						Value: "follower",
+					},
 				},
 				Name:    "consume",
 				Aliases: []string{"con"},
@@ -2,6 +2,7 @@ package operation
 
 import (
 	"encoding/json"
+	"github.com/Kapperchino/jet-stream/util"
 	"github.com/urfave/cli/v2"
 	"os"
 )
@@ -21,6 +22,30 @@ func (i *InitOperator) GetCommand() *cli.Command {
 				Aliases:  []string{"a"},
 				Required: true,
 			},
+			&cli.BoolFlag{
+				Name:  "tls",
+				Usage: "connect with tls, the server is verified with the system roots unless --tls-ca is set",
+			},
+			&cli.StringFlag{
+				Name:  "tls-ca",
+				Usage: "PEM certificates verifying the nodes",
+			},
+			&cli.StringFlag{
+				Name:  "tls-cert",
+				Usage: "PEM client certificate for mutual tls",
+			},
+			&cli.StringFlag{
+				Name:  "tls-key",
+				Usage: "PEM key of the client certificate",
+			},
+			&cli.StringFlag{
+				Name:  "tls-server-name",
+				Usage: "name the node certificates are checked against instead of their host",
+			},
+			&cli.StringFlag{
+				Name:  "token",
+				Usage: "static token or jwt sent with every call",
+			},
 		},
 	}
 
@@ -34,6 +59,15 @@ func (*InitOperator) initialize(cCtx *cli.Context) error {
 	address := cCtx.String("address")
 	meta := CliMeta{
 		Address: address,
+		Token:   cCtx.String("token"),
+	}
+	if cCtx.Bool("tls") || cCtx.IsSet("tls-ca") || cCtx.IsSet("tls-cert") {
+		meta.TLS = &util.TLSConfig{
+			CertFile:   cCtx.String("tls-cert"),
+			KeyFile:    cCtx.String("tls-key"),
+			CAFile:     cCtx.String("tls-ca"),
+			ServerName: cCtx.String("tls-server-name"),
+		}
 	}
 	buf, err := json.Marshal(meta)
 	if err != nil {
@@ -43,7 +77,7 @@ func (*InitOperator) initialize(cCtx *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(dir+CliDir+CliFile, buf, 0644)
+	err = os.WriteFile(dir+CliDir+CliFile, buf, 0600)
 	if err != nil {
 		return err
 	}
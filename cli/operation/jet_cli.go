@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/util"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 	"os"
@@ -16,6 +17,10 @@ type JetCli struct {
 
 type CliMeta struct {
 	Address string
+	// TLS connects to the cluster with tls when set
+	TLS *util.TLSConfig `json:",omitempty"`
+	// Token is the static token or the jwt sent with every call
+	Token string `json:",omitempty"`
 }
 
 func NewJetCli() (*JetCli, error) {
@@ -39,9 +44,16 @@ func NewJetCli() (*JetCli, error) {
 	if err != nil {
 		return nil, err
 	}
-	jetClient, err := client.New(meta.Address)
+	var opts []client.Option
+	if meta.TLS != nil {
+		opts = append(opts, client.WithTLS(meta.TLS))
+	}
+	if meta.Token != "" {
+		opts = append(opts, client.WithToken(meta.Token))
+	}
+	jetClient, err := client.New(meta.Address, opts...)
 
-	operators := []Operation{&Publisher{client: jetClient}, &Consumer{client: jetClient}, &InitOperator{}}
+	operators := []Operation{&Publisher{client: jetClient}, &Topic{client: jetClient}, &Consumer{client: jetClient}, &Partition{client: jetClient}, &Shard{client: jetClient}, &Keyring{client: jetClient}, &Acl{client: jetClient}, &Quota{client: jetClient}, &Node{client: jetClient}, &Cluster{client: jetClient}, &InitOperator{}}
 	return &JetCli{client: jetClient, operations: operators}, nil
 }
 
@@ -0,0 +1,129 @@
+package operation
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/urfave/cli/v2"
+)
+
+// gossipKeySize picks AES-256 for the generated keys
+const gossipKeySize = 32
+
+type Keyring struct {
+	client *client.JetClient
+}
+
+func (k *Keyring) key(cCtx *cli.Context) ([]byte, error) {
+	if !cCtx.IsSet("key") {
+		return nil, errors.New("--key is needed")
+	}
+	return factory.ParseGossipKey(cCtx.String("key"))
+}
+
+func (k *Keyring) installAction(cCtx *cli.Context) error {
+	key, err := k.key(cCtx)
+	if err != nil {
+		return err
+	}
+	if err := k.client.InstallGossipKey(key); err != nil {
+		return err
+	}
+	fmt.Println("installed the key on every node")
+	return nil
+}
+
+func (k *Keyring) useAction(cCtx *cli.Context) error {
+	key, err := k.key(cCtx)
+	if err != nil {
+		return err
+	}
+	if err := k.client.UseGossipKey(key); err != nil {
+		return err
+	}
+	fmt.Println("every node encrypts with the key")
+	return nil
+}
+
+func (k *Keyring) removeAction(cCtx *cli.Context) error {
+	key, err := k.key(cCtx)
+	if err != nil {
+		return err
+	}
+	if err := k.client.RemoveGossipKey(key); err != nil {
+		return err
+	}
+	fmt.Println("removed the key from every node")
+	return nil
+}
+
+// listAction prints the keys of each node, the primary one is marked with a star
+func (k *Keyring) listAction(*cli.Context) error {
+	res, err := k.client.ListGossipKeys()
+	for _, node := range res {
+		fmt.Printf("%s %s\n", node.NodeId, node.Address)
+		for _, key := range node.Keys {
+			mark := " "
+			if bytes.Equal(key, node.Primary) {
+				mark = "*"
+			}
+			fmt.Printf("  %s %s\n", mark, base64.StdEncoding.EncodeToString(key))
+		}
+	}
+	return err
+}
+
+func (k *Keyring) generateAction(*cli.Context) error {
+	key := make([]byte, gossipKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	fmt.Println(base64.StdEncoding.EncodeToString(key))
+	return nil
+}
+
+func (k *Keyring) GetCommand() *cli.Command {
+	key := &cli.StringFlag{
+		Name:  "key",
+		Usage: "base64 gossip key of 16, 24 or 32 bytes",
+	}
+	return &cli.Command{
+		Name:  "keyring",
+		Usage: "Gossip encryption keys, a key is rotated with install, use, then remove of the old key",
+		Subcommands: []*cli.Command{
+			{
+				Flags:  []cli.Flag{key},
+				Name:   "install",
+				Usage:  "add a key to every node, they decrypt with it but still encrypt with their primary key",
+				Action: k.installAction,
+			},
+			{
+				Flags:  []cli.Flag{key},
+				Name:   "use",
+				Usage:  "make an installed key the primary key of every node",
+				Action: k.useAction,
+			},
+			{
+				Flags:  []cli.Flag{key},
+				Name:   "remove",
+				Usage:  "remove a key that isn't primary anymore from every node",
+				Action: k.removeAction,
+			},
+			{
+				Name:    "list",
+				Aliases: []string{"l"},
+				Usage:   "print the keys of every node",
+				Action:  k.listAction,
+			},
+			{
+				Name:   "generate",
+				Usage:  "print a new random key",
+				Action: k.generateAction,
+			},
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/urfave/cli/v2"
+	"strings"
+)
+
+type Node struct {
+	client *client.JetClient
+}
+
+func (n *Node) decommissionAction(cCtx *cli.Context) error {
+	if !cCtx.IsSet("node") {
+		return errors.New("--node is needed")
+	}
+	shardIds, err := n.client.DecommissionNode(cCtx.String("node"), cCtx.StringSlice("shard"), cCtx.Bool("force"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("node %s left %s, it can be stopped\n", cCtx.String("node"), strings.Join(shardIds, ", "))
+	return nil
+}
+
+func (n *Node) GetCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "node",
+		Usage: "Nodes of the cluster",
+		Subcommands: []*cli.Command{
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "node",
+						Usage: "id of the node",
+					},
+					&cli.StringSliceFlag{
+						Name:  "shard",
+						Usage: "shards the node leaves, every shard it hosts when none are given",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "leave even if the voters left reachable aren't a quorum of the shard",
+					},
+				},
+				Name:   "decommission",
+				Usage:  "hand the leaderships of the node over, remove it from its raft groups and the shard info and leave the gossip",
+				Action: n.decommissionAction,
+			},
+		},
+	}
+}
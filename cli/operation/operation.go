@@ -1,7 +1,32 @@
 package operation
 
-import "github.com/urfave/cli/v2"
+import (
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
 
 type Operation interface {
 	GetCommand() *cli.Command
 }
+
+// table prints rows as aligned columns under a header once flushed
+type table struct {
+	writer *tabwriter.Writer
+}
+
+func newTable(header ...string) *table {
+	t := &table{writer: tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)}
+	t.row(header...)
+	return t
+}
+
+func (t *table) row(cells ...string) {
+	_, _ = fmt.Fprintln(t.writer, strings.Join(cells, "\t"))
+}
+
+func (t *table) flush() error {
+	return t.writer.Flush()
+}
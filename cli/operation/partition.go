@@ -0,0 +1,200 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/client"
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/urfave/cli/v2"
+	"os"
+	"os/signal"
+	"time"
+)
+
+const progressInterval = 500 * time.Millisecond
+
+type Partition struct {
+	client *client.JetClient
+}
+
+// moveAction starts a move, or follows or cancels an existing one when an id is given
+func (p *Partition) moveAction(cCtx *cli.Context) error {
+	id := cCtx.String("id")
+	if cCtx.Bool("cancel") {
+		if id == "" {
+			return errors.New("--cancel needs the --id of the move")
+		}
+		res, err := p.client.CancelReassignment(id)
+		if err != nil {
+			return err
+		}
+		printReassignment(res)
+		return nil
+	}
+	if id == "" {
+		if !cCtx.IsSet("topic") || !cCtx.IsSet("partition") || !cCtx.IsSet("target") {
+			return errors.New("a move needs --topic, --partition and --target")
+		}
+		res, err := p.client.ReassignPartition(cCtx.String("topic"), cCtx.Uint64("partition"), cCtx.String("target"))
+		if err != nil {
+			return err
+		}
+		id = res.Id
+		fmt.Printf("started move %s\n", id)
+	}
+	if cCtx.Bool("detach") {
+		return nil
+	}
+	return p.follow(id)
+}
+
+// follow prints the progress of the move until it is done, an interrupt cancels the move
+func (p *Partition) follow(id string) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		res, err := p.client.GetReassignments(id)
+		if err != nil {
+			return err
+		}
+		if len(res) == 0 {
+			return fmt.Errorf("move %s does not exist", id)
+		}
+		printReassignment(res[0])
+		if res[0].Phase == controllerPb.ReassignmentPhase_DONE || res[0].Phase == controllerPb.ReassignmentPhase_CANCELLED {
+			return nil
+		}
+		select {
+		case <-interrupt:
+			fmt.Printf("cancelling move %s\n", id)
+			if _, err := p.client.CancelReassignment(id); err != nil {
+				return err
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Partition) listMovesAction(cCtx *cli.Context) error {
+	res, err := p.client.GetReassignments("")
+	if err != nil {
+		return err
+	}
+	for _, item := range res {
+		printReassignment(item)
+	}
+	return nil
+}
+
+func (p *Partition) balanceAction(cCtx *cli.Context) error {
+	res, err := p.client.Balance(cCtx.Bool("dry-run"))
+	if err != nil {
+		return err
+	}
+	fmt.Println("current load:")
+	printShardLoads(res.Before)
+	if len(res.Moves) == 0 {
+		fmt.Println("shards are balanced, nothing to move")
+		return nil
+	}
+	fmt.Println("planned moves:")
+	for _, move := range res.Moves {
+		state := "planned"
+		if move.ReassignmentId != "" {
+			state = "started " + move.ReassignmentId
+		}
+		fmt.Printf("  topic: %s partition: %-5d %s -> %s bytes: %-10d rate: %-8.1f %s\n", move.Topic, move.Partition,
+			move.SourceShard, move.TargetShard, move.Bytes, move.PublishRate, state)
+	}
+	fmt.Println("load after the moves:")
+	printShardLoads(res.After)
+	return nil
+}
+
+func printShardLoads(loads []*controllerPb.ShardLoad) {
+	for _, load := range loads {
+		fmt.Printf("  %-20s partitions: %-5d bytes: %-10d rate: %-8.1f score: %.2f\n", load.ShardId, load.Partitions,
+			load.Bytes, load.PublishRate, load.Score)
+	}
+}
+
+func printReassignment(item *controllerPb.Reassignment) {
+	progress := 100.0
+	if item.SourceOffset > 0 {
+		progress = float64(item.CopiedOffset) * 100 / float64(item.SourceOffset)
+	}
+	fmt.Printf("%s topic: %s partition: %d %s -> %s phase: %-9s copied: %d/%d (%.1f%%)",
+		item.Id, item.Topic, item.Partition, item.SourceShard, item.TargetShard, item.Phase, item.CopiedOffset,
+		item.SourceOffset, progress)
+	if item.CancelRequested && item.Phase != controllerPb.ReassignmentPhase_CANCELLED {
+		fmt.Printf(" cancelling")
+	}
+	if item.Error != "" {
+		fmt.Printf(" error: %s", item.Error)
+	}
+	fmt.Println()
+}
+
+func (p *Partition) GetCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "partition",
+		Aliases: []string{"p"},
+		Usage:   "Partition commands",
+		Subcommands: []*cli.Command{
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "topic",
+						Usage:   "topic of the partition",
+						Aliases: []string{"t"},
+					},
+					&cli.Uint64Flag{
+						Name:    "partition",
+						Usage:   "number of the partition",
+						Aliases: []string{"p"},
+					},
+					&cli.StringFlag{
+						Name:  "target",
+						Usage: "shard to move the partition to",
+					},
+					&cli.StringFlag{
+						Name:  "id",
+						Usage: "id of a started move, to follow or cancel it",
+					},
+					&cli.BoolFlag{
+						Name:  "cancel",
+						Usage: "cancel the move with the id",
+					},
+					&cli.BoolFlag{
+						Name:  "detach",
+						Usage: "don't wait for the move to finish",
+					},
+				},
+				Name:    "move",
+				Aliases: []string{"mv"},
+				Usage:   "move a partition to another shard, ctrl-c while following cancels the move",
+				Action:  p.moveAction,
+			},
+			{
+				Name:    "moves",
+				Aliases: []string{"l"},
+				Usage:   "list the partition moves",
+				Action:  p.listMovesAction,
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "only print the planned moves",
+					},
+				},
+				Name:   "balance",
+				Usage:  "move partitions to even out the load of the shards, moves are throttled by the controller",
+				Action: p.balanceAction,
+			},
+		},
+	}
+}
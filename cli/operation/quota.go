@@ -0,0 +1,126 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/urfave/cli/v2"
+	"strings"
+)
+
+type Quota struct {
+	client *client.JetClient
+}
+
+// quota reads the entity of the quota from the flags, the rates are added by set
+func (q *Quota) quota(cCtx *cli.Context) (*proto.Quota, error) {
+	var quotas []*proto.Quota
+	for flag, entity := range map[string]proto.QuotaEntity{
+		"principal": proto.QuotaEntity_PRINCIPAL_QUOTA,
+		"client":    proto.QuotaEntity_CLIENT_QUOTA,
+		"topic":     proto.QuotaEntity_TOPIC_QUOTA,
+	} {
+		if cCtx.IsSet(flag) {
+			quotas = append(quotas, &proto.Quota{Entity: entity, Name: cCtx.String(flag)})
+		}
+	}
+	if len(quotas) != 1 {
+		return nil, errors.New("one of --principal, --client or --topic is needed")
+	}
+	return quotas[0], nil
+}
+
+func (q *Quota) setAction(cCtx *cli.Context) error {
+	quota, err := q.quota(cCtx)
+	if err != nil {
+		return err
+	}
+	quota.BytesPerSecond = cCtx.Float64("bytes-per-second")
+	quota.RequestsPerSecond = cCtx.Float64("requests-per-second")
+	if quota.BytesPerSecond == 0 && quota.RequestsPerSecond == 0 {
+		return errors.New("--bytes-per-second or --requests-per-second is needed")
+	}
+	if err := q.client.SetQuotas([]*proto.Quota{quota}); err != nil {
+		return err
+	}
+	fmt.Println("set the quota on every shard")
+	return nil
+}
+
+func (q *Quota) removeAction(cCtx *cli.Context) error {
+	quota, err := q.quota(cCtx)
+	if err != nil {
+		return err
+	}
+	if err := q.client.DeleteQuotas([]*proto.Quota{quota}); err != nil {
+		return err
+	}
+	fmt.Println("removed the quota from every shard")
+	return nil
+}
+
+func (q *Quota) listAction(*cli.Context) error {
+	quotas, err := q.client.ListQuotas()
+	for _, quota := range quotas {
+		fmt.Printf("%-9s %-30s bytes/s: %-12s requests/s: %s\n", strings.ToLower(strings.TrimSuffix(quota.Entity.String(), "_QUOTA")),
+			quota.Name, formatRate(quota.BytesPerSecond), formatRate(quota.RequestsPerSecond))
+	}
+	return err
+}
+
+func formatRate(rate float64) string {
+	if rate == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%g", rate)
+}
+
+func (q *Quota) GetCommand() *cli.Command {
+	entity := []cli.Flag{
+		&cli.StringFlag{
+			Name:  "principal",
+			Usage: "identity of the callers, * for the default of every principal",
+		},
+		&cli.StringFlag{
+			Name:  "client",
+			Usage: "client id sent by the clients, * for the default of every client",
+		},
+		&cli.StringFlag{
+			Name:  "topic",
+			Usage: "topic name, * for the default of every topic",
+		},
+	}
+	return &cli.Command{
+		Name:  "quota",
+		Usage: "Publish quotas, a caller over its quota is slowed down by the shard leader",
+		Subcommands: []*cli.Command{
+			{
+				Flags: append(entity,
+					&cli.Float64Flag{
+						Name:  "bytes-per-second",
+						Usage: "bytes of keys and values published per second, 0 doesn't limit",
+					},
+					&cli.Float64Flag{
+						Name:  "requests-per-second",
+						Usage: "publish calls per second, 0 doesn't limit",
+					}),
+				Name:   "set",
+				Usage:  "set the quota of a principal, client id or topic",
+				Action: q.setAction,
+			},
+			{
+				Flags:  entity,
+				Name:   "remove",
+				Usage:  "remove the quota of a principal, client id or topic",
+				Action: q.removeAction,
+			},
+			{
+				Name:    "list",
+				Aliases: []string{"l"},
+				Usage:   "print the quotas of every shard",
+				Action:  q.listAction,
+			},
+		},
+	}
+}
@@ -0,0 +1,171 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/client"
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/urfave/cli/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Shard struct {
+	client *client.JetClient
+}
+
+// splitAction starts a split, partitions are given as topic:partition
+func (s *Shard) splitAction(cCtx *cli.Context) error {
+	if !cCtx.IsSet("shard") || !cCtx.IsSet("new-shard") {
+		return errors.New("a split needs --shard and --new-shard")
+	}
+	partitions := map[string][]uint64{}
+	for _, item := range cCtx.StringSlice("partition") {
+		topic, num, ok := strings.Cut(item, ":")
+		if !ok {
+			return fmt.Errorf("partition %q is not topic:partition", item)
+		}
+		partition, err := strconv.ParseUint(num, 10, 64)
+		if err != nil {
+			return fmt.Errorf("partition %q is not topic:partition: %w", item, err)
+		}
+		partitions[topic] = append(partitions[topic], partition)
+	}
+	res, err := s.client.SplitShard(cCtx.String("shard"), cCtx.String("new-shard"), partitions)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("started split %s\n", res.Id)
+	if cCtx.Bool("detach") {
+		return nil
+	}
+	return s.follow(res.Id)
+}
+
+func (s *Shard) mergeAction(cCtx *cli.Context) error {
+	if !cCtx.IsSet("source") || !cCtx.IsSet("target") {
+		return errors.New("a merge needs --source and --target")
+	}
+	res, err := s.client.MergeShards(cCtx.String("source"), cCtx.String("target"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("started merge %s\n", res.Id)
+	if cCtx.Bool("detach") {
+		return nil
+	}
+	return s.follow(res.Id)
+}
+
+// follow prints the operation until it completes or fails
+func (s *Shard) follow(id string) error {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		res, err := s.client.GetShardOperations(id)
+		if err != nil {
+			return err
+		}
+		if len(res) == 0 {
+			return fmt.Errorf("operation %s does not exist", id)
+		}
+		printShardOperation(res[0])
+		switch res[0].Phase {
+		case controllerPb.ShardOperationPhase_COMPLETED:
+			return nil
+		case controllerPb.ShardOperationPhase_FAILED:
+			return fmt.Errorf("operation %s failed", id)
+		}
+		<-ticker.C
+	}
+}
+
+func (s *Shard) listAction(cCtx *cli.Context) error {
+	res, err := s.client.GetShardOperations(cCtx.String("id"))
+	if err != nil {
+		return err
+	}
+	for _, item := range res {
+		printShardOperation(item)
+	}
+	return nil
+}
+
+func printShardOperation(item *controllerPb.ShardOperation) {
+	var topics []string
+	for topic, list := range item.Partitions {
+		topics = append(topics, fmt.Sprintf("%s%v", topic, list.Partitions))
+	}
+	sort.Strings(topics)
+	fmt.Printf("%s %s %s -> %s phase: %-9s partitions: %s moves: %d", item.Id, item.Type, item.SourceShard,
+		item.TargetShard, item.Phase, strings.Join(topics, " "), len(item.ReassignmentIds))
+	if item.Error != "" {
+		fmt.Printf(" error: %s", item.Error)
+	}
+	fmt.Println()
+}
+
+func (s *Shard) GetCommand() *cli.Command {
+	detach := &cli.BoolFlag{
+		Name:  "detach",
+		Usage: "don't wait for the operation to finish",
+	}
+	return &cli.Command{
+		Name:    "shard",
+		Aliases: []string{"s"},
+		Usage:   "Shard commands",
+		Subcommands: []*cli.Command{
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "shard",
+						Usage: "shard to split",
+					},
+					&cli.StringFlag{
+						Name:  "new-shard",
+						Usage: "id of the new shard, it runs on the nodes of the split shard",
+					},
+					&cli.StringSliceFlag{
+						Name:    "partition",
+						Usage:   "topic:partition to move to the new shard, every other partition is moved if not set",
+						Aliases: []string{"p"},
+					},
+					detach,
+				},
+				Name:   "split",
+				Usage:  "split a shard in two",
+				Action: s.splitAction,
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "source",
+						Usage: "shard that is merged and stopped",
+					},
+					&cli.StringFlag{
+						Name:  "target",
+						Usage: "shard that takes the partitions",
+					},
+					detach,
+				},
+				Name:   "merge",
+				Usage:  "move every partition of a shard to another one and stop it",
+				Action: s.mergeAction,
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "id",
+						Usage: "only print the operation with the id",
+					},
+				},
+				Name:    "ops",
+				Aliases: []string{"l"},
+				Usage:   "list the splits and merges",
+				Action:  s.listAction,
+			},
+		},
+	}
+}
@@ -2,83 +2,116 @@ package operation
 
 import (
 	"fmt"
-	"github.com/Kapperchino/jet-stream/application/proto/proto"
 	"github.com/Kapperchino/jet-stream/client"
-	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
+	"strconv"
+	"strings"
 )
 
 type Topic struct {
 	client *client.JetClient
 }
 
-func (t *Topic) createTopic(cCtx *cli.Context) (*proto.CreateTopicResponse, error) {
-	topic := cCtx.String("topic")
-	partitions := cCtx.Int("partitions")
-	res, err := t.client.CreateTopic(topic, partitions)
+func (t *Topic) createTopicAction(cCtx *cli.Context) error {
+	if _, err := t.client.CreateTopic(cCtx.String("name"), cCtx.Int("partitions")); err != nil {
+		return err
+	}
+	fmt.Printf("created topic %s with %d partitions\n", cCtx.String("name"), cCtx.Int("partitions"))
+	return nil
+}
+
+func (t *Topic) listAction(*cli.Context) error {
+	topics, err := t.client.ListTopics()
 	if err != nil {
-		log.Fatal().Err(err)
+		return err
+	}
+	table := newTable("TOPIC", "PARTITIONS", "SHARDS", "SIZE", "CONSUMER GROUPS")
+	for _, topic := range topics {
+		partitions := map[uint64]bool{}
+		shards := map[string]bool{}
+		size := uint64(0)
+		for _, partition := range topic.Partitions {
+			partitions[partition.Partition] = true
+			shards[partition.ShardId] = true
+			size += partition.Bytes
+		}
+		table.row(topic.Name, strconv.Itoa(len(partitions)), strings.Join(sortedKeys(shards), ","), formatBytes(size),
+			orNone(strings.Join(topic.ConsumerGroups, ",")))
 	}
-	return res, nil
+	return table.flush()
 }
 
-func (t *Topic) createTopicAction(cCtx *cli.Context) error {
-	res, _ := t.createTopic(cCtx)
-	fmt.Printf("%v", res)
-	return nil
+func (t *Topic) describeAction(cCtx *cli.Context) error {
+	topic, err := t.client.DescribeTopic(cCtx.String("name"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("topic:           %s\n", topic.Name)
+	fmt.Printf("consumer groups: %s\n\n", orNone(strings.Join(topic.ConsumerGroups, ", ")))
+	table := newTable("PARTITION", "SHARD", "LEADER", "HIGH WATERMARK", "SIZE", "STATE")
+	for _, partition := range topic.Partitions {
+		state := "active"
+		if partition.Fenced {
+			state = "fenced"
+		}
+		table.row(strconv.FormatUint(partition.Partition, 10), partition.ShardId, orNone(partition.Leader),
+			strconv.FormatUint(partition.HighWatermark, 10), formatBytes(partition.Bytes), state)
+	}
+	return table.flush()
+}
+
+// formatBytes prints a size with a binary unit
+func formatBytes(size uint64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := uint64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
 }
 
 func (t *Topic) GetCommand() *cli.Command {
+	name := &cli.StringFlag{
+		Name:     "name",
+		Usage:    "name of the topic",
+		Aliases:  []string{"n"},
+		Required: true,
+	}
 	return &cli.Command{
-		Name:    "Topic",
+		Name:    "topic",
 		Aliases: []string{"t"},
 		Usage:   "Topic commands",
 		Subcommands: []*cli.Command{
 			{
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:     "name",
-						Usage:    "name of the Topic",
-						Aliases:  []string{"n"},
-						Required: true,
-					},
+				Flags: []cli.Flag{name,
 					&cli.IntFlag{
 						Name:     "partitions",
-						Usage:    "number of partitions in the Topic",
+						Usage:    "number of partitions in the topic",
 						Aliases:  []string{"p"},
 						Required: true,
 					},
 				},
 				Name:    "create",
 				Aliases: []string{"cre"},
-				Usage:   "creates a new Topic",
+				Usage:   "creates a new topic",
 				Action:  t.createTopicAction,
 			},
 			{
 				Name:    "list",
 				Aliases: []string{"l"},
-				Usage:   "list all the topics",
-				Action: func(cCtx *cli.Context) error {
-					fmt.Println("new task template: ", cCtx.Args().First())
-					return nil
-				},
+				Usage:   "list the topics with their partitions, shards, size and consumer groups",
+				Action:  t.listAction,
 			},
 			{
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:     "name",
-						Usage:    "name of the Topic",
-						Aliases:  []string{"n"},
-						Required: true,
-					},
-				},
-				Name:    "delete",
-				Aliases: []string{"del"},
-				Usage:   "delete a Topic",
-				Action: func(cCtx *cli.Context) error {
-					fmt.Println("removed task template: ", cCtx.Args().First())
-					return nil
-				},
+				Flags:   []cli.Flag{name},
+				Name:    "describe",
+				Aliases: []string{"d"},
+				Usage:   "print the partitions of a topic with their shard, leader, high watermark and size",
+				Action:  t.describeAction,
 			},
 		},
 	}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/application/proto/proto"
+	"sort"
+	"time"
+)
+
+const aclTimeout = 5 * time.Second
+
+// PutAcls adds the acls to every shard, the shards that weren't changed are in the error and the call can be repeated
+func (j *JetClient) PutAcls(acls []*proto.Acl) error {
+	return j.forEachShard(func(ctx context.Context, shard *ShardClient) error {
+		_, err := shard.GetLeader().messageClient.PutAcls(ctx, &proto.PutAclsRequest{Acls: acls})
+		return err
+	})
+}
+
+// DeleteAcls removes the acls from every shard
+func (j *JetClient) DeleteAcls(acls []*proto.Acl) error {
+	return j.forEachShard(func(ctx context.Context, shard *ShardClient) error {
+		_, err := shard.GetLeader().messageClient.DeleteAcls(ctx, &proto.DeleteAclsRequest{Acls: acls})
+		return err
+	})
+}
+
+// ListAcls returns the acls of the principal found on any shard, every acl when it is empty
+func (j *JetClient) ListAcls(principal string) ([]*proto.Acl, error) {
+	acls := map[string]*proto.Acl{}
+	err := j.forEachShard(func(ctx context.Context, shard *ShardClient) error {
+		res, err := shard.GetLeader().messageClient.ListAcls(ctx, &proto.ListAclsRequest{Principal: principal})
+		if err != nil {
+			return err
+		}
+		for _, acl := range res.Acls {
+			acls[fmt.Sprintf("%s\x00%d\x00%s\x00%d", acl.Principal, acl.Resource, acl.Pattern, acl.Operation)] = acl
+		}
+		return nil
+	})
+	keys := make([]string, 0, len(acls))
+	for key := range acls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	res := make([]*proto.Acl, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, acls[key])
+	}
+	return res, err
+}
+
+// forEachShard calls the leader of every shard, it goes on after a shard fails so the error lists every shard that
+// wasn't reached
+func (j *JetClient) forEachShard(call func(ctx context.Context, shard *ShardClient) error) error {
+	if err := j.Refresh(); err != nil {
+		return err
+	}
+	var ids []string
+	j.getShardClients().ForEach(func(shardId string, _ *ShardClient) bool {
+		ids = append(ids, shardId)
+		return true
+	})
+	sort.Strings(ids)
+	var errs []error
+	for _, shardId := range ids {
+		err := j.withRetry(shardId, func(shard *ShardClient) error {
+			ctx, cancel := context.WithTimeout(context.Background(), aclTimeout)
+			defer cancel()
+			return call(ctx, shard)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", shardId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -3,19 +3,40 @@ package client
 import (
 	"context"
 	proto "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/auth"
 	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/controller"
 	"github.com/Kapperchino/jet-stream/util"
 	"github.com/buraksezer/consistent"
-	"github.com/rs/zerolog/log"
 	"github.com/spaolacci/murmur3"
+	"google.golang.org/grpc"
 	"math/rand"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type JetClient struct {
 	info         *clusterPb.ClusterInfo
 	shardClients *util.Map[string, *ShardClient]
 	metaData     *Meta
+	consistency  proto.ReadConsistency
+	// seeds are the addresses used to fetch cluster info on refresh
+	seeds       []string
+	connections *util.Map[string, *grpc.ClientConn]
+	// controller owns topic placement, nil when the cluster runs without one
+	controller *controller.Client
+	// watchers cancel the WatchCluster stream of each shard
+	watchers *util.Map[string, context.CancelFunc]
+	// dialOptions are added to every connection, they carry the transport credentials
+	dialOptions []grpc.DialOption
+	// token is the bearer token sent with every call, over plaintext only when tls is off
+	token        string
+	tls          bool
+	mutex        sync.RWMutex
+	refreshMutex sync.Mutex
+	stop         chan struct{}
 }
 
 type ConsumerGroup struct {
@@ -45,10 +66,15 @@ type ShardClient struct {
 	roundRobinIndex int32
 	partitions      *util.Map[uint64, *PartitionMeta]
 	buffer          [100]*proto.KeyVal
+	lastIndex       atomic.Uint64
+	// throttledUntil is when the quotas of the leader allow the next publish, in unix nanoseconds
+	throttledUntil atomic.Int64
+	mutex          sync.RWMutex
 }
 
 type MemberClient struct {
 	nodeId        string
+	address       string
 	clusterClient clusterPb.ClusterMetaServiceClient
 	messageClient proto.MessageServiceClient
 }
@@ -60,99 +86,101 @@ func (h hasher) Sum64(data []byte) uint64 {
 	return murmur3.Sum64(data)
 }
 
-func New(address string) (*JetClient, error) {
-	con, err := newClientConnection(address)
-	if err != nil {
-		log.Error().Msgf("Error creating connection with client")
-		return nil, err
+// Option changes how the client connects to the cluster
+type Option func(j *JetClient) error
+
+// WithTLS connects to the nodes and the controllers with tls, the certificate of the config is sent for mutual tls
+func WithTLS(config *util.TLSConfig) Option {
+	return func(j *JetClient) error {
+		dialOption, err := util.DialCredentials(config)
+		if err != nil {
+			return err
+		}
+		j.dialOptions = append(j.dialOptions, dialOption)
+		j.tls = true
+		return nil
 	}
-	ctx := context.Background()
-	rootClient := newClusterClient(con)
-	defer con.Close()
-	clusterInfo, err := rootClient.GetClusterInfo(ctx, &clusterPb.GetClusterInfoRequest{})
-	if err != nil {
-		log.Err(err).Msgf("Error getting cluster info")
-		return nil, err
+}
+
+// WithClientId names the client, the quotas of the client id apply to its publishes
+func WithClientId(clientId string) Option {
+	return func(j *JetClient) error {
+		j.dialOptions = append(j.dialOptions, util.ClientIdDialOption(clientId))
+		return nil
 	}
-	shardClients := util.NewMap[string, *ShardClient]()
-	for shardId, shard := range clusterInfo.Info.ShardMap {
-		shardClient := ShardClient{
-			leader:        shard.LeaderId,
-			memberclients: util.NewMap[string, *MemberClient](),
-			partitions:    util.NewMap[uint64, *PartitionMeta](),
-			shardId:       shardId,
-		}
-		shardClients.Set(shardId, &shardClient)
-		for nodeId, member := range shard.MemberAddressMap {
-			con, err = newClientConnection(member.Address)
-			if err != nil {
-				log.Err(err).Msgf("Error creating connection")
-				return nil, err
-			}
-			memberClient := MemberClient{
-				nodeId:        nodeId,
-				clusterClient: newClusterClient(con),
-				messageClient: newMessageClient(con),
-			}
-			shardClient.memberclients.Set(nodeId, &memberClient)
-		}
+}
+
+// WithToken authenticates the calls with a static token or a jwt
+func WithToken(token string) Option {
+	return func(j *JetClient) error {
+		j.token = token
+		return nil
 	}
-	metaData := Meta{topics: util.NewMap[string, *TopicMeta](), consumerGroups: util.NewMap[string, *ConsumerGroup]()}
-	shardClients.ForEach(func(shardId string, client *ShardClient) bool {
-		meta, err := client.GetNextMember().messageClient.GetMeta(ctx, &proto.GetMetaRequest{})
-		if err != nil {
-			log.Err(err).Msgf("Error while getting meta from shard %s", shardId)
-			return false
-		}
-		for key, topic := range meta.Topics {
-			//updating the map
-			var item = metaData.topics.Get(key)
-			if item == nil {
-				topicMeta := &TopicMeta{
-					partitions: util.NewMap[uint64, *PartitionMeta](),
-					hash:       newHashRing(),
-				}
-				for _, partition := range topic.Partitions {
-					topicMeta.partitions.Set(partition.Num, &PartitionMeta{
-						partitionNum: partition.Num,
-						topic:        topic.Name,
-						shardId:      shardId,
-					})
-				}
-				metaData.topics.Set(key, topicMeta)
-				item = topicMeta
-			}
-			partitions := item.partitions
-			for num, partition := range topic.Partitions {
-				partitionMeta := &PartitionMeta{
-					partitionNum: num,
-					topic:        partition.Topic,
-					shardId:      shardId,
-				}
-				item.hash.Add(partitionMeta)
-				partitions.Set(num, partitionMeta)
-				client.partitions.Set(num, partitionMeta)
-			}
-		}
+}
 
-		for id, group := range meta.ConsumerGroups {
-			var partitions []uint64
-			for _, consumer := range group.Consumers {
-				partitions = append(partitions, consumer.Partition)
-			}
-			metaData.consumerGroups.Set(id, &ConsumerGroup{
-				group: group,
-			})
+func New(address string, opts ...Option) (*JetClient, error) {
+	j := &JetClient{
+		seeds:       []string{address},
+		connections: util.NewMap[string, *grpc.ClientConn](),
+		watchers:    util.NewMap[string, context.CancelFunc](),
+		//the calls continue the trace of the publish or consume, spans are dropped until the application sets a
+		//tracer provider
+		dialOptions: util.TracingDialOptions(),
+		stop:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(j); err != nil {
+			return nil, err
 		}
+	}
+	if j.token != "" {
+		j.dialOptions = append(j.dialOptions, auth.TokenCredentials{Token: j.token, AllowInsecure: !j.tls}.DialOption())
+	}
+	err := j.Refresh()
+	if err != nil {
+		j.Close()
+		return nil, err
+	}
+	go j.refreshLoop(refreshInterval)
+	return j, nil
+}
+
+// Close stops the background refresh and the watchers, then closes every connection
+func (j *JetClient) Close() {
+	j.refreshMutex.Lock()
+	defer j.refreshMutex.Unlock()
+	select {
+	case <-j.stop:
+		return
+	default:
+		close(j.stop)
+	}
+	j.stopWatchers()
+	j.connections.ForEach(func(address string, conn *grpc.ClientConn) bool {
+		_ = conn.Close()
 		return true
-	},
-	)
+	})
+	if controllerClient := j.getController(); controllerClient != nil {
+		controllerClient.Close()
+	}
+}
+
+func (j *JetClient) getShardClients() *util.Map[string, *ShardClient] {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return j.shardClients
+}
 
-	return &JetClient{
-		info:         clusterInfo.Info,
-		shardClients: shardClients,
-		metaData:     &metaData,
-	}, nil
+func (j *JetClient) getController() *controller.Client {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return j.controller
+}
+
+func (j *JetClient) getMeta() *Meta {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return j.metaData
 }
 
 type KeyValuePair struct {
@@ -160,13 +188,78 @@ type KeyValuePair struct {
 	count int
 }
 
+// GetLeader returns the leader of the shard, or any member if the leader is unknown since followers forward writes
 func (s *ShardClient) GetLeader() *MemberClient {
-	res := s.memberclients.Get(s.leader)
+	res := s.memberclients.Get(s.getLeaderId())
+	if res == nil {
+		return s.GetNextMember()
+	}
 	return res
 }
 
+func (s *ShardClient) getLeaderId() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.leader
+}
+
+// setLeader updates the leader if it is a known member, returns false if it isn't
+func (s *ShardClient) setLeader(leaderId string) bool {
+	if s.memberclients.Get(leaderId) == nil {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.leader = leaderId
+	return true
+}
+
+// SetReadConsistency sets the consistency used by ConsumeMessage
+func (j *JetClient) SetReadConsistency(consistency proto.ReadConsistency) {
+	j.consistency = consistency
+}
+
+// GetReadMember returns the member that can serve a read with the given consistency
+func (s *ShardClient) GetReadMember(consistency proto.ReadConsistency) *MemberClient {
+	if consistency == proto.ReadConsistency_FOLLOWER {
+		return s.GetNextMember()
+	}
+	return s.GetLeader()
+}
+
+// updateLastIndex records the newest raft index written through this client, used for read-your-writes
+func (s *ShardClient) updateLastIndex(index uint64) {
+	for {
+		cur := s.lastIndex.Load()
+		if index <= cur || s.lastIndex.CompareAndSwap(cur, index) {
+			return
+		}
+	}
+}
+
+// throttle holds the next publishes to the shard back by the delay the leader asked for
+func (s *ShardClient) throttle(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	until := time.Now().Add(delay).UnixNano()
+	for {
+		cur := s.throttledUntil.Load()
+		if until <= cur || s.throttledUntil.CompareAndSwap(cur, until) {
+			return
+		}
+	}
+}
+
+// waitThrottle sleeps until the shard accepts publishes again
+func (s *ShardClient) waitThrottle() {
+	if delay := time.Until(time.Unix(0, s.throttledUntil.Load())); delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
 func (s *ShardClient) GetNextMember() *MemberClient {
-	if s.memberclients.Len() == 1 {
+	if s.memberclients.Len() <= 1 {
 		var res *MemberClient
 		s.memberclients.ForEach(func(s string, client *MemberClient) bool {
 			res = client
@@ -175,8 +268,9 @@ func (s *ShardClient) GetNextMember() *MemberClient {
 		return res
 	}
 	var memberClients []*MemberClient
+	leader := s.getLeaderId()
 	s.memberclients.ForEach(func(id string, client *MemberClient) bool {
-		if id != s.leader {
+		if id != leader {
 			memberClients = append(memberClients, client)
 		}
 		return true
@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	raftadminPb "github.com/Kapperchino/jet-stream/raftadmin/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"sort"
+	"time"
+)
+
+// clusterTimeout bounds the cluster admin calls, a membership change or a snapshot waits for the raft group
+const clusterTimeout = 30 * time.Second
+
+// ShardMember is a member of a shard as the gossip advertises it, with its place in the raft configuration
+type ShardMember struct {
+	NodeId  string
+	Address string
+	Role    clusterPb.MemberRole
+	// Suffrage is the one of the raft configuration of the leader, empty when the member isn't in it
+	Suffrage string
+	Leader   bool
+}
+
+// GetClusterInfo returns the shards and their members after refreshing them
+func (j *JetClient) GetClusterInfo() (*clusterPb.ClusterInfo, error) {
+	if err := j.Refresh(); err != nil {
+		return nil, err
+	}
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return j.info, nil
+}
+
+// GetShardInfo asks the leader of the shard, or a member when it isn't known, for the state of the shard
+func (j *JetClient) GetShardInfo(shardId string) (*clusterPb.ShardInfo, error) {
+	if err := j.Refresh(); err != nil {
+		return nil, err
+	}
+	var info *clusterPb.ShardInfo
+	err := j.withRetry(shardId, func(client *ShardClient) error {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterInfoTimeout)
+		defer cancel()
+		res, err := client.GetLeader().clusterClient.GetShardInfo(ctx, &clusterPb.GetShardInfoRequest{})
+		if err != nil {
+			return err
+		}
+		info = res.Info
+		return nil
+	})
+	return info, err
+}
+
+// GetShardLeader returns the member leading the shard
+func (j *JetClient) GetShardLeader(shardId string) (*clusterPb.MemberInfo, error) {
+	info, err := j.GetShardInfo(shardId)
+	if err != nil {
+		return nil, err
+	}
+	leader := info.GetMemberAddressMap()[info.GetLeaderId()]
+	if leader == nil {
+		return nil, fmt.Errorf("shard %s has no leader", shardId)
+	}
+	return leader, nil
+}
+
+// GetShardMembers lists the members of the shard sorted by id, the raft configuration of the leader adds the ones the
+// gossip doesn't know yet
+func (j *JetClient) GetShardMembers(shardId string) ([]*ShardMember, error) {
+	info, err := j.GetShardInfo(shardId)
+	if err != nil {
+		return nil, err
+	}
+	members := map[string]*ShardMember{}
+	for nodeId, member := range info.GetMemberAddressMap() {
+		members[nodeId] = &ShardMember{
+			NodeId:  nodeId,
+			Address: member.GetAddress(),
+			Role:    member.GetRole(),
+			Leader:  nodeId == info.GetLeaderId(),
+		}
+	}
+	err = j.callRaftAdmin(shardId, info.GetLeaderId(), func(ctx context.Context, admin raftadminPb.RaftAdminClient) error {
+		res, err := admin.GetConfiguration(ctx, &raftadminPb.GetConfigurationRequest{})
+		if err != nil {
+			return err
+		}
+		for _, server := range res.GetServers() {
+			member := members[server.GetId()]
+			if member == nil {
+				member = &ShardMember{NodeId: server.GetId(), Address: server.GetAddress()}
+				members[server.GetId()] = member
+			}
+			member.Suffrage = server.GetSuffrage().String()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var res []*ShardMember
+	for _, member := range members {
+		res = append(res, member)
+	}
+	sort.Slice(res, func(i, k int) bool {
+		return res[i].NodeId < res[k].NodeId
+	})
+	return res, nil
+}
+
+// TransferLeadership hands the leadership of the shard to the node, or to the most up-to-date voter when nodeId is
+// empty
+func (j *JetClient) TransferLeadership(shardId string, nodeId string) error {
+	leader, err := j.GetShardLeader(shardId)
+	if err != nil {
+		return err
+	}
+	target := ""
+	if nodeId != "" {
+		if target, err = j.memberAddress(shardId, nodeId); err != nil {
+			return err
+		}
+	}
+	return j.callRaftAdmin(shardId, leader.GetNodeId(), func(ctx context.Context, admin raftadminPb.RaftAdminClient) error {
+		var future *raftadminPb.Future
+		if nodeId == "" {
+			future, err = admin.LeadershipTransfer(ctx, &raftadminPb.LeadershipTransferRequest{})
+		} else {
+			future, err = admin.LeadershipTransferToServer(ctx, &raftadminPb.LeadershipTransferToServerRequest{Id: nodeId, Address: target})
+		}
+		if err != nil {
+			return err
+		}
+		return await(ctx, admin, future)
+	})
+}
+
+// AddVoter asks the leader of the shard to add the node at address as a voter, like a node joining on start
+func (j *JetClient) AddVoter(shardId string, nodeId string, address string) error {
+	return j.withRetry(shardId, func(client *ShardClient) error {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+		defer cancel()
+		_, err := client.GetLeader().clusterClient.JoinShard(ctx, &clusterPb.JoinShardRequest{
+			NodeId:  nodeId,
+			Address: address,
+			Role:    clusterPb.MemberRole_VOTER,
+		})
+		return err
+	})
+}
+
+// RemoveVoter asks the leader of the shard to remove the node from the raft group and the shard info. It is refused
+// when the voters left reachable aren't a quorum, unless force is set
+func (j *JetClient) RemoveVoter(shardId string, nodeId string, force bool) error {
+	return j.withRetry(shardId, func(client *ShardClient) error {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+		defer cancel()
+		_, err := client.GetLeader().clusterClient.LeaveShard(ctx, &clusterPb.LeaveShardRequest{NodeId: nodeId, Force: force})
+		return err
+	})
+}
+
+// Snapshot has the node take a raft snapshot of the shard, the leader when nodeId is empty
+func (j *JetClient) Snapshot(shardId string, nodeId string) error {
+	return j.callRaftAdmin(shardId, nodeId, func(ctx context.Context, admin raftadminPb.RaftAdminClient) error {
+		future, err := admin.Snapshot(ctx, &raftadminPb.SnapshotRequest{})
+		if err != nil {
+			return err
+		}
+		return await(ctx, admin, future)
+	})
+}
+
+// GetRaftStats returns the raft stats of the node for the shard, the leader's when nodeId is empty
+func (j *JetClient) GetRaftStats(shardId string, nodeId string) (map[string]string, error) {
+	var stats map[string]string
+	err := j.callRaftAdmin(shardId, nodeId, func(ctx context.Context, admin raftadminPb.RaftAdminClient) error {
+		res, err := admin.Stats(ctx, &raftadminPb.StatsRequest{})
+		if err != nil {
+			return err
+		}
+		stats = res.GetStats()
+		return nil
+	})
+	return stats, err
+}
+
+// callRaftAdmin runs call against the raft group of the shard on the node, the leader when nodeId is empty
+func (j *JetClient) callRaftAdmin(shardId string, nodeId string, call func(ctx context.Context, admin raftadminPb.RaftAdminClient) error) error {
+	if nodeId == "" {
+		leader, err := j.GetShardLeader(shardId)
+		if err != nil {
+			return err
+		}
+		nodeId = leader.GetNodeId()
+	}
+	address, err := j.memberAddress(shardId, nodeId)
+	if err != nil {
+		return err
+	}
+	conn, err := j.getConnection(address)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	return call(ctx, raftadminPb.NewRaftAdminClient(util.ShardConn(conn, shardId)))
+}
+
+// memberAddress finds the address of the node among the members of the shard
+func (j *JetClient) memberAddress(shardId string, nodeId string) (string, error) {
+	shard := j.getShardClients().Get(shardId)
+	if shard == nil {
+		return "", fmt.Errorf("shard %s does not exist", shardId)
+	}
+	member := shard.memberclients.Get(nodeId)
+	if member == nil {
+		return "", fmt.Errorf("node %s is not a member of shard %s", nodeId, shardId)
+	}
+	return member.address, nil
+}
+
+// await waits for the raft future started through the admin service and releases it
+func await(ctx context.Context, admin raftadminPb.RaftAdminClient, future *raftadminPb.Future) error {
+	res, err := admin.Await(ctx, future)
+	if err != nil {
+		return err
+	}
+	_, _ = admin.Forget(ctx, future)
+	if res.GetError() != "" {
+		return errors.New(res.GetError())
+	}
+	return nil
+}
@@ -4,43 +4,52 @@ import (
 	"context"
 	"errors"
 	"github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/controller"
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
+	"time"
 )
 
 // CreateConsumerGroup creates multiple consumers on each shard that contains the partitions of the topic, stores
 // the id of each consumer
 func (j *JetClient) CreateConsumerGroup(topicName string) (*proto.CreateConsumerGroupResponse, error) {
-	topic := j.metaData.topics.Get(topicName)
+	metaData := j.getMeta()
+	topic := metaData.topics.Get(topicName)
 	if topic == nil {
 		return nil, errors.New("topic does not exist")
 	}
+	id := uuid.NewString()
+	if controllerClient := j.getController(); controllerClient != nil {
+		return j.createConsumerGroupWithController(controllerClient, topicName, id)
+	}
 	partitionSet := mapset.NewSet[string]()
 	topic.partitions.ForEach(func(u uint64, meta *PartitionMeta) bool {
 		partitionSet.Add(meta.shardId)
 		return true
 	})
-	var curErr error
-	id := uuid.NewString()
 	slice := partitionSet.ToSlice()
 	for _, s := range slice {
-		client := j.shardClients.Get(s)
-		if client == nil {
-			curErr = errors.New("shard needs to be in the meta")
-			return nil, curErr
-		}
-		group, err := client.GetLeader().messageClient.CreateConsumerGroup(context.Background(), &proto.CreateConsumerGroupRequest{
-			Topic: topicName,
-			Id:    id,
+		var group *proto.CreateConsumerGroupResponse
+		err := j.withRetry(s, func(client *ShardClient) error {
+			var err error
+			group, err = client.GetLeader().messageClient.CreateConsumerGroup(context.Background(), &proto.CreateConsumerGroupRequest{
+				Topic: topicName,
+				Id:    id,
+			})
+			return err
 		})
 		if err != nil {
-			curErr = err
 			log.Error().Stack().Err(err)
 			return nil, err
 		}
-		j.metaData.consumerGroups.Set(group.Id, &ConsumerGroup{
+		metaData.consumerGroups.Set(group.Id, &ConsumerGroup{
 			group: group.Group,
 		})
 	}
@@ -50,9 +59,73 @@ func (j *JetClient) CreateConsumerGroup(topicName string) (*proto.CreateConsumer
 	}, nil
 }
 
-// ConsumeMessage need to check if the consumer is created, if true then find
+// createConsumerGroupWithController registers the group with the controller and waits for every shard of the topic
+// to create it
+func (j *JetClient) createConsumerGroupWithController(controllerClient *controller.Client, topicName string, id string) (*proto.CreateConsumerGroupResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), controllerTimeout)
+	defer cancel()
+	_, err := createWithController(ctx, controllerClient, &controllerPb.CreateConsumerGroupRequest{
+		Topic: topicName,
+		Id:    id,
+	}, controllerPb.ControllerServiceClient.CreateConsumerGroup)
+	if err != nil {
+		log.Err(err).Msgf("Error creating consumer group")
+		return nil, err
+	}
+	//the consumers of the group live on the shards
+	if err := j.Refresh(); err != nil {
+		return nil, err
+	}
+	return &proto.CreateConsumerGroupResponse{Id: id}, nil
+}
+
+// ConsumeMessage consumes with the client's read consistency, see SetReadConsistency
 func (j *JetClient) ConsumeMessage(topicName string, id string) ([]*proto.Message, error) {
-	val := j.metaData.consumerGroups.Get(id)
+	return j.ConsumeMessageWithConsistency(topicName, id, j.consistency)
+}
+
+// ConsumeMessageWithConsistency need to check if the consumer is created, if true then find. Follower reads wait
+// for the member to catch up with the last write made by this client to the shard
+func (j *JetClient) ConsumeMessageWithConsistency(topicName string, id string, consistency proto.ReadConsistency) ([]*proto.Message, error) {
+	ctx, span := util.Tracer().Start(context.Background(), "jet.Consume", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("jet.topic", topicName), attribute.String("jet.group", id)))
+	defer span.End()
+	messages, err := j.consume(ctx, topicName, id, consistency)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("jet.messages", len(messages)))
+	return messages, nil
+}
+
+// TraceContext returns ctx with the trace context of the publish of the message, the spans processing it continue the
+// trace of its producer
+func TraceContext(ctx context.Context, message *proto.Message) context.Context {
+	return util.ExtractTraceContext(ctx, message.GetHeaders())
+}
+
+// linkProducers records a span linked to the publishes of the messages, the links can only be set when a span starts
+func linkProducers(ctx context.Context, start time.Time, messages []*proto.Message) {
+	var links []trace.Link
+	for _, message := range messages {
+		producer := trace.SpanContextFromContext(TraceContext(context.Background(), message))
+		if producer.IsValid() {
+			links = append(links, trace.Link{SpanContext: producer})
+		}
+	}
+	if len(links) == 0 {
+		return
+	}
+	_, span := util.Tracer().Start(ctx, "jet.Receive", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithTimestamp(start), trace.WithLinks(links...), trace.WithAttributes(attribute.Int("jet.messages", len(messages))))
+	span.End()
+}
+
+func (j *JetClient) consume(ctx context.Context, topicName string, id string, consistency proto.ReadConsistency) ([]*proto.Message, error) {
+	start := time.Now()
+	metaData := j.getMeta()
+	val := metaData.consumerGroups.Get(id)
 	if val == nil {
 		return nil, errors.New("group does not exist")
 	}
@@ -60,7 +133,7 @@ func (j *JetClient) ConsumeMessage(topicName string, id string) ([]*proto.Messag
 	for _, consumer := range val.group.Consumers {
 		offsets[consumer.Partition] = consumer.Offset
 	}
-	topic := j.metaData.topics.Get(topicName)
+	topic := metaData.topics.Get(topicName)
 	if topic == nil {
 		return nil, errors.New("topic does not exist")
 	}
@@ -69,23 +142,18 @@ func (j *JetClient) ConsumeMessage(topicName string, id string) ([]*proto.Messag
 		partitionSet.Add(meta.shardId)
 		return true
 	})
-	var curErr error
 	partitionMap := map[uint64]uint64{}
-	var clients []*ShardClient
 	var combinedRes []*proto.Message
 	slice := partitionSet.ToSlice()
 	consumeGroup, _ := errgroup.WithContext(context.Background())
 	resChannel := make(chan *proto.ConsumeResponse, len(slice))
 	for _, s := range slice {
-		client := j.shardClients.Get(s)
-		clients = append(clients, client)
-		if client == nil {
-			curErr = errors.New("shard needs to be in the meta")
-			return nil, curErr
-		}
-		curClient := client.GetNextMember().messageClient
+		shardId := s
 		consumeGroup.Go(func() error {
-			return ConsumeMessages(curClient, topicName, id, resChannel, offsets)
+			return j.withRetry(shardId, func(client *ShardClient) error {
+				curClient := client.GetReadMember(consistency).messageClient
+				return ConsumeMessages(ctx, curClient, topicName, id, resChannel, offsets, consistency, client.lastIndex.Load())
+			})
 		})
 	}
 	err := consumeGroup.Wait()
@@ -110,18 +178,18 @@ func (j *JetClient) ConsumeMessage(topicName string, id string) ([]*proto.Messag
 	if len(combinedRes) == 0 {
 		return combinedRes, nil
 	}
+	linkProducers(ctx, start, combinedRes)
 
 	ackGroup, _ := errgroup.WithContext(context.Background())
 	ackChannel := make(chan *proto.AckConsumeResponse, len(slice))
 	//ack everything
-	for _, client := range clients {
-		client := client.GetLeader().messageClient
+	for _, s := range slice {
+		shardId := s
 		ackGroup.Go(func() error {
-			return AckMessages(client, topicName, id, partitionMap, ackChannel)
+			return j.withRetry(shardId, func(client *ShardClient) error {
+				return AckMessages(ctx, client.GetLeader().messageClient, topicName, id, partitionMap, ackChannel)
+			})
 		})
-		if err != nil {
-			return nil, err
-		}
 	}
 	err = ackGroup.Wait()
 	close(ackChannel)
@@ -136,11 +204,13 @@ func (j *JetClient) ConsumeMessage(topicName string, id string) ([]*proto.Messag
 	return combinedRes, nil
 }
 
-func ConsumeMessages(client proto.MessageServiceClient, topicName string, id string, channel chan *proto.ConsumeResponse, offsets map[uint64]uint64) error {
-	res, err := client.Consume(context.Background(), &proto.ConsumeRequest{
-		Topic:   topicName,
-		GroupId: id,
-		Offsets: offsets,
+func ConsumeMessages(ctx context.Context, client proto.MessageServiceClient, topicName string, id string, channel chan *proto.ConsumeResponse, offsets map[uint64]uint64, consistency proto.ReadConsistency, minIndex uint64) error {
+	res, err := client.Consume(ctx, &proto.ConsumeRequest{
+		Topic:       topicName,
+		GroupId:     id,
+		Offsets:     offsets,
+		Consistency: consistency,
+		MinIndex:    minIndex,
 	})
 	if err != nil {
 		log.Err(err).Stack().Msgf("Error consuming from group %s", id)
@@ -150,8 +220,8 @@ func ConsumeMessages(client proto.MessageServiceClient, topicName string, id str
 	return nil
 }
 
-func AckMessages(client proto.MessageServiceClient, topicName string, id string, partitionMap map[uint64]uint64, channel chan *proto.AckConsumeResponse) error {
-	res, err := client.AckConsume(context.Background(), &proto.AckConsumeRequest{
+func AckMessages(ctx context.Context, client proto.MessageServiceClient, topicName string, id string, partitionMap map[uint64]uint64, channel chan *proto.AckConsumeResponse) error {
+	res, err := client.AckConsume(ctx, &proto.AckConsumeRequest{
 		Offsets: partitionMap,
 		GroupId: id,
 		Topic:   topicName,
@@ -3,6 +3,7 @@ package client
 import (
 	"github.com/Kapperchino/jet-stream/application/proto/proto"
 	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
 	"github.com/buraksezer/consistent"
 	grpc_retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
 	"google.golang.org/grpc"
@@ -19,27 +20,28 @@ func newHashRing() *consistent.Consistent {
 	return consistent.New(nil, cfg)
 }
 
-func newClusterClient(conn *grpc.ClientConn) clusterPb.ClusterMetaServiceClient {
-	client := clusterPb.NewClusterMetaServiceClient(conn)
+// newClusterClient calls the shard on the connection, nodes answer for their first shard when shardId is empty
+func newClusterClient(conn *grpc.ClientConn, shardId string) clusterPb.ClusterMetaServiceClient {
+	client := clusterPb.NewClusterMetaServiceClient(util.ShardConn(conn, shardId))
 	return client
 }
 
-func newMessageClient(conn *grpc.ClientConn) proto.MessageServiceClient {
-	client := proto.NewMessageServiceClient(conn)
+func newMessageClient(conn *grpc.ClientConn, shardId string) proto.MessageServiceClient {
+	client := proto.NewMessageServiceClient(util.ShardConn(conn, shardId))
 	return client
 }
 
-func newClientConnection(address string) (*grpc.ClientConn, error) {
+func newClientConnection(address string, dialOptions ...grpc.DialOption) (*grpc.ClientConn, error) {
 	serviceConfig := `{"healthCheckConfig": {"serviceName": "cluster.ClusterMetaService"}, "loadBalancingConfig": [ { "round_robin": {} } ]}`
 	retryOpts := []grpc_retry.CallOption{
 		grpc_retry.WithBackoff(grpc_retry.BackoffExponential(100 * time.Millisecond)),
 		grpc_retry.WithMax(5),
 	}
 	maxSize := 5 * 1024 * 1024 * 1024
-	return grpc.Dial(address,
+	return grpc.Dial(address, append([]grpc.DialOption{
 		grpc.WithDefaultServiceConfig(serviceConfig), grpc.WithInsecure(),
 		grpc.WithDefaultCallOptions(grpc.WaitForReady(false),
 			grpc.MaxCallRecvMsgSize(maxSize),
 			grpc.MaxCallSendMsgSize(maxSize)),
-		grpc.WithUnaryInterceptor(grpc_retry.UnaryClientInterceptor(retryOpts...)))
+		grpc.WithUnaryInterceptor(grpc_retry.UnaryClientInterceptor(retryOpts...))}, dialOptions...)...)
 }
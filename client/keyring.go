@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"sort"
+	"time"
+)
+
+const keyringTimeout = 5 * time.Second
+
+// NodeKeys are the gossip keys of a node
+type NodeKeys struct {
+	NodeId  string
+	Address string
+	Primary []byte
+	Keys    [][]byte
+}
+
+// InstallGossipKey adds the key to the keyring of every node, the nodes decrypt with it but keep encrypting with their
+// primary key
+func (j *JetClient) InstallGossipKey(key []byte) error {
+	return j.forEachNode(func(ctx context.Context, node nodeClient) error {
+		_, err := node.InstallKey(ctx, &clusterPb.InstallKeyRequest{Key: key})
+		return err
+	})
+}
+
+// UseGossipKey makes the key the primary key of every node, it has to be installed on all of them first
+func (j *JetClient) UseGossipKey(key []byte) error {
+	return j.forEachNode(func(ctx context.Context, node nodeClient) error {
+		_, err := node.UseKey(ctx, &clusterPb.UseKeyRequest{Key: key})
+		return err
+	})
+}
+
+// RemoveGossipKey drops the key from every node once no node uses it anymore
+func (j *JetClient) RemoveGossipKey(key []byte) error {
+	return j.forEachNode(func(ctx context.Context, node nodeClient) error {
+		_, err := node.RemoveKey(ctx, &clusterPb.RemoveKeyRequest{Key: key})
+		return err
+	})
+}
+
+// ListGossipKeys returns the keys of every node sorted by node id, the nodes that couldn't be reached are in the error
+func (j *JetClient) ListGossipKeys() ([]*NodeKeys, error) {
+	var res []*NodeKeys
+	err := j.forEachNode(func(ctx context.Context, node nodeClient) error {
+		keys, err := node.ListKeys(ctx, &clusterPb.ListKeysRequest{})
+		if err != nil {
+			return err
+		}
+		res = append(res, &NodeKeys{NodeId: node.nodeId, Address: node.address, Primary: keys.PrimaryKey, Keys: keys.Keys})
+		return nil
+	})
+	sort.Slice(res, func(a, b int) bool {
+		return res[a].NodeId < res[b].NodeId
+	})
+	return res, err
+}
+
+type nodeClient struct {
+	clusterPb.NodeServiceClient
+	nodeId  string
+	address string
+}
+
+// forEachNode calls every node of the cluster once, even if it hosts several shards. It goes on after a node fails so
+// the error lists every node that wasn't changed
+func (j *JetClient) forEachNode(call func(ctx context.Context, node nodeClient) error) error {
+	if err := j.Refresh(); err != nil {
+		return err
+	}
+	nodes := map[string]string{}
+	j.getShardClients().ForEach(func(shardId string, shard *ShardClient) bool {
+		shard.memberclients.ForEach(func(nodeId string, member *MemberClient) bool {
+			nodes[member.address] = nodeId
+			return true
+		})
+		return true
+	})
+	var errs []error
+	for address, nodeId := range nodes {
+		conn, err := j.getConnection(address)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", nodeId, err))
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), keyringTimeout)
+		err = call(ctx, nodeClient{NodeServiceClient: clusterPb.NewNodeServiceClient(conn), nodeId: nodeId, address: address})
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", nodeId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"time"
+)
+
+// decommissionTimeout bounds a decommission, the node hands its leaderships over and waits for the leaders to remove it
+const decommissionTimeout = 5 * time.Minute
+
+// DecommissionNode takes the node out of its shards for good, or out of the given ones: it hands its leaderships over,
+// the leaders remove it from the raft groups and the shard info and it leaves the gossip. A shard is only left while
+// its voters left reachable keep a quorum, unless force is set. It returns the shards the node left
+func (j *JetClient) DecommissionNode(nodeId string, shardIds []string, force bool) ([]string, error) {
+	address, err := j.nodeAddress(nodeId)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := j.getConnection(address)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), decommissionTimeout)
+	defer cancel()
+	res, err := clusterPb.NewNodeServiceClient(conn).Decommission(ctx, &clusterPb.DecommissionRequest{ShardIds: shardIds, Force: force})
+	if err != nil {
+		return nil, err
+	}
+	return res.ShardIds, nil
+}
+
+// nodeAddress finds the address of the node among the members of the shards
+func (j *JetClient) nodeAddress(nodeId string) (string, error) {
+	if err := j.Refresh(); err != nil {
+		return "", err
+	}
+	address := ""
+	j.getShardClients().ForEach(func(shardId string, shard *ShardClient) bool {
+		if member := shard.memberclients.Get(nodeId); member != nil {
+			address = member.address
+			return false
+		}
+		return true
+	})
+	if address == "" {
+		return "", fmt.Errorf("node %s is not a member of any shard", nodeId)
+	}
+	return address, nil
+}
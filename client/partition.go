@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/Kapperchino/jet-stream/controller"
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"google.golang.org/grpc"
+	"time"
+)
+
+const reassignmentTimeout = 5 * time.Second
+
+var errNoController = errors.New("cluster has no controller, partitions can only be moved by a controller")
+
+// ReassignPartition asks the controller to move the partition to the target shard, the move runs in the background
+// and can be followed with GetReassignments
+func (j *JetClient) ReassignPartition(topic string, partition uint64, targetShard string) (*controllerPb.Reassignment, error) {
+	res, err := callController(j, &controllerPb.ReassignPartitionRequest{
+		Topic:       topic,
+		Partition:   partition,
+		TargetShard: targetShard,
+	}, controllerPb.ControllerServiceClient.ReassignPartition)
+	if err != nil {
+		return nil, err
+	}
+	return res.Reassignment, nil
+}
+
+// GetReassignments returns the moves known to the controller, or only the one with the id if it isn't empty
+func (j *JetClient) GetReassignments(id string) ([]*controllerPb.Reassignment, error) {
+	res, err := callController(j, &controllerPb.GetReassignmentsRequest{Id: id}, controllerPb.ControllerServiceClient.GetReassignments)
+	if err != nil {
+		return nil, err
+	}
+	return res.Reassignments, nil
+}
+
+// CancelReassignment stops a move that didn't switch the placement yet
+func (j *JetClient) CancelReassignment(id string) (*controllerPb.Reassignment, error) {
+	res, err := callController(j, &controllerPb.CancelReassignmentRequest{Id: id}, controllerPb.ControllerServiceClient.CancelReassignment)
+	if err != nil {
+		return nil, err
+	}
+	return res.Reassignment, nil
+}
+
+func callController[Req any, Res any](j *JetClient, req Req,
+	call func(controllerPb.ControllerServiceClient, context.Context, Req, ...grpc.CallOption) (Res, error)) (Res, error) {
+	var empty Res
+	controllerClient := j.getController()
+	if controllerClient == nil {
+		return empty, errNoController
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reassignmentTimeout)
+	defer cancel()
+	return controller.Call(ctx, controllerClient, req, call)
+}
+
+// Balance asks the controller for the moves that even out the shard load, they are started unless dryRun is set
+func (j *JetClient) Balance(dryRun bool) (*controllerPb.BalanceResponse, error) {
+	return callController(j, &controllerPb.BalanceRequest{DryRun: dryRun}, controllerPb.ControllerServiceClient.Balance)
+}
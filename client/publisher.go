@@ -4,14 +4,31 @@ import (
 	"context"
 	"errors"
 	"github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 	"strconv"
+	"time"
 )
 
-// PublishMessage need to get each partition's location within the cluster, then do consistent hashing with the key to get the partition needed
+// PublishMessage need to get each partition's location within the cluster, then do consistent hashing with the key to get the partition needed.
+// The trace context of the publish is put in the headers of the messages so their consumers can link to it
 func (j *JetClient) PublishMessage(messages []*proto.KeyVal, topic string) (*proto.PublishMessageResponse, error) {
-	meta := j.metaData.topics.Get(topic)
+	ctx, span := util.Tracer().Start(context.Background(), "jet.Publish", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("jet.topic", topic), attribute.Int("jet.messages", len(messages))))
+	defer span.End()
+	res, err := j.publish(ctx, messages, topic)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return res, err
+}
+
+func (j *JetClient) publish(ctx context.Context, messages []*proto.KeyVal, topic string) (*proto.PublishMessageResponse, error) {
+	meta := j.getMeta().topics.Get(topic)
 	if meta == nil {
 		return nil, errors.New("topic does not exist")
 	}
@@ -22,19 +39,30 @@ func (j *JetClient) PublishMessage(messages []*proto.KeyVal, topic string) (*pro
 		if err != nil {
 			return nil, err
 		}
+		message.Headers = util.InjectTraceContext(ctx, message.Headers)
 		bucket[partition] = append(bucket[partition], message)
 	}
 	publishGroup, _ := errgroup.WithContext(context.Background())
 	resChannel := make(chan *proto.PublishMessageResponse, len(bucket))
 	for partition, list := range bucket {
 		partition := partition
-		meta := meta.partitions.Get(partition)
 		list := list
-		client := j.shardClients.Get(meta.shardId)
-		messageClient := client.GetLeader().messageClient
 		publishGroup.Go(func() error {
 			log.Debug().Msgf("Client publishing batch size of %v to partition: %v", len(list), partition)
-			return PublishMessages(messageClient, topic, partition, list, resChannel)
+			//covers the routing, the redirects and the retries of the partition
+			ctx, span := util.Tracer().Start(ctx, "jet.PublishPartition", trace.WithAttributes(
+				attribute.Int64("jet.partition", int64(partition)), attribute.Int("jet.messages", len(list))))
+			defer span.End()
+			return j.withPartitionRetry(topic, partition, func(client *ShardClient) error {
+				client.waitThrottle()
+				res, err := PublishMessages(ctx, client.GetLeader().messageClient, topic, partition, list, resChannel)
+				if err != nil {
+					return err
+				}
+				client.throttle(time.Duration(res.ThrottleMs) * time.Millisecond)
+				client.updateLastIndex(res.LastIndex)
+				return nil
+			})
 		})
 	}
 	err := publishGroup.Wait()
@@ -43,25 +71,30 @@ func (j *JetClient) PublishMessage(messages []*proto.KeyVal, topic string) (*pro
 	}
 	close(resChannel)
 	var msgList []*proto.Message
+	lastIndex := uint64(0)
 	for response := range resChannel {
 		msgList = append(msgList, response.Messages...)
+		//indexes are per shard, this is only meaningful when a single shard was written to
+		if response.LastIndex > lastIndex {
+			lastIndex = response.LastIndex
+		}
 	}
 	return &proto.PublishMessageResponse{
 		Messages:  msgList,
-		LastIndex: 0,
+		LastIndex: lastIndex,
 	}, nil
 }
 
-func PublishMessages(client proto.MessageServiceClient, topicName string, partition uint64, list []*proto.KeyVal, channel chan *proto.PublishMessageResponse) error {
-	res, err := client.PublishMessages(context.Background(), &proto.PublishMessageRequest{
+func PublishMessages(ctx context.Context, client proto.MessageServiceClient, topicName string, partition uint64, list []*proto.KeyVal, channel chan *proto.PublishMessageResponse) (*proto.PublishMessageResponse, error) {
+	res, err := client.PublishMessages(ctx, &proto.PublishMessageRequest{
 		Topic:     topicName,
 		Partition: partition,
 		Messages:  list,
 	})
 	if err != nil {
 		log.Err(err).Stack().Msgf("Error publishing to topic %v,partition %v", topicName, partition)
-		return err
+		return nil, err
 	}
 	channel <- res
-	return nil
+	return res, nil
 }
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"github.com/Kapperchino/jet-stream/application/proto/proto"
+	"sort"
+)
+
+// SetQuotas replaces the quotas of their entities on every shard, each shard leader limits the publishes it gets
+func (j *JetClient) SetQuotas(quotas []*proto.Quota) error {
+	return j.forEachShard(func(ctx context.Context, shard *ShardClient) error {
+		_, err := shard.GetLeader().messageClient.PutQuotas(ctx, &proto.PutQuotasRequest{Quotas: quotas})
+		return err
+	})
+}
+
+// DeleteQuotas removes the quotas of the entities from every shard
+func (j *JetClient) DeleteQuotas(quotas []*proto.Quota) error {
+	return j.forEachShard(func(ctx context.Context, shard *ShardClient) error {
+		_, err := shard.GetLeader().messageClient.DeleteQuotas(ctx, &proto.DeleteQuotasRequest{Quotas: quotas})
+		return err
+	})
+}
+
+// ListQuotas returns the quotas found on any shard sorted by entity and name
+func (j *JetClient) ListQuotas() ([]*proto.Quota, error) {
+	quotas := map[proto.QuotaEntity]map[string]*proto.Quota{}
+	err := j.forEachShard(func(ctx context.Context, shard *ShardClient) error {
+		res, err := shard.GetLeader().messageClient.ListQuotas(ctx, &proto.ListQuotasRequest{})
+		if err != nil {
+			return err
+		}
+		for _, quota := range res.Quotas {
+			if quotas[quota.Entity] == nil {
+				quotas[quota.Entity] = map[string]*proto.Quota{}
+			}
+			quotas[quota.Entity][quota.Name] = quota
+		}
+		return nil
+	})
+	var res []*proto.Quota
+	for _, byName := range quotas {
+		for _, quota := range byName {
+			res = append(res, quota)
+		}
+	}
+	sort.Slice(res, func(a, b int) bool {
+		if res[a].Entity != res[b].Entity {
+			return res[a].Entity < res[b].Entity
+		}
+		return res[a].Name < res[b].Name
+	})
+	return res, err
+}
@@ -0,0 +1,342 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/application/proto/proto"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/controller"
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+const (
+	refreshInterval    = 30 * time.Second
+	clusterInfoTimeout = 2 * time.Second
+	maxAttempts        = 10
+	retryBackoff       = 250 * time.Millisecond
+	// controllerTimeout covers the controller waiting for the shards to create a topic or a consumer group
+	controllerTimeout = 15 * time.Second
+	// maxThrottleWait caps the time a call waits for the quotas, the throttled error is returned after
+	maxThrottleWait = 30 * time.Second
+)
+
+var errNoMembers = errors.New("shard has no known members")
+
+// Refresh reloads the cluster info and the topic meta from the cluster, safe to call concurrently
+func (j *JetClient) Refresh() error {
+	j.refreshMutex.Lock()
+	defer j.refreshMutex.Unlock()
+	clusterInfo, err := j.fetchClusterInfo()
+	if err != nil {
+		log.Err(err).Msgf("Error getting cluster info")
+		return err
+	}
+	shardClients, err := j.newShardClients(clusterInfo)
+	if err != nil {
+		return err
+	}
+	controllerClient := j.getController()
+	if controllerClient == nil && len(clusterInfo.Controllers) > 0 {
+		controllerClient = controller.NewClient(clusterInfo.Controllers, j.dialOptions...)
+	}
+	var placements map[string]*controllerPb.TopicPlacement
+	if controllerClient != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterInfoTimeout)
+		res, err := controller.Call(ctx, controllerClient, &controllerPb.GetTopicsRequest{}, controllerPb.ControllerServiceClient.GetTopics)
+		cancel()
+		if err != nil {
+			log.Err(err).Msgf("Error getting topics from the controller")
+			return err
+		}
+		placements = res.Topics
+	}
+	metaData, err := loadMeta(shardClients, placements)
+	if err != nil {
+		return err
+	}
+	j.mutex.Lock()
+	j.info = clusterInfo
+	j.shardClients = shardClients
+	j.metaData = metaData
+	j.controller = controllerClient
+	j.mutex.Unlock()
+	select {
+	case <-j.stop:
+	default:
+		j.startWatchers(shardClients)
+	}
+	return nil
+}
+
+// fetchClusterInfo asks the seeds and every known member for the cluster info, the first answer wins
+func (j *JetClient) fetchClusterInfo() (*clusterPb.ClusterInfo, error) {
+	addresses := append([]string{}, j.seeds...)
+	if old := j.getShardClients(); old != nil {
+		old.ForEach(func(shardId string, client *ShardClient) bool {
+			client.memberclients.ForEach(func(nodeId string, member *MemberClient) bool {
+				addresses = append(addresses, member.address)
+				return true
+			})
+			return true
+		})
+	}
+	var lastErr error
+	for _, address := range addresses {
+		con, err := j.getConnection(address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), clusterInfoTimeout)
+		res, err := newClusterClient(con, "").GetClusterInfo(ctx, &clusterPb.GetClusterInfoRequest{})
+		cancel()
+		if err != nil {
+			log.Debug().Err(err).Msgf("Error getting cluster info from %s", address)
+			lastErr = err
+			continue
+		}
+		return res.Info, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no address to get the cluster info from")
+	}
+	return nil, lastErr
+}
+
+func (j *JetClient) newShardClients(clusterInfo *clusterPb.ClusterInfo) (*util.Map[string, *ShardClient], error) {
+	old := j.getShardClients()
+	shardClients := util.NewMap[string, *ShardClient]()
+	for shardId, shard := range clusterInfo.ShardMap {
+		shardClient := &ShardClient{
+			leader:        shard.LeaderId,
+			memberclients: util.NewMap[string, *MemberClient](),
+			partitions:    util.NewMap[uint64, *PartitionMeta](),
+			shardId:       shardId,
+		}
+		//keep the read-your-writes index across refreshes
+		if old != nil {
+			if oldClient := old.Get(shardId); oldClient != nil {
+				shardClient.lastIndex.Store(oldClient.lastIndex.Load())
+				shardClient.throttledUntil.Store(oldClient.throttledUntil.Load())
+			}
+		}
+		for nodeId, member := range shard.MemberAddressMap {
+			con, err := j.getConnection(member.Address)
+			if err != nil {
+				log.Err(err).Msgf("Error creating connection")
+				return nil, err
+			}
+			shardClient.memberclients.Set(nodeId, &MemberClient{
+				nodeId:        nodeId,
+				address:       member.Address,
+				clusterClient: newClusterClient(con, shardId),
+				messageClient: newMessageClient(con, shardId),
+			})
+		}
+		shardClients.Set(shardId, shardClient)
+	}
+	return shardClients, nil
+}
+
+// loadMeta builds the routing tables from the leaders, a follower might not have applied the last writes yet. Topic
+// placement comes from the controller when there is one since shards might only host part of a topic that is still
+// being created
+func loadMeta(shardClients *util.Map[string, *ShardClient], placements map[string]*controllerPb.TopicPlacement) (*Meta, error) {
+	ctx := context.Background()
+	metaData := &Meta{topics: util.NewMap[string, *TopicMeta](), consumerGroups: util.NewMap[string, *ConsumerGroup]()}
+	var err error
+	shardClients.ForEach(func(shardId string, client *ShardClient) bool {
+		member := client.GetLeader()
+		if member == nil {
+			return true
+		}
+		var meta *proto.GetMetaResponse
+		meta, err = member.messageClient.GetMeta(ctx, &proto.GetMetaRequest{})
+		if err != nil {
+			log.Err(err).Msgf("Error while getting meta from shard %s", shardId)
+			return false
+		}
+		if placements == nil {
+			for key, topic := range meta.Topics {
+				for num := range topic.Partitions {
+					metaData.addPartition(client, key, num)
+				}
+			}
+		}
+		for id, group := range meta.ConsumerGroups {
+			metaData.consumerGroups.Set(id, &ConsumerGroup{
+				group: group,
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for name, topic := range placements {
+		if topic.State != controllerPb.TopicState_READY {
+			continue
+		}
+		for num, partition := range topic.Partitions {
+			if client := shardClients.Get(partition.ShardId); client != nil {
+				metaData.addPartition(client, name, num)
+			}
+		}
+	}
+	return metaData, nil
+}
+
+func (m *Meta) addPartition(client *ShardClient, topic string, num uint64) {
+	item := m.topics.Get(topic)
+	if item == nil {
+		item = &TopicMeta{
+			partitions: util.NewMap[uint64, *PartitionMeta](),
+			hash:       newHashRing(),
+		}
+		m.topics.Set(topic, item)
+	}
+	partitionMeta := &PartitionMeta{
+		partitionNum: num,
+		topic:        topic,
+		shardId:      client.shardId,
+	}
+	item.hash.Add(partitionMeta)
+	item.partitions.Set(num, partitionMeta)
+	client.partitions.Set(num, partitionMeta)
+}
+
+func (j *JetClient) getConnection(address string) (*grpc.ClientConn, error) {
+	con := j.connections.Get(address)
+	if con != nil {
+		return con, nil
+	}
+	con, err := newClientConnection(address, j.dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	j.connections.Set(address, con)
+	return con, nil
+}
+
+func (j *JetClient) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+			if err := j.Refresh(); err != nil {
+				log.Warn().Err(err).Msgf("Periodic refresh of the cluster meta failed")
+			}
+		}
+	}
+}
+
+// withRetry runs op against the shard, on a leader change or an unavailable member the meta is refreshed and op is
+// retried
+func (j *JetClient) withRetry(shardId string, op func(client *ShardClient) error) error {
+	return j.withRetryResolve(func() (string, error) {
+		return shardId, nil
+	}, op)
+}
+
+// withPartitionRetry is withRetry for a single partition, the shard is looked up again on every attempt since the
+// partition might have been moved to another shard
+func (j *JetClient) withPartitionRetry(topic string, partition uint64, op func(client *ShardClient) error) error {
+	return j.withRetryResolve(func() (string, error) {
+		meta := j.getMeta().topics.Get(topic)
+		if meta == nil {
+			return "", errors.New("topic does not exist")
+		}
+		partitionMeta := meta.partitions.Get(partition)
+		if partitionMeta == nil {
+			return "", fmt.Errorf("partition %v of topic %s is not in the meta", partition, topic)
+		}
+		return partitionMeta.shardId, nil
+	}, op)
+}
+
+func (j *JetClient) withRetryResolve(resolve func() (string, error), op func(client *ShardClient) error) error {
+	var err error
+	var throttled time.Duration
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		shardId, resolveErr := resolve()
+		if resolveErr != nil {
+			return resolveErr
+		}
+		client := j.getShardClients().Get(shardId)
+		if client == nil {
+			return errors.New("shard needs to be in the meta")
+		}
+		if client.memberclients.Len() == 0 {
+			err = errNoMembers
+		} else {
+			err = op(client)
+		}
+		//a throttled call waits for the quota without counting as a failed attempt, until it waited maxThrottleWait
+		if delay := throttledFromError(err); delay > 0 {
+			throttled += delay
+			if throttled > maxThrottleWait {
+				return err
+			}
+			client.throttle(delay)
+			attempt--
+			continue
+		}
+		if err == nil || !isRetriable(err) {
+			return err
+		}
+		if notLeader := notLeaderFromError(err); notLeader != nil && client.setLeader(notLeader.LeaderId) {
+			log.Debug().Msgf("Redirected to leader %s of shard %s", notLeader.LeaderId, shardId)
+			continue
+		}
+		time.Sleep(retryBackoff)
+		if refreshErr := j.Refresh(); refreshErr != nil {
+			log.Warn().Err(refreshErr).Msgf("Error refreshing the cluster meta")
+		}
+	}
+	return err
+}
+
+func isRetriable(err error) bool {
+	if errors.Is(err, errNoMembers) {
+		return true
+	}
+	return status.Code(err) == codes.Unavailable
+}
+
+// throttledFromError returns the delay of a call rejected by a quota, 0 for other errors
+func throttledFromError(err error) time.Duration {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.ResourceExhausted {
+		return 0
+	}
+	for _, detail := range st.Details() {
+		if throttled, ok := detail.(*proto.Throttled); ok {
+			return time.Duration(throttled.DelayMs) * time.Millisecond
+		}
+	}
+	return 0
+}
+
+// notLeaderFromError returns the redirect attached by a follower that couldn't serve the request
+func notLeaderFromError(err error) *proto.NotLeader {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, detail := range st.Details() {
+		if notLeader, ok := detail.(*proto.NotLeader); ok {
+			return notLeader
+		}
+	}
+	return nil
+}
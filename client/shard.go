@@ -0,0 +1,45 @@
+package client
+
+import (
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+)
+
+// SplitShard asks the controller to start a new shard on the nodes of the shard and move the partitions to it, every
+// other partition of the shard is moved if partitions is empty
+func (j *JetClient) SplitShard(shardId string, newShardId string, partitions map[string][]uint64) (*controllerPb.ShardOperation, error) {
+	req := &controllerPb.SplitShardRequest{
+		ShardId:    shardId,
+		NewShardId: newShardId,
+		Partitions: map[string]*controllerPb.PartitionList{},
+	}
+	for topic, list := range partitions {
+		req.Partitions[topic] = &controllerPb.PartitionList{Partitions: list}
+	}
+	res, err := callController(j, req, controllerPb.ControllerServiceClient.SplitShard)
+	if err != nil {
+		return nil, err
+	}
+	return res.Operation, nil
+}
+
+// MergeShards asks the controller to move every partition of the source shard to the target and stop the source
+func (j *JetClient) MergeShards(sourceShard string, targetShard string) (*controllerPb.ShardOperation, error) {
+	res, err := callController(j, &controllerPb.MergeShardsRequest{
+		SourceShard: sourceShard,
+		TargetShard: targetShard,
+	}, controllerPb.ControllerServiceClient.MergeShards)
+	if err != nil {
+		return nil, err
+	}
+	return res.Operation, nil
+}
+
+// GetShardOperations returns the splits and merges known to the controller, or only the one with the id if it isn't
+// empty
+func (j *JetClient) GetShardOperations(id string) ([]*controllerPb.ShardOperation, error) {
+	res, err := callController(j, &controllerPb.GetShardOperationsRequest{Id: id}, controllerPb.ControllerServiceClient.GetShardOperations)
+	if err != nil {
+		return nil, err
+	}
+	return res.Operations, nil
+}
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"context"
+	"errors"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/auth"
+	"github.com/Kapperchino/jet-stream/client"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/factory"
+	raftadminPb "github.com/Kapperchino/jet-stream/raftadmin/proto/proto"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// two nodes checking acls, admin is a superuser and alice only gets what the acls allow
+type ClientTestACL struct {
+	suite.Suite
+	address [2]string
+	servers []*factory.Server
+	admin   *client.JetClient
+}
+
+func (suite *ClientTestACL) SetupSuite() {
+	suite.address = [2]string{"localhost:8164", "localhost:8166"}
+	gossip := [2]string{"localhost:8165", "localhost:8167"}
+	authConfig := &auth.Config{Tokens: map[string]string{
+		"admin-token": "admin",
+		"alice-token": "alice",
+		"node-token":  "node",
+	}}
+	channel := make(chan *factory.Server, 5)
+	log.Print("Starting the servers")
+	for x, name := range []string{"nodeA", "nodeB"} {
+		rootNode := ""
+		if x > 0 {
+			rootNode = gossip[0]
+		}
+		go factory.SetupServer(&factory.JetConfig{
+			HostAddr:      suite.address[x],
+			GlobalAdr:     suite.address[x],
+			NodeName:      name,
+			GossipAddress: gossip[x],
+			RootNode:      rootNode,
+			Server:        channel,
+			ShardId:       "shardACL",
+			InMemory:      true,
+			Auth:          authConfig,
+			NodeToken:     "node-token",
+			ACL:           true,
+			Superusers:    []string{"admin"},
+		})
+		suite.servers = append(suite.servers, <-channel)
+		time.Sleep(5 * time.Second)
+	}
+	var err error
+	suite.admin, err = client.New(suite.address[0], client.WithToken("admin-token"))
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *ClientTestACL) TearDownSuite() {
+	suite.admin.Close()
+	for _, server := range suite.servers {
+		server.Kill()
+	}
+}
+
+// dial connects as alice to the node, without going through the client meta
+func (suite *ClientTestACL) dial(address string) *grpc.ClientConn {
+	conn, err := grpc.Dial(address, grpc.WithInsecure(), auth.TokenCredentials{Token: "alice-token", AllowInsecure: true}.DialOption())
+	assert.Nil(suite.T(), err)
+	return conn
+}
+
+func (suite *ClientTestACL) publish(conn *grpc.ClientConn, topic string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := pb.NewMessageServiceClient(conn).PublishMessages(ctx, &pb.PublishMessageRequest{
+		Topic:    topic,
+		Messages: []*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}},
+	})
+	return err
+}
+
+func (suite *ClientTestACL) TestTopicAcls() {
+	for _, topic := range []string{"orders.eu", "payments"} {
+		_, err := suite.admin.CreateTopic(topic, 1)
+		assert.Nil(suite.T(), err)
+	}
+	acl := &pb.Acl{Principal: "alice", Resource: pb.AclResource_TOPIC, Pattern: "orders.*", Operation: pb.AclOperation_WRITE}
+	//every node checks the calls it gets, the follower before forwarding them
+	for _, address := range suite.address {
+		conn := suite.dial(address)
+		assert.Equal(suite.T(), codes.PermissionDenied, status.Code(suite.publish(conn, "orders.eu")))
+		conn.Close()
+	}
+	assert.Nil(suite.T(), suite.admin.PutAcls([]*pb.Acl{acl}))
+	for _, address := range suite.address {
+		conn := suite.dial(address)
+		assert.Eventually(suite.T(), func() bool {
+			return suite.publish(conn, "orders.eu") == nil
+		}, 5*time.Second, 100*time.Millisecond)
+		assert.Equal(suite.T(), codes.PermissionDenied, status.Code(suite.publish(conn, "payments")))
+		conn.Close()
+	}
+
+	//alice only sees the topics she has an acl on and can't manage the acls
+	alice, err := client.New(suite.address[0], client.WithToken("alice-token"))
+	assert.Nil(suite.T(), err)
+	_, err = alice.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}}, "orders.eu")
+	assert.Nil(suite.T(), err)
+	_, err = alice.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}}, "payments")
+	assert.NotNil(suite.T(), err)
+	assert.NotNil(suite.T(), alice.PutAcls([]*pb.Acl{{Principal: "alice", Resource: pb.AclResource_CLUSTER, Pattern: "*", Operation: pb.AclOperation_ADMIN}}))
+	alice.Close()
+
+	acls, err := suite.admin.ListAcls("alice")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, len(acls))
+	assert.Nil(suite.T(), suite.admin.DeleteAcls([]*pb.Acl{acl}))
+	for _, address := range suite.address {
+		conn := suite.dial(address)
+		assert.Eventually(suite.T(), func() bool {
+			return status.Code(suite.publish(conn, "orders.eu")) == codes.PermissionDenied
+		}, 5*time.Second, 100*time.Millisecond)
+		conn.Close()
+	}
+}
+
+func (suite *ClientTestACL) TestInvalidAcls() {
+	for _, acl := range []*pb.Acl{
+		{Resource: pb.AclResource_TOPIC, Pattern: "orders", Operation: pb.AclOperation_READ},
+		{Principal: "alice", Resource: pb.AclResource_TOPIC, Pattern: "or*ders", Operation: pb.AclOperation_READ},
+		{Principal: "alice", Resource: pb.AclResource_CLUSTER, Pattern: "orders", Operation: pb.AclOperation_READ},
+	} {
+		assert.Equal(suite.T(), codes.InvalidArgument, statusCode(suite.admin.PutAcls([]*pb.Acl{acl})))
+	}
+}
+
+func (suite *ClientTestACL) TestRaftAdminAcls() {
+	conn := suite.dial(suite.address[0])
+	defer conn.Close()
+	admin := raftadminPb.NewRaftAdminClient(conn)
+	_, err := admin.Stats(context.Background(), &raftadminPb.StatsRequest{})
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+
+	acl := &pb.Acl{Principal: "alice", Resource: pb.AclResource_CLUSTER, Pattern: "*", Operation: pb.AclOperation_READ}
+	assert.Nil(suite.T(), suite.admin.PutAcls([]*pb.Acl{acl}))
+	defer suite.admin.DeleteAcls([]*pb.Acl{acl})
+	assert.Eventually(suite.T(), func() bool {
+		_, err = admin.Stats(context.Background(), &raftadminPb.StatsRequest{})
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+	//reading the raft group doesn't allow changing it
+	_, err = admin.LeadershipTransfer(context.Background(), &raftadminPb.LeadershipTransferRequest{})
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+}
+
+// the node service hosts, drops and decommissions shards and manages the gossip keys, only the cluster admins may
+// call it
+func (suite *ClientTestACL) TestNodeServiceAcls() {
+	conn := suite.dial(suite.address[1])
+	defer conn.Close()
+	node := clusterPb.NewNodeServiceClient(conn)
+	_, err := node.DropShard(context.Background(), &clusterPb.DropShardRequest{ShardId: "shardACL"})
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+	_, err = node.HostShard(context.Background(), &clusterPb.HostShardRequest{ShardId: "shardOther",
+		Members: map[string]string{"nodeB": suite.address[1]}})
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+	_, err = node.GetHostedShards(context.Background(), &clusterPb.GetHostedShardsRequest{})
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+	_, err = node.ListKeys(context.Background(), &clusterPb.ListKeysRequest{})
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+	_, err = node.InstallKey(context.Background(), &clusterPb.InstallKeyRequest{Key: make([]byte, 32)})
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+	_, err = node.Decommission(context.Background(), &clusterPb.DecommissionRequest{})
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+
+	acl := &pb.Acl{Principal: "alice", Resource: pb.AclResource_CLUSTER, Pattern: "*", Operation: pb.AclOperation_ADMIN}
+	assert.Nil(suite.T(), suite.admin.PutAcls([]*pb.Acl{acl}))
+	defer suite.admin.DeleteAcls([]*pb.Acl{acl})
+	assert.Eventually(suite.T(), func() bool {
+		res, err := node.GetHostedShards(context.Background(), &clusterPb.GetHostedShardsRequest{})
+		return err == nil && len(res.GetShardIds()) == 1
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+// statusCode finds the status in the errors the client joins for every shard
+func statusCode(err error) codes.Code {
+	var withStatus interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &withStatus) {
+		return withStatus.GRPCStatus().Code()
+	}
+	return status.Code(err)
+}
+
+func TestACL(t *testing.T) {
+	suite.Run(t, new(ClientTestACL))
+}
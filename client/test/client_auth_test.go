@@ -0,0 +1,108 @@
+package test
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/auth"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"testing"
+	"time"
+)
+
+// two nodes that only accept callers with a token, the nodes call each other with their own token
+type ClientTestAuth struct {
+	suite.Suite
+	address [2]string
+	servers []*factory.Server
+}
+
+func (suite *ClientTestAuth) SetupSuite() {
+	suite.address = [2]string{"localhost:8160", "localhost:8162"}
+	gossip := [2]string{"localhost:8161", "localhost:8163"}
+	authConfig := &auth.Config{Tokens: map[string]string{
+		"publisher-token": "publisher",
+		"node-token":      "node",
+	}}
+	channel := make(chan *factory.Server, 5)
+	log.Print("Starting the servers")
+	for x, name := range []string{"nodeA", "nodeB"} {
+		rootNode := ""
+		if x > 0 {
+			rootNode = gossip[0]
+		}
+		go factory.SetupServer(&factory.JetConfig{
+			HostAddr:      suite.address[x],
+			GlobalAdr:     suite.address[x],
+			NodeName:      name,
+			GossipAddress: gossip[x],
+			RootNode:      rootNode,
+			Server:        channel,
+			ShardId:       "shardAuth",
+			InMemory:      true,
+			Auth:          authConfig,
+			NodeToken:     "node-token",
+		})
+		suite.servers = append(suite.servers, <-channel)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (suite *ClientTestAuth) TearDownSuite() {
+	for _, server := range suite.servers {
+		server.Kill()
+	}
+}
+
+func (suite *ClientTestAuth) TestRejectsClientsWithoutToken() {
+	_, err := client.New(suite.address[0])
+	assert.Equal(suite.T(), codes.Unauthenticated, status.Code(err))
+	_, err = client.New(suite.address[0], client.WithToken("wrong-token"))
+	assert.Equal(suite.T(), codes.Unauthenticated, status.Code(err))
+}
+
+func (suite *ClientTestAuth) TestPublishWithToken() {
+	const TOPIC = "TestAuthPublish"
+	jetClient, err := client.New(suite.address[1], client.WithToken("publisher-token"))
+	assert.Nil(suite.T(), err)
+	defer jetClient.Close()
+	_, err = jetClient.CreateTopic(TOPIC, 1)
+	assert.Nil(suite.T(), err)
+	_, err = jetClient.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}}, TOPIC)
+	assert.Nil(suite.T(), err)
+	//the follower joined through the node service with the node token
+	for _, server := range suite.servers {
+		future := server.Raft.GetConfiguration()
+		assert.Nil(suite.T(), future.Error())
+		assert.Equal(suite.T(), 2, len(future.Configuration().Servers))
+	}
+}
+
+// the raft transport shares the port of the clients, only the nodes call it
+func (suite *ClientTestAuth) TestRejectsRaftCallsFromClients() {
+	const method = "/transport.RaftTransport/RequestVote"
+	conn, err := grpc.Dial(suite.address[0], grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	err = conn.Invoke(context.Background(), method, &emptypb.Empty{}, &emptypb.Empty{})
+	assert.Equal(suite.T(), codes.Unauthenticated, status.Code(err))
+
+	publisher, err := grpc.Dial(suite.address[0], grpc.WithTransportCredentials(insecure.NewCredentials()),
+		auth.TokenCredentials{Token: "publisher-token", AllowInsecure: true}.DialOption())
+	assert.Nil(suite.T(), err)
+	defer publisher.Close()
+	err = publisher.Invoke(context.Background(), method, &emptypb.Empty{}, &emptypb.Empty{})
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+}
+
+func TestAuthentication(t *testing.T) {
+	suite.Run(t, new(ClientTestAuth))
+}
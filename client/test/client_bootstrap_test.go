@@ -0,0 +1,103 @@
+package test
+
+import (
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type ClientTestBootstrap struct {
+	suite.Suite
+	address       [3]string
+	gossipAddress [3]string
+	nodeName      [3]string
+	servers       []*factory.Server
+}
+
+// the nodes start at the same time, none of them is asked to bootstrap alone
+func (suite *ClientTestBootstrap) SetupSuite() {
+	suite.address = [3]string{"localhost:8188", "localhost:8190", "localhost:8192"}
+	suite.gossipAddress = [3]string{"localhost:8189", "localhost:8191", "localhost:8193"}
+	suite.nodeName = [3]string{"nodeA", "nodeB", "nodeC"}
+	servers := make(chan *factory.Server, 5)
+	log.Print("Starting the servers")
+	for x := range suite.address {
+		rootNode := ""
+		if x > 0 {
+			rootNode = suite.gossipAddress[0]
+		}
+		go factory.SetupServer(
+			&factory.JetConfig{
+				HostAddr:        suite.address[x],
+				GlobalAdr:       suite.address[x],
+				NodeName:        suite.nodeName[x],
+				GossipAddress:   suite.gossipAddress[x],
+				RootNode:        rootNode,
+				Server:          servers,
+				ShardId:         "shardBootstrap",
+				InMemory:        true,
+				BootstrapExpect: 3,
+			})
+		suite.servers = append(suite.servers, <-servers)
+	}
+	time.Sleep(10 * time.Second)
+}
+
+func (suite *ClientTestBootstrap) TearDownSuite() {
+	for _, server := range suite.servers {
+		if server.Raft.State() != raft.Shutdown {
+			server.Kill()
+		}
+	}
+}
+
+// the group is bootstrapped once the three voters are in the gossip, they all end up in one configuration
+func (suite *ClientTestBootstrap) TestBootstrapExpect() {
+	leaders := 0
+	for _, server := range suite.servers {
+		if server.Raft.State() == raft.Leader {
+			leaders++
+		}
+		future := server.Raft.GetConfiguration()
+		assert.Nil(suite.T(), future.Error())
+		var ids []string
+		for _, member := range future.Configuration().Servers {
+			assert.Equal(suite.T(), raft.Voter, member.Suffrage)
+			ids = append(ids, string(member.ID))
+		}
+		assert.ElementsMatch(suite.T(), suite.nodeName[:], ids)
+	}
+	assert.Equal(suite.T(), 1, leaders)
+}
+
+// a node that starts after the bootstrap joins through the leader instead
+func (suite *ClientTestBootstrap) TestJoinAfterBootstrap() {
+	servers := make(chan *factory.Server, 1)
+	go factory.SetupServer(
+		&factory.JetConfig{
+			HostAddr:        "localhost:8194",
+			GlobalAdr:       "localhost:8194",
+			NodeName:        "nodeD",
+			GossipAddress:   "localhost:8195",
+			RootNode:        suite.gossipAddress[0],
+			Server:          servers,
+			ShardId:         "shardBootstrap",
+			InMemory:        true,
+			BootstrapExpect: 3,
+		})
+	server := <-servers
+	suite.servers = append(suite.servers, server)
+	assert.Eventually(suite.T(), func() bool {
+		future := server.Raft.GetConfiguration()
+		return future.Error() == nil && len(future.Configuration().Servers) == 4
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.NotEqual(suite.T(), raft.Leader, server.Raft.State())
+}
+
+func TestBootstrap(t *testing.T) {
+	suite.Run(t, new(ClientTestBootstrap))
+}
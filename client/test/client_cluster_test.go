@@ -0,0 +1,154 @@
+package test
+
+import (
+	"github.com/Kapperchino/jet-stream/client"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+const adminShard = "shardAdmin"
+
+type ClientTestCluster struct {
+	suite.Suite
+	client        *client.JetClient
+	address       [3]string
+	gossipAddress [3]string
+	nodeName      [3]string
+	servers       []*factory.Server
+}
+
+func (suite *ClientTestCluster) SetupSuite() {
+	suite.address = [3]string{"localhost:8202", "localhost:8204", "localhost:8206"}
+	suite.gossipAddress = [3]string{"localhost:8203", "localhost:8205", "localhost:8207"}
+	suite.nodeName = [3]string{"nodeA", "nodeB", "nodeC"}
+	servers := make(chan *factory.Server, 5)
+	log.Print("Starting the servers")
+	for x := range suite.address {
+		rootNode := ""
+		if x > 0 {
+			rootNode = suite.gossipAddress[0]
+		}
+		go factory.SetupServer(
+			&factory.JetConfig{
+				HostAddr:      suite.address[x],
+				GlobalAdr:     suite.address[x],
+				NodeName:      suite.nodeName[x],
+				GossipAddress: suite.gossipAddress[x],
+				RootNode:      rootNode,
+				Server:        servers,
+				ShardId:       adminShard,
+				InMemory:      true,
+			})
+		suite.servers = append(suite.servers, <-servers)
+		time.Sleep(5 * time.Second)
+	}
+	jetClient, err := client.New(suite.address[0])
+	assert.Nil(suite.T(), err)
+	suite.client = jetClient
+}
+
+func (suite *ClientTestCluster) TearDownSuite() {
+	suite.client.Close()
+	for _, server := range suite.servers {
+		if server.Raft.State() != raft.Shutdown {
+			server.Kill()
+		}
+	}
+}
+
+func (suite *ClientTestCluster) leader() string {
+	for x, server := range suite.servers {
+		if server.Raft.State() == raft.Leader {
+			return suite.nodeName[x]
+		}
+	}
+	return ""
+}
+
+func (suite *ClientTestCluster) voters() []string {
+	members, err := suite.client.GetShardMembers(adminShard)
+	assert.Nil(suite.T(), err)
+	var ids []string
+	for _, member := range members {
+		if member.Suffrage == "VOTER" {
+			ids = append(ids, member.NodeId)
+		}
+	}
+	return ids
+}
+
+func (suite *ClientTestCluster) TestMembers() {
+	info, err := suite.client.GetClusterInfo()
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), info.GetShardMap(), adminShard)
+
+	leader, err := suite.client.GetShardLeader(adminShard)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), suite.leader(), leader.GetNodeId())
+
+	members, err := suite.client.GetShardMembers(adminShard)
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), members, 3)
+	for x, member := range members {
+		assert.Equal(suite.T(), suite.nodeName[x], member.NodeId)
+		assert.Equal(suite.T(), suite.address[x], member.Address)
+		assert.Equal(suite.T(), clusterPb.MemberRole_VOTER, member.Role)
+		assert.Equal(suite.T(), "VOTER", member.Suffrage)
+		assert.Equal(suite.T(), member.NodeId == leader.GetNodeId(), member.Leader)
+	}
+}
+
+// the leadership goes to the node asked for
+func (suite *ClientTestCluster) TestTransferLeadership() {
+	target := "nodeB"
+	if suite.leader() == target {
+		target = "nodeC"
+	}
+	assert.Nil(suite.T(), suite.client.TransferLeadership(adminShard, target))
+	assert.Eventually(suite.T(), func() bool {
+		return suite.leader() == target
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+// a follower is removed from the raft group and added back as a voter
+func (suite *ClientTestCluster) TestRemoveAndAddVoter() {
+	follower := "nodeA"
+	if suite.leader() == follower {
+		follower = "nodeB"
+	}
+	assert.Nil(suite.T(), suite.client.RemoveVoter(adminShard, follower, false))
+	assert.NotContains(suite.T(), suite.voters(), follower)
+
+	address := suite.address[0]
+	if follower == "nodeB" {
+		address = suite.address[1]
+	}
+	assert.Nil(suite.T(), suite.client.AddVoter(adminShard, follower, address))
+	assert.ElementsMatch(suite.T(), suite.nodeName[:], suite.voters())
+	//the leader puts it back in the shard info once it observes the change
+	assert.Eventually(suite.T(), func() bool {
+		info, err := suite.client.GetShardInfo(adminShard)
+		return err == nil && len(info.GetMemberAddressMap()) == 3
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func (suite *ClientTestCluster) TestSnapshotAndStats() {
+	assert.Nil(suite.T(), suite.client.Snapshot(adminShard, "nodeC"))
+	stats, err := suite.client.GetRaftStats(adminShard, "nodeC")
+	assert.Nil(suite.T(), err)
+	assert.NotEqual(suite.T(), "0", stats["last_snapshot_index"])
+
+	stats, err = suite.client.GetRaftStats(adminShard, "")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "Leader", stats["state"])
+}
+
+func TestCluster(t *testing.T) {
+	suite.Run(t, new(ClientTestCluster))
+}
@@ -0,0 +1,191 @@
+package test
+
+import (
+	"context"
+	"github.com/Kapperchino/jet-stream/application"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+const consistencyShard = "shardConsistency"
+
+// three voters, the reads with each consistency are sent to a follower
+type ClientTestConsistency struct {
+	suite.Suite
+	client        *client.JetClient
+	address       [3]string
+	gossipAddress [3]string
+	servers       []*factory.Server
+}
+
+func (suite *ClientTestConsistency) SetupSuite() {
+	suite.address = [3]string{"localhost:8240", "localhost:8242", "localhost:8244"}
+	suite.gossipAddress = [3]string{"localhost:8241", "localhost:8243", "localhost:8245"}
+	servers := make(chan *factory.Server, 5)
+	log.Print("Starting the servers")
+	suite.T().Cleanup(func() {
+		for _, server := range suite.servers {
+			if server.Raft.State() != raft.Shutdown {
+				server.Kill()
+			}
+		}
+	})
+	for x, name := range []string{"nodeA", "nodeB", "nodeC"} {
+		rootNode := ""
+		if x > 0 {
+			rootNode = suite.gossipAddress[0]
+		}
+		go factory.SetupServer(&factory.JetConfig{
+			HostAddr:      suite.address[x],
+			GlobalAdr:     suite.address[x],
+			NodeName:      name,
+			GossipAddress: suite.gossipAddress[x],
+			RootNode:      rootNode,
+			Server:        servers,
+			ShardId:       consistencyShard,
+			InMemory:      true,
+		})
+		suite.servers = append(suite.servers, <-servers)
+		assert.Eventually(suite.T(), func() bool {
+			leader := suite.leader()
+			if leader == nil {
+				return false
+			}
+			future := leader.Raft.GetConfiguration()
+			return future.Error() == nil && len(future.Configuration().Servers) == x+1
+		}, 15*time.Second, 100*time.Millisecond)
+	}
+	var err error
+	suite.client, err = client.New(suite.address[0])
+	assert.Nil(suite.T(), err)
+	suite.T().Cleanup(suite.client.Close)
+}
+
+func (suite *ClientTestConsistency) leader() *factory.Server {
+	for _, server := range suite.servers {
+		if server.Raft.State() == raft.Leader {
+			return server
+		}
+	}
+	return nil
+}
+
+// follower returns a follower with its message service
+func (suite *ClientTestConsistency) follower() (*factory.Server, pb.MessageServiceClient) {
+	for x, server := range suite.servers {
+		if server.Raft.State() == raft.Follower {
+			conn, err := grpc.Dial(suite.address[x], grpc.WithTransportCredentials(insecure.NewCredentials()))
+			assert.Nil(suite.T(), err)
+			suite.T().Cleanup(func() { _ = conn.Close() })
+			return server, pb.NewMessageServiceClient(util.ShardConn(conn, consistencyShard))
+		}
+	}
+	suite.T().Fatal("no follower")
+	return nil, nil
+}
+
+// topic creates a topic with a message and a group, and returns the group with the index of the publish
+func (suite *ClientTestConsistency) topic(name string) (string, uint64) {
+	_, err := suite.client.CreateTopic(name, 1)
+	assert.Nil(suite.T(), err)
+	group, err := suite.client.CreateConsumerGroup(name)
+	assert.Nil(suite.T(), err)
+	res, err := suite.client.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}}, name)
+	assert.Nil(suite.T(), err)
+	return group.Id, res.LastIndex
+}
+
+func consumeRequest(topic string, group string, consistency pb.ReadConsistency, minIndex uint64) *pb.ConsumeRequest {
+	return &pb.ConsumeRequest{
+		Topic:       topic,
+		GroupId:     group,
+		Offsets:     map[uint64]uint64{0: 0},
+		Consistency: consistency,
+		MinIndex:    minIndex,
+	}
+}
+
+// a follower asked for an index it didn't apply yet waits for it before reading
+func (suite *ClientTestConsistency) TestFollowerWaitsForMinIndex() {
+	const TOPIC = "TestFollowerWaitsForMinIndex"
+	group, index := suite.topic(TOPIC)
+	server, follower := suite.follower()
+	assert.Eventually(suite.T(), func() bool {
+		return server.Raft.AppliedIndex() >= index
+	}, 5*time.Second, 10*time.Millisecond)
+
+	//the index asked for is only reached by the publish sent a second later
+	minIndex := suite.leader().Raft.LastIndex() + 1
+	go func() {
+		time.Sleep(time.Second)
+		_, err := suite.client.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("later")}}, TOPIC)
+		assert.Nil(suite.T(), err)
+	}()
+	start := time.Now()
+	res, err := follower.Consume(context.Background(), consumeRequest(TOPIC, group, pb.ReadConsistency_FOLLOWER, minIndex))
+	assert.Nil(suite.T(), err)
+	assert.GreaterOrEqual(suite.T(), time.Since(start), time.Second)
+	assert.GreaterOrEqual(suite.T(), res.GetLastIndex(), minIndex)
+	if assert.Len(suite.T(), res.GetMessages(), 1) {
+		assert.Len(suite.T(), res.GetMessages()[0].GetMessages(), 2)
+	}
+}
+
+// an index nobody writes fails the read once the wait times out
+func (suite *ClientTestConsistency) TestFollowerMinIndexTimeout() {
+	const TOPIC = "TestFollowerMinIndexTimeout"
+	group, index := suite.topic(TOPIC)
+	_, follower := suite.follower()
+	start := time.Now()
+	_, err := follower.Consume(context.Background(), consumeRequest(TOPIC, group, pb.ReadConsistency_FOLLOWER, index+1000))
+	assert.GreaterOrEqual(suite.T(), time.Since(start), 5*time.Second)
+	assert.Less(suite.T(), time.Since(start), 8*time.Second)
+	assert.NotNil(suite.T(), err)
+	assert.Contains(suite.T(), status.Convert(err).Message(), application.ErrIndexNotReached.Error())
+}
+
+// the linearizable and leader reads are refused by a follower with a redirect to the leader
+func (suite *ClientTestConsistency) TestFollowerRefusesLeaderReads() {
+	const TOPIC = "TestFollowerRefusesLeaderReads"
+	group, _ := suite.topic(TOPIC)
+	_, follower := suite.follower()
+	_, leaderId := suite.leader().Raft.LeaderWithID()
+	for _, consistency := range []pb.ReadConsistency{pb.ReadConsistency_LINEARIZABLE, pb.ReadConsistency_LEADER} {
+		_, err := follower.Consume(context.Background(), consumeRequest(TOPIC, group, consistency, 0))
+		assert.Equal(suite.T(), codes.Unavailable, status.Code(err), consistency.String())
+		var notLeader *pb.NotLeader
+		for _, detail := range status.Convert(err).Details() {
+			notLeader, _ = detail.(*pb.NotLeader)
+		}
+		if assert.NotNil(suite.T(), notLeader, consistency.String()) {
+			assert.Equal(suite.T(), string(leaderId), notLeader.LeaderId)
+		}
+	}
+	//the client sends them to the leader, its meta may come from a follower that didn't apply the group yet
+	var messages []*pb.Message
+	assert.Eventually(suite.T(), func() bool {
+		var err error
+		messages, err = suite.client.ConsumeMessageWithConsistency(TOPIC, group, pb.ReadConsistency_LINEARIZABLE)
+		if err != nil {
+			_ = suite.client.Refresh()
+		}
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.Equal(suite.T(), 1, len(messages))
+}
+
+func TestConsistency(t *testing.T) {
+	suite.Run(t, new(ClientTestConsistency))
+}
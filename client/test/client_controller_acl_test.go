@@ -0,0 +1,186 @@
+package test
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/auth"
+	"github.com/Kapperchino/jet-stream/client"
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// a controller and a shard checking acls, the controller checks its callers against the acls the shard reports
+type ClientTestControllerACL struct {
+	suite.Suite
+	controllerAddress string
+	address           string
+	servers           []*factory.Server
+	admin             *client.JetClient
+}
+
+func (suite *ClientTestControllerACL) SetupSuite() {
+	suite.controllerAddress = "localhost:8220"
+	suite.address = "localhost:8221"
+	authConfig := &auth.Config{Tokens: map[string]string{
+		"admin-token": "admin",
+		"alice-token": "alice",
+		"node-token":  "node",
+	}}
+	channel := make(chan *factory.Server, 5)
+	log.Print("Starting the controller and the shard")
+	go factory.SetupController(&factory.ControllerConfig{
+		HostAddr:   suite.controllerAddress,
+		GlobalAdr:  suite.controllerAddress,
+		NodeName:   "controllerA",
+		InMemory:   true,
+		Server:     channel,
+		Auth:       authConfig,
+		NodeToken:  "node-token",
+		ACL:        true,
+		Superusers: []string{"admin"},
+	})
+	suite.servers = append(suite.servers, <-channel)
+	time.Sleep(3 * time.Second)
+	go factory.SetupServer(&factory.JetConfig{
+		HostAddr:      suite.address,
+		GlobalAdr:     suite.address,
+		NodeName:      "nodeA",
+		GossipAddress: "localhost:8222",
+		Server:        channel,
+		ShardId:       "shardControllerACL",
+		InMemory:      true,
+		Controllers:   []string{suite.controllerAddress},
+		Auth:          authConfig,
+		NodeToken:     "node-token",
+		ACL:           true,
+		Superusers:    []string{"admin"},
+	})
+	suite.servers = append(suite.servers, <-channel)
+	time.Sleep(5 * time.Second)
+	var err error
+	suite.admin, err = client.New(suite.address, client.WithToken("admin-token"))
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *ClientTestControllerACL) TearDownSuite() {
+	suite.admin.Close()
+	for _, server := range suite.servers {
+		server.Kill()
+	}
+}
+
+// the topics created through the controller need the create acl of the topic
+func (suite *ClientTestControllerACL) TestCreateTopicAcls() {
+	alice, err := client.New(suite.address, client.WithToken("alice-token"))
+	assert.Nil(suite.T(), err)
+	defer alice.Close()
+	_, err = alice.CreateTopic("alice.orders", 1)
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+
+	acl := &pb.Acl{Principal: "alice", Resource: pb.AclResource_TOPIC, Pattern: "alice.*", Operation: pb.AclOperation_ADMIN}
+	assert.Nil(suite.T(), suite.admin.PutAcls([]*pb.Acl{acl}))
+	defer suite.admin.DeleteAcls([]*pb.Acl{acl})
+	//the controller gets the acl with the next heartbeat of the shard
+	assert.Eventually(suite.T(), func() bool {
+		_, err = alice.CreateTopic("alice.orders", 1)
+		return err == nil
+	}, 10*time.Second, 500*time.Millisecond)
+	_, err = alice.CreateTopic("payments", 1)
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+	//the group acls are checked too
+	_, err = alice.CreateConsumerGroup("alice.orders")
+	assert.Equal(suite.T(), codes.PermissionDenied, status.Code(err))
+}
+
+// moving partitions, balancing, the shard operations, the config and the heartbeats need admin on the cluster
+func (suite *ClientTestControllerACL) TestAdminAcls() {
+	conn, err := grpc.Dial(suite.controllerAddress, grpc.WithInsecure(), auth.TokenCredentials{Token: "alice-token", AllowInsecure: true}.DialOption())
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	controller := controllerPb.NewControllerServiceClient(conn)
+	ctx := context.Background()
+	calls := map[string]func() error{
+		"ReassignPartition": func() error {
+			_, err := controller.ReassignPartition(ctx, &controllerPb.ReassignPartitionRequest{Topic: "topic", TargetShard: "shard"})
+			return err
+		},
+		"CancelReassignment": func() error {
+			_, err := controller.CancelReassignment(ctx, &controllerPb.CancelReassignmentRequest{Id: "id"})
+			return err
+		},
+		"Balance": func() error {
+			_, err := controller.Balance(ctx, &controllerPb.BalanceRequest{DryRun: true})
+			return err
+		},
+		"SplitShard": func() error {
+			_, err := controller.SplitShard(ctx, &controllerPb.SplitShardRequest{ShardId: "shardControllerACL", NewShardId: "shardNew"})
+			return err
+		},
+		"MergeShards": func() error {
+			_, err := controller.MergeShards(ctx, &controllerPb.MergeShardsRequest{SourceShard: "shardA", TargetShard: "shardB"})
+			return err
+		},
+		"SetConfig": func() error {
+			_, err := controller.SetConfig(ctx, &controllerPb.SetConfigRequest{Key: "key", Value: "val"})
+			return err
+		},
+		"ShardHeartbeat": func() error {
+			_, err := controller.ShardHeartbeat(ctx, &controllerPb.ShardHeartbeatRequest{Shard: &controllerPb.ShardRecord{ShardId: "shardFake"},
+				Acls: []*controllerPb.ShardAcl{{Principal: "alice", Resource: int32(pb.AclResource_CLUSTER), Pattern: "*", Operation: int32(pb.AclOperation_ADMIN)}}})
+			return err
+		},
+	}
+	for name, call := range calls {
+		assert.Equal(suite.T(), codes.PermissionDenied, status.Code(call()), name)
+	}
+	_, err = suite.admin.Balance(true)
+	assert.Nil(suite.T(), err)
+}
+
+// the placements listed by the controller are filtered like the meta of the shards
+func (suite *ClientTestControllerACL) TestListFilteredByAcls() {
+	_, err := suite.admin.CreateTopic("listed.visible", 1)
+	assert.Nil(suite.T(), err)
+	_, err = suite.admin.CreateTopic("listed.hidden", 1)
+	assert.Nil(suite.T(), err)
+	hidden, err := suite.admin.CreateConsumerGroup("listed.hidden")
+	assert.Nil(suite.T(), err)
+	acl := &pb.Acl{Principal: "alice", Resource: pb.AclResource_TOPIC, Pattern: "listed.visible", Operation: pb.AclOperation_READ}
+	assert.Nil(suite.T(), suite.admin.PutAcls([]*pb.Acl{acl}))
+	defer suite.admin.DeleteAcls([]*pb.Acl{acl})
+
+	conn, err := grpc.Dial(suite.controllerAddress, grpc.WithInsecure(), auth.TokenCredentials{Token: "alice-token", AllowInsecure: true}.DialOption())
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	controller := controllerPb.NewControllerServiceClient(conn)
+	var topics *controllerPb.GetTopicsResponse
+	assert.Eventually(suite.T(), func() bool {
+		topics, err = controller.GetTopics(context.Background(), &controllerPb.GetTopicsRequest{})
+		return err == nil && topics.Topics["listed.visible"] != nil
+	}, 10*time.Second, 500*time.Millisecond)
+	assert.Nil(suite.T(), topics.Topics["listed.hidden"])
+	groups, err := controller.GetConsumerGroups(context.Background(), &controllerPb.GetConsumerGroupsRequest{})
+	assert.Nil(suite.T(), err)
+	for _, group := range groups.Groups {
+		assert.NotEqual(suite.T(), hidden.Id, group.Id)
+	}
+	//the superusers see everything
+	admin, err := grpc.Dial(suite.controllerAddress, grpc.WithInsecure(), auth.TokenCredentials{Token: "admin-token", AllowInsecure: true}.DialOption())
+	assert.Nil(suite.T(), err)
+	defer admin.Close()
+	groups, err = controllerPb.NewControllerServiceClient(admin).GetConsumerGroups(context.Background(), &controllerPb.GetConsumerGroupsRequest{Topic: "listed.hidden"})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, len(groups.Groups))
+}
+
+func TestControllerACL(t *testing.T) {
+	suite.Run(t, new(ClientTestControllerACL))
+}
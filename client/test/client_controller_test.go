@@ -0,0 +1,220 @@
+package test
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"testing"
+	"time"
+)
+
+type ClientTestController struct {
+	suite.Suite
+	client            *client.JetClient
+	controllerAddress string
+	address           [2]string
+	gossipAddress     [2]string
+	servers           chan *factory.Server
+}
+
+func (suite *ClientTestController) SetupSuite() {
+	suite.controllerAddress = "localhost:8110"
+	suite.address = [2]string{"localhost:8111", "localhost:8113"}
+	suite.gossipAddress = [2]string{"localhost:8112", "localhost:8114"}
+	suite.servers = make(chan *factory.Server, 5)
+	log.Print("Starting the controller and the shards")
+	go factory.SetupController(&factory.ControllerConfig{
+		HostAddr:  suite.controllerAddress,
+		GlobalAdr: suite.controllerAddress,
+		NodeName:  "controllerA",
+		InMemory:  true,
+		Server:    suite.servers,
+	})
+	time.Sleep(3 * time.Second)
+	shardIds := [2]string{"shardA", "shardB"}
+	for x := range suite.address {
+		rootNode := ""
+		if x > 0 {
+			rootNode = suite.gossipAddress[0]
+		}
+		go factory.SetupServer(&factory.JetConfig{
+			HostAddr:      suite.address[x],
+			GlobalAdr:     suite.address[x],
+			NodeName:      "node" + shardIds[x],
+			GossipAddress: suite.gossipAddress[x],
+			RootNode:      rootNode,
+			Server:        suite.servers,
+			ShardId:       shardIds[x],
+			InMemory:      true,
+			Controllers:   []string{suite.controllerAddress},
+			Bootstrap:     true,
+		})
+		time.Sleep(5 * time.Second)
+	}
+	jetClient, err := client.New(suite.address[0])
+	assert.Nil(suite.T(), err)
+	suite.client = jetClient
+}
+
+func (suite *ClientTestController) TearDownSuite() {
+	suite.client.Close()
+	stopServers(suite.servers)
+}
+
+// topics are placed by the controller over both shards and are only visible once every shard created them
+func (suite *ClientTestController) TestCreateTopicThroughController() {
+	const TOPIC = "TestCreateTopicThroughController"
+	_, err := suite.client.CreateTopic(TOPIC, 4)
+	assert.Nil(suite.T(), err)
+	_, err = suite.client.CreateTopic(TOPIC, 4)
+	assert.NotNil(suite.T(), err)
+
+	conn, err := grpc.Dial(suite.controllerAddress, grpc.WithInsecure())
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	topics, err := controllerPb.NewControllerServiceClient(conn).GetTopics(context.Background(), &controllerPb.GetTopicsRequest{})
+	assert.Nil(suite.T(), err)
+	topic := topics.Topics[TOPIC]
+	assert.Equal(suite.T(), controllerPb.TopicState_READY, topic.State)
+	shards := map[string]bool{}
+	for _, partition := range topic.Partitions {
+		shards[partition.ShardId] = true
+	}
+	assert.Equal(suite.T(), 2, len(shards))
+
+	res, err := suite.client.PublishMessage([]*pb.KeyVal{
+		{Key: []byte("a"), Val: []byte("1")},
+		{Key: []byte("b"), Val: []byte("2")},
+		{Key: []byte("c"), Val: []byte("3")},
+	}, TOPIC)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 3, len(res.Messages))
+
+	group, err := suite.client.CreateConsumerGroup(TOPIC)
+	assert.Nil(suite.T(), err)
+	messages, err := suite.client.ConsumeMessage(TOPIC, group.Id)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 3, len(messages))
+}
+
+// a partition keeps its messages, offsets and consumer position when it is moved to the other shard
+func (suite *ClientTestController) TestReassignPartition() {
+	const TOPIC = "TestReassignPartition"
+	_, err := suite.client.CreateTopic(TOPIC, 2)
+	assert.Nil(suite.T(), err)
+	partition, source := suite.placement(TOPIC, "shardA")
+	assert.Equal(suite.T(), "shardA", source)
+
+	publish := func(count int) []*pb.Message {
+		var list []*pb.KeyVal
+		for i := 0; i < count; i++ {
+			list = append(list, &pb.KeyVal{Key: []byte(uuid.NewString()), Val: []byte("val")})
+		}
+		res, err := suite.client.PublishMessage(list, TOPIC)
+		assert.Nil(suite.T(), err)
+		return res.Messages
+	}
+	publish(20)
+	group, err := suite.client.CreateConsumerGroup(TOPIC)
+	assert.Nil(suite.T(), err)
+	messages, err := suite.client.ConsumeMessage(TOPIC, group.Id)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 20, len(messages))
+	lastOffset := uint64(0)
+	for _, message := range publish(10) {
+		if message.Partition == partition && message.Offset > lastOffset {
+			lastOffset = message.Offset
+		}
+	}
+
+	before := suite.partitionInfo(TOPIC, partition, "shardA")
+
+	move, err := suite.client.ReassignPartition(TOPIC, partition, "shardB")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "shardA", move.SourceShard)
+	assert.Eventually(suite.T(), func() bool {
+		res, err := suite.client.GetReassignments(move.Id)
+		return err == nil && res[0].Phase == controllerPb.ReassignmentPhase_DONE
+	}, 30*time.Second, 500*time.Millisecond)
+	_, shardId := suite.placement(TOPIC, "shardA")
+	assert.Equal(suite.T(), "", shardId)
+	//the size and the offset of the partition are moved with its messages
+	after := suite.partitionInfo(TOPIC, partition, "shardB")
+	if assert.NotNil(suite.T(), before) && assert.NotNil(suite.T(), after) {
+		assert.Greater(suite.T(), before.Bytes, uint64(0))
+		assert.Equal(suite.T(), before.Bytes, after.Bytes)
+		assert.Equal(suite.T(), before.HighWatermark, after.HighWatermark)
+	}
+
+	//only what wasn't consumed before the move is left
+	messages, err = suite.client.ConsumeMessage(TOPIC, group.Id)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 10, len(messages))
+	//the sequence continues on the new shard
+	for _, message := range publish(10) {
+		if message.Partition == partition {
+			assert.Greater(suite.T(), message.Offset, lastOffset)
+		}
+	}
+}
+
+// a cancelled move leaves the partition where it was
+func (suite *ClientTestController) TestCancelReassignment() {
+	const TOPIC = "TestCancelReassignment"
+	_, err := suite.client.CreateTopic(TOPIC, 2)
+	assert.Nil(suite.T(), err)
+	partition, _ := suite.placement(TOPIC, "shardA")
+	move, err := suite.client.ReassignPartition(TOPIC, partition, "shardB")
+	assert.Nil(suite.T(), err)
+	_, err = suite.client.CancelReassignment(move.Id)
+	assert.Nil(suite.T(), err)
+	assert.Eventually(suite.T(), func() bool {
+		res, err := suite.client.GetReassignments(move.Id)
+		return err == nil && res[0].Phase == controllerPb.ReassignmentPhase_CANCELLED
+	}, 30*time.Second, 500*time.Millisecond)
+	_, shardId := suite.placement(TOPIC, "shardA")
+	assert.Equal(suite.T(), "shardA", shardId)
+	res, err := suite.client.PublishMessage([]*pb.KeyVal{{Key: []byte("a"), Val: []byte("1")}}, TOPIC)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, len(res.Messages))
+}
+
+// placement returns a partition of the topic placed on the shard, the shard is empty if there is none
+func (suite *ClientTestController) placement(topic string, shardId string) (uint64, string) {
+	conn, err := grpc.Dial(suite.controllerAddress, grpc.WithInsecure())
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	topics, err := controllerPb.NewControllerServiceClient(conn).GetTopics(context.Background(), &controllerPb.GetTopicsRequest{})
+	assert.Nil(suite.T(), err)
+	for num, partition := range topics.Topics[topic].GetPartitions() {
+		if partition.ShardId == shardId {
+			return num, shardId
+		}
+	}
+	return 0, ""
+}
+
+// partitionInfo is the partition of the topic as the shard hosting it sees it, nil if the shard doesn't host it
+func (suite *ClientTestController) partitionInfo(topic string, partition uint64, shardId string) *client.PartitionInfo {
+	info, err := suite.client.DescribeTopic(topic)
+	if !assert.Nil(suite.T(), err) {
+		return nil
+	}
+	for _, item := range info.Partitions {
+		if item.Partition == partition && item.ShardId == shardId {
+			return item
+		}
+	}
+	return nil
+}
+
+func TestController(t *testing.T) {
+	suite.Run(t, new(ClientTestController))
+}
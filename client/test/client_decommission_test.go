@@ -0,0 +1,116 @@
+package test
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"testing"
+	"time"
+)
+
+const decommissionShard = "shardD"
+
+type ClientTestDecommission struct {
+	suite.Suite
+	client        *client.JetClient
+	address       [3]string
+	gossipAddress [3]string
+	nodeName      [3]string
+	servers       []*factory.Server
+}
+
+func (suite *ClientTestDecommission) SetupSuite() {
+	suite.address = [3]string{"localhost:8196", "localhost:8198", "localhost:8200"}
+	suite.gossipAddress = [3]string{"localhost:8197", "localhost:8199", "localhost:8201"}
+	suite.nodeName = [3]string{"nodeA", "nodeB", "nodeC"}
+	servers := make(chan *factory.Server, 5)
+	log.Print("Starting the servers")
+	for x := range suite.address {
+		rootNode := ""
+		if x > 0 {
+			rootNode = suite.gossipAddress[0]
+		}
+		go factory.SetupServer(
+			&factory.JetConfig{
+				HostAddr:      suite.address[x],
+				GlobalAdr:     suite.address[x],
+				NodeName:      suite.nodeName[x],
+				GossipAddress: suite.gossipAddress[x],
+				RootNode:      rootNode,
+				Server:        servers,
+				ShardId:       decommissionShard,
+				InMemory:      true,
+			})
+		suite.servers = append(suite.servers, <-servers)
+		time.Sleep(5 * time.Second)
+	}
+	//the client doesn't go through the node that is decommissioned
+	jetClient, err := client.New(suite.address[1])
+	assert.Nil(suite.T(), err)
+	suite.client = jetClient
+}
+
+func (suite *ClientTestDecommission) TearDownSuite() {
+	suite.client.Close()
+	for _, server := range suite.servers {
+		if server.Raft.State() != raft.Shutdown {
+			server.Kill()
+		}
+	}
+}
+
+// the leader hands its leadership over, leaves the raft group, the shard info and the gossip, the shard keeps taking
+// writes
+func (suite *ClientTestDecommission) TestDecommissionLeader() {
+	const TOPIC = "TestDecommissionLeader"
+	assert.Equal(suite.T(), raft.Leader, suite.servers[0].Raft.State())
+	_, err := suite.client.CreateTopic(TOPIC, 1)
+	assert.Nil(suite.T(), err)
+
+	shardIds, err := suite.client.DecommissionNode("nodeA", nil, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{decommissionShard}, shardIds)
+	assert.Equal(suite.T(), raft.Shutdown, suite.servers[0].Raft.State())
+	assert.Empty(suite.T(), suite.servers[0].HostedShards())
+
+	var leader *factory.Server
+	for _, server := range suite.servers[1:] {
+		if server.Raft.State() == raft.Leader {
+			leader = server
+		}
+	}
+	assert.NotNil(suite.T(), leader)
+	future := leader.Raft.GetConfiguration()
+	assert.Nil(suite.T(), future.Error())
+	var ids []string
+	for _, server := range future.Configuration().Servers {
+		ids = append(ids, string(server.ID))
+	}
+	assert.ElementsMatch(suite.T(), []string{"nodeB", "nodeC"}, ids)
+	assert.Eventually(suite.T(), func() bool {
+		return leader.MemberList.NumMembers() == 2
+	}, 10*time.Second, 100*time.Millisecond)
+
+	conn, err := grpc.Dial(suite.address[1], grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	res, err := clusterPb.NewClusterMetaServiceClient(util.ShardConn(conn, decommissionShard)).GetShardInfo(context.Background(), &clusterPb.GetShardInfoRequest{})
+	assert.Nil(suite.T(), err)
+	assert.NotContains(suite.T(), res.GetInfo().GetMemberAddressMap(), "nodeA")
+
+	_, err = suite.client.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}}, TOPIC)
+	assert.Nil(suite.T(), err)
+}
+
+func TestDecommission(t *testing.T) {
+	suite.Run(t, new(ClientTestDecommission))
+}
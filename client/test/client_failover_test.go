@@ -0,0 +1,96 @@
+package test
+
+import (
+	"crypto/rand"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type ClientTestFailover struct {
+	suite.Suite
+	client        *client.JetClient
+	address       [3]string
+	gossipAddress [3]string
+	nodeName      [3]string
+	servers       chan *factory.Server
+	started       []*factory.Server
+}
+
+func (suite *ClientTestFailover) SetupSuite() {
+	suite.address = [3]string{"localhost:8090", "localhost:8092", "localhost:8094"}
+	suite.gossipAddress = [3]string{"localhost:8091", "localhost:8093", "localhost:8095"}
+	suite.nodeName = [3]string{"nodeA", "nodeB", "nodeC"}
+	suite.servers = make(chan *factory.Server, 5)
+	log.Print("Starting the servers")
+	for x := range suite.address {
+		rootNode := ""
+		if x > 0 {
+			rootNode = suite.gossipAddress[0]
+		}
+		go factory.SetupServer(
+			&factory.JetConfig{
+				HostAddr:      suite.address[x],
+				GlobalAdr:     suite.address[x],
+				NodeName:      suite.nodeName[x],
+				GossipAddress: suite.gossipAddress[x],
+				RootNode:      rootNode,
+				Server:        suite.servers,
+				ShardId:       "shardF",
+				InMemory:      true,
+			})
+		suite.started = append(suite.started, <-suite.servers)
+		time.Sleep(5 * time.Second)
+	}
+	jetClient, err := client.New(suite.address[0])
+	assert.Nil(suite.T(), err)
+	suite.client = jetClient
+	time.Sleep(5 * time.Second)
+}
+
+func (suite *ClientTestFailover) TearDownSuite() {
+	suite.client.Close()
+	for _, server := range suite.started {
+		if server.Raft.State() != raft.Shutdown {
+			server.Kill()
+		}
+	}
+}
+
+// the leader is killed in the middle of publishing, the client should refresh its meta and keep publishing
+func (suite *ClientTestFailover) TestPublishWhileLeaderIsKilled() {
+	const TOPIC = "TestPublishWhileLeaderIsKilled"
+	_, err := suite.client.CreateTopic(TOPIC, 3)
+	assert.Nil(suite.T(), err)
+	var leader *factory.Server
+	for _, server := range suite.started {
+		if server.Raft.State() == raft.Leader {
+			leader = server
+		}
+	}
+	assert.NotNil(suite.T(), leader)
+	for x := 0; x < 30; x++ {
+		if x == 10 {
+			log.Info().Msgf("Killing the leader")
+			leader.Kill()
+		}
+		key := make([]byte, 16)
+		rand.Read(key)
+		res, err := suite.client.PublishMessage([]*pb.KeyVal{{
+			Key: key,
+			Val: []byte("val"),
+		}}, TOPIC)
+		assert.Nil(suite.T(), err)
+		assert.NotNil(suite.T(), res)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	suite.Run(t, new(ClientTestFailover))
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"encoding/base64"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// two nodes with encrypted gossip, the key is rotated through the node service
+type ClientTestGossipKeyring struct {
+	suite.Suite
+	client  *client.JetClient
+	servers []*factory.Server
+	files   []string
+	oldKey  []byte
+	newKey  []byte
+}
+
+func (suite *ClientTestGossipKeyring) SetupSuite() {
+	suite.oldKey = []byte("0123456789abcdef0123456789abcdef")
+	suite.newKey = []byte("fedcba9876543210fedcba9876543210")
+	dir := suite.T().TempDir()
+	channel := make(chan *factory.Server, 5)
+	nodes := []struct {
+		name, address, gossip, root, shard string
+	}{
+		{"nodeA", "localhost:8140", "localhost:8141", "", "shardA"},
+		{"nodeB", "localhost:8142", "localhost:8143", "localhost:8141", "shardB"},
+	}
+	log.Print("Starting the servers")
+	for _, node := range nodes {
+		file := filepath.Join(dir, node.name, "keyring")
+		suite.files = append(suite.files, file)
+		go factory.SetupServer(&factory.JetConfig{
+			HostAddr:          node.address,
+			GlobalAdr:         node.address,
+			NodeName:          node.name,
+			GossipAddress:     node.gossip,
+			RootNode:          node.root,
+			Server:            channel,
+			ShardId:           node.shard,
+			InMemory:          true,
+			GossipKeys:        [][]byte{suite.oldKey},
+			GossipKeyringFile: file,
+			Bootstrap:         true,
+		})
+		suite.servers = append(suite.servers, <-channel)
+	}
+	time.Sleep(5 * time.Second)
+	jetClient, err := client.New(nodes[0].address)
+	assert.Nil(suite.T(), err)
+	suite.client = jetClient
+}
+
+func (suite *ClientTestGossipKeyring) TearDownSuite() {
+	suite.client.Close()
+	for _, server := range suite.servers {
+		server.Kill()
+	}
+}
+
+func (suite *ClientTestGossipKeyring) assertKeys(primary []byte, keys int) {
+	res, err := suite.client.ListGossipKeys()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, len(res))
+	for _, node := range res {
+		assert.Equal(suite.T(), primary, node.Primary)
+		assert.Equal(suite.T(), keys, len(node.Keys))
+	}
+}
+
+func (suite *ClientTestGossipKeyring) TestRotateKey() {
+	assert.Equal(suite.T(), 2, suite.servers[0].MemberList.NumMembers())
+	suite.assertKeys(suite.oldKey, 1)
+
+	assert.Nil(suite.T(), suite.client.InstallGossipKey(suite.newKey))
+	suite.assertKeys(suite.oldKey, 2)
+	assert.Nil(suite.T(), suite.client.UseGossipKey(suite.newKey))
+	suite.assertKeys(suite.newKey, 2)
+	assert.Nil(suite.T(), suite.client.RemoveGossipKey(suite.oldKey))
+	suite.assertKeys(suite.newKey, 1)
+	//the primary key can't be removed
+	assert.NotNil(suite.T(), suite.client.RemoveGossipKey(suite.newKey))
+
+	//the nodes still understand each other with the new key only
+	for _, server := range suite.servers {
+		assert.Nil(suite.T(), server.MemberList.UpdateNode(5*time.Second))
+	}
+	time.Sleep(3 * time.Second)
+	for _, server := range suite.servers {
+		assert.Equal(suite.T(), 2, server.MemberList.NumMembers())
+	}
+	//a restarted node reads the rotated keys from the keyring file
+	for _, file := range suite.files {
+		buf, err := os.ReadFile(file)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), base64.StdEncoding.EncodeToString(suite.newKey), strings.TrimSpace(string(buf)))
+	}
+}
+
+func TestGossipKeyring(t *testing.T) {
+	suite.Run(t, new(ClientTestGossipKeyring))
+}
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"bytes"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/hashicorp/go-msgpack/codec"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// the raft log kept in badger
+type ClientTestLogStore struct {
+	suite.Suite
+	db   *badger.DB
+	logs factory.BadgerLogStore
+}
+
+func (suite *ClientTestLogStore) SetupTest() {
+	db, err := factory.NewBadger("", true)
+	assert.Nil(suite.T(), err)
+	suite.db = db
+	suite.logs, err = factory.NewBadgerLogStore(db)
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *ClientTestLogStore) TearDownTest() {
+	assert.Nil(suite.T(), suite.db.Close())
+}
+
+func entries(first uint64, last uint64) []*raft.Log {
+	var logs []*raft.Log
+	for index := first; index <= last; index++ {
+		logs = append(logs, &raft.Log{Index: index, Term: 1, Type: raft.LogCommand, Data: []byte("entry")})
+	}
+	return logs
+}
+
+// the bounds follow the index past the 255 entries a little endian key keeps in order
+func (suite *ClientTestLogStore) TestIndexBounds() {
+	first, err := suite.logs.FirstIndex()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), uint64(0), first)
+
+	assert.Nil(suite.T(), suite.logs.StoreLogs(entries(1, 1000)))
+	first, err = suite.logs.FirstIndex()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), uint64(1), first)
+	last, err := suite.logs.LastIndex()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), uint64(1000), last)
+
+	//compaction removes the head of the log
+	assert.Nil(suite.T(), suite.logs.DeleteRange(1, 700))
+	first, err = suite.logs.FirstIndex()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), uint64(701), first)
+	var entry raft.Log
+	assert.Nil(suite.T(), suite.logs.GetLog(1000, &entry))
+	assert.Equal(suite.T(), uint64(1000), entry.Index)
+}
+
+// the entries written under the little endian index are moved to the versioned keys
+func (suite *ClientTestLogStore) TestMigrateKeys() {
+	db, err := factory.NewBadger("", true)
+	assert.Nil(suite.T(), err)
+	defer db.Close()
+	err = db.Update(func(txn *badger.Txn) error {
+		for _, entry := range entries(250, 260) {
+			var buf bytes.Buffer
+			if err := codec.NewEncoder(&buf, &codec.MsgpackHandle{}).Encode(entry); err != nil {
+				return err
+			}
+			if err := txn.Set(util.ULongToBytes(entry.Index), buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.Nil(suite.T(), err)
+
+	logs, err := factory.NewBadgerLogStore(db)
+	assert.Nil(suite.T(), err)
+	first, err := logs.FirstIndex()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), uint64(250), first)
+	last, err := logs.LastIndex()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), uint64(260), last)
+	var entry raft.Log
+	assert.Nil(suite.T(), logs.GetLog(256, &entry))
+	assert.Equal(suite.T(), []byte("entry"), entry.Data)
+	err = db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(util.ULongToBytes(256))
+		return err
+	})
+	assert.ErrorIs(suite.T(), err, badger.ErrKeyNotFound)
+}
+
+func TestLogStore(t *testing.T) {
+	suite.Run(t, new(ClientTestLogStore))
+}
@@ -0,0 +1,98 @@
+package test
+
+import (
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// one node serving its prometheus metrics
+type ClientTestMetrics struct {
+	suite.Suite
+	metricsAddress string
+	server         *factory.Server
+	client         *client.JetClient
+}
+
+func (suite *ClientTestMetrics) SetupSuite() {
+	address := "localhost:8170"
+	suite.metricsAddress = "localhost:8172"
+	channel := make(chan *factory.Server, 5)
+	log.Print("Starting the server")
+	go factory.SetupServer(&factory.JetConfig{
+		HostAddr:       address,
+		GlobalAdr:      address,
+		NodeName:       "nodeA",
+		GossipAddress:  "localhost:8171",
+		Server:         channel,
+		ShardId:        "shardMetrics",
+		InMemory:       true,
+		MetricsAddress: suite.metricsAddress,
+	})
+	suite.server = <-channel
+	time.Sleep(5 * time.Second)
+	var err error
+	suite.client, err = client.New(address)
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *ClientTestMetrics) TearDownSuite() {
+	suite.client.Close()
+	suite.server.Kill()
+}
+
+func (suite *ClientTestMetrics) scrape() string {
+	res, err := http.Get("http://" + suite.metricsAddress + "/metrics")
+	if !assert.Nil(suite.T(), err) {
+		return ""
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	assert.Nil(suite.T(), err)
+	return string(body)
+}
+
+func (suite *ClientTestMetrics) TestMetrics() {
+	_, err := suite.client.CreateTopic("measured", 1)
+	assert.Nil(suite.T(), err)
+	group, err := suite.client.CreateConsumerGroup("measured")
+	assert.Nil(suite.T(), err)
+	_, err = suite.client.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}}, "measured")
+	assert.Nil(suite.T(), err)
+	_, err = suite.client.ConsumeMessage("measured", group.GetId())
+	assert.Nil(suite.T(), err)
+
+	body := suite.scrape()
+	for _, metric := range []string{
+		`jet_publish_messages{partition="0",shard="shardMetrics",topic="measured"} 1`,
+		`jet_publish_bytes{partition="0",shard="shardMetrics",topic="measured"} 6`,
+		`jet_publish_latency_count{partition="0",shard="shardMetrics",topic="measured"} 1`,
+		`jet_consume_latency_count{shard="shardMetrics",topic="measured"}`,
+		`jet_grpc_requests{code="OK",method="/message.MessageService/PublishMessages"}`,
+		//emitted by raft itself
+		`raft_apply`,
+		`go_goroutines`,
+	} {
+		assert.Contains(suite.T(), body, metric)
+	}
+	//the gauges of the shard are updated in the background
+	assert.Eventually(suite.T(), func() bool {
+		body = suite.scrape()
+		return strings.Contains(body, `jet_raft_is_leader{shard="shardMetrics"} 1`) &&
+			strings.Contains(body, `jet_badger_lsm_size{shard="shardMetrics",store="messages"}`) &&
+			strings.Contains(body, `jet_gossip_members{shard="shardMetrics"} 1`)
+	}, 15*time.Second, 500*time.Millisecond)
+	assert.Contains(suite.T(), body, `jet_raft_commit_index{shard="shardMetrics"}`)
+}
+
+func TestMetrics(t *testing.T) {
+	suite.Run(t, new(ClientTestMetrics))
+}
@@ -0,0 +1,106 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// one node hosting two shards on the same grpc server
+type ClientTestMultiShardNode struct {
+	suite.Suite
+	client  *client.JetClient
+	address string
+	server  *factory.Server
+	servers chan *factory.Server
+}
+
+func (suite *ClientTestMultiShardNode) SetupSuite() {
+	suite.address = "localhost:8120"
+	suite.servers = make(chan *factory.Server, 5)
+	log.Print("Starting the server")
+	go factory.SetupServer(
+		&factory.JetConfig{
+			HostAddr:             suite.address,
+			GlobalAdr:            suite.address,
+			NodeName:             "nodeA",
+			GossipAddress:        "localhost:8121",
+			Server:               suite.servers,
+			ShardId:              "shardA",
+			ShardIds:             []string{"shardA", "shardB"},
+			ShardGossipAddresses: []string{"localhost:8122"},
+			InMemory:             true,
+		})
+	suite.server = <-suite.servers
+	time.Sleep(5 * time.Second)
+	jetClient, err := client.New(suite.address)
+	assert.Nil(suite.T(), err)
+	suite.client = jetClient
+}
+
+func (suite *ClientTestMultiShardNode) TearDownSuite() {
+	suite.client.Close()
+	suite.server.Kill()
+}
+
+func (suite *ClientTestMultiShardNode) TestShardsShareTheNode() {
+	assert.Equal(suite.T(), 2, len(suite.server.Shards))
+	conn, err := grpc.Dial(suite.address, grpc.WithInsecure())
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	clusterClient := clusterPb.NewClusterMetaServiceClient(conn)
+	res, err := clusterClient.GetClusterInfo(context.Background(), &clusterPb.GetClusterInfoRequest{})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, len(res.Info.ShardMap))
+	for _, shard := range res.Info.ShardMap {
+		for _, member := range shard.MemberAddressMap {
+			assert.Equal(suite.T(), suite.address, member.Address)
+		}
+	}
+	//calls are answered by the shard in their metadata
+	for _, shardId := range []string{"shardA", "shardB"} {
+		shardRes, err := clusterClient.GetShardInfo(util.WithShardId(context.Background(), shardId), &clusterPb.GetShardInfoRequest{})
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), shardId, shardRes.Info.ShardId)
+	}
+	_, err = clusterClient.GetShardInfo(util.WithShardId(context.Background(), "shardC"), &clusterPb.GetShardInfoRequest{})
+	assert.Equal(suite.T(), codes.NotFound, status.Code(err))
+}
+
+func (suite *ClientTestMultiShardNode) TestPublishAndConsume() {
+	const TOPIC = "TestMultiShardPublishAndConsume"
+	_, err := suite.client.CreateTopic(TOPIC, 4)
+	assert.Nil(suite.T(), err)
+	token := make([]byte, 1024)
+	_, _ = rand.Read(token)
+	for x := 0; x < 20; x++ {
+		key := make([]byte, 16)
+		_, _ = rand.Read(key)
+		_, err := suite.client.PublishMessage([]*pb.KeyVal{{
+			Key: key,
+			Val: token,
+		}}, TOPIC)
+		assert.Nil(suite.T(), err)
+	}
+	id, err := suite.client.CreateConsumerGroup(TOPIC)
+	assert.Nil(suite.T(), err)
+	messages, err := suite.client.ConsumeMessage(TOPIC, id.Id)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 20, len(messages))
+}
+
+func TestMultiShardNode(t *testing.T) {
+	suite.Run(t, new(ClientTestMultiShardNode))
+}
@@ -101,10 +101,58 @@ func (suite *ClientTestOneNodeCluster) TestConsumeMessage() {
 	assert.Equal(suite.T(), 0, len(messages))
 }
 
+func (suite *ClientTestOneNodeCluster) TestConsumeMessageConsistency() {
+	const TOPIC = "TestConsumeMessageConsistency"
+	_, err := suite.client.CreateTopic(TOPIC, 2)
+	assert.Nil(suite.T(), err)
+	token := make([]byte, 1024)
+	rand.Read(token)
+	for x := 0; x < 10; x++ {
+		key := make([]byte, 16)
+		rand.Read(key)
+		res, err := suite.client.PublishMessage([]*pb.KeyVal{{
+			Key: key,
+			Val: token,
+		}}, TOPIC)
+		assert.Nil(suite.T(), err)
+		assert.NotZero(suite.T(), res.LastIndex)
+	}
+	id, err := suite.client.CreateConsumerGroup(TOPIC)
+	assert.Nil(suite.T(), err)
+	messages, err := suite.client.ConsumeMessageWithConsistency(TOPIC, id.Id, pb.ReadConsistency_LINEARIZABLE)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 10, len(messages))
+	messages, err = suite.client.ConsumeMessageWithConsistency(TOPIC, id.Id, pb.ReadConsistency_LEADER)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, len(messages))
+}
+
+func (suite *ClientTestOneNodeCluster) TearDownSuite() {
+	suite.client.Close()
+	stopServers(suite.servers)
+}
+
 func TestOneNode(t *testing.T) {
 	suite.Run(t, new(ClientTestOneNodeCluster))
 }
 
+// a topic created by another client reaches this one through WatchCluster
+func (suite *ClientTestOneNodeCluster) TestWatchPicksUpNewTopic() {
+	const TOPIC = "TestWatchPicksUpNewTopic"
+	otherClient, err := suite.setupClient(suite.address)
+	assert.Nil(suite.T(), err)
+	defer otherClient.Close()
+	_, err = otherClient.CreateTopic(TOPIC, 1)
+	assert.Nil(suite.T(), err)
+	assert.Eventually(suite.T(), func() bool {
+		_, err := suite.client.PublishMessage([]*pb.KeyVal{{
+			Key: []byte("key"),
+			Val: []byte("val"),
+		}}, TOPIC)
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
 func (suite *ClientTestOneNodeCluster) setupClient(address string) (*client.JetClient, error) {
 	return client.New(address)
 }
@@ -0,0 +1,157 @@
+package test
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// one node with publish quotas, the callers over them are slowed down
+type ClientTestQuota struct {
+	suite.Suite
+	address string
+	server  *factory.Server
+	client  *client.JetClient
+}
+
+func (suite *ClientTestQuota) SetupSuite() {
+	suite.address = "localhost:8168"
+	channel := make(chan *factory.Server, 5)
+	log.Print("Starting the server")
+	go factory.SetupServer(&factory.JetConfig{
+		HostAddr:      suite.address,
+		GlobalAdr:     suite.address,
+		NodeName:      "nodeA",
+		GossipAddress: "localhost:8169",
+		Server:        channel,
+		ShardId:       "shardQuota",
+		InMemory:      true,
+	})
+	suite.server = <-channel
+	time.Sleep(5 * time.Second)
+	var err error
+	suite.client, err = client.New(suite.address, client.WithClientId("quota-test"))
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *ClientTestQuota) TearDownSuite() {
+	suite.client.Close()
+	suite.server.Kill()
+}
+
+func (suite *ClientTestQuota) publish(conn *grpc.ClientConn, topic string) (*pb.PublishMessageResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return pb.NewMessageServiceClient(conn).PublishMessages(ctx, &pb.PublishMessageRequest{
+		Topic:    topic,
+		Messages: []*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}},
+	})
+}
+
+func (suite *ClientTestQuota) TestTopicQuota() {
+	_, err := suite.client.CreateTopic("limited", 1)
+	assert.Nil(suite.T(), err)
+	quota := &pb.Quota{Entity: pb.QuotaEntity_TOPIC_QUOTA, Name: "limited", RequestsPerSecond: 2}
+	assert.Nil(suite.T(), suite.client.SetQuotas([]*pb.Quota{quota}))
+
+	//a caller that ignores the hints is rejected once it is in debt, with the delay to wait
+	conn, err := grpc.Dial(suite.address, grpc.WithInsecure())
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	var throttled *pb.Throttled
+	for i := 0; i < 10 && throttled == nil; i++ {
+		_, err = suite.publish(conn, "limited")
+		if status.Code(err) == codes.ResourceExhausted {
+			for _, detail := range status.Convert(err).Details() {
+				throttled, _ = detail.(*pb.Throttled)
+			}
+		}
+	}
+	if assert.NotNil(suite.T(), throttled) {
+		assert.Greater(suite.T(), throttled.DelayMs, uint32(0))
+	}
+
+	//the client waits for the hints and its publishes go through
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err = suite.client.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}}, "limited")
+		assert.Nil(suite.T(), err)
+	}
+	assert.Greater(suite.T(), time.Since(start), time.Second)
+
+	quotas, err := suite.client.ListQuotas()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, len(quotas))
+	assert.Nil(suite.T(), suite.client.DeleteQuotas([]*pb.Quota{quota}))
+	quotas, err = suite.client.ListQuotas()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, len(quotas))
+}
+
+func (suite *ClientTestQuota) TestClientQuota() {
+	_, err := suite.client.CreateTopic("bytes", 1)
+	assert.Nil(suite.T(), err)
+	//the default quota of the client ids gives every client its own bucket
+	quota := &pb.Quota{Entity: pb.QuotaEntity_CLIENT_QUOTA, Name: "*", BytesPerSecond: 10}
+	assert.Nil(suite.T(), suite.client.SetQuotas([]*pb.Quota{quota}))
+	defer suite.client.DeleteQuotas([]*pb.Quota{quota})
+
+	conn, err := grpc.Dial(suite.address, grpc.WithInsecure(), util.ClientIdDialOption("greedy"))
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	rejected := false
+	for i := 0; i < 10 && !rejected; i++ {
+		_, err = suite.publish(conn, "bytes")
+		rejected = status.Code(err) == codes.ResourceExhausted
+	}
+	assert.True(suite.T(), rejected)
+	//a caller without a client id isn't limited by the client quotas
+	plain, err := grpc.Dial(suite.address, grpc.WithInsecure())
+	assert.Nil(suite.T(), err)
+	defer plain.Close()
+	for i := 0; i < 5; i++ {
+		res, err := suite.publish(plain, "bytes")
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), uint32(0), res.GetThrottleMs())
+	}
+}
+
+// the header the followers forward writes with doesn't skip the quotas when a client sets it
+func (suite *ClientTestQuota) TestForgedForward() {
+	_, err := suite.client.CreateTopic("forged", 1)
+	assert.Nil(suite.T(), err)
+	quota := &pb.Quota{Entity: pb.QuotaEntity_TOPIC_QUOTA, Name: "forged", RequestsPerSecond: 2}
+	assert.Nil(suite.T(), suite.client.SetQuotas([]*pb.Quota{quota}))
+	defer suite.client.DeleteQuotas([]*pb.Quota{quota})
+
+	conn, err := grpc.Dial(suite.address, grpc.WithInsecure())
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	rejected := false
+	for i := 0; i < 10 && !rejected; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx = metadata.AppendToOutgoingContext(ctx, "jet-forwarded-by", "nodeB", "jet-forwarded-for", "admin")
+		_, err = pb.NewMessageServiceClient(conn).PublishMessages(ctx, &pb.PublishMessageRequest{
+			Topic:    "forged",
+			Messages: []*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}},
+		})
+		cancel()
+		rejected = status.Code(err) == codes.ResourceExhausted
+	}
+	assert.True(suite.T(), rejected)
+}
+
+func TestQuota(t *testing.T) {
+	suite.Run(t, new(ClientTestQuota))
+}
@@ -0,0 +1,152 @@
+package test
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"testing"
+	"time"
+)
+
+const replicaShard = "shardR"
+
+type ClientTestReadReplica struct {
+	suite.Suite
+	client        *client.JetClient
+	address       [3]string
+	gossipAddress [3]string
+	nodeName      [3]string
+	servers       []*factory.Server
+}
+
+func (suite *ClientTestReadReplica) SetupSuite() {
+	suite.address = [3]string{"localhost:8230", "localhost:8232", "localhost:8234"}
+	suite.gossipAddress = [3]string{"localhost:8231", "localhost:8233", "localhost:8235"}
+	suite.nodeName = [3]string{"nodeA", "nodeB", "nodeC"}
+	servers := make(chan *factory.Server, 5)
+	log.Print("Starting the servers")
+	//the servers are stopped even when the setup fails halfway, the next suites reuse nothing of them
+	suite.T().Cleanup(func() {
+		for _, server := range suite.servers {
+			if server.Raft.State() != raft.Shutdown {
+				server.Kill()
+			}
+		}
+	})
+	for x := range suite.address {
+		rootNode := ""
+		if x > 0 {
+			rootNode = suite.gossipAddress[0]
+		}
+		go factory.SetupServer(
+			&factory.JetConfig{
+				HostAddr:      suite.address[x],
+				GlobalAdr:     suite.address[x],
+				NodeName:      suite.nodeName[x],
+				GossipAddress: suite.gossipAddress[x],
+				RootNode:      rootNode,
+				Server:        servers,
+				ShardId:       replicaShard,
+				InMemory:      true,
+				ReadReplica:   x == 2,
+			})
+		suite.servers = append(suite.servers, <-servers)
+		//every node is in the configuration of the leader before the next one joins through it
+		assert.Eventually(suite.T(), func() bool {
+			return len(suite.configuration()) == x+1
+		}, 15*time.Second, 100*time.Millisecond)
+	}
+	jetClient, err := client.New(suite.address[0])
+	assert.Nil(suite.T(), err)
+	suite.client = jetClient
+	suite.T().Cleanup(suite.client.Close)
+}
+
+// configuration is the suffrage of the members in the configuration of the leader, empty without a leader
+func (suite *ClientTestReadReplica) configuration() map[string]raft.ServerSuffrage {
+	suffrage := map[string]raft.ServerSuffrage{}
+	for _, server := range suite.servers {
+		if server.Raft.State() != raft.Leader {
+			continue
+		}
+		future := server.Raft.GetConfiguration()
+		if future.Error() != nil {
+			return suffrage
+		}
+		for _, member := range future.Configuration().Servers {
+			suffrage[string(member.ID)] = member.Suffrage
+		}
+	}
+	return suffrage
+}
+
+func (suite *ClientTestReadReplica) conn(address string) grpc.ClientConnInterface {
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(suite.T(), err)
+	suite.T().Cleanup(func() { _ = conn.Close() })
+	return util.ShardConn(conn, replicaShard)
+}
+
+// the replica is a nonvoter of the raft group and shows up with its role in the shard info
+func (suite *ClientTestReadReplica) TestReplicaJoinsAsNonvoter() {
+	assert.Equal(suite.T(), map[string]raft.ServerSuffrage{"nodeA": raft.Voter, "nodeB": raft.Voter, "nodeC": raft.Nonvoter}, suite.configuration())
+
+	//the roles reach the shard info through the gossip
+	meta := clusterPb.NewClusterMetaServiceClient(suite.conn(suite.address[0]))
+	var members map[string]*clusterPb.MemberInfo
+	assert.Eventually(suite.T(), func() bool {
+		res, err := meta.GetShardInfo(context.Background(), &clusterPb.GetShardInfoRequest{})
+		if err != nil {
+			return false
+		}
+		members = res.GetInfo().GetMemberAddressMap()
+		return len(members) == 3 && members["nodeC"].GetRole() == clusterPb.MemberRole_READ_REPLICA
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Equal(suite.T(), clusterPb.MemberRole_VOTER, members["nodeB"].GetRole())
+	assert.Equal(suite.T(), clusterPb.MemberRole_READ_REPLICA, members["nodeC"].GetRole())
+}
+
+// the replica serves consumes of what was published through the voters, and losing it doesn't stop the writes
+func (suite *ClientTestReadReplica) TestConsumeFromReplica() {
+	const TOPIC = "TestConsumeFromReplica"
+	_, err := suite.client.CreateTopic(TOPIC, 1)
+	assert.Nil(suite.T(), err)
+	group, err := suite.client.CreateConsumerGroup(TOPIC)
+	assert.Nil(suite.T(), err)
+	published, err := suite.client.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}}, TOPIC)
+	assert.Nil(suite.T(), err)
+
+	//the replica learns the topic and the group from the log, it may still be catching up
+	replica := pb.NewMessageServiceClient(suite.conn(suite.address[2]))
+	var res *pb.ConsumeResponse
+	assert.Eventually(suite.T(), func() bool {
+		res, err = replica.Consume(context.Background(), &pb.ConsumeRequest{
+			Topic:       TOPIC,
+			GroupId:     group.Id,
+			Offsets:     map[uint64]uint64{0: 0},
+			Consistency: pb.ReadConsistency_FOLLOWER,
+			MinIndex:    published.LastIndex,
+		})
+		return err == nil && len(res.GetMessages()) == 1 && len(res.GetMessages()[0].GetMessages()) == 1
+	}, 15*time.Second, 200*time.Millisecond)
+	if assert.Nil(suite.T(), err) && assert.Len(suite.T(), res.GetMessages(), 1) {
+		assert.Equal(suite.T(), []byte("val"), res.GetMessages()[0].GetMessages()[0].GetPayload())
+	}
+
+	suite.servers[2].Kill()
+	_, err = suite.client.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}}, TOPIC)
+	assert.Nil(suite.T(), err)
+}
+
+func TestReadReplica(t *testing.T) {
+	suite.Run(t, new(ClientTestReadReplica))
+}
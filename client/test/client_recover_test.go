@@ -0,0 +1,181 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/application/fsm"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type ClientTestRecover struct {
+	suite.Suite
+	dir     string
+	dataDir string
+}
+
+// the raft state of nodeA in a group of three, with enough entries that the log keys aren't in index order in badger.
+// Every entry puts an acl the shard stores get once the entries are replayed
+func (suite *ClientTestRecover) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	suite.dataDir = suite.T().TempDir()
+	for _, store := range []string{"Meta", "Messages"} {
+		db, err := factory.NewBadger(filepath.Join(suite.dataDir, store), false)
+		assert.Nil(suite.T(), err)
+		assert.Nil(suite.T(), db.Close())
+	}
+	logs, stable, snaps := suite.openStores()
+	configuration := raft.Configuration{Servers: []raft.Server{
+		{Suffrage: raft.Voter, ID: "nodeA", Address: "localhost:8202"},
+		{Suffrage: raft.Voter, ID: "nodeB", Address: "localhost:8203"},
+		{Suffrage: raft.Voter, ID: "nodeC", Address: "localhost:8204"},
+	}}
+	c := raft.DefaultConfig()
+	c.LocalID = "nodeA"
+	_, trans := raft.NewInmemTransport("localhost:8202")
+	assert.Nil(suite.T(), raft.BootstrapCluster(c, logs, stable, snaps, trans, configuration))
+	var entries []*raft.Log
+	for index := uint64(2); index <= 300; index++ {
+		data, err := util.SerializeMessage(&pb.WriteOperation{
+			Operation: &pb.WriteOperation_PutAcls{PutAcls: &pb.PutAcls{Acls: []*pb.Acl{{
+				Principal: fmt.Sprintf("user-%d", index),
+				Resource:  pb.AclResource_TOPIC,
+				Pattern:   "orders",
+				Operation: pb.AclOperation_READ,
+			}}}},
+			Code: pb.Operation_PUT_ACLS,
+		})
+		assert.Nil(suite.T(), err)
+		entries = append(entries, &raft.Log{Index: index, Term: 1, Type: raft.LogCommand, Data: data})
+	}
+	assert.Nil(suite.T(), logs.StoreLogs(entries))
+	suite.closeStores(logs, stable)
+}
+
+func (suite *ClientTestRecover) openStores() (factory.BadgerLogStore, factory.BadgerLogStore, raft.SnapshotStore) {
+	logDb, err := factory.NewBadger(filepath.Join(suite.dir, "logs"), false)
+	assert.Nil(suite.T(), err)
+	stableDb, err := factory.NewBadger(filepath.Join(suite.dir, "stable"), false)
+	assert.Nil(suite.T(), err)
+	snaps, err := raft.NewFileSnapshotStore(suite.dir, 3, io.Discard)
+	assert.Nil(suite.T(), err)
+	logs, err := factory.NewBadgerLogStore(logDb)
+	assert.Nil(suite.T(), err)
+	return logs, factory.BadgerLogStore{LogStore: stableDb}, snaps
+}
+
+func (suite *ClientTestRecover) closeStores(logs factory.BadgerLogStore, stable factory.BadgerLogStore) {
+	assert.Nil(suite.T(), logs.LogStore.Close())
+	assert.Nil(suite.T(), stable.LogStore.Close())
+}
+
+func (suite *ClientTestRecover) configuration() (raft.Configuration, uint64) {
+	logs, stable, snaps := suite.openStores()
+	defer suite.closeStores(logs, stable)
+	configuration, lastIndex, err := factory.ReadConfiguration(logs, snaps)
+	assert.Nil(suite.T(), err)
+	return configuration, lastIndex
+}
+
+// acls are the acls in the Meta store of the shard
+func (suite *ClientTestRecover) acls() []*pb.Acl {
+	meta, err := factory.NewBadger(filepath.Join(suite.dataDir, "Meta"), false)
+	if !assert.Nil(suite.T(), err) {
+		return nil
+	}
+	defer meta.Close()
+	acls, err := (&fsm.NodeState{MetaStore: meta}).GetAcls("")
+	assert.Nil(suite.T(), err)
+	return acls
+}
+
+func single() raft.Configuration {
+	return raft.Configuration{Servers: []raft.Server{{Suffrage: raft.Voter, ID: "nodeA", Address: "localhost:8202"}}}
+}
+
+// without the confirmation both configurations are printed and nothing changes
+func (suite *ClientTestRecover) TestRecoverNeedsConfirm() {
+	var out bytes.Buffer
+	err := factory.RecoverRaft(suite.dir, suite.dataDir, single(), false, false, &out)
+	assert.ErrorIs(suite.T(), err, factory.ErrNotConfirmed)
+	assert.Contains(suite.T(), out.String(), "last index 300")
+	assert.Contains(suite.T(), out.String(), "300 entries after the snapshot at index 0")
+	assert.Contains(suite.T(), out.String(), "current configuration")
+	assert.Contains(suite.T(), out.String(), "localhost:8204")
+	assert.Contains(suite.T(), out.String(), "new configuration")
+
+	configuration, lastIndex := suite.configuration()
+	assert.Len(suite.T(), configuration.Servers, 3)
+	assert.Equal(suite.T(), uint64(300), lastIndex)
+	assert.Empty(suite.T(), suite.acls())
+}
+
+// a shard can't be recovered without its stores, the entries would be lost with the log
+func (suite *ClientTestRecover) TestRecoverNeedsStores() {
+	var out bytes.Buffer
+	assert.NotNil(suite.T(), factory.RecoverRaft(suite.dir, suite.T().TempDir(), single(), false, true, &out))
+	configuration, _ := suite.configuration()
+	assert.Len(suite.T(), configuration.Servers, 3)
+}
+
+// the node left alone gets a configuration of itself and elects itself once restarted
+func (suite *ClientTestRecover) TestRecoverSingleNode() {
+	var out bytes.Buffer
+	assert.Nil(suite.T(), factory.RecoverRaft(suite.dir, suite.dataDir, single(), false, true, &out))
+	configuration, lastIndex := suite.configuration()
+	assert.Equal(suite.T(), single(), configuration)
+	assert.Equal(suite.T(), uint64(300), lastIndex)
+	//the entries compacted into the snapshot were applied to the shard stores
+	assert.Len(suite.T(), suite.acls(), 299)
+
+	logs, stable, snaps := suite.openStores()
+	defer suite.closeStores(logs, stable)
+	c := raft.DefaultConfig()
+	c.LocalID = "nodeA"
+	_, trans := raft.NewInmemTransport("localhost:8202")
+	fsm := &shardFSM{}
+	r, err := raft.NewRaft(c, fsm, logs, stable, snaps, trans)
+	if !assert.Nil(suite.T(), err) {
+		return
+	}
+	defer func() {
+		_ = r.Shutdown().Error()
+	}()
+	assert.Eventually(suite.T(), func() bool {
+		return r.State() == raft.Leader
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Nil(suite.T(), r.Apply([]byte("write"), time.Second).Error())
+	//the entries compacted in the recovery aren't applied again
+	assert.Equal(suite.T(), 1, fsm.applied)
+}
+
+// shardFSM keeps nothing in the snapshots like the state of the shards, their data is in their own stores
+type shardFSM struct {
+	applied int
+}
+
+func (f *shardFSM) Apply(*raft.Log) interface{} {
+	f.applied++
+	return nil
+}
+
+func (f *shardFSM) Snapshot() (raft.FSMSnapshot, error) {
+	return nil, errors.New("the shard state isn't snapshotted")
+}
+
+func (f *shardFSM) Restore(r io.ReadCloser) error {
+	return r.Close()
+}
+
+func TestRecover(t *testing.T) {
+	suite.Run(t, new(ClientTestRecover))
+}
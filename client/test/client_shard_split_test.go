@@ -0,0 +1,137 @@
+package test
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"testing"
+	"time"
+)
+
+// one node with a controller, its shard is split in two and merged back
+type ClientTestShardSplit struct {
+	suite.Suite
+	client            *client.JetClient
+	controllerAddress string
+	address           string
+	controller        *factory.Server
+	server            *factory.Server
+	servers           chan *factory.Server
+}
+
+func (suite *ClientTestShardSplit) SetupSuite() {
+	suite.controllerAddress = "localhost:8130"
+	suite.address = "localhost:8131"
+	suite.servers = make(chan *factory.Server, 5)
+	log.Print("Starting the controller and the node")
+	go factory.SetupController(&factory.ControllerConfig{
+		HostAddr:  suite.controllerAddress,
+		GlobalAdr: suite.controllerAddress,
+		NodeName:  "controllerA",
+		InMemory:  true,
+		Server:    suite.servers,
+	})
+	suite.controller = <-suite.servers
+	time.Sleep(3 * time.Second)
+	go factory.SetupServer(&factory.JetConfig{
+		HostAddr:      suite.address,
+		GlobalAdr:     suite.address,
+		NodeName:      "nodeA",
+		GossipAddress: "localhost:8132",
+		Server:        suite.servers,
+		ShardId:       "shardA",
+		InMemory:      true,
+		Controllers:   []string{suite.controllerAddress},
+	})
+	suite.server = <-suite.servers
+	time.Sleep(5 * time.Second)
+	jetClient, err := client.New(suite.address)
+	assert.Nil(suite.T(), err)
+	suite.client = jetClient
+}
+
+func (suite *ClientTestShardSplit) TearDownSuite() {
+	suite.client.Close()
+	suite.server.Kill()
+	suite.controller.Kill()
+}
+
+// half of the partitions move to the new shard with their messages, merging moves them back and stops the shard
+func (suite *ClientTestShardSplit) TestSplitAndMerge() {
+	const TOPIC = "TestSplitAndMerge"
+	_, err := suite.client.CreateTopic(TOPIC, 4)
+	assert.Nil(suite.T(), err)
+	publish := func(count int) {
+		var list []*pb.KeyVal
+		for i := 0; i < count; i++ {
+			list = append(list, &pb.KeyVal{Key: []byte(uuid.NewString()), Val: []byte("val")})
+		}
+		_, err := suite.client.PublishMessage(list, TOPIC)
+		assert.Nil(suite.T(), err)
+	}
+	publish(20)
+	group, err := suite.client.CreateConsumerGroup(TOPIC)
+	assert.Nil(suite.T(), err)
+
+	split, err := suite.client.SplitShard("shardA", "shardB", nil)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []uint64{1, 3}, split.Partitions[TOPIC].Partitions)
+	_, err = suite.client.SplitShard("shardA", "shardC", nil)
+	assert.NotNil(suite.T(), err)
+	suite.waitCompleted(split.Id)
+	assert.Equal(suite.T(), map[string]int{"shardA": 2, "shardB": 2}, suite.placements(TOPIC))
+	assert.Equal(suite.T(), []string{"shardA", "shardB"}, suite.server.HostedShards())
+
+	//the client finds the new shard and nothing is lost
+	assert.Nil(suite.T(), suite.client.Refresh())
+	messages, err := suite.client.ConsumeMessage(TOPIC, group.Id)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 20, len(messages))
+	publish(10)
+
+	merge, err := suite.client.MergeShards("shardB", "shardA")
+	assert.Nil(suite.T(), err)
+	suite.waitCompleted(merge.Id)
+	assert.Equal(suite.T(), map[string]int{"shardA": 4}, suite.placements(TOPIC))
+	assert.Equal(suite.T(), []string{"shardA"}, suite.server.HostedShards())
+
+	assert.Nil(suite.T(), suite.client.Refresh())
+	messages, err = suite.client.ConsumeMessage(TOPIC, group.Id)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 10, len(messages))
+	operations, err := suite.client.GetShardOperations("")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, len(operations))
+}
+
+func (suite *ClientTestShardSplit) waitCompleted(id string) {
+	assert.Eventually(suite.T(), func() bool {
+		res, err := suite.client.GetShardOperations(id)
+		return err == nil && res[0].Phase == controllerPb.ShardOperationPhase_COMPLETED
+	}, 60*time.Second, 500*time.Millisecond)
+}
+
+// placements counts the partitions of the topic on each shard
+func (suite *ClientTestShardSplit) placements(topic string) map[string]int {
+	conn, err := grpc.Dial(suite.controllerAddress, grpc.WithInsecure())
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	topics, err := controllerPb.NewControllerServiceClient(conn).GetTopics(context.Background(), &controllerPb.GetTopicsRequest{})
+	assert.Nil(suite.T(), err)
+	res := map[string]int{}
+	for _, partition := range topics.Topics[topic].GetPartitions() {
+		res[partition.ShardId]++
+	}
+	return res
+}
+
+func TestShardSplit(t *testing.T) {
+	suite.Run(t, new(ClientTestShardSplit))
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+	"time"
+)
+
+type ClientTestShutdown struct {
+	suite.Suite
+	client        *client.JetClient
+	address       [3]string
+	gossipAddress [3]string
+	nodeName      [3]string
+	servers       []*factory.Server
+}
+
+func (suite *ClientTestShutdown) SetupSuite() {
+	suite.address = [3]string{"localhost:8176", "localhost:8178", "localhost:8180"}
+	suite.gossipAddress = [3]string{"localhost:8177", "localhost:8179", "localhost:8181"}
+	suite.nodeName = [3]string{"nodeA", "nodeB", "nodeC"}
+	servers := make(chan *factory.Server, 5)
+	log.Print("Starting the servers")
+	for x := range suite.address {
+		rootNode := ""
+		if x > 0 {
+			rootNode = suite.gossipAddress[0]
+		}
+		go factory.SetupServer(
+			&factory.JetConfig{
+				HostAddr:      suite.address[x],
+				GlobalAdr:     suite.address[x],
+				NodeName:      suite.nodeName[x],
+				GossipAddress: suite.gossipAddress[x],
+				RootNode:      rootNode,
+				Server:        servers,
+				ShardId:       "shardS",
+				InMemory:      true,
+			})
+		suite.servers = append(suite.servers, <-servers)
+		time.Sleep(5 * time.Second)
+	}
+	jetClient, err := client.New(suite.address[0])
+	assert.Nil(suite.T(), err)
+	suite.client = jetClient
+	time.Sleep(5 * time.Second)
+}
+
+func (suite *ClientTestShutdown) TearDownSuite() {
+	suite.client.Close()
+	for _, server := range suite.servers {
+		if server.Raft.State() != raft.Shutdown {
+			server.Kill()
+		}
+	}
+}
+
+// the leader is shut down while publishes are running, it hands the leadership over so none of them fail
+func (suite *ClientTestShutdown) TestPublishWhileLeaderShutsDown() {
+	const TOPIC = "TestPublishWhileLeaderShutsDown"
+	_, err := suite.client.CreateTopic(TOPIC, 3)
+	assert.Nil(suite.T(), err)
+	var leader *factory.Server
+	for _, server := range suite.servers {
+		if server.Raft.State() == raft.Leader {
+			leader = server
+		}
+	}
+	assert.NotNil(suite.T(), leader)
+	var wg sync.WaitGroup
+	for x := 0; x < 3; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := 0; y < 20; y++ {
+				key := make([]byte, 16)
+				rand.Read(key)
+				res, err := suite.client.PublishMessage([]*pb.KeyVal{{
+					Key: key,
+					Val: []byte("val"),
+				}}, TOPIC)
+				assert.Nil(suite.T(), err)
+				assert.NotNil(suite.T(), res)
+				time.Sleep(20 * time.Millisecond)
+			}
+		}()
+	}
+	time.Sleep(200 * time.Millisecond)
+	log.Info().Msgf("Shutting down the leader")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	leader.Shutdown(ctx)
+	assert.Equal(suite.T(), raft.Shutdown, leader.Raft.State())
+	wg.Wait()
+	var newLeader *factory.Server
+	for _, server := range suite.servers {
+		if server != leader && server.Raft.State() == raft.Leader {
+			newLeader = server
+		}
+	}
+	assert.NotNil(suite.T(), newLeader)
+}
+
+func TestShutdown(t *testing.T) {
+	suite.Run(t, new(ClientTestShutdown))
+}
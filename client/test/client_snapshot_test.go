@@ -0,0 +1,120 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"testing"
+	"time"
+)
+
+const snapshotShard = "shardSnapshot"
+
+// the leader compacts its whole log behind a snapshot, so the node joining after it is sent the snapshot
+type ClientTestSnapshot struct {
+	suite.Suite
+	client        *client.JetClient
+	address       [2]string
+	gossipAddress [2]string
+	servers       []*factory.Server
+}
+
+func (suite *ClientTestSnapshot) SetupSuite() {
+	suite.address = [2]string{"localhost:8250", "localhost:8252"}
+	suite.gossipAddress = [2]string{"localhost:8251", "localhost:8253"}
+	log.Print("Starting the servers")
+	suite.T().Cleanup(func() {
+		for _, server := range suite.servers {
+			if server.Raft.State() != raft.Shutdown {
+				server.Kill()
+			}
+		}
+	})
+	suite.start(0)
+	assert.Eventually(suite.T(), func() bool {
+		return suite.servers[0].Raft.State() == raft.Leader
+	}, 15*time.Second, 100*time.Millisecond)
+	var err error
+	suite.client, err = client.New(suite.address[0])
+	assert.Nil(suite.T(), err)
+	suite.T().Cleanup(suite.client.Close)
+}
+
+func (suite *ClientTestSnapshot) start(x int) {
+	servers := make(chan *factory.Server, 1)
+	rootNode := ""
+	if x > 0 {
+		rootNode = suite.gossipAddress[0]
+	}
+	go factory.SetupServer(&factory.JetConfig{
+		HostAddr:      suite.address[x],
+		GlobalAdr:     suite.address[x],
+		NodeName:      fmt.Sprintf("node%d", x),
+		GossipAddress: suite.gossipAddress[x],
+		RootNode:      rootNode,
+		Server:        servers,
+		ShardId:       snapshotShard,
+		InMemory:      true,
+	})
+	suite.servers = append(suite.servers, <-servers)
+}
+
+// the topic, the group and the messages written before the snapshot are read from the node that joined after it
+func (suite *ClientTestSnapshot) TestJoinAfterSnapshot() {
+	const TOPIC = "TestJoinAfterSnapshot"
+	_, err := suite.client.CreateTopic(TOPIC, 1)
+	assert.Nil(suite.T(), err)
+	group, err := suite.client.CreateConsumerGroup(TOPIC)
+	assert.Nil(suite.T(), err)
+	var list []*pb.KeyVal
+	for i := 0; i < 50; i++ {
+		list = append(list, &pb.KeyVal{Key: []byte(fmt.Sprintf("key%d", i)), Val: []byte("val")})
+	}
+	_, err = suite.client.PublishMessage(list, TOPIC)
+	assert.Nil(suite.T(), err)
+
+	leader := suite.servers[0].Raft
+	reloadable := leader.ReloadableConfig()
+	reloadable.TrailingLogs = 0
+	assert.Nil(suite.T(), leader.ReloadConfig(reloadable))
+	assert.Nil(suite.T(), leader.Snapshot().Error())
+	assert.NotEqual(suite.T(), "0", leader.Stats()["last_snapshot_index"])
+
+	suite.start(1)
+	joined := suite.servers[1].Raft
+	assert.Eventually(suite.T(), func() bool {
+		future := leader.GetConfiguration()
+		return future.Error() == nil && len(future.Configuration().Servers) == 2
+	}, 15*time.Second, 100*time.Millisecond)
+	assert.Eventually(suite.T(), func() bool {
+		return joined.Stats()["last_snapshot_index"] != "0"
+	}, 15*time.Second, 100*time.Millisecond)
+
+	conn, err := grpc.Dial(suite.address[1], grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(suite.T(), err)
+	defer conn.Close()
+	res, err := pb.NewMessageServiceClient(util.ShardConn(conn, snapshotShard)).Consume(context.Background(), &pb.ConsumeRequest{
+		Topic:       TOPIC,
+		GroupId:     group.Id,
+		Offsets:     map[uint64]uint64{0: 0},
+		Consistency: pb.ReadConsistency_FOLLOWER,
+		MinIndex:    leader.LastIndex(),
+	})
+	assert.Nil(suite.T(), err)
+	if assert.Len(suite.T(), res.GetMessages(), 1) {
+		assert.Len(suite.T(), res.GetMessages()[0].GetMessages(), 50)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	suite.Run(t, new(ClientTestSnapshot))
+}
@@ -1,13 +1,17 @@
 package test
 
 import (
+	"context"
 	"crypto/rand"
 	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
 	client "github.com/Kapperchino/jet-stream/client"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
 	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/hashicorp/raft"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
 	"testing"
 	"time"
 )
@@ -132,12 +136,61 @@ func (suite *ClientTestOneShardCluster) TestConsumeMessage() {
 	assert.Equal(suite.T(), 0, len(messages))
 }
 
+// writes sent to a follower should be forwarded to the leader
+func (suite *ClientTestOneShardCluster) TestFollowerForwardsWrites() {
+	const TOPIC = "TestFollowerForwardsWrites"
+	var follower pb.MessageServiceClient
+	for x, address := range suite.address {
+		conn, err := grpc.Dial(address, grpc.WithInsecure())
+		assert.Nil(suite.T(), err)
+		info, err := clusterPb.NewClusterMetaServiceClient(conn).GetShardInfo(context.Background(), &clusterPb.GetShardInfoRequest{})
+		assert.Nil(suite.T(), err)
+		if info.Info.LeaderId != suite.nodeName[x] {
+			follower = pb.NewMessageServiceClient(conn)
+			break
+		}
+	}
+	assert.NotNil(suite.T(), follower)
+	_, err := follower.CreateTopic(context.Background(), &pb.CreateTopicRequest{
+		Topic:      TOPIC,
+		Partitions: []uint64{0},
+	})
+	assert.Nil(suite.T(), err)
+	res, err := follower.PublishMessages(context.Background(), &pb.PublishMessageRequest{
+		Topic:     TOPIC,
+		Partition: 0,
+		Messages:  []*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}},
+	})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, len(res.Messages))
+	assert.NotZero(suite.T(), res.LastIndex)
+}
+
+func (suite *ClientTestOneShardCluster) TearDownSuite() {
+	stopServers(suite.servers)
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestOneShard(t *testing.T) {
 	suite.Run(t, new(ClientTestOneShardCluster))
 }
 
+// stopServers kills the servers sent on the channel that are still running, so the suites after them don't run out
+// of memory
+func stopServers(servers chan *factory.Server) {
+	for {
+		select {
+		case server := <-servers:
+			if server.Raft.State() != raft.Shutdown {
+				server.Kill()
+			}
+		default:
+			return
+		}
+	}
+}
+
 func publishMessages(client *client.JetClient, topic string, messages []*pb.KeyVal) (*pb.PublishMessageResponse, error) {
 	return client.PublishMessage(messages, topic)
 }
@@ -57,6 +57,7 @@ func (suite *ClientTestThreeShardCluster) SetupSuite() {
 			Server:        suite.servers,
 			ShardId:       "shardB",
 			InMemory:      true,
+			Bootstrap:     true,
 		})
 	time.Sleep(5 * time.Second)
 	go factory.SetupServer(
@@ -71,6 +72,7 @@ func (suite *ClientTestThreeShardCluster) SetupSuite() {
 			Server:        suite.servers,
 			ShardId:       "shardC",
 			InMemory:      true,
+			Bootstrap:     true,
 		})
 	time.Sleep(5 * time.Second)
 	log.Print("Starting the jetClient")
@@ -132,6 +134,10 @@ func (suite *ClientTestThreeShardCluster) TestConsumeMessage() {
 	assert.Equal(suite.T(), 0, len(messages))
 }
 
+func (suite *ClientTestThreeShardCluster) TearDownSuite() {
+	stopServers(suite.servers)
+}
+
 func TestThreeShard(t *testing.T) {
 	suite.Run(t, new(ClientTestThreeShardCluster))
 }
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// two nodes of a shard with mutual tls, the raft traffic between them goes through the tls grpc server
+type ClientTestTLS struct {
+	suite.Suite
+	dir     string
+	address [2]string
+	ca      *testCA
+	servers []*factory.Server
+}
+
+func (suite *ClientTestTLS) SetupSuite() {
+	suite.dir = suite.T().TempDir()
+	suite.address = [2]string{"localhost:8150", "localhost:8152"}
+	gossip := [2]string{"localhost:8151", "localhost:8153"}
+	suite.ca = newTestCA(suite.T(), "jet-ca")
+	suite.ca.write(suite.T(), suite.path("ca.pem"))
+	suite.ca.issue(suite.T(), "node", suite.path("node.pem"), suite.path("node-key.pem"))
+	suite.ca.issue(suite.T(), "client", suite.path("client.pem"), suite.path("client-key.pem"))
+	channel := make(chan *factory.Server, 5)
+	log.Print("Starting the servers")
+	for x, name := range []string{"nodeA", "nodeB"} {
+		rootNode := ""
+		if x > 0 {
+			rootNode = gossip[0]
+		}
+		go factory.SetupServer(&factory.JetConfig{
+			HostAddr:      suite.address[x],
+			GlobalAdr:     suite.address[x],
+			NodeName:      name,
+			GossipAddress: gossip[x],
+			RootNode:      rootNode,
+			Server:        channel,
+			ShardId:       "shardT",
+			InMemory:      true,
+			TLS: &util.TLSConfig{
+				CertFile:   suite.path("node.pem"),
+				KeyFile:    suite.path("node-key.pem"),
+				CAFile:     suite.path("ca.pem"),
+				ClientAuth: util.ClientAuthRequire,
+			},
+		})
+		suite.servers = append(suite.servers, <-channel)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (suite *ClientTestTLS) TearDownSuite() {
+	for _, server := range suite.servers {
+		server.Kill()
+	}
+}
+
+func (suite *ClientTestTLS) path(name string) string {
+	return filepath.Join(suite.dir, name)
+}
+
+func (suite *ClientTestTLS) clientTLS(ca string) *util.TLSConfig {
+	return &util.TLSConfig{
+		CertFile: suite.path("client.pem"),
+		KeyFile:  suite.path("client-key.pem"),
+		CAFile:   ca,
+	}
+}
+
+func (suite *ClientTestTLS) leader() *factory.Server {
+	for _, server := range suite.servers {
+		if server.Raft.State() == raft.Leader {
+			return server
+		}
+	}
+	return nil
+}
+
+func (suite *ClientTestTLS) TestPublishReplicates() {
+	const TOPIC = "TestTLSPublishReplicates"
+	jetClient, err := client.New(suite.address[0], client.WithTLS(suite.clientTLS(suite.path("ca.pem"))))
+	assert.Nil(suite.T(), err)
+	defer jetClient.Close()
+	_, err = jetClient.CreateTopic(TOPIC, 1)
+	assert.Nil(suite.T(), err)
+	_, err = jetClient.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}}, TOPIC)
+	assert.Nil(suite.T(), err)
+	leader := suite.leader()
+	assert.NotNil(suite.T(), leader)
+	//the follower was added through the gossip and gets the writes over tls
+	future := leader.Raft.GetConfiguration()
+	assert.Nil(suite.T(), future.Error())
+	assert.Equal(suite.T(), 2, len(future.Configuration().Servers))
+	assert.Eventually(suite.T(), func() bool {
+		for _, server := range suite.servers {
+			if server.Raft.AppliedIndex() != leader.Raft.LastIndex() {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func (suite *ClientTestTLS) TestRejectsClientsWithoutCertificate() {
+	_, err := client.New(suite.address[0])
+	assert.NotNil(suite.T(), err)
+	_, err = client.New(suite.address[0], client.WithTLS(&util.TLSConfig{CAFile: suite.path("ca.pem")}))
+	assert.NotNil(suite.T(), err)
+}
+
+// the node certificates are replaced by ones of another ca while the nodes run
+func (suite *ClientTestTLS) TestReloadCertificates() {
+	ca := newTestCA(suite.T(), "jet-ca-2")
+	ca.write(suite.T(), suite.path("ca-2.pem"))
+	_, err := client.New(suite.address[0], client.WithTLS(suite.clientTLS(suite.path("ca-2.pem"))))
+	assert.NotNil(suite.T(), err)
+
+	//the nodes trust both cas during the rotation, so the clients of the first one still get in
+	both, err := os.ReadFile(suite.path("ca.pem"))
+	assert.Nil(suite.T(), err)
+	both = append(both, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...)
+	assert.Nil(suite.T(), os.WriteFile(suite.path("ca.pem"), both, 0600))
+	ca.issue(suite.T(), "node", suite.path("node.pem"), suite.path("node-key.pem"))
+	later := time.Now().Add(time.Minute)
+	for _, file := range []string{"ca.pem", "node.pem", "node-key.pem"} {
+		assert.Nil(suite.T(), os.Chtimes(suite.path(file), later, later))
+	}
+
+	jetClient, err := client.New(suite.address[0], client.WithTLS(suite.clientTLS(suite.path("ca-2.pem"))))
+	assert.Nil(suite.T(), err)
+	jetClient.Close()
+}
+
+func TestTLS(t *testing.T) {
+	suite.Run(t, new(ClientTestTLS))
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+func (c *testCA) write(t *testing.T, file string) {
+	assert.Nil(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600))
+}
+
+// issue writes a certificate for localhost usable by servers and clients
+func (c *testCA) issue(t *testing.T, name string, certFile string, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.cert, &key.PublicKey, c.key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+}
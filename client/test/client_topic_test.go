@@ -0,0 +1,98 @@
+package test
+
+import (
+	"fmt"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+// one node hosting two shards, the topics are spread over both
+type ClientTestTopic struct {
+	suite.Suite
+	client *client.JetClient
+	server *factory.Server
+}
+
+func (suite *ClientTestTopic) SetupSuite() {
+	servers := make(chan *factory.Server, 1)
+	log.Print("Starting the server")
+	go factory.SetupServer(
+		&factory.JetConfig{
+			HostAddr:             "localhost:8208",
+			GlobalAdr:            "localhost:8208",
+			NodeName:             "nodeA",
+			GossipAddress:        "localhost:8209",
+			Server:               servers,
+			ShardId:              "shardA",
+			ShardIds:             []string{"shardA", "shardB"},
+			ShardGossipAddresses: []string{"localhost:8210"},
+			InMemory:             true,
+		})
+	suite.server = <-servers
+	time.Sleep(5 * time.Second)
+	jetClient, err := client.New("localhost:8208")
+	assert.Nil(suite.T(), err)
+	suite.client = jetClient
+}
+
+func (suite *ClientTestTopic) TearDownSuite() {
+	suite.client.Close()
+	suite.server.Kill()
+}
+
+func (suite *ClientTestTopic) TestDescribeTopic() {
+	const TOPIC = "TestDescribeTopic"
+	_, err := suite.client.CreateTopic(TOPIC, 4)
+	assert.Nil(suite.T(), err)
+	group, err := suite.client.CreateConsumerGroup(TOPIC)
+	assert.Nil(suite.T(), err)
+	for x := 0; x < 20; x++ {
+		_, err = suite.client.PublishMessage([]*pb.KeyVal{{Key: []byte(fmt.Sprintf("key%d", x)), Val: []byte("val")}}, TOPIC)
+		assert.Nil(suite.T(), err)
+	}
+
+	topic, err := suite.client.DescribeTopic(TOPIC)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{group.Id}, topic.ConsumerGroups)
+	assert.Len(suite.T(), topic.Partitions, 4)
+	shards := map[string]bool{}
+	messages := uint64(0)
+	for x, partition := range topic.Partitions {
+		assert.Equal(suite.T(), uint64(x), partition.Partition)
+		assert.Equal(suite.T(), "nodeA", partition.Leader)
+		assert.False(suite.T(), partition.Fenced)
+		assert.Equal(suite.T(), partition.HighWatermark > 0, partition.Bytes > 0)
+		shards[partition.ShardId] = true
+		messages += partition.HighWatermark
+	}
+	assert.Equal(suite.T(), map[string]bool{"shardA": true, "shardB": true}, shards)
+	assert.Equal(suite.T(), uint64(20), messages)
+
+	_, err = suite.client.DescribeTopic("TestDescribeTopicMissing")
+	assert.ErrorContains(suite.T(), err, "does not exist")
+}
+
+func (suite *ClientTestTopic) TestListTopics() {
+	for _, name := range []string{"TestListTopicsB", "TestListTopicsA"} {
+		_, err := suite.client.CreateTopic(name, 2)
+		assert.Nil(suite.T(), err)
+	}
+	topics, err := suite.client.ListTopics()
+	assert.Nil(suite.T(), err)
+	var names []string
+	for _, topic := range topics {
+		names = append(names, topic.Name)
+	}
+	assert.Subset(suite.T(), names, []string{"TestListTopicsA", "TestListTopicsB"})
+	assert.IsIncreasing(suite.T(), names)
+}
+
+func TestTopic(t *testing.T) {
+	suite.Run(t, new(ClientTestTopic))
+}
@@ -0,0 +1,112 @@
+package test
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/client"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+	"time"
+)
+
+// one node sharing the tracer provider of the test with the client, the spans are kept in memory
+type ClientTestTracing struct {
+	suite.Suite
+	exporter *tracetest.InMemoryExporter
+	server   *factory.Server
+	client   *client.JetClient
+}
+
+func (suite *ClientTestTracing) SetupSuite() {
+	suite.exporter = tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(suite.exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	address := "localhost:8174"
+	channel := make(chan *factory.Server, 5)
+	log.Print("Starting the server")
+	go factory.SetupServer(&factory.JetConfig{
+		HostAddr:      address,
+		GlobalAdr:     address,
+		NodeName:      "nodeA",
+		GossipAddress: "localhost:8175",
+		Server:        channel,
+		ShardId:       "shardTracing",
+		InMemory:      true,
+		Tracing:       &factory.TracingConfig{},
+	})
+	suite.server = <-channel
+	time.Sleep(5 * time.Second)
+	var err error
+	suite.client, err = client.New(address)
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *ClientTestTracing) TearDownSuite() {
+	suite.client.Close()
+	suite.server.Kill()
+	otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+}
+
+// spans returns the ended spans by name, the last one wins. The grpc calls are named after their method on both ends,
+// only the server ones are kept
+func (suite *ClientTestTracing) spans() map[string]tracetest.SpanStub {
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range suite.exporter.GetSpans() {
+		if span.SpanKind != trace.SpanKindClient {
+			spans[span.Name] = span
+		}
+	}
+	return spans
+}
+
+func (suite *ClientTestTracing) TestPublishAndConsume() {
+	_, err := suite.client.CreateTopic("traced", 1)
+	assert.Nil(suite.T(), err)
+	group, err := suite.client.CreateConsumerGroup("traced")
+	assert.Nil(suite.T(), err)
+	suite.exporter.Reset()
+
+	_, err = suite.client.PublishMessage([]*pb.KeyVal{{Key: []byte("key"), Val: []byte("val")}}, "traced")
+	assert.Nil(suite.T(), err)
+	spans := suite.spans()
+	publish := spans["jet.Publish"]
+	//the spans of the client, the grpc call, the raft write and the fsm are in one trace
+	for child, parent := range map[string]string{
+		"jet.PublishPartition": "jet.Publish",
+		"raft.Apply":           "message.MessageService/PublishMessages",
+		"fsm.Publish":          "raft.Apply",
+		"badger.Commit":        "fsm.Publish",
+	} {
+		if assert.Contains(suite.T(), spans, child) && assert.Contains(suite.T(), spans, parent) {
+			assert.Equal(suite.T(), publish.SpanContext.TraceID(), spans[child].SpanContext.TraceID())
+			assert.Equal(suite.T(), spans[parent].SpanContext.SpanID(), spans[child].Parent.SpanID(), child)
+		}
+	}
+
+	messages, err := suite.client.ConsumeMessage("traced", group.GetId())
+	assert.Nil(suite.T(), err)
+	if assert.Equal(suite.T(), 1, len(messages)) {
+		//the consumer finds the publish in the headers of the message
+		producer := trace.SpanContextFromContext(client.TraceContext(context.Background(), messages[0]))
+		assert.Equal(suite.T(), publish.SpanContext.TraceID(), producer.TraceID())
+	}
+	spans = suite.spans()
+	if assert.Contains(suite.T(), spans, "jet.Receive") && assert.Equal(suite.T(), 1, len(spans["jet.Receive"].Links)) {
+		assert.Equal(suite.T(), publish.SpanContext.TraceID(), spans["jet.Receive"].Links[0].SpanContext.TraceID())
+		assert.Equal(suite.T(), spans["jet.Consume"].SpanContext.SpanID(), spans["jet.Receive"].Parent.SpanID())
+	}
+	assert.Equal(suite.T(), spans["jet.Consume"].SpanContext.TraceID(), spans["jet.Read"].SpanContext.TraceID())
+}
+
+func TestTracing(t *testing.T) {
+	suite.Run(t, new(ClientTestTracing))
+}
@@ -3,69 +3,241 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/controller"
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
 	"github.com/Kapperchino/jet-stream/util"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sort"
+	"time"
 )
 
+// TopicInfo describes a topic from the meta of the shards hosting it
+type TopicInfo struct {
+	Name string
+	// Partitions are sorted by number, a partition being moved is listed once per shard holding it
+	Partitions []*PartitionInfo
+	// ConsumerGroups are the ids of the groups consuming the topic, sorted
+	ConsumerGroups []string
+}
+
+// PartitionInfo is a partition as the shard hosting it sees it
+type PartitionInfo struct {
+	Partition uint64
+	ShardId   string
+	// Leader is the leader of the shard, empty when it has none
+	Leader string
+	// HighWatermark is the offset of the last message published to the partition
+	HighWatermark uint64
+	// Bytes is the size of the messages in the stores of the leader
+	Bytes  uint64
+	Fenced bool
+}
+
 func (j *JetClient) CreateTopic(name string, partitions int) (*proto.CreateTopicResponse, error) {
-	val := j.metaData.topics.Get(name)
+	metaData := j.getMeta()
+	val := metaData.topics.Get(name)
 	if val != nil {
 		return nil, errors.New("topic exists")
 	}
+	if controllerClient := j.getController(); controllerClient != nil {
+		return j.createTopicWithController(controllerClient, name, partitions)
+	}
 	var ids []string
-	j.shardClients.ForEach(func(s string, client *ShardClient) bool {
+	j.getShardClients().ForEach(func(s string, client *ShardClient) bool {
 		ids = append(ids, s)
 		return true
 	})
-	//round robind
-	curIndex := 0
-	partitionNum := 0
-	var partitionsToCreate [][]uint64
-	j.shardClients.ForEach(func(s string, client *ShardClient) bool {
-		partitionsToCreate = append(partitionsToCreate, []uint64{})
-		return true
-	})
-	for partitionNum < partitions {
+	if len(ids) == 0 {
+		return nil, errors.New("no shards in the cluster")
+	}
+	//round robin
+	partitionsToCreate := make([][]uint64, len(ids))
+	for partitionNum := 0; partitionNum < partitions; partitionNum++ {
+		curIndex := partitionNum % len(ids)
 		partitionsToCreate[curIndex] = append(partitionsToCreate[curIndex], uint64(partitionNum))
-		partitionNum++
-		curIndex = (curIndex + 1) % j.shardClients.Len()
 	}
 
-	hash := newHashRing()
-	curIndex = 0
-	var err error
-	partitionsMeta := util.NewMap[uint64, *PartitionMeta]()
-	j.shardClients.ForEach(func(s string, client *ShardClient) bool {
-		partitionList := partitionsToCreate[curIndex]
-		curIndex++
+	created := map[uint64]string{}
+	for i, shardId := range ids {
+		partitionList := partitionsToCreate[i]
 		req := proto.CreateTopicRequest{
 			Topic:      name,
 			Partitions: partitionList,
 		}
-		_, err = client.GetLeader().messageClient.CreateTopic(context.Background(), &req)
+		err := j.withRetry(shardId, func(client *ShardClient) error {
+			_, err := client.GetLeader().messageClient.CreateTopic(context.Background(), &req)
+			if err != nil {
+				return err
+			}
+			for _, num := range partitionList {
+				created[num] = client.shardId
+			}
+			return nil
+		})
 		if err != nil {
 			log.Err(err).Msgf("Error creating topic")
-			return false
+			return nil, err
 		}
-		for _, num := range partitionList {
-			meta := &PartitionMeta{
-				partitionNum: num,
-				topic:        name,
-				shardId:      client.shardId,
-			}
-			partitionsMeta.Set(num, meta)
+	}
+	//a refresh running meanwhile swaps the meta, so the topic goes into the current one
+	j.refreshMutex.Lock()
+	defer j.refreshMutex.Unlock()
+	hash := newHashRing()
+	partitionsMeta := util.NewMap[uint64, *PartitionMeta]()
+	shardClients := j.getShardClients()
+	for num, shardId := range created {
+		meta := &PartitionMeta{
+			partitionNum: num,
+			topic:        name,
+			shardId:      shardId,
+		}
+		partitionsMeta.Set(num, meta)
+		hash.Add(meta)
+		if client := shardClients.Get(shardId); client != nil {
 			client.partitions.Set(num, meta)
-			hash.Add(meta)
 		}
-		return true
-	})
-	if err != nil {
-		return nil, err
 	}
-	j.metaData.topics.Set(name, &TopicMeta{
+	j.getMeta().topics.Set(name, &TopicMeta{
 		partitions: partitionsMeta,
 		hash:       hash,
 	})
 	return &proto.CreateTopicResponse{}, nil
 }
+
+// createTopicWithController lets the controller place the topic in one step, so the topic is either fully created or
+// not visible to clients
+func (j *JetClient) createTopicWithController(controllerClient *controller.Client, name string, partitions int) (*proto.CreateTopicResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), controllerTimeout)
+	defer cancel()
+	_, err := createWithController(ctx, controllerClient, &controllerPb.CreateTopicRequest{
+		Topic:      name,
+		Partitions: uint64(partitions),
+	}, controllerPb.ControllerServiceClient.CreateTopic)
+	if status.Code(err) == codes.AlreadyExists {
+		return nil, errors.New("topic exists")
+	}
+	if err != nil {
+		log.Err(err).Msgf("Error creating topic")
+		return nil, err
+	}
+	if err := j.Refresh(); err != nil {
+		return nil, err
+	}
+	return &proto.CreateTopicResponse{}, nil
+}
+
+// createWithController calls the controller again when it timed out waiting for the shards, it then waits for what it
+// already placed, until ctx is done
+func createWithController[Req any, Res any](ctx context.Context, c *controller.Client, req Req,
+	call func(controllerPb.ControllerServiceClient, context.Context, Req, ...grpc.CallOption) (Res, error)) (Res, error) {
+	for {
+		res, err := controller.Call(ctx, c, req, call)
+		if status.Code(err) != codes.DeadlineExceeded {
+			return res, err
+		}
+		select {
+		case <-ctx.Done():
+			return res, err
+		case <-time.After(retryBackoff):
+		}
+	}
+}
+
+// ListTopics describes every topic of the cluster sorted by name
+func (j *JetClient) ListTopics() ([]*TopicInfo, error) {
+	topics, err := j.topicInfos()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*TopicInfo, 0, len(topics))
+	for _, topic := range topics {
+		res = append(res, topic)
+	}
+	sort.Slice(res, func(i, k int) bool {
+		return res[i].Name < res[k].Name
+	})
+	return res, nil
+}
+
+// DescribeTopic describes the partitions of the topic and the consumer groups attached to it
+func (j *JetClient) DescribeTopic(name string) (*TopicInfo, error) {
+	topics, err := j.topicInfos()
+	if err != nil {
+		return nil, err
+	}
+	topic := topics[name]
+	if topic == nil {
+		return nil, fmt.Errorf("topic %s does not exist", name)
+	}
+	return topic, nil
+}
+
+// topicInfos aggregates the meta of the leader of every shard, sized
+func (j *JetClient) topicInfos() (map[string]*TopicInfo, error) {
+	if err := j.Refresh(); err != nil {
+		return nil, err
+	}
+	var shardIds []string
+	j.getShardClients().ForEach(func(shardId string, client *ShardClient) bool {
+		shardIds = append(shardIds, shardId)
+		return true
+	})
+	sort.Strings(shardIds)
+	topics := map[string]*TopicInfo{}
+	groups := map[string]map[string]bool{}
+	for _, shardId := range shardIds {
+		var meta *proto.GetMetaResponse
+		var leader string
+		err := j.withRetry(shardId, func(client *ShardClient) error {
+			ctx, cancel := context.WithTimeout(context.Background(), clusterInfoTimeout)
+			defer cancel()
+			res, err := client.GetLeader().messageClient.GetMeta(ctx, &proto.GetMetaRequest{WithSizes: true})
+			if err != nil {
+				return err
+			}
+			meta, leader = res, client.getLeaderId()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for name, topic := range meta.GetTopics() {
+			info := topics[name]
+			if info == nil {
+				info = &TopicInfo{Name: name}
+				topics[name] = info
+				groups[name] = map[string]bool{}
+			}
+			for num, partition := range topic.GetPartitions() {
+				info.Partitions = append(info.Partitions, &PartitionInfo{
+					Partition:     num,
+					ShardId:       shardId,
+					Leader:        leader,
+					HighWatermark: partition.GetOffset(),
+					Bytes:         partition.GetBytes(),
+					Fenced:        partition.GetFenced(),
+				})
+			}
+		}
+		for id, group := range meta.GetConsumerGroups() {
+			if groups[group.GetTopic()] != nil {
+				groups[group.GetTopic()][id] = true
+			}
+		}
+	}
+	for name, info := range topics {
+		sort.SliceStable(info.Partitions, func(i, k int) bool {
+			return info.Partitions[i].Partition < info.Partitions[k].Partition
+		})
+		for id := range groups[name] {
+			info.ConsumerGroups = append(info.ConsumerGroups, id)
+		}
+		sort.Strings(info.ConsumerGroups)
+	}
+	return topics, nil
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const watchBackoff = time.Second
+
+// startWatchers keeps one WatchCluster stream per shard, watchers of shards that are gone are stopped
+func (j *JetClient) startWatchers(shardClients *util.Map[string, *ShardClient]) {
+	shardClients.ForEach(func(shardId string, client *ShardClient) bool {
+		if j.watchers.Get(shardId) != nil {
+			return true
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		j.watchers.Set(shardId, cancel)
+		go j.watchShard(ctx, shardId)
+		return true
+	})
+	//the map is locked while iterating, removed shards are deleted afterwards
+	var removed []string
+	j.watchers.ForEach(func(shardId string, cancel context.CancelFunc) bool {
+		if shardClients.Get(shardId) == nil {
+			cancel()
+			removed = append(removed, shardId)
+		}
+		return true
+	})
+	for _, shardId := range removed {
+		j.watchers.Del(shardId)
+	}
+}
+
+func (j *JetClient) stopWatchers() {
+	j.watchers.ForEach(func(shardId string, cancel context.CancelFunc) bool {
+		cancel()
+		return true
+	})
+}
+
+// watchShard follows the topology changes seen by a member of the shard, reconnecting and resuming from the last
+// version when the stream breaks
+func (j *JetClient) watchShard(ctx context.Context, shardId string) {
+	var epoch string
+	var version uint64
+	for {
+		client := j.getShardClients().Get(shardId)
+		if client == nil {
+			return
+		}
+		if member := client.GetNextMember(); member != nil {
+			stream, err := member.clusterClient.WatchCluster(ctx, &clusterPb.WatchClusterRequest{
+				Epoch:       epoch,
+				FromVersion: version,
+			})
+			for err == nil {
+				var event *clusterPb.ClusterEvent
+				event, err = stream.Recv()
+				if err != nil {
+					break
+				}
+				//a snapshot after we have seen events means the history was lost, our view might be stale
+				if event.Type != clusterPb.ClusterEventType_SNAPSHOT || epoch != "" {
+					j.applyEvent(event)
+				}
+				epoch, version = event.Epoch, event.Version
+			}
+			log.Debug().Err(err).Msgf("Watch of shard %s stopped", shardId)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.stop:
+			return
+		case <-time.After(watchBackoff):
+		}
+	}
+}
+
+// applyEvent updates the routing tables, leader changes are applied in place and anything else reloads the meta
+func (j *JetClient) applyEvent(event *clusterPb.ClusterEvent) {
+	log.Debug().Msgf("Cluster event %s for shard %s, version %d", event.Type, event.ShardId, event.Version)
+	if event.Type == clusterPb.ClusterEventType_LEADER_CHANGED {
+		client := j.getShardClients().Get(event.ShardId)
+		if client != nil && client.setLeader(event.GetShard().GetLeaderId()) {
+			return
+		}
+	}
+	if err := j.Refresh(); err != nil {
+		log.Warn().Err(err).Msgf("Error refreshing the cluster meta after %s", event.Type)
+	}
+}
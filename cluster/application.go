@@ -7,46 +7,33 @@ import (
 	"github.com/hashicorp/memberlist"
 	"github.com/hashicorp/raft"
 	"github.com/rs/zerolog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
 )
 
+// joinTimeout is how long the leader waits for the raft group to take a joining node
+const joinTimeout = 20 * time.Second
+
 type RpcInterface struct {
 	Raft         *raft.Raft
 	ClusterState *ClusterState
 	Logger       *zerolog.Logger
 	MemberList   *memberlist.Memberlist
+	// Controllers are the addresses of the metadata controllers, advertised to clients through GetClusterInfo
+	Controllers []string
+	// Authorize checks the callers allowed to change the members of the shard, anyone is when it is nil
+	Authorize func(ctx context.Context) error
 	pb.UnimplementedClusterMetaServiceServer
 }
 
 func InitClusterState(i *RpcInterface, nodeName string, address string, shardId string, logger *zerolog.Logger, raftPtr *raft.Raft) *ClusterState {
-	clusterState := ClusterState{
-		ClusterInfo: util.NewMap[string, *ShardInfo](),
-		CurShardState: &ShardState{
-			RaftChan: make(chan raft.Observation, 50),
-			ShardInfo: &ShardInfo{
-				shardId:   shardId,
-				Leader:    "",
-				nodeId:    nodeName,
-				MemberMap: util.NewMap[string, *MemberInfo](),
-			},
-			MemberInfo: &MemberInfo{
-				NodeId:   nodeName,
-				IsLeader: false,
-				Address:  address,
-			},
-			Raft: raftPtr,
-		},
-		Logger: logger,
-	}
-	clusterState.CurShardState.ShardInfo.MemberMap.Set(nodeName, &MemberInfo{
-		NodeId:   nodeName,
-		IsLeader: false,
-		Address:  address,
-	})
-	clusterState.ClusterInfo.Set(shardId, clusterState.CurShardState.ShardInfo)
+	clusterState := NewClusterState(nodeName, address, shardId, logger)
+	clusterState.CurShardState.Raft = raftPtr
 	observer := raft.NewObserver(clusterState.CurShardState.RaftChan, false, nil)
 	i.Raft.RegisterObserver(observer)
 	go onRaftUpdates(clusterState.CurShardState.RaftChan, i)
-	return &clusterState
+	return clusterState
 }
 
 func (r RpcInterface) GetShardInfo(_ context.Context, req *pb.GetShardInfoRequest) (*pb.GetShardInfoResponse, error) {
@@ -63,35 +50,139 @@ func (r RpcInterface) GetShardInfo(_ context.Context, req *pb.GetShardInfoReques
 		res.GetInfo().MemberAddressMap[s] = &pb.MemberInfo{
 			NodeId:  info.NodeId,
 			Address: info.Address,
+			Role:    info.Role(),
 		}
 		return true
 	})
 	return &res, nil
 }
 
+// JoinShard adds the node to the raft group of the shard, as a nonvoter if it is a read replica. A node that is already
+// a member with the same address is left as it is
+func (r RpcInterface) JoinShard(ctx context.Context, req *pb.JoinShardRequest) (*pb.JoinShardResponse, error) {
+	if req.GetNodeId() == "" || req.GetAddress() == "" {
+		return nil, status.Error(codes.InvalidArgument, "joining a shard needs the node id and its address")
+	}
+	if r.Authorize != nil {
+		if err := r.Authorize(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if r.Raft.State() != raft.Leader {
+		return nil, status.Errorf(codes.Unavailable, "node %s is not the leader of shard %s, the leader is %q", r.ClusterState.getNodeId(), r.ClusterState.getShardId(), r.ClusterState.getLeader())
+	}
+	add := r.Raft.AddVoter
+	if req.GetRole() == pb.MemberRole_READ_REPLICA {
+		add = r.Raft.AddNonvoter
+	}
+	if err := add(raft.ServerID(req.GetNodeId()), raft.ServerAddress(req.GetAddress()), 0, joinTimeout).Error(); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "adding %s to shard %s: %v", req.GetNodeId(), r.ClusterState.getShardId(), err)
+	}
+	r.Logger.Info().Msgf("%s joined shard %s as %s", req.GetNodeId(), r.ClusterState.getShardId(), req.GetRole())
+	return &pb.JoinShardResponse{}, nil
+}
+
+// LeaveShard removes the node from the raft group and the members of the shard info. A voter is only removed while the
+// voters left reachable keep a quorum, unless it is forced
+func (r RpcInterface) LeaveShard(ctx context.Context, req *pb.LeaveShardRequest) (*pb.LeaveShardResponse, error) {
+	if req.GetNodeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "leaving a shard needs the node id")
+	}
+	if r.Authorize != nil {
+		if err := r.Authorize(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if r.Raft.State() != raft.Leader {
+		return nil, status.Errorf(codes.Unavailable, "node %s is not the leader of shard %s, the leader is %q", r.ClusterState.getNodeId(), r.ClusterState.getShardId(), r.ClusterState.getLeader())
+	}
+	if req.GetNodeId() == r.ClusterState.getNodeId() {
+		return nil, status.Errorf(codes.FailedPrecondition, "the leader of shard %s can't leave it, it has to hand the leadership over first", r.ClusterState.getShardId())
+	}
+	future := r.Raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if !req.GetForce() {
+		if err := leaveAllowed(future.Configuration().Servers, raft.ServerID(req.GetNodeId()), r.ClusterState.unreachable); err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+	if err := removeMember(&r, raft.ServerID(req.GetNodeId()), joinTimeout); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "removing %s from shard %s: %v", req.GetNodeId(), r.ClusterState.getShardId(), err)
+	}
+	r.Logger.Info().Msgf("%s left shard %s", req.GetNodeId(), r.ClusterState.getShardId())
+	return &pb.LeaveShardResponse{}, nil
+}
+
 func (r RpcInterface) GetClusterInfo(context.Context, *pb.GetClusterInfoRequest) (*pb.GetClusterInfoResponse, error) {
 	clusterMap := r.ClusterState.ClusterInfo
 	res := &pb.GetClusterInfoResponse{Info: &pb.ClusterInfo{}}
 	resMap := make(map[string]*pb.ShardInfo)
 	clusterMap.ForEach(func(s string, info *ShardInfo) bool {
-		resMap[s] = &pb.ShardInfo{
-			MemberAddressMap: toProtoMemberMap(info.MemberMap),
-			LeaderId:         info.Leader,
-			ShardId:          info.shardId,
-			NodeId:           info.nodeId,
-		}
+		resMap[s] = toProtoShardInfo(info)
 		return true
 	})
 	res.Info.ShardMap = resMap
+	res.Info.Controllers = r.Controllers
 	return res, nil
 }
 
+// WatchCluster sends the missed events or a snapshot, then every topology change until the watcher goes away
+func (r RpcInterface) WatchCluster(req *pb.WatchClusterRequest, stream pb.ClusterMetaService_WatchClusterServer) error {
+	hub := r.ClusterState.Events
+	watcher := hub.Subscribe(req.GetEpoch(), req.GetFromVersion())
+	defer hub.Unsubscribe(watcher)
+	if !watcher.Resumed {
+		info, _ := r.GetClusterInfo(stream.Context(), &pb.GetClusterInfoRequest{})
+		err := stream.Send(&pb.ClusterEvent{
+			Epoch:   hub.Epoch(),
+			Version: watcher.Version,
+			Type:    pb.ClusterEventType_SNAPSHOT,
+			Cluster: info.Info,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, event := range watcher.Backlog {
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell behind, resume from the last version")
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toProtoShardInfo(info *ShardInfo) *pb.ShardInfo {
+	return &pb.ShardInfo{
+		MemberAddressMap: toProtoMemberMap(info.MemberMap),
+		LeaderId:         info.Leader,
+		ShardId:          info.shardId,
+		NodeId:           info.nodeId,
+		Term:             info.term,
+		Version:          info.version,
+	}
+}
+
 func toProtoMemberMap(memberMap *util.Map[string, *MemberInfo]) map[string]*pb.MemberInfo {
 	res := make(map[string]*pb.MemberInfo)
 	memberMap.ForEach(func(s string, info *MemberInfo) bool {
 		res[s] = &pb.MemberInfo{
 			NodeId:  info.NodeId,
 			Address: info.Address,
+			Role:    info.Role(),
 		}
 		return true
 	})
@@ -2,25 +2,26 @@ package cluster
 
 import (
 	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/config"
 	grpc_retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
 	"time"
 )
 
-func CreateClusterClient(address string) (clusterPb.ClusterMetaServiceClient, error) {
+func CreateClusterClient(address string, dialOptions ...grpc.DialOption) (clusterPb.ClusterMetaServiceClient, error) {
 	serviceConfig := `{"healthCheckConfig": {"serviceName": "Example"}, "loadBalancingConfig": [ { "round_robin": {} } ]}`
 	retryOpts := []grpc_retry.CallOption{
 		grpc_retry.WithBackoff(grpc_retry.BackoffExponential(100 * time.Millisecond)),
 		grpc_retry.WithMax(5),
 	}
-	maxSize := 1 * 1024 * 1024 * 1024
-	conn, err := grpc.Dial(address,
+	maxSize := config.DefaultMaxMessageSize
+	conn, err := grpc.Dial(address, append([]grpc.DialOption{
 		grpc.WithDefaultServiceConfig(serviceConfig), grpc.WithInsecure(),
 		grpc.WithDefaultCallOptions(grpc.WaitForReady(true),
 			grpc.MaxCallRecvMsgSize(maxSize),
 			grpc.MaxCallSendMsgSize(maxSize)),
-		grpc.WithUnaryInterceptor(grpc_retry.UnaryClientInterceptor(retryOpts...)))
+		grpc.WithUnaryInterceptor(grpc_retry.UnaryClientInterceptor(retryOpts...))}, dialOptions...)...)
 	if err != nil {
 		log.Err(err).Msgf("Error creating connection for clusterService at port %s", address)
 		return nil, err
@@ -5,6 +5,7 @@ import (
 	"github.com/Kapperchino/jet-stream/util"
 	"github.com/hashicorp/memberlist"
 	"github.com/rs/zerolog"
+	"strings"
 )
 
 type ClusterListener struct {
@@ -15,45 +16,38 @@ func (c ClusterListener) Logger() *zerolog.Logger {
 	return c.state.Logger
 }
 
+// NotifyJoin applies the shard state in the meta of the node, NotifyLeave is called for nodes that left or failed
 func (c ClusterListener) NotifyJoin(node *memberlist.Node) {
 	c.Logger().Info().Msgf("node %s has joined the cluster", node.Name)
+	c.mergeMeta(node)
 }
 
+// NotifyLeave removes the member from its shard, the shard is removed with its last member
 func (c ClusterListener) NotifyLeave(node *memberlist.Node) {
-	c.Logger().Warn().Msgf("Shard %s has left the cluster")
-	item := c.state.ClusterInfo.Get(node.Name)
-	if item == nil {
+	c.Logger().Warn().Msgf("node %s has left the cluster", node.Name)
+	shardId, nodeId, ok := strings.Cut(node.Name, "/")
+	if !ok {
 		return
 	}
-	c.state.ClusterInfo.Del(node.Name)
+	c.state.removeMember(shardId, nodeId)
 }
 
 func (c ClusterListener) NotifyUpdate(node *memberlist.Node) {
-	//TODO implement me
 	if node.Name == c.state.getShardId()+"/"+c.state.getNodeId() {
 		return
 	}
-	c.Logger().Info().Msgf("node %s has been updated", node.Name)
-	var meta proto.ShardInfo
-	err := util.DeserializeMessage(node.Meta, &meta)
-	if err != nil {
-		c.Logger().Err(err).Msgf("Error deserializing message")
+	c.Logger().Debug().Msgf("node %s has been updated", node.Name)
+	c.mergeMeta(node)
+}
+
+func (c ClusterListener) mergeMeta(node *memberlist.Node) {
+	if len(node.Meta) == 0 {
 		return
 	}
-	var localShardInfo = c.state.ClusterInfo.Get(meta.ShardId)
-	if localShardInfo == nil {
-		c.Logger().Info().Msgf("what the heeeeeell")
+	var meta proto.ShardInfo
+	if err := util.DeserializeMessage(node.Meta, &meta); err != nil {
+		c.Logger().Err(err).Msgf("Error deserializing the meta of %s", node.Name)
 		return
 	}
-	localShardInfo.Leader = meta.LeaderId
-	localShardInfo.MemberMap.ForEach(func(key string, val *MemberInfo) bool {
-		val.Address = meta.MemberAddressMap[key].Address
-		val.IsLeader = val.NodeId == meta.LeaderId
-		return true
-	})
-}
-
-func (c ClusterListener) Find(node *memberlist.Node) {
-	//TODO implement me
-	panic("implement me")
+	c.state.mergeShard(&meta)
 }
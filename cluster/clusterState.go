@@ -1,9 +1,11 @@
 package cluster
 
 import (
+	pb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
 	"github.com/Kapperchino/jet-stream/util"
 	"github.com/hashicorp/raft"
 	"github.com/rs/zerolog"
+	"time"
 )
 
 type ClusterState struct {
@@ -11,6 +13,8 @@ type ClusterState struct {
 	CurShardState *ShardState
 	ClusterInfo   *util.Map[string, *ShardInfo]
 	Logger        *zerolog.Logger
+	Events        *EventHub
+	Gossip        *Gossip
 }
 
 type ShardState struct {
@@ -18,12 +22,24 @@ type ShardState struct {
 	MemberInfo *MemberInfo
 	RaftChan   chan raft.Observation
 	Raft       *raft.Raft
+	// Unreachable are the peers the leader failed to heartbeat, with when it last reached them
+	Unreachable *util.Map[string, time.Time]
 }
 
 type MemberInfo struct {
 	NodeId   string
 	IsLeader bool
 	Address  string
+	// ReadReplica is a raft nonvoter, it replicates the shard and serves reads but never leads or counts in the quorum
+	ReadReplica bool
+}
+
+// Role is how the member shows up in the shard info
+func (m *MemberInfo) Role() pb.MemberRole {
+	if m.ReadReplica {
+		return pb.MemberRole_READ_REPLICA
+	}
+	return pb.MemberRole_VOTER
 }
 
 type ShardInfo struct {
@@ -31,6 +47,54 @@ type ShardInfo struct {
 	nodeId    string
 	Leader    string
 	MemberMap *util.Map[string, *MemberInfo]
+	term      uint64
+	version   uint64
+}
+
+func (s *ShardInfo) GetShardId() string {
+	return s.shardId
+}
+
+// NewClusterState is the state of a node in the shard that only knows about itself, it isn't tied to raft so the
+// gossip can run on its own
+func NewClusterState(nodeName string, address string, shardId string, logger *zerolog.Logger) *ClusterState {
+	clusterState := ClusterState{
+		ClusterInfo: util.NewMap[string, *ShardInfo](),
+		CurShardState: &ShardState{
+			RaftChan:    make(chan raft.Observation, 50),
+			Unreachable: util.NewMap[string, time.Time](),
+			ShardInfo: &ShardInfo{
+				shardId:   shardId,
+				Leader:    "",
+				nodeId:    nodeName,
+				MemberMap: util.NewMap[string, *MemberInfo](),
+			},
+			MemberInfo: &MemberInfo{
+				NodeId:   nodeName,
+				IsLeader: false,
+				Address:  address,
+			},
+		},
+		Logger: logger,
+		Events: NewEventHub(nodeName),
+		Gossip: NewGossip(),
+	}
+	clusterState.CurShardState.ShardInfo.MemberMap.Set(nodeName, &MemberInfo{
+		NodeId:   nodeName,
+		IsLeader: false,
+		Address:  address,
+	})
+	clusterState.ClusterInfo.Set(shardId, clusterState.CurShardState.ShardInfo)
+	return &clusterState
+}
+
+// MarkReadReplica makes this node join its shard as a read replica, the leader adds it as a nonvoter. It has to be
+// called before the node gossips
+func (c ClusterState) MarkReadReplica() {
+	c.getMemberInfo().ReadReplica = true
+	if member := c.getMemberMap().Get(c.getNodeId()); member != nil {
+		member.ReadReplica = true
+	}
 }
 
 func (c ClusterState) GetShardInfo() *ShardInfo {
@@ -60,3 +124,28 @@ func (c ClusterState) getShardId() string {
 func (c ClusterState) getShardState() *ShardState {
 	return c.CurShardState
 }
+
+// publishShardEvent notifies the watchers that the shard changed
+func (c ClusterState) publishShardEvent(eventType pb.ClusterEventType, shardInfo *ShardInfo, nodeId string) {
+	if c.Events == nil {
+		return
+	}
+	c.Events.Publish(&pb.ClusterEvent{
+		Type:    eventType,
+		ShardId: shardInfo.shardId,
+		Shard:   toProtoShardInfo(shardInfo),
+		NodeId:  nodeId,
+	})
+}
+
+// PublishTopicCreated notifies the watchers that a topic was created on this shard
+func (c ClusterState) PublishTopicCreated(topic string) {
+	if c.Events == nil {
+		return
+	}
+	c.Events.Publish(&pb.ClusterEvent{
+		Type:    pb.ClusterEventType_TOPIC_CREATED,
+		ShardId: c.getShardId(),
+		Topic:   topic,
+	})
+}
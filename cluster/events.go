@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"fmt"
+	pb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"sync"
+	"time"
+)
+
+const (
+	// historySize is how many events are kept around for watchers to resume from
+	historySize = 256
+	// watcherBuffer is how many events a slow watcher can lag behind before it is dropped
+	watcherBuffer = 64
+)
+
+// EventHub versions topology changes of the node and fans them out to the watchers
+type EventHub struct {
+	epoch       string
+	version     uint64
+	history     []*pb.ClusterEvent
+	watchers    map[uint64]chan *pb.ClusterEvent
+	nextWatcher uint64
+	mutex       sync.Mutex
+}
+
+// Watcher is a subscription to the hub, Backlog holds the events missed since the requested version when Resumed is
+// true, otherwise the watcher has to start from a snapshot taken at Version
+type Watcher struct {
+	id      uint64
+	Events  <-chan *pb.ClusterEvent
+	Backlog []*pb.ClusterEvent
+	Resumed bool
+	Version uint64
+}
+
+func NewEventHub(nodeId string) *EventHub {
+	return &EventHub{
+		//a restarted node starts a new history, the epoch tells watchers their version is from the old one
+		epoch:    fmt.Sprintf("%s-%d", nodeId, time.Now().UnixNano()),
+		watchers: map[uint64]chan *pb.ClusterEvent{},
+	}
+}
+
+func (h *EventHub) Epoch() string {
+	return h.epoch
+}
+
+// Publish stamps the event with the next version and sends it to every watcher, watchers that can't keep up are
+// closed so they reconnect and resume
+func (h *EventHub) Publish(event *pb.ClusterEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.version++
+	event.Epoch = h.epoch
+	event.Version = h.version
+	h.history = append(h.history, event)
+	if len(h.history) > historySize {
+		h.history = h.history[len(h.history)-historySize:]
+	}
+	for id, events := range h.watchers {
+		select {
+		case events <- event:
+		default:
+			close(events)
+			delete(h.watchers, id)
+		}
+	}
+}
+
+// Subscribe registers a watcher, it resumes after fromVersion if the epoch matches and the events are still in the
+// history
+func (h *EventHub) Subscribe(epoch string, fromVersion uint64) *Watcher {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	events := make(chan *pb.ClusterEvent, watcherBuffer)
+	h.nextWatcher++
+	h.watchers[h.nextWatcher] = events
+	watcher := &Watcher{
+		id:      h.nextWatcher,
+		Events:  events,
+		Version: h.version,
+	}
+	if epoch != h.epoch || fromVersion > h.version || fromVersion < h.oldestVersion()-1 {
+		return watcher
+	}
+	watcher.Resumed = true
+	for _, event := range h.history {
+		if event.Version > fromVersion {
+			watcher.Backlog = append(watcher.Backlog, event)
+		}
+	}
+	return watcher
+}
+
+func (h *EventHub) Unsubscribe(watcher *Watcher) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if events, ok := h.watchers[watcher.id]; ok {
+		close(events)
+		delete(h.watchers, watcher.id)
+	}
+}
+
+func (h *EventHub) oldestVersion() uint64 {
+	if len(h.history) == 0 {
+		return h.version + 1
+	}
+	return h.history[0].Version
+}
@@ -0,0 +1,246 @@
+package cluster
+
+import (
+	pb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/memberlist"
+	"sync"
+	"time"
+)
+
+// retransmitMult is how many times a broadcast is sent, scaled by the log of the cluster size
+const retransmitMult = 4
+
+// Gossip spreads shard states with memberlist broadcasts. Each state is versioned with a hybrid clock, it is never
+// behind the wall clock and jumps past every version received, so a restarted leader still sends newer states
+type Gossip struct {
+	queue   *memberlist.TransmitLimitedQueue
+	members *memberlist.Memberlist
+	clock   uint64
+	// removed keeps the version of the shards that left, so a late broadcast doesn't bring them back
+	removed map[string]uint64
+	mutex   sync.Mutex
+}
+
+func NewGossip() *Gossip {
+	g := &Gossip{removed: map[string]uint64{}}
+	g.queue = &memberlist.TransmitLimitedQueue{
+		NumNodes: func() int {
+			g.mutex.Lock()
+			defer g.mutex.Unlock()
+			if g.members == nil {
+				return 1
+			}
+			return g.members.NumMembers()
+		},
+		RetransmitMult: retransmitMult,
+	}
+	return g
+}
+
+// SetMemberList sizes the retransmits with the members of the list, it is created after the delegate
+func (g *Gossip) SetMemberList(members *memberlist.Memberlist) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.members = members
+}
+
+func (g *Gossip) GetBroadcasts(overhead, limit int) [][]byte {
+	return g.queue.GetBroadcasts(overhead, limit)
+}
+
+// NumQueued is the number of broadcasts that still have to be sent
+func (g *Gossip) NumQueued() int {
+	return g.queue.NumQueued()
+}
+
+func (g *Gossip) tick() uint64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.clock++
+	if now := uint64(time.Now().UnixNano()); now > g.clock {
+		g.clock = now
+	}
+	return g.clock
+}
+
+func (g *Gossip) witness(version uint64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if version > g.clock {
+		g.clock = version
+	}
+}
+
+func (g *Gossip) queueShard(shardId string, msg []byte) {
+	g.queue.QueueBroadcast(&shardBroadcast{shardId: shardId, msg: msg})
+}
+
+// shardBroadcast is the state of a shard, a newer one replaces it in the queue
+type shardBroadcast struct {
+	shardId string
+	msg     []byte
+}
+
+func (b *shardBroadcast) Invalidates(other memberlist.Broadcast) bool {
+	item, ok := other.(*shardBroadcast)
+	return ok && item.shardId == b.shardId
+}
+
+func (b *shardBroadcast) Message() []byte {
+	return b.msg
+}
+
+func (b *shardBroadcast) Finished() {}
+
+// BroadcastShard sends the state of the local shard with a new version, only the leader does so since the raft
+// configuration it has is the source of truth for the shard
+func (c ClusterState) BroadcastShard() {
+	if c.Gossip == nil || c.getLeader() != c.getNodeId() {
+		return
+	}
+	shardInfo := c.GetShardInfo()
+	shardInfo.version = c.Gossip.tick()
+	msg, err := util.SerializeMessage(&pb.GossipMessage{Shard: toProtoShardInfo(shardInfo)})
+	if err != nil {
+		c.Logger.Err(err).Msgf("Error serializing the state of shard %s", shardInfo.shardId)
+		return
+	}
+	c.Gossip.queueShard(shardInfo.shardId, msg)
+}
+
+// SetLeader records the leader elected in the term and broadcasts the change if this node is the leader
+func (c ClusterState) SetLeader(leaderId string, term uint64) {
+	shardInfo := c.GetShardInfo()
+	shardInfo.Leader = leaderId
+	if term > shardInfo.term {
+		shardInfo.term = term
+	}
+	c.getMemberInfo().IsLeader = leaderId == c.getNodeId()
+	shardInfo.MemberMap.ForEach(func(key string, val *MemberInfo) bool {
+		val.IsLeader = val.NodeId == leaderId
+		return true
+	})
+	c.publishShardEvent(pb.ClusterEventType_LEADER_CHANGED, shardInfo, leaderId)
+	c.BroadcastShard()
+}
+
+// SetMember adds a voter to the local shard
+func (c ClusterState) SetMember(nodeId string, address string) {
+	c.AddMember(nodeId, address, false)
+}
+
+// AddMember adds a voter or a read replica to the local shard
+func (c ClusterState) AddMember(nodeId string, address string, readReplica bool) {
+	c.getMemberMap().Set(nodeId, &MemberInfo{
+		NodeId:      nodeId,
+		IsLeader:    nodeId == c.getLeader(),
+		Address:     address,
+		ReadReplica: readReplica,
+	})
+	c.publishShardEvent(pb.ClusterEventType_MEMBER_ADDED, c.GetShardInfo(), nodeId)
+	c.BroadcastShard()
+}
+
+// DelMember removes the member from the local shard
+func (c ClusterState) DelMember(nodeId string) {
+	c.getMemberMap().Del(nodeId)
+	c.publishShardEvent(pb.ClusterEventType_MEMBER_REMOVED, c.GetShardInfo(), nodeId)
+	c.BroadcastShard()
+}
+
+// mergeShard applies the state of another shard if it is newer than the one known, it returns true if it was applied
+// so the caller spreads it further
+func (c ClusterState) mergeShard(remote *pb.ShardInfo) bool {
+	if remote == nil || remote.ShardId == "" || remote.ShardId == c.getShardId() {
+		return false
+	}
+	current := c.ClusterInfo.Get(remote.ShardId)
+	if current != nil && !isNewer(remote, current) {
+		return false
+	}
+	if c.Gossip != nil {
+		c.Gossip.witness(remote.Version)
+		c.Gossip.mutex.Lock()
+		removed, ok := c.Gossip.removed[remote.ShardId]
+		if ok && current == nil && remote.Version <= removed {
+			c.Gossip.mutex.Unlock()
+			return false
+		}
+		delete(c.Gossip.removed, remote.ShardId)
+		c.Gossip.mutex.Unlock()
+	}
+	shardInfo := fromProtoShardInfo(remote)
+	c.ClusterInfo.Set(remote.ShardId, shardInfo)
+	if current != nil && current.Leader != shardInfo.Leader {
+		c.publishShardEvent(pb.ClusterEventType_LEADER_CHANGED, shardInfo, shardInfo.Leader)
+	} else {
+		c.publishShardEvent(pb.ClusterEventType_SHARD_UPDATED, shardInfo, remote.NodeId)
+	}
+	return true
+}
+
+// removeMember drops a member of another shard that left or failed, the shard goes with its last member. The version
+// isn't changed, a newer state from the leader of the shard still replaces it
+func (c ClusterState) removeMember(shardId string, nodeId string) {
+	if shardId == c.getShardId() {
+		return
+	}
+	item := c.ClusterInfo.Get(shardId)
+	if item == nil {
+		return
+	}
+	item.MemberMap.Del(nodeId)
+	if item.MemberMap.Len() > 0 {
+		c.publishShardEvent(pb.ClusterEventType_MEMBER_REMOVED, item, nodeId)
+		return
+	}
+	c.ClusterInfo.Del(shardId)
+	if c.Gossip != nil {
+		c.Gossip.mutex.Lock()
+		c.Gossip.removed[shardId] = item.version
+		c.Gossip.mutex.Unlock()
+	}
+	c.publishShardEvent(pb.ClusterEventType_SHARD_REMOVED, item, nodeId)
+}
+
+// gossipState is the push/pull state of the node, the local shard and every shard it learned about
+func (c ClusterState) gossipState() *pb.GossipState {
+	res := &pb.GossipState{
+		ShardId: c.getShardId(),
+		NodeId:  c.getNodeId(),
+		Shards:  map[string]*pb.ShardInfo{},
+	}
+	c.ClusterInfo.ForEach(func(shardId string, info *ShardInfo) bool {
+		res.Shards[shardId] = toProtoShardInfo(info)
+		return true
+	})
+	return res
+}
+
+func isNewer(remote *pb.ShardInfo, current *ShardInfo) bool {
+	if remote.Term != current.term {
+		return remote.Term > current.term
+	}
+	return remote.Version > current.version
+}
+
+func fromProtoShardInfo(info *pb.ShardInfo) *ShardInfo {
+	memberMap := util.NewMap[string, *MemberInfo]()
+	for key, val := range info.MemberAddressMap {
+		memberMap.Set(key, &MemberInfo{
+			NodeId:      val.NodeId,
+			IsLeader:    val.NodeId == info.LeaderId,
+			Address:     val.Address,
+			ReadReplica: val.Role == pb.MemberRole_READ_REPLICA,
+		})
+	}
+	return &ShardInfo{
+		shardId:   info.ShardId,
+		nodeId:    info.NodeId,
+		Leader:    info.LeaderId,
+		MemberMap: memberMap,
+		term:      info.Term,
+		version:   info.Version,
+	}
+}
@@ -0,0 +1,73 @@
+package cluster
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// KeyManager changes the keys the gossip of a node is encrypted with. A key is rotated by installing it on every node,
+// using it on every node, then removing the old one, the nodes can decrypt with both keys in between
+type KeyManager interface {
+	InstallKey(key []byte) error
+	UseKey(key []byte) error
+	RemoveKey(key []byte) error
+	ListKeys() (primary []byte, keys [][]byte)
+}
+
+func (n NodeRpc) InstallKey(ctx context.Context, req *pb.InstallKeyRequest) (*pb.InstallKeyResponse, error) {
+	if err := n.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := n.changeKey(req.GetKey(), KeyManager.InstallKey); err != nil {
+		return nil, err
+	}
+	return &pb.InstallKeyResponse{}, nil
+}
+
+func (n NodeRpc) UseKey(ctx context.Context, req *pb.UseKeyRequest) (*pb.UseKeyResponse, error) {
+	if err := n.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := n.changeKey(req.GetKey(), KeyManager.UseKey); err != nil {
+		return nil, err
+	}
+	return &pb.UseKeyResponse{}, nil
+}
+
+func (n NodeRpc) RemoveKey(ctx context.Context, req *pb.RemoveKeyRequest) (*pb.RemoveKeyResponse, error) {
+	if err := n.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := n.changeKey(req.GetKey(), KeyManager.RemoveKey); err != nil {
+		return nil, err
+	}
+	return &pb.RemoveKeyResponse{}, nil
+}
+
+func (n NodeRpc) ListKeys(ctx context.Context, _ *pb.ListKeysRequest) (*pb.ListKeysResponse, error) {
+	if err := n.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if n.Keys == nil {
+		return nil, errEncryptionDisabled
+	}
+	primary, keys := n.Keys.ListKeys()
+	return &pb.ListKeysResponse{PrimaryKey: primary, Keys: keys}, nil
+}
+
+var errEncryptionDisabled = status.Error(codes.FailedPrecondition, "gossip encryption is not enabled on this node")
+
+func (n NodeRpc) changeKey(key []byte, change func(KeyManager, []byte) error) error {
+	if n.Keys == nil {
+		return errEncryptionDisabled
+	}
+	if len(key) == 0 {
+		return status.Error(codes.InvalidArgument, "a key is needed")
+	}
+	if err := change(n.Keys, key); err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return nil
+}
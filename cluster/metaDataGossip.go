@@ -3,10 +3,7 @@ package cluster
 import (
 	"github.com/Kapperchino/jet-stream/cluster/proto/proto"
 	"github.com/Kapperchino/jet-stream/util"
-	"github.com/hashicorp/raft"
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
-	"time"
 )
 
 type ClusterDelegate struct {
@@ -17,52 +14,44 @@ func (c ClusterDelegate) Logger() *zerolog.Logger {
 	return c.ClusterState.Logger
 }
 
+// NodeMeta is the state of the local shard, the versioned broadcasts carry the changes but the meta lets nodes that
+// missed them catch up on the next update
 func (c ClusterDelegate) NodeMeta(limit int) []byte {
-	shardMap := c.ClusterState.getMemberMap()
-	res := proto.ShardInfo{
-		MemberAddressMap: map[string]*proto.MemberInfo{},
-		LeaderId:         c.ClusterState.getLeader(),
-		ShardId:          c.ClusterState.getShardId(),
-	}
-	shardMap.ForEach(func(s string, info *MemberInfo) bool {
-		res.MemberAddressMap[s] = &proto.MemberInfo{
-			NodeId:  info.NodeId,
-			Address: info.Address,
-		}
-		return true
-	})
-	bytes, err := util.SerializeMessage(&res)
+	bytes, err := util.SerializeMessage(toProtoShardInfo(c.ClusterState.GetShardInfo()))
 	if err != nil {
 		c.Logger().Err(err)
 		return nil
 	}
+	if len(bytes) > limit {
+		c.Logger().Warn().Msgf("Shard state of %d bytes is over the meta limit of %d", len(bytes), limit)
+		return nil
+	}
 	return bytes
 }
 
+// NotifyMsg applies a shard state broadcast by another node, states that were new to us are broadcast again
 func (c ClusterDelegate) NotifyMsg(bytes []byte) {
-	c.Logger().Debug().Msgf("received %s", bytes)
+	var msg proto.GossipMessage
+	if err := util.DeserializeMessage(bytes, &msg); err != nil {
+		c.Logger().Err(err).Msgf("Error deserializing gossip message")
+		return
+	}
+	if c.ClusterState.mergeShard(msg.Shard) && c.ClusterState.Gossip != nil {
+		//the buffer is reused by memberlist
+		c.ClusterState.Gossip.queueShard(msg.Shard.ShardId, append([]byte{}, bytes...))
+	}
 }
 
 func (c ClusterDelegate) GetBroadcasts(overhead, limit int) [][]byte {
-	return nil
+	if c.ClusterState.Gossip == nil {
+		return nil
+	}
+	return c.ClusterState.Gossip.GetBroadcasts(overhead, limit)
 }
 
+// LocalState is every shard known to the node, push/pull repairs what the broadcasts missed
 func (c ClusterDelegate) LocalState(join bool) []byte {
-	shardMap := c.ClusterState.getMemberMap()
-	res := proto.ShardInfo{
-		MemberAddressMap: map[string]*proto.MemberInfo{},
-		LeaderId:         c.ClusterState.getLeader(),
-		ShardId:          c.ClusterState.getShardId(),
-		NodeId:           c.ClusterState.getNodeId(),
-	}
-	shardMap.ForEach(func(s string, info *MemberInfo) bool {
-		res.MemberAddressMap[s] = &proto.MemberInfo{
-			NodeId:  info.NodeId,
-			Address: info.Address,
-		}
-		return true
-	})
-	bytes, err := util.SerializeMessage(&res)
+	bytes, err := util.SerializeMessage(c.ClusterState.gossipState())
 	if err != nil {
 		c.Logger().Err(err)
 		return nil
@@ -71,78 +60,14 @@ func (c ClusterDelegate) LocalState(join bool) []byte {
 }
 
 func (c ClusterDelegate) MergeRemoteState(buf []byte, join bool) {
-	var meta proto.ShardInfo
-	err := util.DeserializeMessage(buf, &meta)
+	var state proto.GossipState
+	err := util.DeserializeMessage(buf, &state)
 	if err != nil {
 		c.Logger().Err(err).Msgf("Error deserializing message")
 		return
 	}
-	c.Logger().Debug().Msgf("remote state is %v, join: %s", meta, join)
-	//adding it to the cluster
-	if join {
-		//add node to shard
-		if meta.ShardId == c.ClusterState.getShardId() && meta.NodeId != c.ClusterState.getNodeId() {
-			if meta.LeaderId == c.ClusterState.getLeader() {
-				raftPrt := c.ClusterState.getShardState().Raft
-				//add voter
-				address := meta.MemberAddressMap[meta.NodeId].Address
-				future := raftPrt.AddVoter(raft.ServerID(meta.NodeId), raft.ServerAddress(address), 0, time.Second*20)
-				go func() {
-					err := future.Error()
-					if err != nil {
-						log.Err(err).Msgf("Error when adding voter")
-					}
-				}()
-			}
-			return
-		}
-		memberMap := util.NewMap[string, *MemberInfo]()
-		for key, val := range meta.MemberAddressMap {
-			info := MemberInfo{
-				NodeId:   val.NodeId,
-				IsLeader: false,
-				Address:  val.Address,
-			}
-			if info.NodeId == meta.LeaderId {
-				info.IsLeader = true
-			}
-			memberMap.Set(key, &info)
-		}
-		c.ClusterState.ClusterInfo.Set(meta.ShardId, &ShardInfo{
-			shardId:   meta.ShardId,
-			Leader:    meta.LeaderId,
-			nodeId:    meta.NodeId,
-			MemberMap: memberMap,
-		})
-		return
-	}
-	//update the map if possible
-	memberMap := c.ClusterState.getMemberMap()
-	//check if Leader is the same
-	if meta.LeaderId != c.ClusterState.getLeader() {
-		//Leader changed need to update everything
-		for key, val := range meta.MemberAddressMap {
-			member := memberMap.Get(key)
-			if member != nil {
-				//everything is immutable other than  isleader
-				if member.NodeId == meta.LeaderId {
-					member.IsLeader = true
-				} else {
-					member.IsLeader = false
-				}
-			} else {
-				info := MemberInfo{
-					NodeId:   val.NodeId,
-					IsLeader: false,
-					Address:  val.Address,
-				}
-				if info.NodeId == meta.LeaderId {
-					info.IsLeader = true
-				}
-				memberMap.Set(key, &info)
-			}
-		}
-		c.ClusterState.GetShardInfo().Leader = meta.LeaderId
-		return
+	c.Logger().Debug().Msgf("remote state of %s/%s has %d shards, join: %v", state.ShardId, state.NodeId, len(state.Shards), join)
+	for _, shard := range state.Shards {
+		c.ClusterState.mergeShard(shard)
 	}
 }
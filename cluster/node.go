@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ShardHost starts and stops the raft groups hosted by a node
+type ShardHost interface {
+	// HostShard starts the shard bootstrapped with the members, it does nothing if the node already hosts it
+	HostShard(shardId string, members map[string]string, gossipAddress string) error
+	DropShard(shardId string) error
+	// DecommissionShard has the leader remove the node from the shard, then stops it and keeps its data
+	DecommissionShard(shardId string, force bool) error
+	HostedShards() []string
+}
+
+type NodeRpc struct {
+	Host ShardHost
+	// Keys is nil when the gossip isn't encrypted
+	Keys KeyManager
+	// Authorize checks the callers allowed to administer the node, anyone is when it is nil
+	Authorize func(ctx context.Context) error
+	pb.UnimplementedNodeServiceServer
+}
+
+func (n NodeRpc) authorize(ctx context.Context) error {
+	if n.Authorize == nil {
+		return nil
+	}
+	return n.Authorize(ctx)
+}
+
+func (n NodeRpc) HostShard(ctx context.Context, req *pb.HostShardRequest) (*pb.HostShardResponse, error) {
+	if err := n.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetShardId() == "" || len(req.GetMembers()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "hosting a shard needs a shard id and its members")
+	}
+	if err := n.Host.HostShard(req.GetShardId(), req.GetMembers(), req.GetGossipAddress()); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.HostShardResponse{}, nil
+}
+
+func (n NodeRpc) DropShard(ctx context.Context, req *pb.DropShardRequest) (*pb.DropShardResponse, error) {
+	if err := n.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := n.Host.DropShard(req.GetShardId()); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &pb.DropShardResponse{}, nil
+}
+
+// Decommission takes the node out of the shards one by one, it stops at the first one that can't be left
+func (n NodeRpc) Decommission(ctx context.Context, req *pb.DecommissionRequest) (*pb.DecommissionResponse, error) {
+	if err := n.authorize(ctx); err != nil {
+		return nil, err
+	}
+	shardIds := req.GetShardIds()
+	if len(shardIds) == 0 {
+		shardIds = n.Host.HostedShards()
+	}
+	for x, shardId := range shardIds {
+		if err := n.Host.DecommissionShard(shardId, req.GetForce()); err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "left %v, then failed to leave shard %s: %v", shardIds[:x], shardId, err)
+		}
+	}
+	return &pb.DecommissionResponse{ShardIds: shardIds}, nil
+}
+
+func (n NodeRpc) GetHostedShards(ctx context.Context, _ *pb.GetHostedShardsRequest) (*pb.GetHostedShardsResponse, error) {
+	if err := n.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return &pb.GetHostedShardsResponse{ShardIds: n.Host.HostedShards()}, nil
+}
@@ -21,6 +21,9 @@ const _ = grpc.SupportPackageIsVersion7
 const (
 	ClusterMetaService_GetClusterInfo_FullMethodName = "/cluster.ClusterMetaService/GetClusterInfo"
 	ClusterMetaService_GetShardInfo_FullMethodName   = "/cluster.ClusterMetaService/GetShardInfo"
+	ClusterMetaService_WatchCluster_FullMethodName   = "/cluster.ClusterMetaService/WatchCluster"
+	ClusterMetaService_JoinShard_FullMethodName      = "/cluster.ClusterMetaService/JoinShard"
+	ClusterMetaService_LeaveShard_FullMethodName     = "/cluster.ClusterMetaService/LeaveShard"
 )
 
 // ClusterMetaServiceClient is the client API for ClusterMetaService service.
@@ -29,6 +32,13 @@ const (
 type ClusterMetaServiceClient interface {
 	GetClusterInfo(ctx context.Context, in *GetClusterInfoRequest, opts ...grpc.CallOption) (*GetClusterInfoResponse, error)
 	GetShardInfo(ctx context.Context, in *GetShardInfoRequest, opts ...grpc.CallOption) (*GetShardInfoResponse, error)
+	// WatchCluster streams topology changes, starting with a snapshot unless the watcher can resume
+	WatchCluster(ctx context.Context, in *WatchClusterRequest, opts ...grpc.CallOption) (ClusterMetaService_WatchClusterClient, error)
+	// JoinShard adds the calling node to the raft group of the shard, only the leader takes it
+	JoinShard(ctx context.Context, in *JoinShardRequest, opts ...grpc.CallOption) (*JoinShardResponse, error)
+	// LeaveShard removes a decommissioned node from the raft group and the shard info, only the leader takes it and it
+	// can't remove itself
+	LeaveShard(ctx context.Context, in *LeaveShardRequest, opts ...grpc.CallOption) (*LeaveShardResponse, error)
 }
 
 type clusterMetaServiceClient struct {
@@ -57,12 +67,69 @@ func (c *clusterMetaServiceClient) GetShardInfo(ctx context.Context, in *GetShar
 	return out, nil
 }
 
+func (c *clusterMetaServiceClient) WatchCluster(ctx context.Context, in *WatchClusterRequest, opts ...grpc.CallOption) (ClusterMetaService_WatchClusterClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ClusterMetaService_ServiceDesc.Streams[0], ClusterMetaService_WatchCluster_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &clusterMetaServiceWatchClusterClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ClusterMetaService_WatchClusterClient interface {
+	Recv() (*ClusterEvent, error)
+	grpc.ClientStream
+}
+
+type clusterMetaServiceWatchClusterClient struct {
+	grpc.ClientStream
+}
+
+func (x *clusterMetaServiceWatchClusterClient) Recv() (*ClusterEvent, error) {
+	m := new(ClusterEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *clusterMetaServiceClient) JoinShard(ctx context.Context, in *JoinShardRequest, opts ...grpc.CallOption) (*JoinShardResponse, error) {
+	out := new(JoinShardResponse)
+	err := c.cc.Invoke(ctx, ClusterMetaService_JoinShard_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterMetaServiceClient) LeaveShard(ctx context.Context, in *LeaveShardRequest, opts ...grpc.CallOption) (*LeaveShardResponse, error) {
+	out := new(LeaveShardResponse)
+	err := c.cc.Invoke(ctx, ClusterMetaService_LeaveShard_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ClusterMetaServiceServer is the server API for ClusterMetaService service.
 // All implementations must embed UnimplementedClusterMetaServiceServer
 // for forward compatibility
 type ClusterMetaServiceServer interface {
 	GetClusterInfo(context.Context, *GetClusterInfoRequest) (*GetClusterInfoResponse, error)
 	GetShardInfo(context.Context, *GetShardInfoRequest) (*GetShardInfoResponse, error)
+	// WatchCluster streams topology changes, starting with a snapshot unless the watcher can resume
+	WatchCluster(*WatchClusterRequest, ClusterMetaService_WatchClusterServer) error
+	// JoinShard adds the calling node to the raft group of the shard, only the leader takes it
+	JoinShard(context.Context, *JoinShardRequest) (*JoinShardResponse, error)
+	// LeaveShard removes a decommissioned node from the raft group and the shard info, only the leader takes it and it
+	// can't remove itself
+	LeaveShard(context.Context, *LeaveShardRequest) (*LeaveShardResponse, error)
 	mustEmbedUnimplementedClusterMetaServiceServer()
 }
 
@@ -76,6 +143,15 @@ func (UnimplementedClusterMetaServiceServer) GetClusterInfo(context.Context, *Ge
 func (UnimplementedClusterMetaServiceServer) GetShardInfo(context.Context, *GetShardInfoRequest) (*GetShardInfoResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetShardInfo not implemented")
 }
+func (UnimplementedClusterMetaServiceServer) WatchCluster(*WatchClusterRequest, ClusterMetaService_WatchClusterServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchCluster not implemented")
+}
+func (UnimplementedClusterMetaServiceServer) JoinShard(context.Context, *JoinShardRequest) (*JoinShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method JoinShard not implemented")
+}
+func (UnimplementedClusterMetaServiceServer) LeaveShard(context.Context, *LeaveShardRequest) (*LeaveShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LeaveShard not implemented")
+}
 func (UnimplementedClusterMetaServiceServer) mustEmbedUnimplementedClusterMetaServiceServer() {}
 
 // UnsafeClusterMetaServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -125,6 +201,63 @@ func _ClusterMetaService_GetShardInfo_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ClusterMetaService_WatchCluster_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchClusterRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClusterMetaServiceServer).WatchCluster(m, &clusterMetaServiceWatchClusterServer{stream})
+}
+
+type ClusterMetaService_WatchClusterServer interface {
+	Send(*ClusterEvent) error
+	grpc.ServerStream
+}
+
+type clusterMetaServiceWatchClusterServer struct {
+	grpc.ServerStream
+}
+
+func (x *clusterMetaServiceWatchClusterServer) Send(m *ClusterEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ClusterMetaService_JoinShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JoinShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterMetaServiceServer).JoinShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterMetaService_JoinShard_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterMetaServiceServer).JoinShard(ctx, req.(*JoinShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterMetaService_LeaveShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaveShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterMetaServiceServer).LeaveShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClusterMetaService_LeaveShard_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterMetaServiceServer).LeaveShard(ctx, req.(*LeaveShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ClusterMetaService_ServiceDesc is the grpc.ServiceDesc for ClusterMetaService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -140,6 +273,383 @@ var ClusterMetaService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetShardInfo",
 			Handler:    _ClusterMetaService_GetShardInfo_Handler,
 		},
+		{
+			MethodName: "JoinShard",
+			Handler:    _ClusterMetaService_JoinShard_Handler,
+		},
+		{
+			MethodName: "LeaveShard",
+			Handler:    _ClusterMetaService_LeaveShard_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchCluster",
+			Handler:       _ClusterMetaService_WatchCluster_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "cluster.proto",
+}
+
+const (
+	NodeService_HostShard_FullMethodName       = "/cluster.NodeService/HostShard"
+	NodeService_DropShard_FullMethodName       = "/cluster.NodeService/DropShard"
+	NodeService_GetHostedShards_FullMethodName = "/cluster.NodeService/GetHostedShards"
+	NodeService_Decommission_FullMethodName    = "/cluster.NodeService/Decommission"
+	NodeService_InstallKey_FullMethodName      = "/cluster.NodeService/InstallKey"
+	NodeService_UseKey_FullMethodName          = "/cluster.NodeService/UseKey"
+	NodeService_RemoveKey_FullMethodName       = "/cluster.NodeService/RemoveKey"
+	NodeService_ListKeys_FullMethodName        = "/cluster.NodeService/ListKeys"
+)
+
+// NodeServiceClient is the client API for NodeService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NodeServiceClient interface {
+	// HostShard starts a raft group for the shard, every member is called with the same members to bootstrap it
+	HostShard(ctx context.Context, in *HostShardRequest, opts ...grpc.CallOption) (*HostShardResponse, error)
+	// DropShard leaves the gossip, stops the raft group of the shard and deletes its data
+	DropShard(ctx context.Context, in *DropShardRequest, opts ...grpc.CallOption) (*DropShardResponse, error)
+	GetHostedShards(ctx context.Context, in *GetHostedShardsRequest, opts ...grpc.CallOption) (*GetHostedShardsResponse, error)
+	// Decommission takes the node out of its shards for good: it hands its leaderships over, has the leaders remove it
+	// and leaves the gossip. Its data is kept
+	Decommission(ctx context.Context, in *DecommissionRequest, opts ...grpc.CallOption) (*DecommissionResponse, error)
+	// InstallKey adds a key to the gossip keyring of the node, it is used to decrypt until it is made primary
+	InstallKey(ctx context.Context, in *InstallKeyRequest, opts ...grpc.CallOption) (*InstallKeyResponse, error)
+	// UseKey makes an installed key the one the node encrypts with
+	UseKey(ctx context.Context, in *UseKeyRequest, opts ...grpc.CallOption) (*UseKeyResponse, error)
+	// RemoveKey drops a key from the keyring, the primary key can't be removed
+	RemoveKey(ctx context.Context, in *RemoveKeyRequest, opts ...grpc.CallOption) (*RemoveKeyResponse, error)
+	ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error)
+}
+
+type nodeServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNodeServiceClient(cc grpc.ClientConnInterface) NodeServiceClient {
+	return &nodeServiceClient{cc}
+}
+
+func (c *nodeServiceClient) HostShard(ctx context.Context, in *HostShardRequest, opts ...grpc.CallOption) (*HostShardResponse, error) {
+	out := new(HostShardResponse)
+	err := c.cc.Invoke(ctx, NodeService_HostShard_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeServiceClient) DropShard(ctx context.Context, in *DropShardRequest, opts ...grpc.CallOption) (*DropShardResponse, error) {
+	out := new(DropShardResponse)
+	err := c.cc.Invoke(ctx, NodeService_DropShard_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeServiceClient) GetHostedShards(ctx context.Context, in *GetHostedShardsRequest, opts ...grpc.CallOption) (*GetHostedShardsResponse, error) {
+	out := new(GetHostedShardsResponse)
+	err := c.cc.Invoke(ctx, NodeService_GetHostedShards_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeServiceClient) Decommission(ctx context.Context, in *DecommissionRequest, opts ...grpc.CallOption) (*DecommissionResponse, error) {
+	out := new(DecommissionResponse)
+	err := c.cc.Invoke(ctx, NodeService_Decommission_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeServiceClient) InstallKey(ctx context.Context, in *InstallKeyRequest, opts ...grpc.CallOption) (*InstallKeyResponse, error) {
+	out := new(InstallKeyResponse)
+	err := c.cc.Invoke(ctx, NodeService_InstallKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeServiceClient) UseKey(ctx context.Context, in *UseKeyRequest, opts ...grpc.CallOption) (*UseKeyResponse, error) {
+	out := new(UseKeyResponse)
+	err := c.cc.Invoke(ctx, NodeService_UseKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeServiceClient) RemoveKey(ctx context.Context, in *RemoveKeyRequest, opts ...grpc.CallOption) (*RemoveKeyResponse, error) {
+	out := new(RemoveKeyResponse)
+	err := c.cc.Invoke(ctx, NodeService_RemoveKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeServiceClient) ListKeys(ctx context.Context, in *ListKeysRequest, opts ...grpc.CallOption) (*ListKeysResponse, error) {
+	out := new(ListKeysResponse)
+	err := c.cc.Invoke(ctx, NodeService_ListKeys_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NodeServiceServer is the server API for NodeService service.
+// All implementations must embed UnimplementedNodeServiceServer
+// for forward compatibility
+type NodeServiceServer interface {
+	// HostShard starts a raft group for the shard, every member is called with the same members to bootstrap it
+	HostShard(context.Context, *HostShardRequest) (*HostShardResponse, error)
+	// DropShard leaves the gossip, stops the raft group of the shard and deletes its data
+	DropShard(context.Context, *DropShardRequest) (*DropShardResponse, error)
+	GetHostedShards(context.Context, *GetHostedShardsRequest) (*GetHostedShardsResponse, error)
+	// Decommission takes the node out of its shards for good: it hands its leaderships over, has the leaders remove it
+	// and leaves the gossip. Its data is kept
+	Decommission(context.Context, *DecommissionRequest) (*DecommissionResponse, error)
+	// InstallKey adds a key to the gossip keyring of the node, it is used to decrypt until it is made primary
+	InstallKey(context.Context, *InstallKeyRequest) (*InstallKeyResponse, error)
+	// UseKey makes an installed key the one the node encrypts with
+	UseKey(context.Context, *UseKeyRequest) (*UseKeyResponse, error)
+	// RemoveKey drops a key from the keyring, the primary key can't be removed
+	RemoveKey(context.Context, *RemoveKeyRequest) (*RemoveKeyResponse, error)
+	ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error)
+	mustEmbedUnimplementedNodeServiceServer()
+}
+
+// UnimplementedNodeServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedNodeServiceServer struct {
+}
+
+func (UnimplementedNodeServiceServer) HostShard(context.Context, *HostShardRequest) (*HostShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HostShard not implemented")
+}
+func (UnimplementedNodeServiceServer) DropShard(context.Context, *DropShardRequest) (*DropShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DropShard not implemented")
+}
+func (UnimplementedNodeServiceServer) GetHostedShards(context.Context, *GetHostedShardsRequest) (*GetHostedShardsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHostedShards not implemented")
+}
+func (UnimplementedNodeServiceServer) Decommission(context.Context, *DecommissionRequest) (*DecommissionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Decommission not implemented")
+}
+func (UnimplementedNodeServiceServer) InstallKey(context.Context, *InstallKeyRequest) (*InstallKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InstallKey not implemented")
+}
+func (UnimplementedNodeServiceServer) UseKey(context.Context, *UseKeyRequest) (*UseKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UseKey not implemented")
+}
+func (UnimplementedNodeServiceServer) RemoveKey(context.Context, *RemoveKeyRequest) (*RemoveKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveKey not implemented")
+}
+func (UnimplementedNodeServiceServer) ListKeys(context.Context, *ListKeysRequest) (*ListKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListKeys not implemented")
+}
+func (UnimplementedNodeServiceServer) mustEmbedUnimplementedNodeServiceServer() {}
+
+// UnsafeNodeServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NodeServiceServer will
+// result in compilation errors.
+type UnsafeNodeServiceServer interface {
+	mustEmbedUnimplementedNodeServiceServer()
+}
+
+func RegisterNodeServiceServer(s grpc.ServiceRegistrar, srv NodeServiceServer) {
+	s.RegisterService(&NodeService_ServiceDesc, srv)
+}
+
+func _NodeService_HostShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HostShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServiceServer).HostShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NodeService_HostShard_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServiceServer).HostShard(ctx, req.(*HostShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NodeService_DropShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DropShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServiceServer).DropShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NodeService_DropShard_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServiceServer).DropShard(ctx, req.(*DropShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NodeService_GetHostedShards_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetHostedShardsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServiceServer).GetHostedShards(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NodeService_GetHostedShards_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServiceServer).GetHostedShards(ctx, req.(*GetHostedShardsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NodeService_Decommission_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecommissionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServiceServer).Decommission(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NodeService_Decommission_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServiceServer).Decommission(ctx, req.(*DecommissionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NodeService_InstallKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InstallKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServiceServer).InstallKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NodeService_InstallKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServiceServer).InstallKey(ctx, req.(*InstallKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NodeService_UseKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UseKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServiceServer).UseKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NodeService_UseKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServiceServer).UseKey(ctx, req.(*UseKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NodeService_RemoveKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServiceServer).RemoveKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NodeService_RemoveKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServiceServer).RemoveKey(ctx, req.(*RemoveKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NodeService_ListKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServiceServer).ListKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NodeService_ListKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServiceServer).ListKeys(ctx, req.(*ListKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// NodeService_ServiceDesc is the grpc.ServiceDesc for NodeService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NodeService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cluster.NodeService",
+	HandlerType: (*NodeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "HostShard",
+			Handler:    _NodeService_HostShard_Handler,
+		},
+		{
+			MethodName: "DropShard",
+			Handler:    _NodeService_DropShard_Handler,
+		},
+		{
+			MethodName: "GetHostedShards",
+			Handler:    _NodeService_GetHostedShards_Handler,
+		},
+		{
+			MethodName: "Decommission",
+			Handler:    _NodeService_Decommission_Handler,
+		},
+		{
+			MethodName: "InstallKey",
+			Handler:    _NodeService_InstallKey_Handler,
+		},
+		{
+			MethodName: "UseKey",
+			Handler:    _NodeService_UseKey_Handler,
+		},
+		{
+			MethodName: "RemoveKey",
+			Handler:    _NodeService_RemoveKey_Handler,
+		},
+		{
+			MethodName: "ListKeys",
+			Handler:    _NodeService_ListKeys_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "cluster.proto",
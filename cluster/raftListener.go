@@ -2,9 +2,11 @@ package cluster
 
 import (
 	fsmPb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/config"
 	"github.com/Kapperchino/jet-stream/util"
 	"github.com/hashicorp/raft"
 	"github.com/rs/zerolog/log"
+	"strconv"
 	"time"
 )
 
@@ -36,6 +38,9 @@ func onRaftUpdates(raftChan chan raft.Observation, i *RpcInterface) {
 			case raft.FailedHeartbeatObservation:
 				onFailedHeartbeat(i, val)
 				break
+			case raft.ResumedHeartbeatObservation:
+				i.ClusterState.CurShardState.Unreachable.Del(string(val.PeerID))
+				break
 			}
 		default:
 			i.Logger.Trace().Msgf("No updates for shard %s", i.ClusterState.getShardId())
@@ -44,28 +49,34 @@ func onRaftUpdates(raftChan chan raft.Observation, i *RpcInterface) {
 
 }
 
-// TODO: update node when there's a change
+// onFailedHeartbeat removes a peer the leader hasn't reached for longer than the removal policy allows, unless the
+// policy keeps it
 func onFailedHeartbeat(i *RpcInterface, update raft.FailedHeartbeatObservation) {
-	i.Logger.Warn().Msgf("Peer %s cannot be connected to, last contact: %s, nodes: %s", update.PeerID, update.LastContact.String(), i.Raft.GetConfiguration().Configuration().Servers)
-	if time.Now().Sub(update.LastContact).Seconds() > 10 {
-		err := i.Raft.RemoveServer(update.PeerID, i.Raft.LastIndex()-1, 0).Error()
-		if err != nil {
-			return
-		}
-		i.Logger.Info().Msgf("Peer %s is removed", update.PeerID)
-		i.ClusterState.getMemberMap().Del(string(update.PeerID))
-		err = RemovePeer(i, string(update.PeerID))
-		if err != nil {
-			i.Logger.Err(err).Msgf("Error removing peer %s", update.PeerID)
-			return
-		}
+	i.ClusterState.CurShardState.Unreachable.Set(string(update.PeerID), update.LastContact)
+	i.Logger.Warn().Msgf("Peer %s cannot be connected to, last contact: %s", update.PeerID, update.LastContact.String())
+	policy := config.Current().Removal
+	if time.Since(update.LastContact) <= policy.After {
+		return
+	}
+	future := i.Raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return
+	}
+	if err := RemovalAllowed(policy, future.Configuration().Servers, update.PeerID, i.ClusterState.unreachable); err != nil {
+		i.Logger.Warn().Msgf("Peer %s is kept: %v", update.PeerID, err)
+		return
+	}
+	if err := removeMember(i, update.PeerID, 0); err != nil {
+		i.Logger.Err(err).Msgf("Error removing peer %s", update.PeerID)
+		return
 	}
+	i.Logger.Info().Msgf("Peer %s is removed", update.PeerID)
 }
 
 func onPeerUpdate(i *RpcInterface, update raft.PeerObservation) {
 	if update.Removed {
 		i.Logger.Info().Msgf("Peer %s is removed", update.Peer.ID)
-		i.ClusterState.getMemberMap().Del(string(update.Peer.ID))
+		i.ClusterState.DelMember(string(update.Peer.ID))
 		err := RemovePeer(i, string(update.Peer.ID))
 		if err != nil {
 			i.Logger.Err(err).Msgf("Error removing peer %s", update.Peer.ID)
@@ -78,16 +89,12 @@ func onPeerUpdate(i *RpcInterface, update raft.PeerObservation) {
 	}
 	//peer is updated
 	item := i.ClusterState.getMemberMap().Get(string(update.Peer.ID))
-	if item == nil {
+	if item != nil {
 		i.Logger.Debug().Msgf("Peer %s already exists, no-op", update.Peer.ID)
 		return
 	}
 	//add peer
-	i.ClusterState.getMemberMap().Set(string(update.Peer.ID), &MemberInfo{
-		NodeId:   string(update.Peer.ID),
-		IsLeader: false,
-		Address:  string(update.Peer.Address),
-	})
+	i.ClusterState.AddMember(string(update.Peer.ID), string(update.Peer.Address), update.Peer.Suffrage == raft.Nonvoter)
 
 	i.Logger.Info().Msgf("Replicating peer %s", update.Peer.ID)
 	err := ReplicatePeer(i, update)
@@ -99,45 +106,31 @@ func onPeerUpdate(i *RpcInterface, update raft.PeerObservation) {
 }
 
 func onLeaderUpdate(i *RpcInterface, update raft.LeaderObservation) {
-	if string(update.LeaderID) == i.ClusterState.getMemberInfo().NodeId {
-		i.Logger.Debug().Msgf("Node %s is already Leader", i.ClusterState.getMemberInfo().NodeId)
-		i.ClusterState.GetShardInfo().Leader = string(update.LeaderID)
-		i.ClusterState.getMemberInfo().IsLeader = true
-		err := i.MemberList.UpdateNode(0)
-		if err != nil {
-			log.Err(err).Msgf("Error when broadcasting change to the current node")
-		}
-		return
-	}
-	//leadership update
-	i.ClusterState.getMemberInfo().IsLeader = false
-	i.ClusterState.GetShardInfo().Leader = string(update.LeaderID)
-	item := i.ClusterState.getMemberMap().Get(string(update.LeaderID))
-	if item != nil {
-		if item.NodeId == "" {
-			item.NodeId = string(update.LeaderID)
-		}
-		i.Logger.Debug().Msgf("Leader %s already exists, no-op", update.LeaderAddr)
-		return
-	}
-	//Leader added, we should try to see if we can get a list of servers to add to
-	servers := i.Raft.GetConfiguration().Configuration().Servers
-	for _, server := range servers {
-		item := i.ClusterState.getMemberMap().Get(string(server.ID))
-		if item != nil {
+	//the members are known once there's a leader, they go out with the leader change
+	for _, server := range i.Raft.GetConfiguration().Configuration().Servers {
+		readReplica := server.Suffrage == raft.Nonvoter
+		if item := i.ClusterState.getMemberMap().Get(string(server.ID)); item == nil {
 			i.Logger.Info().Msgf("adding server %s", server)
 			i.ClusterState.getMemberMap().Set(string(server.ID), &MemberInfo{
-				NodeId:   string(server.ID),
-				IsLeader: false,
-				Address:  string(server.Address),
+				NodeId:      string(server.ID),
+				Address:     string(server.Address),
+				ReadReplica: readReplica,
 			})
+		} else {
+			item.ReadReplica = readReplica
 		}
 	}
-	i.ClusterState.getMemberMap().Set(string(update.LeaderID), &MemberInfo{
-		NodeId:   string(update.LeaderID),
-		IsLeader: true,
-		Address:  string(update.LeaderAddr),
-	})
+	if item := i.ClusterState.getMemberMap().Get(string(update.LeaderID)); item == nil && len(update.LeaderID) > 0 {
+		i.ClusterState.getMemberMap().Set(string(update.LeaderID), &MemberInfo{
+			NodeId:  string(update.LeaderID),
+			Address: string(update.LeaderAddr),
+		})
+	}
+	term, _ := strconv.ParseUint(i.Raft.Stats()["term"], 10, 64)
+	i.ClusterState.SetLeader(string(update.LeaderID), term)
+	if i.MemberList == nil {
+		return
+	}
 	err := i.MemberList.UpdateNode(0)
 	if err != nil {
 		log.Err(err).Msgf("Error when broadcasting change to the current node")
@@ -163,13 +156,14 @@ func onStateUpdate(i *RpcInterface, state raft.RaftState) {
 func ReplicatePeer(i *RpcInterface, update raft.PeerObservation) error {
 	input := &fsmPb.WriteOperation{
 		Operation: &fsmPb.WriteOperation_AddMember{AddMember: &fsmPb.AddMember{
-			NodeId:  string(update.Peer.ID),
-			Address: string(update.Peer.Address),
+			NodeId:      string(update.Peer.ID),
+			Address:     string(update.Peer.Address),
+			ReadReplica: update.Peer.Suffrage == raft.Nonvoter,
 		}},
 		Code: fsmPb.Operation_ADD_MEMBER,
 	}
 	val, _ := util.SerializeMessage(input)
-	res := i.Raft.Apply(val, time.Second)
+	res := i.Raft.Apply(val, config.Current().ApplyTimeout)
 	if err := res.Error(); err != nil {
 		i.Logger.Err(err)
 		return err
@@ -195,7 +189,7 @@ func RemovePeer(i *RpcInterface, peerId string) error {
 		Code: fsmPb.Operation_REMOVE_MEMBER,
 	}
 	val, _ := util.SerializeMessage(input)
-	res := i.Raft.Apply(val, time.Second)
+	res := i.Raft.Apply(val, config.Current().ApplyTimeout)
 	if err := res.Error(); err != nil {
 		i.Logger.Err(err)
 		return err
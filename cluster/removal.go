@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/config"
+	"github.com/hashicorp/raft"
+	"time"
+)
+
+// countVoters returns the voters of the configuration, how many of them other than the peer the leader reaches and the
+// peer itself, nil if it isn't a member
+func countVoters(servers []raft.Server, peer raft.ServerID, unreachable func(raft.ServerID) bool) (int, int, *raft.Server) {
+	voters, reachable := 0, 0
+	var member *raft.Server
+	for x := range servers {
+		server := &servers[x]
+		if server.ID == peer {
+			member = server
+		}
+		if server.Suffrage != raft.Voter {
+			continue
+		}
+		voters++
+		if server.ID != peer && !unreachable(server.ID) {
+			reachable++
+		}
+	}
+	return voters, reachable, member
+}
+
+// RemovalAllowed checks the policy before the leader removes a peer it can't reach. The voters still reachable have to
+// be a quorum of the group with the peer, or the removal can't commit, and the group keeps MinVoters
+func RemovalAllowed(policy config.RemovalPolicy, servers []raft.Server, peer raft.ServerID, unreachable func(raft.ServerID) bool) error {
+	if !policy.Enabled {
+		return errors.New("the automatic removal is off")
+	}
+	voters, reachable, member := countVoters(servers, peer, unreachable)
+	if member == nil {
+		return fmt.Errorf("%s is not a member", peer)
+	}
+	if member.Suffrage != raft.Voter {
+		return nil
+	}
+	if voters-1 < policy.MinVoters {
+		return fmt.Errorf("removing %s leaves %d voters, the policy keeps %d", peer, voters-1, policy.MinVoters)
+	}
+	if reachable < voters/2+1 {
+		return fmt.Errorf("only %d of the %d voters are reachable, removing %s needs %d", reachable, voters, peer, voters/2+1)
+	}
+	return nil
+}
+
+// leaveAllowed checks that the voters still reachable once the leaving peer is gone are a quorum of the group left
+func leaveAllowed(servers []raft.Server, peer raft.ServerID, unreachable func(raft.ServerID) bool) error {
+	voters, reachable, member := countVoters(servers, peer, unreachable)
+	if member == nil || member.Suffrage != raft.Voter {
+		return nil
+	}
+	if reachable < (voters-1)/2+1 {
+		return fmt.Errorf("only %d of the %d voters left are reachable without %s, the shard would lose its quorum", reachable, voters-1, peer)
+	}
+	return nil
+}
+
+// removeMember takes the peer out of the raft group, then out of the members of the shard info
+func removeMember(i *RpcInterface, peer raft.ServerID, timeout time.Duration) error {
+	if err := i.Raft.RemoveServer(peer, 0, timeout).Error(); err != nil {
+		return err
+	}
+	i.ClusterState.CurShardState.Unreachable.Del(string(peer))
+	i.ClusterState.DelMember(string(peer))
+	return RemovePeer(i, string(peer))
+}
+
+// unreachable tells if the leader failed to heartbeat the peer since it last reached it
+func (c ClusterState) unreachable(peer raft.ServerID) bool {
+	return !c.CurShardState.Unreachable.Get(string(peer)).IsZero()
+}
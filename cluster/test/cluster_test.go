@@ -9,6 +9,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"os"
 	"testing"
 	"time"
@@ -64,6 +66,7 @@ func (suite *ClusterTest) SetupSuite() {
 			Server:        suite.servers,
 			ShardId:       "shardB",
 			InMemory:      true,
+			Bootstrap:     true,
 		})
 	log.Print("Starting the client")
 	suite.client[0] = suite.setupClient(suite.address[0])
@@ -84,6 +87,39 @@ func (suite *ClusterTest) TestGetClusterInfo() {
 	log.Info().Msgf("%s", res)
 }
 
+func (suite *ClusterTest) TestWatchCluster() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := suite.client[0].WatchCluster(ctx, &clusterPb.WatchClusterRequest{})
+	assert.Nil(suite.T(), err)
+	snapshot, err := stream.Recv()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), clusterPb.ClusterEventType_SNAPSHOT, snapshot.Type)
+	assert.NotEmpty(suite.T(), snapshot.Epoch)
+	assert.Equal(suite.T(), 2, len(snapshot.GetCluster().GetShardMap()))
+
+	//resuming from the snapshot doesn't send another one
+	resumeCtx, resumeCancel := context.WithTimeout(context.Background(), time.Second)
+	defer resumeCancel()
+	stream, err = suite.client[0].WatchCluster(resumeCtx, &clusterPb.WatchClusterRequest{
+		Epoch:       snapshot.Epoch,
+		FromVersion: snapshot.Version,
+	})
+	assert.Nil(suite.T(), err)
+	_, err = stream.Recv()
+	assert.Equal(suite.T(), codes.DeadlineExceeded, status.Code(err))
+
+	//an unknown epoch starts over from a snapshot
+	stream, err = suite.client[0].WatchCluster(ctx, &clusterPb.WatchClusterRequest{
+		Epoch:       "unknown",
+		FromVersion: snapshot.Version,
+	})
+	assert.Nil(suite.T(), err)
+	event, err := stream.Recv()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), clusterPb.ClusterEventType_SNAPSHOT, event.Type)
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestClusters(t *testing.T) {
@@ -0,0 +1,201 @@
+package test
+
+import (
+	"fmt"
+	"github.com/Kapperchino/jet-stream/cluster"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/memberlist"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+const (
+	gossipShards = 3
+	gossipNodes  = 9
+)
+
+// many memberlists in the test process on loopback ports picked by the os, each node only runs the gossip of its
+// shard without raft
+type GossipTest struct {
+	suite.Suite
+	nodes []*gossipNode
+}
+
+type gossipNode struct {
+	shardId string
+	nodeId  string
+	state   *cluster.ClusterState
+	list    *memberlist.Memberlist
+}
+
+func (suite *GossipTest) SetupTest() {
+	suite.nodes = nil
+	var addresses []string
+	for i := 0; i < gossipNodes; i++ {
+		node := suite.newNode(fmt.Sprintf("shard%d", i%gossipShards), fmt.Sprintf("node%d", i))
+		//joining every node makes the membership complete without relying on the broadcasts under test
+		if len(addresses) > 0 {
+			_, err := node.list.Join(addresses)
+			assert.Nil(suite.T(), err)
+		}
+		addresses = append(addresses, node.list.LocalNode().Address())
+		suite.nodes = append(suite.nodes, node)
+	}
+	assert.Eventually(suite.T(), func() bool {
+		for _, node := range suite.nodes {
+			if node.list.NumMembers() != gossipNodes {
+				return false
+			}
+		}
+		return true
+	}, 30*time.Second, 100*time.Millisecond)
+	for i := 0; i < gossipShards; i++ {
+		suite.lead(i, 1)
+	}
+}
+
+func (suite *GossipTest) TearDownTest() {
+	for _, node := range suite.nodes {
+		if node.list != nil {
+			_ = node.list.Shutdown()
+		}
+	}
+}
+
+// stop shuts the node down, leaving the gossip first if leave is set
+func (suite *GossipTest) stop(node *gossipNode, leave bool) {
+	if leave {
+		assert.Nil(suite.T(), node.list.Leave(time.Second))
+	}
+	_ = node.list.Shutdown()
+	node.list = nil
+}
+
+func (suite *GossipTest) newNode(shardId string, nodeId string) *gossipNode {
+	logger := zerolog.Nop()
+	state := cluster.NewClusterState(nodeId, nodeId+":8080", shardId, &logger)
+	config := memberlist.DefaultLocalConfig()
+	config.Name = shardId + "/" + nodeId
+	config.BindAddr = "127.0.0.1"
+	config.BindPort = 0
+	config.Delegate = cluster.ClusterDelegate{ClusterState: state}
+	config.Events = cluster.InitClusterListener(state)
+	//the push/pull repairs the broadcasts a node missed, the way it does outside of the tests
+	config.PushPullInterval = time.Second
+	config.LogOutput = io.Discard
+	list, err := memberlist.Create(config)
+	assert.Nil(suite.T(), err)
+	state.Gossip.SetMemberList(list)
+	return &gossipNode{shardId: shardId, nodeId: nodeId, state: state, list: list}
+}
+
+// lead makes the node at index the leader of its shard in the term, the way raft would tell it
+func (suite *GossipTest) lead(index int, term uint64) {
+	leader := suite.nodes[index]
+	for _, node := range suite.nodes {
+		if node.shardId == leader.shardId && node != leader && node.list != nil {
+			leader.state.SetMember(node.nodeId, node.nodeId+":8080")
+		}
+	}
+	leader.state.SetLeader(leader.nodeId, term)
+}
+
+// converged is true once every running node outside the shard sees it with the leader and the number of members
+func (suite *GossipTest) converged(shardId string, leaderId string, members int) bool {
+	for _, node := range suite.nodes {
+		if node.shardId == shardId || node.list == nil {
+			continue
+		}
+		info := node.state.ClusterInfo.Get(shardId)
+		if members == 0 {
+			if info != nil {
+				return false
+			}
+			continue
+		}
+		if info == nil || info.Leader != leaderId || info.MemberMap.Len() != members {
+			return false
+		}
+	}
+	return true
+}
+
+func (suite *GossipTest) TestShardsSpread() {
+	for i := 0; i < gossipShards; i++ {
+		shardId := fmt.Sprintf("shard%d", i)
+		assert.Eventually(suite.T(), func() bool {
+			return suite.converged(shardId, fmt.Sprintf("node%d", i), gossipNodes/gossipShards)
+		}, 30*time.Second, 100*time.Millisecond)
+	}
+	for _, node := range suite.nodes {
+		assert.Equal(suite.T(), gossipShards, node.state.ClusterInfo.Len())
+	}
+}
+
+// a newer term wins and states from an older term are dropped, whatever their version
+func (suite *GossipTest) TestLeaderChange() {
+	assert.Eventually(suite.T(), func() bool {
+		return suite.converged("shard0", "node0", 3)
+	}, 30*time.Second, 100*time.Millisecond)
+	suite.lead(3, 2)
+	assert.Eventually(suite.T(), func() bool {
+		return suite.converged("shard0", "node3", 3)
+	}, 30*time.Second, 100*time.Millisecond)
+
+	stale, err := util.SerializeMessage(&clusterPb.GossipMessage{Shard: &clusterPb.ShardInfo{
+		ShardId:          "shard0",
+		LeaderId:         "node0",
+		MemberAddressMap: map[string]*clusterPb.MemberInfo{"node0": {NodeId: "node0", Address: "node0:8080"}},
+		Term:             1,
+		Version:          math.MaxUint64,
+	}})
+	assert.Nil(suite.T(), err)
+	cluster.ClusterDelegate{ClusterState: suite.nodes[1].state}.NotifyMsg(stale)
+	assert.Equal(suite.T(), "node3", suite.nodes[1].state.ClusterInfo.Get("shard0").Leader)
+}
+
+// a failed node is dropped from its shard without leaving
+func (suite *GossipTest) TestNodeFailure() {
+	assert.Eventually(suite.T(), func() bool {
+		return suite.converged("shard1", "node1", 3)
+	}, 30*time.Second, 100*time.Millisecond)
+	suite.stop(suite.nodes[4], false)
+	assert.Eventually(suite.T(), func() bool {
+		return suite.converged("shard1", "node1", 2)
+	}, 30*time.Second, 100*time.Millisecond)
+}
+
+// a shard is removed when its last member leaves, and a late broadcast doesn't bring it back
+func (suite *GossipTest) TestShardLeaves() {
+	assert.Eventually(suite.T(), func() bool {
+		return suite.converged("shard2", "node2", 3)
+	}, 30*time.Second, 100*time.Millisecond)
+	late := suite.nodes[2].state.ClusterInfo.Get("shard2")
+	msg, err := util.SerializeMessage(&clusterPb.GossipMessage{Shard: &clusterPb.ShardInfo{
+		ShardId:          "shard2",
+		LeaderId:         late.Leader,
+		MemberAddressMap: map[string]*clusterPb.MemberInfo{"node2": {NodeId: "node2", Address: "node2:8080"}},
+	}})
+	assert.Nil(suite.T(), err)
+	for _, node := range suite.nodes {
+		if node.shardId != "shard2" {
+			continue
+		}
+		suite.stop(node, true)
+	}
+	assert.Eventually(suite.T(), func() bool {
+		return suite.converged("shard2", "", 0)
+	}, 30*time.Second, 100*time.Millisecond)
+	cluster.ClusterDelegate{ClusterState: suite.nodes[0].state}.NotifyMsg(msg)
+	assert.Nil(suite.T(), suite.nodes[0].state.ClusterInfo.Get("shard2"))
+}
+
+func TestGossip(t *testing.T) {
+	suite.Run(t, new(GossipTest))
+}
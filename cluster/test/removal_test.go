@@ -0,0 +1,75 @@
+package test
+
+import (
+	"github.com/Kapperchino/jet-stream/cluster"
+	"github.com/Kapperchino/jet-stream/config"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type RemovalTest struct {
+	suite.Suite
+	policy config.RemovalPolicy
+}
+
+func (suite *RemovalTest) SetupTest() {
+	suite.policy = config.RemovalPolicy{Enabled: true, After: 10 * time.Second, MinVoters: 1}
+}
+
+func servers(voters int, nonvoters ...string) []raft.Server {
+	var res []raft.Server
+	for _, id := range []string{"nodeA", "nodeB", "nodeC", "nodeD", "nodeE"}[:voters] {
+		res = append(res, raft.Server{Suffrage: raft.Voter, ID: raft.ServerID(id)})
+	}
+	for _, id := range nonvoters {
+		res = append(res, raft.Server{Suffrage: raft.Nonvoter, ID: raft.ServerID(id)})
+	}
+	return res
+}
+
+func unreachable(ids ...raft.ServerID) func(raft.ServerID) bool {
+	return func(id raft.ServerID) bool {
+		for _, unreachable := range ids {
+			if id == unreachable {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// one lost voter of three is removed, the two left are a quorum
+func (suite *RemovalTest) TestRemoveWithQuorum() {
+	assert.Nil(suite.T(), cluster.RemovalAllowed(suite.policy, servers(3), "nodeC", unreachable("nodeC")))
+}
+
+// with two voters of five lost the removal of one can still commit, with three it can't
+func (suite *RemovalTest) TestKeepWithoutQuorum() {
+	assert.Nil(suite.T(), cluster.RemovalAllowed(suite.policy, servers(5), "nodeE", unreachable("nodeD", "nodeE")))
+	assert.ErrorContains(suite.T(), cluster.RemovalAllowed(suite.policy, servers(5), "nodeE", unreachable("nodeC", "nodeD", "nodeE")), "reachable")
+}
+
+func (suite *RemovalTest) TestMinVoters() {
+	suite.policy.MinVoters = 3
+	assert.ErrorContains(suite.T(), cluster.RemovalAllowed(suite.policy, servers(3), "nodeC", unreachable("nodeC")), "keeps 3")
+	assert.Nil(suite.T(), cluster.RemovalAllowed(suite.policy, servers(4), "nodeD", unreachable("nodeD")))
+}
+
+// read replicas don't count in the quorum, they are removed even when the voters lost it
+func (suite *RemovalTest) TestReadReplica() {
+	assert.Nil(suite.T(), cluster.RemovalAllowed(suite.policy, servers(3, "nodeR"), "nodeR", unreachable("nodeB", "nodeC", "nodeR")))
+}
+
+func (suite *RemovalTest) TestDisabled() {
+	suite.policy.Enabled = false
+	assert.NotNil(suite.T(), cluster.RemovalAllowed(suite.policy, servers(3), "nodeC", unreachable("nodeC")))
+	suite.policy.Enabled = true
+	assert.NotNil(suite.T(), cluster.RemovalAllowed(suite.policy, servers(3), "nodeX", unreachable("nodeX")))
+}
+
+func TestRemoval(t *testing.T) {
+	suite.Run(t, new(RemovalTest))
+}
@@ -1,7 +1,74 @@
 package config
 
-import "github.com/rs/zerolog"
+import (
+	"github.com/rs/zerolog"
+	"sync/atomic"
+	"time"
+)
 
-const DEV_MODE = false
-const CONSUME_CHUNK uint64 = 100
-const LOG_LEVEL = zerolog.DebugLevel
+// DefaultMaxMessageSize is the largest grpc message the nodes send and receive
+const DefaultMaxMessageSize = 1 * 1024 * 1024 * 1024
+
+// Settings can change while the node runs, they are read through Current and replaced by a reload of the config file
+type Settings struct {
+	// LogLevel filters the logs of every logger of the process
+	LogLevel zerolog.Level
+	// ConsumeChunk is how many messages a consume prefetches from the store at once
+	ConsumeChunk int
+	// ApplyTimeout is how long a write waits to be enqueued by raft
+	ApplyTimeout time.Duration
+	// Removal is when the leader of a shard removes the peers it lost contact with
+	Removal RemovalPolicy
+}
+
+// RemovalPolicy lets the leader of a shard remove a peer it hasn't reached for After. A voter is only removed while
+// the reachable voters keep a quorum and the group keeps MinVoters, read replicas never count
+type RemovalPolicy struct {
+	Enabled   bool          `yaml:"enabled"`
+	After     time.Duration `yaml:"after"`
+	MinVoters int           `yaml:"min_voters"`
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		LogLevel:     zerolog.DebugLevel,
+		ConsumeChunk: 100,
+		ApplyTimeout: time.Second,
+		Removal: RemovalPolicy{
+			Enabled:   true,
+			After:     10 * time.Second,
+			MinVoters: 1,
+		},
+	}
+}
+
+var (
+	current atomic.Pointer[Settings]
+	// devMode stores the messages as json to read them while debugging, it can't change once data was written
+	devMode atomic.Bool
+)
+
+func init() {
+	Set(DefaultSettings())
+}
+
+// Current returns the settings in use
+func Current() Settings {
+	return *current.Load()
+}
+
+// Set replaces the settings in use, the log level applies to the loggers already created
+func Set(settings Settings) {
+	current.Store(&settings)
+	zerolog.SetGlobalLevel(settings.LogLevel)
+}
+
+// DevMode is true when the messages are stored as json
+func DevMode() bool {
+	return devMode.Load()
+}
+
+// SetDevMode switches the encoding of the stored messages, only before the stores are opened
+func SetDevMode(enabled bool) {
+	devMode.Store(enabled)
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the environment variables overriding the file
+const EnvPrefix = "JET"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ApplyEnv overrides the fields of the file with the environment variables named after their yaml path, like
+// JET_RUNTIME_LOG_LEVEL for runtime.log_level. Lists are comma separated
+func ApplyEnv(file *File, lookup func(string) (string, bool)) error {
+	return applyEnv(reflect.ValueOf(file).Elem(), EnvPrefix, lookup)
+}
+
+func applyEnv(value reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		name := prefix + "_" + strings.ToUpper(value.Type().Field(i).Tag.Get("yaml"))
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field, name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		env, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setField(field, env); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, env string) error {
+	if field.Type() == durationType {
+		duration, err := time.ParseDuration(env)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(env)
+	case reflect.Bool:
+		enabled, err := strconv.ParseBool(env)
+		if err != nil {
+			return err
+		}
+		field.SetBool(enabled)
+	case reflect.Int, reflect.Int64:
+		num, err := strconv.ParseInt(env, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(num)
+	case reflect.Float64:
+		num, err := strconv.ParseFloat(env, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(num)
+	case reflect.Slice:
+		var list []string
+		if env != "" {
+			list = strings.Split(env, ",")
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"reflect"
+	"time"
+)
+
+// File is the yaml config of a node, every field can be overridden by an environment variable named after its path,
+// like JET_NODE_NAME or JET_GOSSIP_PROBE_INTERVAL
+type File struct {
+	Node    Node    `yaml:"node"`
+	Gossip  Gossip  `yaml:"gossip"`
+	TLS     TLS     `yaml:"tls"`
+	Auth    Auth    `yaml:"auth"`
+	Metrics Metrics `yaml:"metrics"`
+	Tracing Tracing `yaml:"tracing"`
+	// Balancer is only used by the controllers
+	Balancer Balancer `yaml:"balancer"`
+	// Runtime are the settings applied again when the file is reloaded
+	Runtime Runtime `yaml:"runtime"`
+}
+
+type Node struct {
+	// Name is the raft id of the node
+	Name string `yaml:"name"`
+	// Address is where the other nodes and the clients reach the node
+	Address string `yaml:"address"`
+	// ListenAddress is where the grpc server listens, the address when empty
+	ListenAddress string `yaml:"listen_address"`
+	DataDir       string `yaml:"data_dir"`
+	RaftDir       string `yaml:"raft_dir"`
+	InMemory      bool   `yaml:"in_memory"`
+	// Shards are hosted by the node, the first one gossips on gossip.address and the next ones on gossip.shard_addresses
+	Shards []string `yaml:"shards"`
+	// Controller runs the node as a metadata controller instead of a shard member
+	Controller bool `yaml:"controller"`
+	// ReadReplica joins the shards as a raft nonvoter that serves reads without counting in the quorum
+	ReadReplica bool `yaml:"read_replica"`
+	// Bootstrap starts the shards without raft state as single node groups, a node without gossip.root_node always
+	// does. The others ask the leader of each shard to add them
+	Bootstrap bool `yaml:"bootstrap"`
+	// BootstrapExpect starts the shards without raft state once this many voters of each are in the gossip, unless
+	// one of them already has a leader
+	BootstrapExpect int `yaml:"bootstrap_expect"`
+	// Controllers are the addresses of the metadata controllers
+	Controllers   []string `yaml:"controllers"`
+	ForwardWrites bool     `yaml:"forward_writes"`
+	// MaxMessageSize is the largest grpc message in bytes
+	MaxMessageSize int `yaml:"max_message_size"`
+	// DevMode stores the messages as json, the stores can't be read once it changed
+	DevMode bool `yaml:"dev_mode"`
+	// ShutdownTimeout is how long a stopping node waits for the calls in flight
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type Gossip struct {
+	Address string `yaml:"address"`
+	// ShardAddresses are the gossip addresses of the shards after the first one of node.shards, in the same order
+	ShardAddresses []string      `yaml:"shard_addresses"`
+	RootNode       string        `yaml:"root_node"`
+	Keys           []string      `yaml:"keys"`
+	KeyringFile    string        `yaml:"keyring_file"`
+	Timings        GossipTimings `yaml:"timings"`
+}
+
+// GossipTimings tune the failure detection and the spread of the gossip of the shards
+type GossipTimings struct {
+	TCPTimeout       time.Duration `yaml:"tcp_timeout"`
+	ProbeInterval    time.Duration `yaml:"probe_interval"`
+	ProbeTimeout     time.Duration `yaml:"probe_timeout"`
+	GossipInterval   time.Duration `yaml:"gossip_interval"`
+	PushPullInterval time.Duration `yaml:"push_pull_interval"`
+	// GossipToTheDeadTime is how long the dead members still get the gossip
+	GossipToTheDeadTime time.Duration `yaml:"gossip_to_the_dead_time"`
+	// SuspicionMult scales how long a member is suspected before it is declared dead
+	SuspicionMult int `yaml:"suspicion_mult"`
+	// GossipNodes is how many members get each gossip message
+	GossipNodes int `yaml:"gossip_nodes"`
+}
+
+func DefaultGossipTimings() GossipTimings {
+	return GossipTimings{
+		TCPTimeout:          30 * time.Second,
+		ProbeInterval:       5 * time.Second,
+		ProbeTimeout:        3 * time.Second,
+		GossipInterval:      500 * time.Millisecond,
+		PushPullInterval:    60 * time.Second,
+		GossipToTheDeadTime: 60 * time.Second,
+		SuspicionMult:       6,
+		GossipNodes:         4,
+	}
+}
+
+type TLS struct {
+	Cert       string `yaml:"cert"`
+	Key        string `yaml:"key"`
+	CA         string `yaml:"ca"`
+	ClientAuth string `yaml:"client_auth"`
+	ServerName string `yaml:"server_name"`
+}
+
+type Auth struct {
+	TokenFile   string   `yaml:"token_file"`
+	JWKSFile    string   `yaml:"jwks_file"`
+	JWTIssuer   string   `yaml:"jwt_issuer"`
+	JWTAudience string   `yaml:"jwt_audience"`
+	MTLS        bool     `yaml:"mtls"`
+	NodeToken   string   `yaml:"node_token"`
+	ACL         bool     `yaml:"acl"`
+	Superusers  []string `yaml:"superusers"`
+}
+
+// Enabled is true when the callers are authenticated
+func (a Auth) Enabled() bool {
+	return a.TokenFile != "" || a.JWKSFile != "" || a.MTLS
+}
+
+type Metrics struct {
+	Address string `yaml:"address"`
+}
+
+type Tracing struct {
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+type Balancer struct {
+	Enabled   bool          `yaml:"enabled"`
+	DryRun    bool          `yaml:"dry_run"`
+	Interval  time.Duration `yaml:"interval"`
+	MaxMoves  int           `yaml:"max_moves"`
+	Threshold float64       `yaml:"threshold"`
+}
+
+type Runtime struct {
+	LogLevel     string        `yaml:"log_level"`
+	ConsumeChunk int           `yaml:"consume_chunk"`
+	ApplyTimeout time.Duration `yaml:"apply_timeout"`
+	Removal      RemovalPolicy `yaml:"removal"`
+}
+
+// Settings parses the runtime section, the file has to be valid
+func (r Runtime) Settings() (Settings, error) {
+	level, err := zerolog.ParseLevel(r.LogLevel)
+	if err != nil {
+		return Settings{}, err
+	}
+	return Settings{
+		LogLevel:     level,
+		ConsumeChunk: r.ConsumeChunk,
+		ApplyTimeout: r.ApplyTimeout,
+		Removal:      r.Removal,
+	}, nil
+}
+
+// Default returns the config the file and the environment are applied on
+func Default() *File {
+	settings := DefaultSettings()
+	return &File{
+		Node: Node{
+			RaftDir:         "data/",
+			ForwardWrites:   true,
+			MaxMessageSize:  DefaultMaxMessageSize,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Gossip: Gossip{Timings: DefaultGossipTimings()},
+		TLS:    TLS{ClientAuth: "none"},
+		Tracing: Tracing{
+			SampleRatio: 1,
+		},
+		Balancer: Balancer{
+			Interval:  time.Minute,
+			MaxMoves:  1,
+			Threshold: 0.1,
+		},
+		Runtime: Runtime{
+			LogLevel:     settings.LogLevel.String(),
+			ConsumeChunk: settings.ConsumeChunk,
+			ApplyTimeout: settings.ApplyTimeout,
+			Removal:      settings.Removal,
+		},
+	}
+}
+
+// Load reads the yaml file over the defaults, then the environment variables over it, and validates the result
+func Load(path string) (*File, error) {
+	file := Default()
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(buf))
+	decoder.KnownFields(true)
+	//an empty file keeps the defaults
+	if err := decoder.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := ApplyEnv(file, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := file.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Validate checks the settings that would only fail once the node runs
+func (f *File) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(f.Node.Name != "", "node.name is needed")
+	check(f.Node.Address != "", "node.address is needed")
+	check(f.Node.MaxMessageSize > 0, "node.max_message_size has to be positive")
+	check(f.Node.ShutdownTimeout > 0, "node.shutdown_timeout has to be positive")
+	if !f.Node.Controller {
+		check(len(f.Node.Shards) > 0, "node.shards needs at least one shard")
+		check(f.Node.DataDir != "" || f.Node.InMemory, "node.data_dir is needed unless node.in_memory is set")
+		check(f.Gossip.Address != "", "gossip.address is needed")
+		check(len(f.Node.Shards) < 2 || len(f.Gossip.ShardAddresses) == len(f.Node.Shards)-1,
+			"gossip.shard_addresses needs an address for every shard after the first one of node.shards")
+		check(!f.Node.ReadReplica || f.Gossip.RootNode != "", "node.read_replica needs gossip.root_node to join its shards")
+	}
+	check(!f.Node.ReadReplica || !f.Node.Controller, "node.read_replica can't be a controller")
+	check(f.Node.BootstrapExpect >= 0, "node.bootstrap_expect can't be negative")
+	check(!f.Node.Bootstrap || f.Node.BootstrapExpect == 0, "node.bootstrap and node.bootstrap_expect can't be used together")
+	check(!f.Node.ReadReplica || !f.Node.Bootstrap && f.Node.BootstrapExpect == 0, "node.read_replica can't bootstrap its shards")
+	timings := reflect.ValueOf(f.Gossip.Timings)
+	for i := 0; i < timings.NumField(); i++ {
+		check(timings.Field(i).Int() > 0, "gossip.timings.%s has to be positive", timings.Type().Field(i).Tag.Get("yaml"))
+	}
+	check(f.TLS.Cert == "" || f.TLS.Key != "", "tls.key is needed with tls.cert")
+	check(f.TLS.Cert != "" || f.TLS.Key == "" && f.TLS.CA == "", "tls.cert is needed with tls.key and tls.ca")
+	switch f.TLS.ClientAuth {
+	case "none", "request", "require":
+	default:
+		check(false, "tls.client_auth is none, request or require, not %q", f.TLS.ClientAuth)
+	}
+	check(!f.Auth.ACL || f.Auth.Enabled(), "auth.acl needs auth.token_file, auth.jwks_file or auth.mtls")
+	check(!f.Auth.MTLS || f.TLS.ClientAuth != "none", "auth.mtls needs tls.client_auth")
+	check(f.Tracing.SampleRatio >= 0 && f.Tracing.SampleRatio <= 1, "tracing.sample_ratio is between 0 and 1")
+	check(f.Balancer.Interval > 0, "balancer.interval has to be positive")
+	check(f.Balancer.MaxMoves > 0, "balancer.max_moves has to be positive")
+	check(f.Balancer.Threshold >= 0, "balancer.threshold can't be negative")
+	_, err := zerolog.ParseLevel(f.Runtime.LogLevel)
+	check(err == nil, "runtime.log_level %q is not a level", f.Runtime.LogLevel)
+	check(f.Runtime.ConsumeChunk > 0, "runtime.consume_chunk has to be positive")
+	check(f.Runtime.ApplyTimeout > 0, "runtime.apply_timeout has to be positive")
+	check(f.Runtime.Removal.After > 0, "runtime.removal.after has to be positive")
+	check(f.Runtime.Removal.MinVoters > 0, "runtime.removal.min_voters has to be positive")
+	return errors.Join(errs...)
+}
+
+// Reload reads the file again and applies its runtime settings. The sections that changed otherwise are returned,
+// they only apply after a restart
+func Reload(path string, running *File) ([]string, error) {
+	file, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := file.Runtime.Settings()
+	if err != nil {
+		return nil, err
+	}
+	Set(settings)
+	var restart []string
+	old, updated := reflect.ValueOf(running).Elem(), reflect.ValueOf(file).Elem()
+	for i := 0; i < old.NumField(); i++ {
+		name := old.Type().Field(i).Tag.Get("yaml")
+		if name != "runtime" && !reflect.DeepEqual(old.Field(i).Interface(), updated.Field(i).Interface()) {
+			restart = append(restart, name)
+		}
+	}
+	running.Runtime = file.Runtime
+	return restart, nil
+}
@@ -2,10 +2,17 @@ module github.com/Kapperchino/jet-stream/config
 
 go 1.20
 
-require github.com/rs/zerolog v1.29.0
+require (
+	github.com/rs/zerolog v1.29.0
+	github.com/stretchr/testify v1.8.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.17 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/objx v0.5.0 // indirect
 	golang.org/x/sys v0.5.0 // indirect
 )
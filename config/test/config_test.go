@@ -0,0 +1,149 @@
+package test
+
+import (
+	"github.com/Kapperchino/jet-stream/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const nodeConfig = `
+node:
+  name: nodeA
+  address: localhost:8080
+  data_dir: ./testData/nodeA
+  shards: [shard1, shard2]
+gossip:
+  address: localhost:8081
+  shard_addresses: [localhost:8082]
+  timings:
+    probe_interval: 1s
+runtime:
+  log_level: info
+  consume_chunk: 50
+`
+
+type ConfigTest struct {
+	suite.Suite
+	path string
+}
+
+func (suite *ConfigTest) SetupTest() {
+	suite.path = filepath.Join(suite.T().TempDir(), "jet.yaml")
+	suite.write(nodeConfig)
+}
+
+func (suite *ConfigTest) TearDownTest() {
+	config.Set(config.DefaultSettings())
+}
+
+func (suite *ConfigTest) write(content string) {
+	assert.Nil(suite.T(), os.WriteFile(suite.path, []byte(content), 0600))
+}
+
+func (suite *ConfigTest) TestLoad() {
+	file, err := config.Load(suite.path)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "nodeA", file.Node.Name)
+	assert.Equal(suite.T(), []string{"shard1", "shard2"}, file.Node.Shards)
+	assert.Equal(suite.T(), []string{"localhost:8082"}, file.Gossip.ShardAddresses)
+	assert.Equal(suite.T(), time.Second, file.Gossip.Timings.ProbeInterval)
+	//the fields missing from the file keep the defaults
+	assert.Equal(suite.T(), config.DefaultGossipTimings().ProbeTimeout, file.Gossip.Timings.ProbeTimeout)
+	assert.Equal(suite.T(), config.DefaultMaxMessageSize, file.Node.MaxMessageSize)
+	assert.True(suite.T(), file.Node.ForwardWrites)
+	settings, err := file.Runtime.Settings()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), zerolog.InfoLevel, settings.LogLevel)
+	assert.Equal(suite.T(), 50, settings.ConsumeChunk)
+	assert.Equal(suite.T(), time.Second, settings.ApplyTimeout)
+}
+
+func (suite *ConfigTest) TestUnknownField() {
+	suite.write(nodeConfig + "  unknown: 1\n")
+	_, err := config.Load(suite.path)
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *ConfigTest) TestEnvOverride() {
+	suite.T().Setenv("JET_NODE_NAME", "nodeB")
+	suite.T().Setenv("JET_NODE_SHARDS", "shard3")
+	suite.T().Setenv("JET_NODE_FORWARD_WRITES", "false")
+	suite.T().Setenv("JET_GOSSIP_TIMINGS_GOSSIP_INTERVAL", "250ms")
+	suite.T().Setenv("JET_TRACING_SAMPLE_RATIO", "0.5")
+	file, err := config.Load(suite.path)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "nodeB", file.Node.Name)
+	assert.Equal(suite.T(), []string{"shard3"}, file.Node.Shards)
+	assert.False(suite.T(), file.Node.ForwardWrites)
+	assert.Equal(suite.T(), 250*time.Millisecond, file.Gossip.Timings.GossipInterval)
+	assert.Equal(suite.T(), 0.5, file.Tracing.SampleRatio)
+
+	suite.T().Setenv("JET_RUNTIME_CONSUME_CHUNK", "many")
+	_, err = config.Load(suite.path)
+	assert.ErrorContains(suite.T(), err, "JET_RUNTIME_CONSUME_CHUNK")
+}
+
+func (suite *ConfigTest) TestValidate() {
+	suite.write(`
+node:
+  address: localhost:8080
+  shards: [shard1, shard2]
+  bootstrap: true
+  bootstrap_expect: 3
+tls:
+  key: node.key
+  client_auth: sometimes
+auth:
+  acl: true
+runtime:
+  log_level: loud
+  apply_timeout: 0s
+  removal:
+    min_voters: 0
+`)
+	_, err := config.Load(suite.path)
+	assert.NotNil(suite.T(), err)
+	for _, field := range []string{"node.name", "node.data_dir", "gossip.address", "gossip.shard_addresses", "node.bootstrap_expect", "tls.cert", "tls.client_auth",
+		"auth.acl", "runtime.log_level", "runtime.apply_timeout", "runtime.removal.min_voters"} {
+		assert.ErrorContains(suite.T(), err, field)
+	}
+	//controllers don't host shards or gossip
+	file := config.Default()
+	file.Node.Name = "controller1"
+	file.Node.Address = "localhost:8090"
+	file.Node.Controller = true
+	assert.Nil(suite.T(), file.Validate())
+}
+
+func (suite *ConfigTest) TestReload() {
+	running, err := config.Load(suite.path)
+	assert.Nil(suite.T(), err)
+	suite.write(nodeConfig + `  apply_timeout: 3s
+metrics:
+  address: localhost:9100
+`)
+	restart, err := config.Reload(suite.path, running)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"metrics"}, restart)
+	assert.Equal(suite.T(), 3*time.Second, config.Current().ApplyTimeout)
+	assert.Equal(suite.T(), zerolog.InfoLevel, zerolog.GlobalLevel())
+	//the running config only takes the runtime section
+	assert.Equal(suite.T(), "", running.Metrics.Address)
+	assert.Equal(suite.T(), 3*time.Second, running.Runtime.ApplyTimeout)
+
+	//an invalid file keeps the settings in use
+	suite.write(strings.Replace(nodeConfig, "consume_chunk: 50", "consume_chunk: 0", 1))
+	_, err = config.Reload(suite.path, running)
+	assert.NotNil(suite.T(), err)
+	assert.Equal(suite.T(), 3*time.Second, config.Current().ApplyTimeout)
+}
+
+func TestConfig(t *testing.T) {
+	suite.Run(t, new(ConfigTest))
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	appPb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	pb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"sync"
+	"time"
+)
+
+// AclTracker keeps the acls reported by each shard, the calls to the controller are checked against them. It only
+// lives on the leader and is rebuilt from the heartbeats after a leader change
+type AclTracker struct {
+	shards     map[string][]*pb.ShardAcl
+	heartbeats map[string]time.Time
+	mutex      sync.Mutex
+}
+
+func NewAclTracker() *AclTracker {
+	return &AclTracker{
+		shards:     map[string][]*pb.ShardAcl{},
+		heartbeats: map[string]time.Time{},
+	}
+}
+
+func (a *AclTracker) heartbeat(shardId string, acls []*pb.ShardAcl) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.shards[shardId] = acls
+	a.heartbeats[shardId] = time.Now()
+}
+
+// Acls are the acls every shard that sent a heartbeat lately has, an acl missing on a shard isn't applied yet. There
+// are none before the first heartbeat
+func (a *AclTracker) Acls() []*appPb.Acl {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	counts := map[string]int{}
+	acls := map[string]*appPb.Acl{}
+	shards := 0
+	for shardId, shardAcls := range a.shards {
+		if time.Since(a.heartbeats[shardId]) >= heartbeatTimeout {
+			continue
+		}
+		shards++
+		seen := map[string]bool{}
+		for _, acl := range shardAcls {
+			key := fmt.Sprintf("%s/%d/%s/%d", acl.Principal, acl.Resource, acl.Pattern, acl.Operation)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			counts[key]++
+			acls[key] = &appPb.Acl{
+				Principal: acl.Principal,
+				Resource:  appPb.AclResource(acl.Resource),
+				Pattern:   acl.Pattern,
+				Operation: appPb.AclOperation(acl.Operation),
+			}
+		}
+	}
+	var res []*appPb.Acl
+	for key, count := range counts {
+		if count == shards {
+			res = append(res, acls[key])
+		}
+	}
+	return res
+}
+
+// authorize checks that the caller may do the operation on the resource, anyone may when Authorize is nil
+func (r RpcInterface) authorize(ctx context.Context, resource appPb.AclResource, name string, operation appPb.AclOperation) error {
+	if r.Authorize == nil {
+		return nil
+	}
+	return r.Authorize(ctx, resource, name, operation)
+}
+
+// visible returns a filter of the resources the caller may see, all of them when Visible is nil
+func (r RpcInterface) visible(ctx context.Context) (func(resource appPb.AclResource, name string) bool, error) {
+	if r.Visible == nil {
+		return func(appPb.AclResource, string) bool { return true }, nil
+	}
+	return r.Visible(ctx)
+}
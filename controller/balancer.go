@@ -0,0 +1,321 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	appPb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	pb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/hashicorp/raft"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	balancerPoll = time.Second
+	// heartbeatTimeout is how long a shard may go without a heartbeat before nothing is moved to it
+	heartbeatTimeout = 10 * time.Second
+)
+
+type BalancerConfig struct {
+	// Enabled runs balancing rounds in the background, Balance can be called either way
+	Enabled bool
+	// DryRun makes the background rounds only log the plan
+	DryRun   bool
+	Interval time.Duration
+	// MaxConcurrentMoves throttles the moves, a round only starts moves while fewer are running
+	MaxConcurrentMoves int
+	// Threshold is the score difference between the most and the least loaded shard that is left alone
+	Threshold float64
+}
+
+func DefaultBalancerConfig() BalancerConfig {
+	return BalancerConfig{
+		Interval:           time.Minute,
+		MaxConcurrentMoves: 1,
+		Threshold:          0.1,
+	}
+}
+
+// LoadTracker keeps the last load reported by each shard, it only lives on the leader and is rebuilt from the
+// heartbeats after a leader change
+type LoadTracker struct {
+	partitions map[string]*pb.PartitionLoad
+	heartbeats map[string]time.Time
+	mutex      sync.Mutex
+}
+
+func NewLoadTracker() *LoadTracker {
+	return &LoadTracker{
+		partitions: map[string]*pb.PartitionLoad{},
+		heartbeats: map[string]time.Time{},
+	}
+}
+
+func (l *LoadTracker) heartbeat(shardId string, loads []*pb.PartitionLoad) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.heartbeats[shardId] = time.Now()
+	for _, load := range loads {
+		l.partitions[partitionKey(load.Topic, load.Partition)] = load
+	}
+}
+
+func (l *LoadTracker) alive(shardId string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return time.Since(l.heartbeats[shardId]) < heartbeatTimeout
+}
+
+func (l *LoadTracker) get(topic string, partition uint64) *pb.PartitionLoad {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if load := l.partitions[partitionKey(topic, partition)]; load != nil {
+		return load
+	}
+	return &pb.PartitionLoad{Topic: topic, Partition: partition}
+}
+
+func partitionKey(topic string, partition uint64) string {
+	return fmt.Sprintf("%s/%d", topic, partition)
+}
+
+func (r RpcInterface) Balance(ctx context.Context, req *pb.BalanceRequest) (*pb.BalanceResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, appPb.AclResource_CLUSTER, "", appPb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	res := r.planBalance()
+	if !req.GetDryRun() {
+		if err := r.startMoves(res.Moves); err != nil {
+			return nil, r.toStatusError(err)
+		}
+	}
+	return res, nil
+}
+
+// RunBalancer runs a balancing round every interval and whenever a shard registers, until stop is closed
+func RunBalancer(r RpcInterface, stop <-chan struct{}) {
+	ticker := time.NewTicker(balancerPoll)
+	defer ticker.Stop()
+	var lastRound time.Time
+	shards := 0
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if r.Raft.State() != raft.Leader {
+			continue
+		}
+		var current int
+		r.State.read(func(state *pb.ControllerState) {
+			current = len(state.Shards)
+		})
+		joined := current > shards
+		shards = current
+		if !joined && time.Since(lastRound) < r.Balancer.Interval {
+			continue
+		}
+		lastRound = time.Now()
+		res := r.planBalance()
+		if len(res.Moves) == 0 {
+			continue
+		}
+		if r.Balancer.DryRun {
+			r.State.Logger.Info().Msgf("Balancer dry run, planned moves: %s", describeMoves(res.Moves))
+			continue
+		}
+		if err := r.startMoves(res.Moves); err != nil {
+			r.State.Logger.Warn().Err(err).Msgf("Error starting the balancer moves")
+		}
+	}
+}
+
+// startMoves starts the planned moves in order while the throttle allows, later moves are planned again next round
+func (r RpcInterface) startMoves(moves []*pb.PlannedMove) error {
+	running := 0
+	r.State.read(func(state *pb.ControllerState) {
+		for _, item := range state.Reassignments {
+			if isActive(item) {
+				running++
+			}
+		}
+	})
+	for _, move := range moves {
+		if running >= r.Balancer.MaxConcurrentMoves {
+			return nil
+		}
+		res, err := r.ReassignPartition(context.Background(), &pb.ReassignPartitionRequest{
+			Topic:       move.Topic,
+			Partition:   move.Partition,
+			TargetShard: move.TargetShard,
+		})
+		if err != nil {
+			return err
+		}
+		move.ReassignmentId = res.Reassignment.Id
+		running++
+		r.State.Logger.Info().Msgf("Balancer moving partition %v of topic %s from %s to %s", move.Partition,
+			move.Topic, move.SourceShard, move.TargetShard)
+	}
+	return nil
+}
+
+type balanceItem struct {
+	load    *pb.PartitionLoad
+	shardId string
+	weight  float64
+	movable bool
+}
+
+// planBalance greedily moves the partition that best halves the gap between the most and the least loaded shard
+// until the gap is under the threshold. The score of a shard is its share of the partitions, bytes and publish rate
+func (r RpcInterface) planBalance() *pb.BalanceResponse {
+	var shards []string
+	var items []*balanceItem
+	r.State.read(func(state *pb.ControllerState) {
+		moving := map[string]string{}
+		for _, item := range state.Reassignments {
+			if isActive(item) {
+				moving[partitionKey(item.Topic, item.Partition)] = item.TargetShard
+			}
+		}
+		draining := drainingShards(state)
+		for shardId := range state.Shards {
+			if draining[shardId] {
+				continue
+			}
+			if r.Load == nil || r.Load.alive(shardId) {
+				shards = append(shards, shardId)
+			}
+		}
+		for name, topic := range state.Topics {
+			if topic.State != pb.TopicState_READY {
+				continue
+			}
+			for num, partition := range topic.Partitions {
+				item := &balanceItem{
+					load:    &pb.PartitionLoad{Topic: name, Partition: num},
+					shardId: partition.ShardId,
+					movable: true,
+				}
+				if r.Load != nil {
+					item.load = r.Load.get(name, num)
+				}
+				//count running moves on their target, they are not moved again
+				if target, ok := moving[partitionKey(name, num)]; ok {
+					item.shardId = target
+					item.movable = false
+				}
+				items = append(items, item)
+			}
+		}
+	})
+	sort.Strings(shards)
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].load.Topic != items[j].load.Topic {
+			return items[i].load.Topic < items[j].load.Topic
+		}
+		return items[i].load.Partition < items[j].load.Partition
+	})
+	var totalBytes uint64
+	var totalRate float64
+	for _, item := range items {
+		totalBytes += item.load.Bytes
+		totalRate += item.load.PublishRate
+	}
+	for _, item := range items {
+		item.weight = 1 / float64(len(items))
+		if totalBytes > 0 {
+			item.weight += float64(item.load.Bytes) / float64(totalBytes)
+		}
+		if totalRate > 0 {
+			item.weight += item.load.PublishRate / totalRate
+		}
+	}
+	res := &pb.BalanceResponse{Before: shardLoads(shards, items)}
+	for len(shards) > 1 {
+		scores := map[string]float64{}
+		for _, item := range items {
+			scores[item.shardId] += item.weight
+		}
+		most, least := shards[0], shards[0]
+		for _, shardId := range shards {
+			if scores[shardId] > scores[most] {
+				most = shardId
+			}
+			if scores[shardId] < scores[least] {
+				least = shardId
+			}
+		}
+		gap := scores[most] - scores[least]
+		if gap <= r.Balancer.Threshold {
+			break
+		}
+		//the move has to shrink the gap, the best one halves it
+		var best *balanceItem
+		for _, item := range items {
+			if !item.movable || item.shardId != most || item.weight >= gap {
+				continue
+			}
+			if best == nil || abs(item.weight-gap/2) < abs(best.weight-gap/2) {
+				best = item
+			}
+		}
+		if best == nil {
+			break
+		}
+		res.Moves = append(res.Moves, &pb.PlannedMove{
+			Topic:       best.load.Topic,
+			Partition:   best.load.Partition,
+			SourceShard: best.shardId,
+			TargetShard: least,
+			Bytes:       best.load.Bytes,
+			PublishRate: best.load.PublishRate,
+		})
+		best.shardId = least
+		best.movable = false
+	}
+	res.After = shardLoads(shards, items)
+	return res
+}
+
+func shardLoads(shards []string, items []*balanceItem) []*pb.ShardLoad {
+	loads := map[string]*pb.ShardLoad{}
+	var res []*pb.ShardLoad
+	for _, shardId := range shards {
+		loads[shardId] = &pb.ShardLoad{ShardId: shardId}
+		res = append(res, loads[shardId])
+	}
+	for _, item := range items {
+		load := loads[item.shardId]
+		if load == nil {
+			continue
+		}
+		load.Partitions++
+		load.Bytes += item.load.Bytes
+		load.PublishRate += item.load.PublishRate
+		load.Score += item.weight
+	}
+	return res
+}
+
+func describeMoves(moves []*pb.PlannedMove) string {
+	var list []string
+	for _, move := range moves {
+		list = append(list, fmt.Sprintf("%s/%d %s -> %s", move.Topic, move.Partition, move.SourceShard, move.TargetShard))
+	}
+	return strings.Join(list, ", ")
+}
+
+func abs(val float64) float64 {
+	if val < 0 {
+		return -val
+	}
+	return val
+}
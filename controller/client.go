@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	pb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+// Client talks to the controller leader, following redirects from followers
+type Client struct {
+	addresses   []string
+	leader      string
+	conns       map[string]*grpc.ClientConn
+	dialOptions []grpc.DialOption
+	mutex       sync.Mutex
+}
+
+// NewClient calls the controllers at the addresses, the dial options replace the plaintext default
+func NewClient(addresses []string, dialOptions ...grpc.DialOption) *Client {
+	return &Client{
+		addresses:   addresses,
+		conns:       map[string]*grpc.ClientConn{},
+		dialOptions: dialOptions,
+	}
+}
+
+// Call runs the rpc against the controller leader, trying every known controller until one of them answers
+func Call[Req any, Res any](ctx context.Context, c *Client, req Req,
+	call func(pb.ControllerServiceClient, context.Context, Req, ...grpc.CallOption) (Res, error)) (Res, error) {
+	var res Res
+	var err error
+	tried := map[string]bool{}
+	for _, address := range c.candidates() {
+		for address != "" && !tried[address] {
+			tried[address] = true
+			var client pb.ControllerServiceClient
+			client, err = c.getClient(address)
+			if err != nil {
+				break
+			}
+			res, err = call(client, ctx, req)
+			if err == nil {
+				c.setLeader(address)
+				return res, nil
+			}
+			if status.Code(err) != codes.Unavailable {
+				return res, err
+			}
+			//follow the redirect if the follower knows the leader
+			address = notLeaderAddress(err)
+		}
+	}
+	if err == nil {
+		err = errors.New("no controller address")
+	}
+	return res, err
+}
+
+func (c *Client) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for address, conn := range c.conns {
+		_ = conn.Close()
+		delete(c.conns, address)
+	}
+}
+
+// candidates is the last known leader followed by every configured controller
+func (c *Client) candidates() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string{c.leader}, c.addresses...)
+}
+
+func (c *Client) setLeader(address string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.leader = address
+}
+
+func (c *Client) getClient(address string) (pb.ControllerServiceClient, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	conn := c.conns[address]
+	if conn == nil {
+		var err error
+		conn, err = grpc.Dial(address, append([]grpc.DialOption{grpc.WithInsecure()}, c.dialOptions...)...)
+		if err != nil {
+			return nil, err
+		}
+		c.conns[address] = conn
+	}
+	return pb.NewControllerServiceClient(conn), nil
+}
+
+func notLeaderAddress(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, detail := range st.Details() {
+		if notLeader, ok := detail.(*pb.NotLeader); ok {
+			return notLeader.LeaderAddress
+		}
+	}
+	return ""
+}
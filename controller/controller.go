@@ -0,0 +1,433 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	appPb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/config"
+	pb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/Kapperchino/jet-stream/leader-rpc/rafterrors"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"sort"
+	"time"
+)
+
+const (
+	// readyTimeout is how long CreateTopic and CreateConsumerGroup wait for the shards to create what was placed
+	readyTimeout      = 10 * time.Second
+	readyPollInterval = 100 * time.Millisecond
+)
+
+type RpcInterface struct {
+	State *State
+	Raft  *raft.Raft
+	// Load is what the shards reported in their heartbeats, the balancer treats every partition the same if nil
+	Load     *LoadTracker
+	Balancer BalancerConfig
+	// DialOptions are used to call the shards, they carry the transport credentials
+	DialOptions []grpc.DialOption
+	// Acls are the acls reported by the shards, Authorize checks the callers against them
+	Acls *AclTracker
+	// Authorize checks the callers like the shards do, anyone is allowed when it is nil
+	Authorize func(ctx context.Context, resource appPb.AclResource, name string, operation appPb.AclOperation) error
+	// Visible filters the topics and groups listed to the ones the caller has an acl on, all are listed when it is nil
+	Visible func(ctx context.Context) (func(resource appPb.AclResource, name string) bool, error)
+	pb.UnimplementedControllerServiceServer
+}
+
+// CreateTopic places the partitions on the shards in a single raft entry, the shards pick the placement up through
+// their heartbeats. It fails with DeadlineExceeded when the shards didn't create the topic in time, calling it again
+// with the same partitions waits for the placed topic
+func (r RpcInterface) CreateTopic(ctx context.Context, req *pb.CreateTopicRequest) (*pb.CreateTopicResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, appPb.AclResource_TOPIC, req.GetTopic(), appPb.AclOperation_CREATE); err != nil {
+		return nil, err
+	}
+	if req.GetTopic() == "" || req.GetPartitions() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "topic needs a name and at least one partition")
+	}
+	var pending bool
+	r.State.read(func(state *pb.ControllerState) {
+		topic := state.Topics[req.GetTopic()]
+		pending = topic != nil && topic.State != pb.TopicState_READY && uint64(len(topic.Partitions)) == req.GetPartitions()
+	})
+	if !pending {
+		placement, err := r.placeTopic(req)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := apply[*pb.TopicPlacement](r, &pb.WriteOperation{
+			Code:      pb.Operation_CREATE_TOPIC,
+			Operation: &pb.WriteOperation_CreateTopic{CreateTopic: placement},
+		}); err != nil {
+			return nil, r.toStatusError(err)
+		}
+	}
+	var res *pb.TopicPlacement
+	ready := r.waitUntil(ctx, func(state *pb.ControllerState) bool {
+		if topic := state.Topics[req.GetTopic()]; topic != nil && topic.State == pb.TopicState_READY {
+			res = proto.Clone(topic).(*pb.TopicPlacement)
+			return true
+		}
+		return false
+	})
+	if !ready {
+		return nil, status.Errorf(codes.DeadlineExceeded, "topic %s is placed but not created by every shard yet", req.GetTopic())
+	}
+	return &pb.CreateTopicResponse{Topic: res}, nil
+}
+
+// placeTopic spreads the partitions round robin over the shards, starting from the least loaded. Shards being
+// merged away are skipped
+func (r RpcInterface) placeTopic(req *pb.CreateTopicRequest) (*pb.TopicPlacement, error) {
+	var shards []string
+	load := map[string]int{}
+	r.State.read(func(state *pb.ControllerState) {
+		draining := drainingShards(state)
+		for shardId := range state.Shards {
+			if !draining[shardId] {
+				shards = append(shards, shardId)
+			}
+		}
+		for _, topic := range state.Topics {
+			for _, partition := range topic.Partitions {
+				load[partition.ShardId]++
+			}
+		}
+	})
+	if len(shards) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "no shards registered with the controller")
+	}
+	sort.Slice(shards, func(i, j int) bool {
+		if load[shards[i]] != load[shards[j]] {
+			return load[shards[i]] < load[shards[j]]
+		}
+		return shards[i] < shards[j]
+	})
+	placement := &pb.TopicPlacement{
+		Name:       req.GetTopic(),
+		Partitions: map[uint64]*pb.PartitionPlacement{},
+		State:      pb.TopicState_PENDING,
+	}
+	for num := uint64(0); num < req.GetPartitions(); num++ {
+		placement.Partitions[num] = &pb.PartitionPlacement{
+			Partition: num,
+			ShardId:   shards[num%uint64(len(shards))],
+		}
+	}
+	return placement, nil
+}
+
+// GetTopics only returns the topics the caller has an acl on, like the meta of the shards
+func (r RpcInterface) GetTopics(ctx context.Context, _ *pb.GetTopicsRequest) (*pb.GetTopicsResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	visible, err := r.visible(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.GetTopicsResponse{Topics: map[string]*pb.TopicPlacement{}}
+	r.State.read(func(state *pb.ControllerState) {
+		for name, topic := range state.Topics {
+			if visible(appPb.AclResource_TOPIC, name) {
+				res.Topics[name] = proto.Clone(topic).(*pb.TopicPlacement)
+			}
+		}
+	})
+	return res, nil
+}
+
+// CreateConsumerGroup fails with DeadlineExceeded when the shards of the topic didn't create the group in time,
+// calling it again with the same id waits for the registered group
+func (r RpcInterface) CreateConsumerGroup(ctx context.Context, req *pb.CreateConsumerGroupRequest) (*pb.CreateConsumerGroupResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, appPb.AclResource_TOPIC, req.GetTopic(), appPb.AclOperation_READ); err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, appPb.AclResource_GROUP, req.GetId(), appPb.AclOperation_CREATE); err != nil {
+		return nil, err
+	}
+	var pending bool
+	r.State.read(func(state *pb.ControllerState) {
+		group := state.ConsumerGroups[req.GetId()]
+		pending = group != nil && !group.Ready && group.Topic == req.GetTopic()
+	})
+	if !pending {
+		if _, err := apply[*pb.ConsumerGroupInfo](r, &pb.WriteOperation{
+			Code: pb.Operation_CREATE_CONSUMER_GROUP,
+			Operation: &pb.WriteOperation_CreateConsumerGroup{CreateConsumerGroup: &pb.ConsumerGroupInfo{
+				Id:    req.GetId(),
+				Topic: req.GetTopic(),
+			}},
+		}); err != nil {
+			return nil, r.toStatusError(err)
+		}
+	}
+	var res *pb.ConsumerGroupInfo
+	ready := r.waitUntil(ctx, func(state *pb.ControllerState) bool {
+		if group := state.ConsumerGroups[req.GetId()]; group != nil && group.Ready {
+			res = proto.Clone(group).(*pb.ConsumerGroupInfo)
+			return true
+		}
+		return false
+	})
+	if !ready {
+		return nil, status.Errorf(codes.DeadlineExceeded, "consumer group %s is registered but not created by every shard yet", req.GetId())
+	}
+	return &pb.CreateConsumerGroupResponse{Group: res}, nil
+}
+
+// GetConsumerGroups only returns the groups the caller has an acl on
+func (r RpcInterface) GetConsumerGroups(ctx context.Context, req *pb.GetConsumerGroupsRequest) (*pb.GetConsumerGroupsResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	visible, err := r.visible(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.GetConsumerGroupsResponse{}
+	r.State.read(func(state *pb.ControllerState) {
+		for _, group := range state.ConsumerGroups {
+			if !visible(appPb.AclResource_GROUP, group.Id) {
+				continue
+			}
+			if req.GetTopic() == "" || group.Topic == req.GetTopic() {
+				res.Groups = append(res.Groups, proto.Clone(group).(*pb.ConsumerGroupInfo))
+			}
+		}
+	})
+	return res, nil
+}
+
+func (r RpcInterface) SetConfig(ctx context.Context, req *pb.SetConfigRequest) (*pb.SetConfigResponse, error) {
+	if err := r.authorize(ctx, appPb.AclResource_CLUSTER, "", appPb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	res, err := apply[*pb.SetConfigResponse](r, &pb.WriteOperation{
+		Code:      pb.Operation_SET_CONFIG,
+		Operation: &pb.WriteOperation_SetConfig{SetConfig: req},
+	})
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	return res, nil
+}
+
+func (r RpcInterface) GetConfig(_ context.Context, _ *pb.GetConfigRequest) (*pb.GetConfigResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	res := &pb.GetConfigResponse{Config: map[string]string{}}
+	r.State.read(func(state *pb.ControllerState) {
+		for key, val := range state.Config {
+			res.Config[key] = val
+		}
+	})
+	return res, nil
+}
+
+// ShardHeartbeat registers the shard, marks what it hosts as ready and returns what it still has to create
+func (r RpcInterface) ShardHeartbeat(ctx context.Context, req *pb.ShardHeartbeatRequest) (*pb.ShardHeartbeatResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	//the heartbeats carry the acls, only the nodes may send them
+	if err := r.authorize(ctx, appPb.AclResource_CLUSTER, "", appPb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	shard := req.GetShard()
+	if shard.GetShardId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "heartbeat needs a shard id")
+	}
+	var dropping bool
+	r.State.read(func(state *pb.ControllerState) {
+		for _, item := range state.ShardOperations {
+			if item.SourceShard == shard.ShardId && item.Phase == pb.ShardOperationPhase_DROPPING {
+				dropping = true
+			}
+		}
+	})
+	//a merged shard that is being stopped must not register itself again
+	if dropping {
+		return &pb.ShardHeartbeatResponse{}, nil
+	}
+	if r.Load != nil {
+		r.Load.heartbeat(shard.ShardId, req.GetLoad())
+	}
+	if r.Acls != nil {
+		r.Acls.heartbeat(shard.ShardId, req.GetAcls())
+	}
+	var changed bool
+	ready := &pb.MarkReady{ShardId: shard.ShardId, Topics: map[string]*pb.PartitionList{}}
+	res := &pb.ShardHeartbeatResponse{Topics: map[string]*pb.PartitionList{}}
+	r.State.read(func(state *pb.ControllerState) {
+		changed = !proto.Equal(state.Shards[shard.ShardId], shard)
+		for name, topic := range state.Topics {
+			hosted := map[uint64]bool{}
+			for _, num := range req.GetTopics()[name].GetPartitions() {
+				hosted[num] = true
+			}
+			for num, partition := range topic.Partitions {
+				if partition.ShardId != shard.ShardId {
+					continue
+				}
+				if !hosted[num] {
+					appendPartition(res.Topics, name, num)
+				} else if !partition.Ready {
+					appendPartition(ready.Topics, name, num)
+				}
+			}
+		}
+		for id, group := range state.ConsumerGroups {
+			if !topicShards(state.Topics[group.Topic])[shard.ShardId] || contains(group.ReadyShards, shard.ShardId) {
+				continue
+			}
+			if contains(req.GetConsumerGroups(), id) {
+				ready.ConsumerGroups = append(ready.ConsumerGroups, id)
+			} else {
+				res.ConsumerGroups = append(res.ConsumerGroups, proto.Clone(group).(*pb.ConsumerGroupInfo))
+			}
+		}
+	})
+	if changed {
+		_, err := apply[*pb.ShardRecord](r, &pb.WriteOperation{
+			Code:      pb.Operation_UPDATE_SHARD,
+			Operation: &pb.WriteOperation_UpdateShard{UpdateShard: shard},
+		})
+		if err != nil {
+			return nil, r.toStatusError(err)
+		}
+	}
+	if len(ready.Topics) > 0 || len(ready.ConsumerGroups) > 0 {
+		_, err := apply[*pb.MarkReady](r, &pb.WriteOperation{
+			Code:      pb.Operation_MARK_READY,
+			Operation: &pb.WriteOperation_MarkReady{MarkReady: ready},
+		})
+		if err != nil {
+			return nil, r.toStatusError(err)
+		}
+	}
+	return res, nil
+}
+
+func (r RpcInterface) GetControllerInfo(_ context.Context, _ *pb.GetControllerInfoRequest) (*pb.GetControllerInfoResponse, error) {
+	address, id := r.Raft.LeaderWithID()
+	res := &pb.GetControllerInfoResponse{
+		LeaderId:      string(id),
+		LeaderAddress: string(address),
+		Members:       map[string]string{},
+	}
+	future := r.Raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, rafterrors.MarkRetriable(err)
+	}
+	for _, server := range future.Configuration().Servers {
+		res.Members[string(server.ID)] = string(server.Address)
+	}
+	return res, nil
+}
+
+// apply commits the operation through raft and returns the fsm response
+func apply[Res any](r RpcInterface, operation *pb.WriteOperation) (Res, error) {
+	var empty Res
+	val, _ := util.SerializeMessage(operation)
+	future := r.Raft.Apply(val, config.Current().ApplyTimeout)
+	if err := future.Error(); err != nil {
+		return empty, err
+	}
+	if err, isErr := future.Response().(error); isErr {
+		return empty, err
+	}
+	response, isValid := future.Response().(Res)
+	if !isValid {
+		return empty, errors.New("unknown data type")
+	}
+	return response, nil
+}
+
+// waitUntil polls the state until done returns true or the request is out of time, leaving time to answer before the
+// caller's deadline. It returns whether done returned true
+func (r RpcInterface) waitUntil(ctx context.Context, done func(state *pb.ControllerState) bool) bool {
+	timeout := readyTimeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline)-readyPollInterval < timeout {
+		timeout = time.Until(deadline) - readyPollInterval
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+	for {
+		var finished bool
+		r.State.read(func(state *pb.ControllerState) {
+			finished = done(state)
+		})
+		if finished {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkLeader rejects requests on followers, reads are served by the leader so they see every committed change
+func (r RpcInterface) checkLeader() error {
+	if r.Raft.State() == raft.Leader {
+		return nil
+	}
+	return r.notLeaderError()
+}
+
+// notLeaderError is a retriable status carrying a NotLeader detail so clients can redirect to the leader
+func (r RpcInterface) notLeaderError() error {
+	address, id := r.Raft.LeaderWithID()
+	st, err := status.New(rafterrors.RetriableCode(raft.ErrNotLeader), raft.ErrNotLeader.Error()).WithDetails(&pb.NotLeader{
+		LeaderId:      string(id),
+		LeaderAddress: string(address),
+	})
+	if err != nil {
+		return rafterrors.MarkRetriable(raft.ErrNotLeader)
+	}
+	return st.Err()
+}
+
+// toStatusError maps raft and fsm errors to grpc status codes
+func (r RpcInterface) toStatusError(err error) error {
+	switch {
+	case errors.Is(err, raft.ErrNotLeader), errors.Is(err, raft.ErrLeadershipLost):
+		return r.notLeaderError()
+	case errors.Is(err, ErrTopicExists), errors.Is(err, ErrGroupExists), errors.Is(err, ErrShardExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrTopicNotFound), errors.Is(err, ErrPartitionNotFound), errors.Is(err, ErrShardNotFound),
+		errors.Is(err, ErrReassignmentNotFound), errors.Is(err, ErrShardOperationNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrReassignmentActive), errors.Is(err, ErrReassignmentSwitched),
+		errors.Is(err, ErrShardOperationActive), errors.Is(err, ErrShardNotEmpty):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case rafterrors.RetriableCode(err) != codes.Unknown:
+		return rafterrors.MarkRetriable(err)
+	}
+	return err
+}
+
+func appendPartition(topics map[string]*pb.PartitionList, name string, num uint64) {
+	list := topics[name]
+	if list == nil {
+		list = &pb.PartitionList{}
+		topics[name] = list
+	}
+	list.Partitions = append(list.Partitions, num)
+}
@@ -0,0 +1,352 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	pb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"sync"
+)
+
+var (
+	ErrTopicExists   = errors.New("topic exists")
+	ErrTopicNotFound = errors.New("topic does not exist")
+	ErrGroupExists   = errors.New("consumer group exists")
+
+	ErrPartitionNotFound    = errors.New("partition does not exist")
+	ErrShardNotFound        = errors.New("shard is not registered with the controller")
+	ErrReassignmentActive   = errors.New("partition is already being moved or is on the target shard")
+	ErrReassignmentNotFound = errors.New("reassignment does not exist")
+	ErrReassignmentSwitched = errors.New("reassignment already switched the placement")
+
+	ErrShardExists            = errors.New("shard is already registered with the controller")
+	ErrShardOperationActive   = errors.New("shard is already being split or merged")
+	ErrShardOperationNotFound = errors.New("shard operation does not exist")
+	ErrShardNotEmpty          = errors.New("shard still hosts partitions")
+)
+
+// State is the fsm of the controller raft group, everything is kept in memory and snapshotted as a whole
+type State struct {
+	state  *pb.ControllerState
+	mutex  sync.RWMutex
+	Logger *zerolog.Logger
+}
+
+var _ raft.FSM = &State{}
+
+func NewState(logger *zerolog.Logger) *State {
+	return &State{
+		state:  newControllerState(),
+		Logger: logger,
+	}
+}
+
+func newControllerState() *pb.ControllerState {
+	return &pb.ControllerState{
+		Topics:          map[string]*pb.TopicPlacement{},
+		ConsumerGroups:  map[string]*pb.ConsumerGroupInfo{},
+		Config:          map[string]string{},
+		Shards:          map[string]*pb.ShardRecord{},
+		Reassignments:   map[string]*pb.Reassignment{},
+		ShardOperations: map[string]*pb.ShardOperation{},
+	}
+}
+
+func (s *State) Apply(l *raft.Log) interface{} {
+	operation := &pb.WriteOperation{}
+	err := util.DeserializeMessage(l.Data, operation)
+	if err != nil {
+		s.Logger.Error().Err(err).Msgf("Error deserializing operation")
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch operation.Code {
+	case pb.Operation_CREATE_TOPIC:
+		return s.createTopic(operation.GetCreateTopic())
+	case pb.Operation_CREATE_CONSUMER_GROUP:
+		return s.createConsumerGroup(operation.GetCreateConsumerGroup())
+	case pb.Operation_SET_CONFIG:
+		s.state.Config[operation.GetSetConfig().Key] = operation.GetSetConfig().Value
+		return &pb.SetConfigResponse{}
+	case pb.Operation_UPDATE_SHARD:
+		shard := operation.GetUpdateShard()
+		s.state.Shards[shard.ShardId] = shard
+		return proto.Clone(shard)
+	case pb.Operation_MARK_READY:
+		s.markReady(operation.GetMarkReady())
+		return &pb.MarkReady{}
+	case pb.Operation_START_REASSIGNMENT:
+		return s.startReassignment(operation.GetStartReassignment())
+	case pb.Operation_UPDATE_REASSIGNMENT:
+		return s.updateReassignment(operation.GetUpdateReassignment())
+	case pb.Operation_CANCEL_REASSIGNMENT:
+		return s.cancelReassignment(operation.GetCancelReassignment().GetId())
+	case pb.Operation_START_SHARD_OPERATION:
+		return s.startShardOperation(operation.GetStartShardOperation())
+	case pb.Operation_UPDATE_SHARD_OPERATION:
+		return s.updateShardOperation(operation.GetUpdateShardOperation())
+	case pb.Operation_REMOVE_SHARD:
+		return s.removeShard(operation.GetRemoveShard().GetShardId())
+	}
+	return fmt.Errorf("unknown operation %s", operation.Code)
+}
+
+func (s *State) createTopic(topic *pb.TopicPlacement) interface{} {
+	if s.state.Topics[topic.Name] != nil {
+		return ErrTopicExists
+	}
+	s.state.Topics[topic.Name] = topic
+	s.Logger.Info().Msgf("Placed topic %s with %d partitions", topic.Name, len(topic.Partitions))
+	return proto.Clone(topic)
+}
+
+func (s *State) createConsumerGroup(group *pb.ConsumerGroupInfo) interface{} {
+	if s.state.Topics[group.Topic] == nil {
+		return ErrTopicNotFound
+	}
+	if s.state.ConsumerGroups[group.Id] != nil {
+		return ErrGroupExists
+	}
+	s.state.ConsumerGroups[group.Id] = group
+	return proto.Clone(group)
+}
+
+// markReady records what a shard reported to host, topics and groups are ready once every shard involved has them
+func (s *State) markReady(req *pb.MarkReady) {
+	for name, list := range req.Topics {
+		topic := s.state.Topics[name]
+		if topic == nil {
+			continue
+		}
+		for _, num := range list.Partitions {
+			if partition := topic.Partitions[num]; partition != nil && partition.ShardId == req.ShardId {
+				partition.Ready = true
+			}
+		}
+		topic.State = pb.TopicState_READY
+		for _, partition := range topic.Partitions {
+			if !partition.Ready {
+				topic.State = pb.TopicState_PENDING
+				break
+			}
+		}
+	}
+	for _, id := range req.ConsumerGroups {
+		group := s.state.ConsumerGroups[id]
+		if group == nil || contains(group.ReadyShards, req.ShardId) {
+			continue
+		}
+		group.ReadyShards = append(group.ReadyShards, req.ShardId)
+		group.Ready = true
+		for shardId := range topicShards(s.state.Topics[group.Topic]) {
+			if !contains(group.ReadyShards, shardId) {
+				group.Ready = false
+				break
+			}
+		}
+	}
+}
+
+func (s *State) startReassignment(req *pb.Reassignment) interface{} {
+	topic := s.state.Topics[req.Topic]
+	if topic == nil {
+		return ErrTopicNotFound
+	}
+	partition := topic.Partitions[req.Partition]
+	if partition == nil {
+		return ErrPartitionNotFound
+	}
+	if s.state.Shards[req.TargetShard] == nil {
+		return ErrShardNotFound
+	}
+	if partition.ShardId != req.SourceShard || req.SourceShard == req.TargetShard {
+		return ErrReassignmentActive
+	}
+	for _, item := range s.state.Reassignments {
+		if item.Topic == req.Topic && item.Partition == req.Partition && isActive(item) {
+			return ErrReassignmentActive
+		}
+	}
+	if s.state.Reassignments == nil {
+		s.state.Reassignments = map[string]*pb.Reassignment{}
+	}
+	s.state.Reassignments[req.Id] = req
+	s.Logger.Info().Msgf("Moving partition %v of topic %s from %s to %s", req.Partition, req.Topic, req.SourceShard, req.TargetShard)
+	return proto.Clone(req)
+}
+
+// updateReassignment records the progress of a move, the placement switches to the target when the move enters
+// SWITCHED
+func (s *State) updateReassignment(req *pb.Reassignment) interface{} {
+	current := s.state.Reassignments[req.Id]
+	if current == nil {
+		return ErrReassignmentNotFound
+	}
+	if !isActive(current) {
+		return proto.Clone(current)
+	}
+	//the cancel flag is only set through CANCEL_REASSIGNMENT, the mover might not have seen it yet
+	req.CancelRequested = req.CancelRequested || current.CancelRequested
+	if req.Phase == pb.ReassignmentPhase_SWITCHED && current.Phase < pb.ReassignmentPhase_SWITCHED {
+		if topic := s.state.Topics[req.Topic]; topic != nil && topic.Partitions[req.Partition] != nil {
+			topic.Partitions[req.Partition].ShardId = req.TargetShard
+		}
+		s.Logger.Info().Msgf("Switched partition %v of topic %s to %s", req.Partition, req.Topic, req.TargetShard)
+	}
+	s.state.Reassignments[req.Id] = req
+	return proto.Clone(req)
+}
+
+func (s *State) cancelReassignment(id string) interface{} {
+	current := s.state.Reassignments[id]
+	if current == nil {
+		return ErrReassignmentNotFound
+	}
+	if current.Phase >= pb.ReassignmentPhase_SWITCHED {
+		return ErrReassignmentSwitched
+	}
+	current.CancelRequested = true
+	return proto.Clone(current)
+}
+
+func (s *State) startShardOperation(req *pb.ShardOperation) interface{} {
+	if s.state.Shards[req.SourceShard] == nil {
+		return ErrShardNotFound
+	}
+	if req.Type == pb.ShardOperationType_SPLIT && s.state.Shards[req.TargetShard] != nil {
+		return ErrShardExists
+	}
+	if req.Type == pb.ShardOperationType_MERGE && s.state.Shards[req.TargetShard] == nil {
+		return ErrShardNotFound
+	}
+	for _, item := range s.state.ShardOperations {
+		if !isOperationActive(item) {
+			continue
+		}
+		for _, shardId := range []string{item.SourceShard, item.TargetShard} {
+			if shardId == req.SourceShard || shardId == req.TargetShard {
+				return ErrShardOperationActive
+			}
+		}
+	}
+	for name, list := range req.Partitions {
+		for _, num := range list.Partitions {
+			partition := s.state.Topics[name].GetPartitions()[num]
+			if partition == nil || partition.ShardId != req.SourceShard {
+				return fmt.Errorf("partition %d of topic %s is not on shard %s: %w", num, name, req.SourceShard, ErrPartitionNotFound)
+			}
+		}
+	}
+	if s.state.ShardOperations == nil {
+		s.state.ShardOperations = map[string]*pb.ShardOperation{}
+	}
+	s.state.ShardOperations[req.Id] = req
+	s.Logger.Info().Msgf("Started %s of shard %s and %s", req.Type, req.SourceShard, req.TargetShard)
+	return proto.Clone(req)
+}
+
+func (s *State) updateShardOperation(req *pb.ShardOperation) interface{} {
+	current := s.state.ShardOperations[req.Id]
+	if current == nil {
+		return ErrShardOperationNotFound
+	}
+	if !isOperationActive(current) {
+		return proto.Clone(current)
+	}
+	s.state.ShardOperations[req.Id] = req
+	return proto.Clone(req)
+}
+
+// removeShard forgets a shard whose raft group was stopped, nothing may be placed on it anymore
+func (s *State) removeShard(shardId string) interface{} {
+	for _, topic := range s.state.Topics {
+		if topicShards(topic)[shardId] {
+			return ErrShardNotEmpty
+		}
+	}
+	delete(s.state.Shards, shardId)
+	s.Logger.Info().Msgf("Removed shard %s", shardId)
+	return &pb.RemoveShard{ShardId: shardId}
+}
+
+func isOperationActive(operation *pb.ShardOperation) bool {
+	return operation.Phase != pb.ShardOperationPhase_COMPLETED && operation.Phase != pb.ShardOperationPhase_FAILED
+}
+
+func isActive(reassignment *pb.Reassignment) bool {
+	return reassignment.Phase != pb.ReassignmentPhase_DONE && reassignment.Phase != pb.ReassignmentPhase_CANCELLED
+}
+
+// read runs f with a consistent view of the state, f must not keep references to it
+func (s *State) read(f func(state *pb.ControllerState)) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	f(s.state)
+}
+
+func (s *State) Snapshot() (raft.FSMSnapshot, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	buf, err := util.SerializeMessage(s.state)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot{buf: buf}, nil
+}
+
+func (s *State) Restore(r io.ReadCloser) error {
+	defer r.Close()
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	state := newControllerState()
+	if err := util.DeserializeMessage(buf, state); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state = state
+	return nil
+}
+
+type snapshot struct {
+	buf []byte
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if _, err := sink.Write(s.buf); err != nil {
+		sink.Cancel()
+		return fmt.Errorf("sink.Write(): %v", err)
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {
+}
+
+// topicShards returns the shards hosting partitions of the topic
+func topicShards(topic *pb.TopicPlacement) map[string]bool {
+	res := map[string]bool{}
+	if topic == nil {
+		return res
+	}
+	for _, partition := range topic.Partitions {
+		res[partition.ShardId] = true
+	}
+	return res
+}
+
+func contains(list []string, val string) bool {
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+module github.com/Kapperchino/jet-stream/controller
+
+go 1.20
+
+require (
+	github.com/Kapperchino/jet-stream/application/proto v0.0.0-20230505163303-df5e695febce
+	github.com/Kapperchino/jet-stream/cluster/proto v0.0.0-20230225202306-4020fc0a51bf
+	github.com/Kapperchino/jet-stream/controller/proto v0.0.0-20230225202306-4020fc0a51bf
+	github.com/Kapperchino/jet-stream/config v0.0.0-20230225202306-4020fc0a51bf
+	github.com/Kapperchino/jet-stream/factory v0.0.0-20230225202306-4020fc0a51bf
+	github.com/Kapperchino/jet-stream/leader-rpc v0.0.0-20230225202306-4020fc0a51bf
+	github.com/Kapperchino/jet-stream/util v0.0.0-20230225202306-4020fc0a51bf
+	github.com/hashicorp/raft v1.3.11
+	github.com/rs/zerolog v1.29.0
+	github.com/stretchr/testify v1.8.1
+	google.golang.org/grpc v1.53.0
+	google.golang.org/protobuf v1.28.1
+)
@@ -0,0 +1,639 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: controller.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	ControllerService_CreateTopic_FullMethodName         = "/controller.ControllerService/CreateTopic"
+	ControllerService_GetTopics_FullMethodName           = "/controller.ControllerService/GetTopics"
+	ControllerService_CreateConsumerGroup_FullMethodName = "/controller.ControllerService/CreateConsumerGroup"
+	ControllerService_GetConsumerGroups_FullMethodName   = "/controller.ControllerService/GetConsumerGroups"
+	ControllerService_SetConfig_FullMethodName           = "/controller.ControllerService/SetConfig"
+	ControllerService_GetConfig_FullMethodName           = "/controller.ControllerService/GetConfig"
+	ControllerService_ShardHeartbeat_FullMethodName      = "/controller.ControllerService/ShardHeartbeat"
+	ControllerService_GetControllerInfo_FullMethodName   = "/controller.ControllerService/GetControllerInfo"
+	ControllerService_ReassignPartition_FullMethodName   = "/controller.ControllerService/ReassignPartition"
+	ControllerService_GetReassignments_FullMethodName    = "/controller.ControllerService/GetReassignments"
+	ControllerService_CancelReassignment_FullMethodName  = "/controller.ControllerService/CancelReassignment"
+	ControllerService_Balance_FullMethodName             = "/controller.ControllerService/Balance"
+	ControllerService_SplitShard_FullMethodName          = "/controller.ControllerService/SplitShard"
+	ControllerService_MergeShards_FullMethodName         = "/controller.ControllerService/MergeShards"
+	ControllerService_GetShardOperations_FullMethodName  = "/controller.ControllerService/GetShardOperations"
+)
+
+// ControllerServiceClient is the client API for ControllerService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ControllerServiceClient interface {
+	CreateTopic(ctx context.Context, in *CreateTopicRequest, opts ...grpc.CallOption) (*CreateTopicResponse, error)
+	GetTopics(ctx context.Context, in *GetTopicsRequest, opts ...grpc.CallOption) (*GetTopicsResponse, error)
+	CreateConsumerGroup(ctx context.Context, in *CreateConsumerGroupRequest, opts ...grpc.CallOption) (*CreateConsumerGroupResponse, error)
+	GetConsumerGroups(ctx context.Context, in *GetConsumerGroupsRequest, opts ...grpc.CallOption) (*GetConsumerGroupsResponse, error)
+	SetConfig(ctx context.Context, in *SetConfigRequest, opts ...grpc.CallOption) (*SetConfigResponse, error)
+	GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*GetConfigResponse, error)
+	// ShardHeartbeat is sent by shard leaders, it reports what the shard hosts and returns what it still has to create
+	ShardHeartbeat(ctx context.Context, in *ShardHeartbeatRequest, opts ...grpc.CallOption) (*ShardHeartbeatResponse, error)
+	GetControllerInfo(ctx context.Context, in *GetControllerInfoRequest, opts ...grpc.CallOption) (*GetControllerInfoResponse, error)
+	// ReassignPartition starts moving a partition to another shard, the move is driven by the controller leader
+	ReassignPartition(ctx context.Context, in *ReassignPartitionRequest, opts ...grpc.CallOption) (*ReassignPartitionResponse, error)
+	GetReassignments(ctx context.Context, in *GetReassignmentsRequest, opts ...grpc.CallOption) (*GetReassignmentsResponse, error)
+	// CancelReassignment stops a move that hasn't switched the placement yet, the copy on the target is deleted
+	CancelReassignment(ctx context.Context, in *CancelReassignmentRequest, opts ...grpc.CallOption) (*CancelReassignmentResponse, error)
+	// Balance plans partition moves that even out the load of the shards and starts them unless it is a dry run
+	Balance(ctx context.Context, in *BalanceRequest, opts ...grpc.CallOption) (*BalanceResponse, error)
+	// SplitShard starts a new raft group on the nodes of the shard and moves part of its partitions to it
+	SplitShard(ctx context.Context, in *SplitShardRequest, opts ...grpc.CallOption) (*SplitShardResponse, error)
+	// MergeShards moves every partition of the source shard to the target, then stops the source raft group
+	MergeShards(ctx context.Context, in *MergeShardsRequest, opts ...grpc.CallOption) (*MergeShardsResponse, error)
+	GetShardOperations(ctx context.Context, in *GetShardOperationsRequest, opts ...grpc.CallOption) (*GetShardOperationsResponse, error)
+}
+
+type controllerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewControllerServiceClient(cc grpc.ClientConnInterface) ControllerServiceClient {
+	return &controllerServiceClient{cc}
+}
+
+func (c *controllerServiceClient) CreateTopic(ctx context.Context, in *CreateTopicRequest, opts ...grpc.CallOption) (*CreateTopicResponse, error) {
+	out := new(CreateTopicResponse)
+	err := c.cc.Invoke(ctx, ControllerService_CreateTopic_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) GetTopics(ctx context.Context, in *GetTopicsRequest, opts ...grpc.CallOption) (*GetTopicsResponse, error) {
+	out := new(GetTopicsResponse)
+	err := c.cc.Invoke(ctx, ControllerService_GetTopics_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) CreateConsumerGroup(ctx context.Context, in *CreateConsumerGroupRequest, opts ...grpc.CallOption) (*CreateConsumerGroupResponse, error) {
+	out := new(CreateConsumerGroupResponse)
+	err := c.cc.Invoke(ctx, ControllerService_CreateConsumerGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) GetConsumerGroups(ctx context.Context, in *GetConsumerGroupsRequest, opts ...grpc.CallOption) (*GetConsumerGroupsResponse, error) {
+	out := new(GetConsumerGroupsResponse)
+	err := c.cc.Invoke(ctx, ControllerService_GetConsumerGroups_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) SetConfig(ctx context.Context, in *SetConfigRequest, opts ...grpc.CallOption) (*SetConfigResponse, error) {
+	out := new(SetConfigResponse)
+	err := c.cc.Invoke(ctx, ControllerService_SetConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*GetConfigResponse, error) {
+	out := new(GetConfigResponse)
+	err := c.cc.Invoke(ctx, ControllerService_GetConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) ShardHeartbeat(ctx context.Context, in *ShardHeartbeatRequest, opts ...grpc.CallOption) (*ShardHeartbeatResponse, error) {
+	out := new(ShardHeartbeatResponse)
+	err := c.cc.Invoke(ctx, ControllerService_ShardHeartbeat_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) GetControllerInfo(ctx context.Context, in *GetControllerInfoRequest, opts ...grpc.CallOption) (*GetControllerInfoResponse, error) {
+	out := new(GetControllerInfoResponse)
+	err := c.cc.Invoke(ctx, ControllerService_GetControllerInfo_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) ReassignPartition(ctx context.Context, in *ReassignPartitionRequest, opts ...grpc.CallOption) (*ReassignPartitionResponse, error) {
+	out := new(ReassignPartitionResponse)
+	err := c.cc.Invoke(ctx, ControllerService_ReassignPartition_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) GetReassignments(ctx context.Context, in *GetReassignmentsRequest, opts ...grpc.CallOption) (*GetReassignmentsResponse, error) {
+	out := new(GetReassignmentsResponse)
+	err := c.cc.Invoke(ctx, ControllerService_GetReassignments_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) CancelReassignment(ctx context.Context, in *CancelReassignmentRequest, opts ...grpc.CallOption) (*CancelReassignmentResponse, error) {
+	out := new(CancelReassignmentResponse)
+	err := c.cc.Invoke(ctx, ControllerService_CancelReassignment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) Balance(ctx context.Context, in *BalanceRequest, opts ...grpc.CallOption) (*BalanceResponse, error) {
+	out := new(BalanceResponse)
+	err := c.cc.Invoke(ctx, ControllerService_Balance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) SplitShard(ctx context.Context, in *SplitShardRequest, opts ...grpc.CallOption) (*SplitShardResponse, error) {
+	out := new(SplitShardResponse)
+	err := c.cc.Invoke(ctx, ControllerService_SplitShard_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) MergeShards(ctx context.Context, in *MergeShardsRequest, opts ...grpc.CallOption) (*MergeShardsResponse, error) {
+	out := new(MergeShardsResponse)
+	err := c.cc.Invoke(ctx, ControllerService_MergeShards_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) GetShardOperations(ctx context.Context, in *GetShardOperationsRequest, opts ...grpc.CallOption) (*GetShardOperationsResponse, error) {
+	out := new(GetShardOperationsResponse)
+	err := c.cc.Invoke(ctx, ControllerService_GetShardOperations_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ControllerServiceServer is the server API for ControllerService service.
+// All implementations must embed UnimplementedControllerServiceServer
+// for forward compatibility
+type ControllerServiceServer interface {
+	CreateTopic(context.Context, *CreateTopicRequest) (*CreateTopicResponse, error)
+	GetTopics(context.Context, *GetTopicsRequest) (*GetTopicsResponse, error)
+	CreateConsumerGroup(context.Context, *CreateConsumerGroupRequest) (*CreateConsumerGroupResponse, error)
+	GetConsumerGroups(context.Context, *GetConsumerGroupsRequest) (*GetConsumerGroupsResponse, error)
+	SetConfig(context.Context, *SetConfigRequest) (*SetConfigResponse, error)
+	GetConfig(context.Context, *GetConfigRequest) (*GetConfigResponse, error)
+	// ShardHeartbeat is sent by shard leaders, it reports what the shard hosts and returns what it still has to create
+	ShardHeartbeat(context.Context, *ShardHeartbeatRequest) (*ShardHeartbeatResponse, error)
+	GetControllerInfo(context.Context, *GetControllerInfoRequest) (*GetControllerInfoResponse, error)
+	// ReassignPartition starts moving a partition to another shard, the move is driven by the controller leader
+	ReassignPartition(context.Context, *ReassignPartitionRequest) (*ReassignPartitionResponse, error)
+	GetReassignments(context.Context, *GetReassignmentsRequest) (*GetReassignmentsResponse, error)
+	// CancelReassignment stops a move that hasn't switched the placement yet, the copy on the target is deleted
+	CancelReassignment(context.Context, *CancelReassignmentRequest) (*CancelReassignmentResponse, error)
+	// Balance plans partition moves that even out the load of the shards and starts them unless it is a dry run
+	Balance(context.Context, *BalanceRequest) (*BalanceResponse, error)
+	// SplitShard starts a new raft group on the nodes of the shard and moves part of its partitions to it
+	SplitShard(context.Context, *SplitShardRequest) (*SplitShardResponse, error)
+	// MergeShards moves every partition of the source shard to the target, then stops the source raft group
+	MergeShards(context.Context, *MergeShardsRequest) (*MergeShardsResponse, error)
+	GetShardOperations(context.Context, *GetShardOperationsRequest) (*GetShardOperationsResponse, error)
+	mustEmbedUnimplementedControllerServiceServer()
+}
+
+// UnimplementedControllerServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedControllerServiceServer struct {
+}
+
+func (UnimplementedControllerServiceServer) CreateTopic(context.Context, *CreateTopicRequest) (*CreateTopicResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTopic not implemented")
+}
+func (UnimplementedControllerServiceServer) GetTopics(context.Context, *GetTopicsRequest) (*GetTopicsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTopics not implemented")
+}
+func (UnimplementedControllerServiceServer) CreateConsumerGroup(context.Context, *CreateConsumerGroupRequest) (*CreateConsumerGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateConsumerGroup not implemented")
+}
+func (UnimplementedControllerServiceServer) GetConsumerGroups(context.Context, *GetConsumerGroupsRequest) (*GetConsumerGroupsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConsumerGroups not implemented")
+}
+func (UnimplementedControllerServiceServer) SetConfig(context.Context, *SetConfigRequest) (*SetConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetConfig not implemented")
+}
+func (UnimplementedControllerServiceServer) GetConfig(context.Context, *GetConfigRequest) (*GetConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConfig not implemented")
+}
+func (UnimplementedControllerServiceServer) ShardHeartbeat(context.Context, *ShardHeartbeatRequest) (*ShardHeartbeatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShardHeartbeat not implemented")
+}
+func (UnimplementedControllerServiceServer) GetControllerInfo(context.Context, *GetControllerInfoRequest) (*GetControllerInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetControllerInfo not implemented")
+}
+func (UnimplementedControllerServiceServer) ReassignPartition(context.Context, *ReassignPartitionRequest) (*ReassignPartitionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReassignPartition not implemented")
+}
+func (UnimplementedControllerServiceServer) GetReassignments(context.Context, *GetReassignmentsRequest) (*GetReassignmentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetReassignments not implemented")
+}
+func (UnimplementedControllerServiceServer) CancelReassignment(context.Context, *CancelReassignmentRequest) (*CancelReassignmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelReassignment not implemented")
+}
+func (UnimplementedControllerServiceServer) Balance(context.Context, *BalanceRequest) (*BalanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Balance not implemented")
+}
+func (UnimplementedControllerServiceServer) SplitShard(context.Context, *SplitShardRequest) (*SplitShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SplitShard not implemented")
+}
+func (UnimplementedControllerServiceServer) MergeShards(context.Context, *MergeShardsRequest) (*MergeShardsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MergeShards not implemented")
+}
+func (UnimplementedControllerServiceServer) GetShardOperations(context.Context, *GetShardOperationsRequest) (*GetShardOperationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetShardOperations not implemented")
+}
+func (UnimplementedControllerServiceServer) mustEmbedUnimplementedControllerServiceServer() {}
+
+// UnsafeControllerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ControllerServiceServer will
+// result in compilation errors.
+type UnsafeControllerServiceServer interface {
+	mustEmbedUnimplementedControllerServiceServer()
+}
+
+func RegisterControllerServiceServer(s grpc.ServiceRegistrar, srv ControllerServiceServer) {
+	s.RegisterService(&ControllerService_ServiceDesc, srv)
+}
+
+func _ControllerService_CreateTopic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTopicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).CreateTopic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_CreateTopic_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).CreateTopic(ctx, req.(*CreateTopicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_GetTopics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTopicsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).GetTopics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_GetTopics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).GetTopics(ctx, req.(*GetTopicsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_CreateConsumerGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateConsumerGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).CreateConsumerGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_CreateConsumerGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).CreateConsumerGroup(ctx, req.(*CreateConsumerGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_GetConsumerGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConsumerGroupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).GetConsumerGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_GetConsumerGroups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).GetConsumerGroups(ctx, req.(*GetConsumerGroupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_SetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).SetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_SetConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).SetConfig(ctx, req.(*SetConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_GetConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).GetConfig(ctx, req.(*GetConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_ShardHeartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShardHeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).ShardHeartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_ShardHeartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).ShardHeartbeat(ctx, req.(*ShardHeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_GetControllerInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetControllerInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).GetControllerInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_GetControllerInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).GetControllerInfo(ctx, req.(*GetControllerInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_ReassignPartition_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReassignPartitionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).ReassignPartition(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_ReassignPartition_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).ReassignPartition(ctx, req.(*ReassignPartitionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_GetReassignments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetReassignmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).GetReassignments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_GetReassignments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).GetReassignments(ctx, req.(*GetReassignmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_CancelReassignment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelReassignmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).CancelReassignment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_CancelReassignment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).CancelReassignment(ctx, req.(*CancelReassignmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_Balance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).Balance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_Balance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).Balance(ctx, req.(*BalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_SplitShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SplitShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).SplitShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_SplitShard_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).SplitShard(ctx, req.(*SplitShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_MergeShards_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MergeShardsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).MergeShards(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_MergeShards_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).MergeShards(ctx, req.(*MergeShardsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_GetShardOperations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetShardOperationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).GetShardOperations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_GetShardOperations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).GetShardOperations(ctx, req.(*GetShardOperationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ControllerService_ServiceDesc is the grpc.ServiceDesc for ControllerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ControllerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "controller.ControllerService",
+	HandlerType: (*ControllerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateTopic",
+			Handler:    _ControllerService_CreateTopic_Handler,
+		},
+		{
+			MethodName: "GetTopics",
+			Handler:    _ControllerService_GetTopics_Handler,
+		},
+		{
+			MethodName: "CreateConsumerGroup",
+			Handler:    _ControllerService_CreateConsumerGroup_Handler,
+		},
+		{
+			MethodName: "GetConsumerGroups",
+			Handler:    _ControllerService_GetConsumerGroups_Handler,
+		},
+		{
+			MethodName: "SetConfig",
+			Handler:    _ControllerService_SetConfig_Handler,
+		},
+		{
+			MethodName: "GetConfig",
+			Handler:    _ControllerService_GetConfig_Handler,
+		},
+		{
+			MethodName: "ShardHeartbeat",
+			Handler:    _ControllerService_ShardHeartbeat_Handler,
+		},
+		{
+			MethodName: "GetControllerInfo",
+			Handler:    _ControllerService_GetControllerInfo_Handler,
+		},
+		{
+			MethodName: "ReassignPartition",
+			Handler:    _ControllerService_ReassignPartition_Handler,
+		},
+		{
+			MethodName: "GetReassignments",
+			Handler:    _ControllerService_GetReassignments_Handler,
+		},
+		{
+			MethodName: "CancelReassignment",
+			Handler:    _ControllerService_CancelReassignment_Handler,
+		},
+		{
+			MethodName: "Balance",
+			Handler:    _ControllerService_Balance_Handler,
+		},
+		{
+			MethodName: "SplitShard",
+			Handler:    _ControllerService_SplitShard_Handler,
+		},
+		{
+			MethodName: "MergeShards",
+			Handler:    _ControllerService_MergeShards_Handler,
+		},
+		{
+			MethodName: "GetShardOperations",
+			Handler:    _ControllerService_GetShardOperations_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "controller.proto",
+}
@@ -0,0 +1,330 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	appPb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/config"
+	pb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	reassignInterval = 500 * time.Millisecond
+	// reassignBatch is the number of messages copied per export
+	reassignBatch = 500
+	// catchUpLag is how far the copy may be behind the source when the source gets fenced, it bounds how long
+	// writes to the partition are rejected
+	catchUpLag   = 2 * reassignBatch
+	shardTimeout = 5 * time.Second
+)
+
+// ReassignPartition records the move, RunReassignments does the copying on the leader
+func (r RpcInterface) ReassignPartition(ctx context.Context, req *pb.ReassignPartitionRequest) (*pb.ReassignPartitionResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, appPb.AclResource_CLUSTER, "", appPb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	if req.GetTopic() == "" || req.GetTargetShard() == "" {
+		return nil, status.Error(codes.InvalidArgument, "reassignment needs a topic and a target shard")
+	}
+	var source string
+	r.State.read(func(state *pb.ControllerState) {
+		if partition := state.Topics[req.GetTopic()].GetPartitions()[req.GetPartition()]; partition != nil {
+			source = partition.ShardId
+		}
+	})
+	now := time.Now().UnixMilli()
+	res, err := apply[*pb.Reassignment](r, &pb.WriteOperation{
+		Code: pb.Operation_START_REASSIGNMENT,
+		Operation: &pb.WriteOperation_StartReassignment{StartReassignment: &pb.Reassignment{
+			Id:          fmt.Sprintf("%s-%d-%d", req.GetTopic(), req.GetPartition(), time.Now().UnixNano()),
+			Topic:       req.GetTopic(),
+			Partition:   req.GetPartition(),
+			SourceShard: source,
+			TargetShard: req.GetTargetShard(),
+			Phase:       pb.ReassignmentPhase_COPYING,
+			StartedAt:   now,
+			UpdatedAt:   now,
+		}},
+	})
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	return &pb.ReassignPartitionResponse{Reassignment: res}, nil
+}
+
+func (r RpcInterface) GetReassignments(_ context.Context, req *pb.GetReassignmentsRequest) (*pb.GetReassignmentsResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	res := &pb.GetReassignmentsResponse{}
+	r.State.read(func(state *pb.ControllerState) {
+		for id, item := range state.Reassignments {
+			if req.GetId() == "" || req.GetId() == id {
+				res.Reassignments = append(res.Reassignments, proto.Clone(item).(*pb.Reassignment))
+			}
+		}
+	})
+	if req.GetId() != "" && len(res.Reassignments) == 0 {
+		return nil, r.toStatusError(ErrReassignmentNotFound)
+	}
+	sort.Slice(res.Reassignments, func(i, j int) bool {
+		return res.Reassignments[i].StartedAt < res.Reassignments[j].StartedAt
+	})
+	return res, nil
+}
+
+func (r RpcInterface) CancelReassignment(ctx context.Context, req *pb.CancelReassignmentRequest) (*pb.CancelReassignmentResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, appPb.AclResource_CLUSTER, "", appPb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	res, err := apply[*pb.Reassignment](r, &pb.WriteOperation{
+		Code:      pb.Operation_CANCEL_REASSIGNMENT,
+		Operation: &pb.WriteOperation_CancelReassignment{CancelReassignment: req},
+	})
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	return &pb.CancelReassignmentResponse{Reassignment: res}, nil
+}
+
+// RunReassignments moves the partitions while this controller is the leader, runs until stop is closed. Every step
+// is recorded through raft so a new leader continues where the old one stopped
+func RunReassignments(r RpcInterface, stop <-chan struct{}) {
+	shards := newShardClients(r.DialOptions)
+	defer shards.close()
+	ticker := time.NewTicker(reassignInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if r.Raft.State() != raft.Leader {
+			continue
+		}
+		var active []*pb.Reassignment
+		r.State.read(func(state *pb.ControllerState) {
+			for _, item := range state.Reassignments {
+				if isActive(item) {
+					active = append(active, proto.Clone(item).(*pb.Reassignment))
+				}
+			}
+		})
+		for _, item := range active {
+			if err := r.stepReassignment(shards, item); err != nil {
+				r.State.Logger.Warn().Err(err).Msgf("Error moving partition %v of topic %s", item.Partition, item.Topic)
+			}
+		}
+	}
+}
+
+// stepReassignment advances the move by at most one phase, errors are recorded on the move and retried next time
+func (r RpcInterface) stepReassignment(shards *shardClients, item *pb.Reassignment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), shardTimeout)
+	defer cancel()
+	next := proto.Clone(item).(*pb.Reassignment)
+	next.Error = ""
+	var err error
+	switch {
+	case item.CancelRequested && item.Phase < pb.ReassignmentPhase_SWITCHED:
+		err = r.cancelMove(ctx, shards, next)
+	case item.Phase == pb.ReassignmentPhase_COPYING:
+		err = r.copyBatch(ctx, shards, next, false)
+		if err == nil && next.CopiedOffset+catchUpLag >= next.SourceOffset {
+			err = r.fence(ctx, shards, next.SourceShard, next, true)
+			next.Phase = pb.ReassignmentPhase_FENCED
+		}
+	case item.Phase == pb.ReassignmentPhase_FENCED:
+		//nothing is written to the source anymore, copy the tail together with the consumer offsets
+		err = r.copyBatch(ctx, shards, next, true)
+		for err == nil && next.CopiedOffset < next.SourceOffset {
+			err = r.copyBatch(ctx, shards, next, true)
+		}
+		next.Phase = pb.ReassignmentPhase_SWITCHED
+	case item.Phase == pb.ReassignmentPhase_SWITCHED:
+		err = r.fence(ctx, shards, next.TargetShard, next, false)
+		if err == nil {
+			err = r.deletePartition(ctx, shards, next.SourceShard, next)
+		}
+		next.Phase = pb.ReassignmentPhase_DONE
+	}
+	if err != nil {
+		next = proto.Clone(item).(*pb.Reassignment)
+		next.Error = err.Error()
+	}
+	if proto.Equal(next, item) {
+		return err
+	}
+	next.UpdatedAt = time.Now().UnixMilli()
+	if _, applyErr := apply[*pb.Reassignment](r, &pb.WriteOperation{
+		Code:      pb.Operation_UPDATE_REASSIGNMENT,
+		Operation: &pb.WriteOperation_UpdateReassignment{UpdateReassignment: next},
+	}); applyErr != nil {
+		return applyErr
+	}
+	return err
+}
+
+// copyBatch copies the next messages after the copied offset from the source to the target
+func (r RpcInterface) copyBatch(ctx context.Context, shards *shardClients, next *pb.Reassignment, withOffsets bool) error {
+	source, err := shards.get(r.State, next.SourceShard)
+	if err != nil {
+		return err
+	}
+	target, err := shards.get(r.State, next.TargetShard)
+	if err != nil {
+		return err
+	}
+	exported, err := source.ExportPartition(ctx, &appPb.ExportPartitionRequest{
+		Topic:      next.Topic,
+		Partition:  next.Partition,
+		FromOffset: next.CopiedOffset,
+		Limit:      reassignBatch,
+	})
+	if err != nil {
+		return err
+	}
+	req := &appPb.ImportPartitionRequest{
+		Topic:     next.Topic,
+		Partition: next.Partition,
+		Messages:  exported.Messages,
+	}
+	if withOffsets {
+		req.ConsumerOffsets = exported.ConsumerOffsets
+	}
+	if _, err := target.ImportPartition(ctx, req); err != nil {
+		return err
+	}
+	if n := len(exported.Messages); n > 0 {
+		next.CopiedOffset = exported.Messages[n-1].Offset
+	}
+	next.SourceOffset = exported.LastOffset
+	return nil
+}
+
+func (r RpcInterface) fence(ctx context.Context, shards *shardClients, shardId string, next *pb.Reassignment, fenced bool) error {
+	client, err := shards.get(r.State, shardId)
+	if err != nil {
+		return err
+	}
+	_, err = client.FencePartition(ctx, &appPb.FencePartitionRequest{
+		Topic:     next.Topic,
+		Partition: next.Partition,
+		Fenced:    fenced,
+	})
+	return err
+}
+
+func (r RpcInterface) deletePartition(ctx context.Context, shards *shardClients, shardId string, next *pb.Reassignment) error {
+	client, err := shards.get(r.State, shardId)
+	if err != nil {
+		return err
+	}
+	_, err = client.DeletePartition(ctx, &appPb.DeletePartitionRequest{
+		Topic:     next.Topic,
+		Partition: next.Partition,
+	})
+	return err
+}
+
+// cancelMove lets the source take writes again and drops the copy on the target
+func (r RpcInterface) cancelMove(ctx context.Context, shards *shardClients, next *pb.Reassignment) error {
+	if next.Phase == pb.ReassignmentPhase_FENCED {
+		if err := r.fence(ctx, shards, next.SourceShard, next, false); err != nil {
+			return err
+		}
+	}
+	if err := r.deletePartition(ctx, shards, next.TargetShard, next); err != nil {
+		return err
+	}
+	next.Phase = pb.ReassignmentPhase_CANCELLED
+	return nil
+}
+
+// shardClients keeps connections to the shard leaders known from the heartbeats
+type shardClients struct {
+	conns       map[string]*grpc.ClientConn
+	dialOptions []grpc.DialOption
+	mutex       sync.Mutex
+}
+
+func newShardClients(dialOptions []grpc.DialOption) *shardClients {
+	return &shardClients{conns: map[string]*grpc.ClientConn{}, dialOptions: dialOptions}
+}
+
+func (s *shardClients) get(state *State, shardId string) (appPb.MessageServiceClient, error) {
+	var address string
+	state.read(func(state *pb.ControllerState) {
+		record := state.Shards[shardId]
+		if record == nil {
+			return
+		}
+		//followers forward to the leader, so any member works if the leader is unknown
+		address = record.Members[record.LeaderId]
+		for _, member := range record.Members {
+			if address == "" {
+				address = member
+			}
+		}
+	})
+	if address == "" {
+		return nil, fmt.Errorf("no known address for shard %s", shardId)
+	}
+	conn, err := s.conn(address)
+	if err != nil {
+		return nil, err
+	}
+	return appPb.NewMessageServiceClient(util.ShardConn(conn, shardId)), nil
+}
+
+// node is the client of the node service at the address, it starts and stops the shards of the node
+func (s *shardClients) node(address string) (clusterPb.NodeServiceClient, error) {
+	conn, err := s.conn(address)
+	if err != nil {
+		return nil, err
+	}
+	return clusterPb.NewNodeServiceClient(conn), nil
+}
+
+func (s *shardClients) conn(address string) (*grpc.ClientConn, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	conn := s.conns[address]
+	if conn == nil {
+		maxSize := config.DefaultMaxMessageSize
+		var err error
+		conn, err = grpc.Dial(address, append([]grpc.DialOption{grpc.WithInsecure(),
+			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxSize), grpc.MaxCallSendMsgSize(maxSize))}, s.dialOptions...)...)
+		if err != nil {
+			return nil, err
+		}
+		s.conns[address] = conn
+	}
+	return conn, nil
+}
+
+func (s *shardClients) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for address, conn := range s.conns {
+		_ = conn.Close()
+		delete(s.conns, address)
+	}
+}
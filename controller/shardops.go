@@ -0,0 +1,354 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	appPb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	pb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/hashicorp/raft"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SplitShard records the split, RunShardOperations starts the new shard and moves the partitions on the leader
+func (r RpcInterface) SplitShard(ctx context.Context, req *pb.SplitShardRequest) (*pb.SplitShardResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, appPb.AclResource_CLUSTER, "", appPb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	if req.GetShardId() == "" || req.GetNewShardId() == "" || req.GetShardId() == req.GetNewShardId() {
+		return nil, status.Error(codes.InvalidArgument, "split needs the shard and a different id for the new shard")
+	}
+	partitions := req.GetPartitions()
+	var members map[string]string
+	r.State.read(func(state *pb.ControllerState) {
+		members = state.Shards[req.GetShardId()].GetMembers()
+		if len(partitions) == 0 {
+			partitions = splitPartitions(state, req.GetShardId())
+		}
+	})
+	if len(partitions) == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "shard %s has no partitions to split", req.GetShardId())
+	}
+	res, err := r.startShardOperation(&pb.ShardOperation{
+		Type:        pb.ShardOperationType_SPLIT,
+		SourceShard: req.GetShardId(),
+		TargetShard: req.GetNewShardId(),
+		Phase:       pb.ShardOperationPhase_STARTING,
+		Partitions:  partitions,
+		Members:     members,
+	})
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	return &pb.SplitShardResponse{Operation: res}, nil
+}
+
+// MergeShards records the merge, the partitions of the source are moved to the target before the source is stopped
+func (r RpcInterface) MergeShards(ctx context.Context, req *pb.MergeShardsRequest) (*pb.MergeShardsResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, appPb.AclResource_CLUSTER, "", appPb.AclOperation_ADMIN); err != nil {
+		return nil, err
+	}
+	if req.GetSourceShard() == "" || req.GetTargetShard() == "" || req.GetSourceShard() == req.GetTargetShard() {
+		return nil, status.Error(codes.InvalidArgument, "merge needs two different shards")
+	}
+	partitions := map[string]*pb.PartitionList{}
+	var members map[string]string
+	r.State.read(func(state *pb.ControllerState) {
+		members = state.Shards[req.GetSourceShard()].GetMembers()
+		for name, topic := range state.Topics {
+			for num, partition := range topic.Partitions {
+				if partition.ShardId == req.GetSourceShard() {
+					appendPartition(partitions, name, num)
+				}
+			}
+		}
+	})
+	res, err := r.startShardOperation(&pb.ShardOperation{
+		Type:        pb.ShardOperationType_MERGE,
+		SourceShard: req.GetSourceShard(),
+		TargetShard: req.GetTargetShard(),
+		Phase:       pb.ShardOperationPhase_MOVING,
+		Partitions:  partitions,
+		Members:     members,
+	})
+	if err != nil {
+		return nil, r.toStatusError(err)
+	}
+	return &pb.MergeShardsResponse{Operation: res}, nil
+}
+
+func (r RpcInterface) GetShardOperations(_ context.Context, req *pb.GetShardOperationsRequest) (*pb.GetShardOperationsResponse, error) {
+	if err := r.checkLeader(); err != nil {
+		return nil, err
+	}
+	res := &pb.GetShardOperationsResponse{}
+	r.State.read(func(state *pb.ControllerState) {
+		for id, item := range state.ShardOperations {
+			if req.GetId() == "" || req.GetId() == id {
+				res.Operations = append(res.Operations, proto.Clone(item).(*pb.ShardOperation))
+			}
+		}
+	})
+	if req.GetId() != "" && len(res.Operations) == 0 {
+		return nil, r.toStatusError(ErrShardOperationNotFound)
+	}
+	sort.Slice(res.Operations, func(i, j int) bool {
+		return res.Operations[i].StartedAt < res.Operations[j].StartedAt
+	})
+	return res, nil
+}
+
+func (r RpcInterface) startShardOperation(operation *pb.ShardOperation) (*pb.ShardOperation, error) {
+	now := time.Now().UnixMilli()
+	operation.Id = fmt.Sprintf("%s-%s-%s-%d", strings.ToLower(operation.Type.String()), operation.SourceShard,
+		operation.TargetShard, time.Now().UnixNano())
+	operation.StartedAt = now
+	operation.UpdatedAt = now
+	return apply[*pb.ShardOperation](r, &pb.WriteOperation{
+		Code:      pb.Operation_START_SHARD_OPERATION,
+		Operation: &pb.WriteOperation_StartShardOperation{StartShardOperation: operation},
+	})
+}
+
+// splitPartitions picks every other partition of the shard in topic and partition order
+func splitPartitions(state *pb.ControllerState, shardId string) map[string]*pb.PartitionList {
+	var names []string
+	for name := range state.Topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := map[string]*pb.PartitionList{}
+	i := 0
+	for _, name := range names {
+		var nums []uint64
+		for num, partition := range state.Topics[name].Partitions {
+			if partition.ShardId == shardId {
+				nums = append(nums, num)
+			}
+		}
+		sort.Slice(nums, func(a, b int) bool {
+			return nums[a] < nums[b]
+		})
+		for _, num := range nums {
+			if i%2 == 1 {
+				appendPartition(res, name, num)
+			}
+			i++
+		}
+	}
+	return res
+}
+
+// RunShardOperations drives the splits and merges while this controller is the leader, runs until stop is closed.
+// Like the reassignments every step is recorded through raft
+func RunShardOperations(r RpcInterface, stop <-chan struct{}) {
+	shards := newShardClients(r.DialOptions)
+	defer shards.close()
+	ticker := time.NewTicker(reassignInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if r.Raft.State() != raft.Leader {
+			continue
+		}
+		var active []*pb.ShardOperation
+		r.State.read(func(state *pb.ControllerState) {
+			for _, item := range state.ShardOperations {
+				if isOperationActive(item) {
+					active = append(active, proto.Clone(item).(*pb.ShardOperation))
+				}
+			}
+		})
+		for _, item := range active {
+			if err := r.stepShardOperation(shards, item); err != nil {
+				r.State.Logger.Warn().Err(err).Msgf("Error in %s of shard %s and %s", item.Type, item.SourceShard, item.TargetShard)
+			}
+		}
+	}
+}
+
+// stepShardOperation advances the operation by at most one phase, errors are recorded on it and retried next time
+func (r RpcInterface) stepShardOperation(shards *shardClients, item *pb.ShardOperation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), shardTimeout)
+	defer cancel()
+	next := proto.Clone(item).(*pb.ShardOperation)
+	next.Error = ""
+	var err error
+	switch item.Phase {
+	case pb.ShardOperationPhase_STARTING:
+		err = r.hostShard(ctx, shards, next)
+	case pb.ShardOperationPhase_MOVING:
+		err = r.movePartitions(next)
+	case pb.ShardOperationPhase_DROPPING:
+		err = r.dropShard(ctx, shards, next)
+	}
+	if err != nil {
+		next = proto.Clone(item).(*pb.ShardOperation)
+		next.Error = err.Error()
+	}
+	if proto.Equal(next, item) {
+		return err
+	}
+	next.UpdatedAt = time.Now().UnixMilli()
+	if _, applyErr := apply[*pb.ShardOperation](r, &pb.WriteOperation{
+		Code:      pb.Operation_UPDATE_SHARD_OPERATION,
+		Operation: &pb.WriteOperation_UpdateShardOperation{UpdateShardOperation: next},
+	}); applyErr != nil {
+		return applyErr
+	}
+	return err
+}
+
+// hostShard starts the new shard on every node of the source with the same members, so each node bootstraps the
+// same configuration. The partitions are moved once the new shard elected a leader and sent its heartbeat
+func (r RpcInterface) hostShard(ctx context.Context, shards *shardClients, next *pb.ShardOperation) error {
+	for nodeId, address := range next.Members {
+		client, err := shards.node(address)
+		if err != nil {
+			return err
+		}
+		if _, err := client.HostShard(ctx, &clusterPb.HostShardRequest{
+			ShardId: next.TargetShard,
+			Members: next.Members,
+		}); err != nil {
+			return fmt.Errorf("starting shard %s on node %s: %w", next.TargetShard, nodeId, err)
+		}
+	}
+	r.State.read(func(state *pb.ControllerState) {
+		if state.Shards[next.TargetShard].GetLeaderId() != "" {
+			next.Phase = pb.ShardOperationPhase_MOVING
+		}
+	})
+	return nil
+}
+
+// movePartitions starts a reassignment for every partition of the operation still on the source, partitions moved by
+// someone else are waited for
+func (r RpcInterface) movePartitions(next *pb.ShardOperation) error {
+	var pending []*pb.Reassignment
+	var running, cancelled bool
+	r.State.read(func(state *pb.ControllerState) {
+		for _, id := range next.ReassignmentIds {
+			item := state.Reassignments[id]
+			if item.GetPhase() == pb.ReassignmentPhase_CANCELLED {
+				cancelled = true
+			}
+		}
+		moving := map[string]bool{}
+		for _, item := range state.Reassignments {
+			if isActive(item) {
+				moving[partitionKey(item.Topic, item.Partition)] = true
+			}
+		}
+		for name, topic := range state.Topics {
+			for num, partition := range topic.Partitions {
+				if partition.ShardId != next.SourceShard || !operationPartition(next, name, num) {
+					continue
+				}
+				if moving[partitionKey(name, num)] {
+					running = true
+					continue
+				}
+				pending = append(pending, &pb.Reassignment{Topic: name, Partition: num})
+			}
+		}
+	})
+	if cancelled {
+		next.Phase = pb.ShardOperationPhase_FAILED
+		return nil
+	}
+	for _, item := range pending {
+		res, err := r.ReassignPartition(context.Background(), &pb.ReassignPartitionRequest{
+			Topic:       item.Topic,
+			Partition:   item.Partition,
+			TargetShard: next.TargetShard,
+		})
+		if err != nil {
+			return err
+		}
+		next.ReassignmentIds = append(next.ReassignmentIds, res.Reassignment.Id)
+		running = true
+	}
+	if running {
+		return nil
+	}
+	if next.Type == pb.ShardOperationType_MERGE {
+		next.Phase = pb.ShardOperationPhase_DROPPING
+	} else {
+		next.Phase = pb.ShardOperationPhase_COMPLETED
+	}
+	return nil
+}
+
+// operationPartition is true if the operation moves the partition, a merge moves everything on the source
+func operationPartition(operation *pb.ShardOperation, name string, num uint64) bool {
+	if operation.Type == pb.ShardOperationType_MERGE {
+		return true
+	}
+	for _, item := range operation.Partitions[name].GetPartitions() {
+		if item == num {
+			return true
+		}
+	}
+	return false
+}
+
+// dropShard stops the merged shard on its nodes and forgets it, partitions that landed on it since go back to moving
+func (r RpcInterface) dropShard(ctx context.Context, shards *shardClients, next *pb.ShardOperation) error {
+	var empty bool
+	r.State.read(func(state *pb.ControllerState) {
+		empty = true
+		for _, topic := range state.Topics {
+			if topicShards(topic)[next.SourceShard] {
+				empty = false
+			}
+		}
+	})
+	if !empty {
+		next.Phase = pb.ShardOperationPhase_MOVING
+		return nil
+	}
+	for nodeId, address := range next.Members {
+		client, err := shards.node(address)
+		if err != nil {
+			return err
+		}
+		if _, err := client.DropShard(ctx, &clusterPb.DropShardRequest{ShardId: next.SourceShard}); err != nil {
+			return fmt.Errorf("stopping shard %s on node %s: %w", next.SourceShard, nodeId, err)
+		}
+	}
+	if _, err := apply[*pb.RemoveShard](r, &pb.WriteOperation{
+		Code:      pb.Operation_REMOVE_SHARD,
+		Operation: &pb.WriteOperation_RemoveShard{RemoveShard: &pb.RemoveShard{ShardId: next.SourceShard}},
+	}); err != nil {
+		return err
+	}
+	next.Phase = pb.ShardOperationPhase_COMPLETED
+	return nil
+}
+
+// drainingShards are the sources of the active merges, nothing new is placed on them
+func drainingShards(state *pb.ControllerState) map[string]bool {
+	res := map[string]bool{}
+	for _, item := range state.ShardOperations {
+		if item.Type == pb.ShardOperationType_MERGE && isOperationActive(item) {
+			res[item.SourceShard] = true
+		}
+	}
+	return res
+}
@@ -0,0 +1,98 @@
+package test
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type BalancerTest struct {
+	suite.Suite
+	client  pb.ControllerServiceClient
+	address string
+	servers chan *factory.Server
+}
+
+func (suite *BalancerTest) SetupSuite() {
+	suite.address = "localhost:8101"
+	suite.servers = make(chan *factory.Server, 5)
+	log.Print("Starting the controller")
+	go factory.SetupController(&factory.ControllerConfig{
+		HostAddr:  suite.address,
+		GlobalAdr: suite.address,
+		NodeName:  "controllerB",
+		InMemory:  true,
+		Server:    suite.servers,
+	})
+	time.Sleep(5 * time.Second)
+	conn, err := grpc.Dial(suite.address, grpc.WithInsecure())
+	assert.Nil(suite.T(), err)
+	suite.client = pb.NewControllerServiceClient(conn)
+}
+
+func (suite *BalancerTest) TearDownSuite() {
+	server := <-suite.servers
+	server.Kill()
+}
+
+func (suite *BalancerTest) heartbeat(shardId string, topics map[string]*pb.PartitionList, load []*pb.PartitionLoad) {
+	_, err := suite.client.ShardHeartbeat(context.Background(), &pb.ShardHeartbeatRequest{
+		Shard:  &pb.ShardRecord{ShardId: shardId, LeaderId: "node" + shardId, Members: map[string]string{}},
+		Topics: topics,
+		Load:   load,
+	})
+	assert.Nil(suite.T(), err)
+}
+
+// a shard that joins after the topic was placed gets half of the partitions, one move at a time
+func (suite *BalancerTest) TestBalanceNewShard() {
+	const TOPIC = "TestBalanceNewShard"
+	suite.heartbeat("shardA", nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err := suite.client.CreateTopic(ctx, &pb.CreateTopicRequest{Topic: TOPIC, Partitions: 4})
+	assert.Equal(suite.T(), codes.DeadlineExceeded, status.Code(err))
+	var load []*pb.PartitionLoad
+	for num := uint64(0); num < 4; num++ {
+		load = append(load, &pb.PartitionLoad{Topic: TOPIC, Partition: num, Bytes: 1000, LastOffset: 10})
+	}
+	suite.heartbeat("shardA", map[string]*pb.PartitionList{TOPIC: {Partitions: []uint64{0, 1, 2, 3}}}, load)
+	suite.heartbeat("shardB", nil, nil)
+
+	plan, err := suite.client.Balance(context.Background(), &pb.BalanceRequest{DryRun: true})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, len(plan.Moves))
+	for _, move := range plan.Moves {
+		assert.Equal(suite.T(), "shardA", move.SourceShard)
+		assert.Equal(suite.T(), "shardB", move.TargetShard)
+		assert.Equal(suite.T(), "", move.ReassignmentId)
+	}
+	for _, shard := range plan.After {
+		assert.Equal(suite.T(), uint64(2), shard.Partitions)
+	}
+	moves, err := suite.client.GetReassignments(context.Background(), &pb.GetReassignmentsRequest{})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, len(moves.Reassignments))
+
+	//only one move runs at a time by default
+	plan, err = suite.client.Balance(context.Background(), &pb.BalanceRequest{})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, len(plan.Moves))
+	assert.NotEqual(suite.T(), "", plan.Moves[0].ReassignmentId)
+	assert.Equal(suite.T(), "", plan.Moves[1].ReassignmentId)
+	moves, err = suite.client.GetReassignments(context.Background(), &pb.GetReassignmentsRequest{})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, len(moves.Reassignments))
+}
+
+func TestBalancer(t *testing.T) {
+	suite.Run(t, new(BalancerTest))
+}
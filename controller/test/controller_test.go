@@ -0,0 +1,136 @@
+package test
+
+import (
+	"context"
+	pb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type ControllerTest struct {
+	suite.Suite
+	client  pb.ControllerServiceClient
+	address string
+	servers chan *factory.Server
+}
+
+func (suite *ControllerTest) SetupSuite() {
+	suite.address = "localhost:8100"
+	suite.servers = make(chan *factory.Server, 5)
+	log.Print("Starting the controller")
+	go factory.SetupController(&factory.ControllerConfig{
+		HostAddr:  suite.address,
+		GlobalAdr: suite.address,
+		NodeName:  "controllerA",
+		InMemory:  true,
+		Server:    suite.servers,
+	})
+	time.Sleep(5 * time.Second)
+	conn, err := grpc.Dial(suite.address, grpc.WithInsecure())
+	assert.Nil(suite.T(), err)
+	suite.client = pb.NewControllerServiceClient(conn)
+}
+
+func (suite *ControllerTest) TearDownSuite() {
+	server := <-suite.servers
+	server.Kill()
+}
+
+func (suite *ControllerTest) TestGetControllerInfo() {
+	res, err := suite.client.GetControllerInfo(context.Background(), &pb.GetControllerInfoRequest{})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "controllerA", res.LeaderId)
+	assert.Equal(suite.T(), suite.address, res.LeaderAddress)
+}
+
+// a topic stays pending until every shard it is placed on reports the partitions
+func (suite *ControllerTest) TestCreateTopicWaitsForShards() {
+	const TOPIC = "TestCreateTopicWaitsForShards"
+	for _, shardId := range []string{"shardA", "shardB"} {
+		_, err := suite.client.ShardHeartbeat(context.Background(), &pb.ShardHeartbeatRequest{
+			Shard: &pb.ShardRecord{ShardId: shardId, LeaderId: "node" + shardId, Members: map[string]string{}},
+		})
+		assert.Nil(suite.T(), err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err := suite.client.CreateTopic(ctx, &pb.CreateTopicRequest{Topic: TOPIC, Partitions: 4})
+	assert.Equal(suite.T(), codes.DeadlineExceeded, status.Code(err))
+	topics, err := suite.client.GetTopics(context.Background(), &pb.GetTopicsRequest{})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), pb.TopicState_PENDING, topics.Topics[TOPIC].State)
+	assert.Equal(suite.T(), 4, len(topics.Topics[TOPIC].Partitions))
+
+	//a retry waits for the placed topic, another partition count is a different topic
+	retry, cancelRetry := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancelRetry()
+	_, err = suite.client.CreateTopic(retry, &pb.CreateTopicRequest{Topic: TOPIC, Partitions: 4})
+	assert.Equal(suite.T(), codes.DeadlineExceeded, status.Code(err))
+	_, err = suite.client.CreateTopic(context.Background(), &pb.CreateTopicRequest{Topic: TOPIC, Partitions: 2})
+	assert.Equal(suite.T(), codes.AlreadyExists, status.Code(err))
+
+	for _, shardId := range []string{"shardA", "shardB"} {
+		heartbeat := &pb.ShardHeartbeatRequest{
+			Shard: &pb.ShardRecord{ShardId: shardId, LeaderId: "node" + shardId, Members: map[string]string{}},
+		}
+		assignment, err := suite.client.ShardHeartbeat(context.Background(), heartbeat)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), 2, len(assignment.Topics[TOPIC].GetPartitions()))
+		//report the assigned partitions as created
+		heartbeat.Topics = assignment.Topics
+		assignment, err = suite.client.ShardHeartbeat(context.Background(), heartbeat)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), 0, len(assignment.Topics))
+	}
+	topics, err = suite.client.GetTopics(context.Background(), &pb.GetTopicsRequest{})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), pb.TopicState_READY, topics.Topics[TOPIC].State)
+	_, err = suite.client.CreateTopic(context.Background(), &pb.CreateTopicRequest{Topic: TOPIC, Partitions: 4})
+	assert.Equal(suite.T(), codes.AlreadyExists, status.Code(err))
+}
+
+func (suite *ControllerTest) TestConfig() {
+	_, err := suite.client.SetConfig(context.Background(), &pb.SetConfigRequest{Key: "retention", Value: "1h"})
+	assert.Nil(suite.T(), err)
+	res, err := suite.client.GetConfig(context.Background(), &pb.GetConfigRequest{})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "1h", res.Config["retention"])
+}
+
+// moves are only accepted for placed partitions going to another registered shard
+func (suite *ControllerTest) TestReassignPartitionValidation() {
+	const TOPIC = "TestReassignPartitionValidation"
+	_, err := suite.client.ShardHeartbeat(context.Background(), &pb.ShardHeartbeatRequest{
+		Shard: &pb.ShardRecord{ShardId: "shardA", LeaderId: "nodeshardA", Members: map[string]string{}},
+	})
+	assert.Nil(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err = suite.client.CreateTopic(ctx, &pb.CreateTopicRequest{Topic: TOPIC, Partitions: 1})
+	assert.Equal(suite.T(), codes.DeadlineExceeded, status.Code(err))
+	topics, err := suite.client.GetTopics(context.Background(), &pb.GetTopicsRequest{})
+	assert.Nil(suite.T(), err)
+	source := topics.Topics[TOPIC].Partitions[0].ShardId
+
+	_, err = suite.client.ReassignPartition(context.Background(), &pb.ReassignPartitionRequest{Topic: "missing", TargetShard: "shardA"})
+	assert.Equal(suite.T(), codes.NotFound, status.Code(err))
+	_, err = suite.client.ReassignPartition(context.Background(), &pb.ReassignPartitionRequest{Topic: TOPIC, Partition: 5, TargetShard: "shardA"})
+	assert.Equal(suite.T(), codes.NotFound, status.Code(err))
+	_, err = suite.client.ReassignPartition(context.Background(), &pb.ReassignPartitionRequest{Topic: TOPIC, TargetShard: "missing"})
+	assert.Equal(suite.T(), codes.NotFound, status.Code(err))
+	_, err = suite.client.ReassignPartition(context.Background(), &pb.ReassignPartitionRequest{Topic: TOPIC, TargetShard: source})
+	assert.Equal(suite.T(), codes.FailedPrecondition, status.Code(err))
+	_, err = suite.client.CancelReassignment(context.Background(), &pb.CancelReassignmentRequest{Id: "missing"})
+	assert.Equal(suite.T(), codes.NotFound, status.Code(err))
+}
+
+func TestController(t *testing.T) {
+	suite.Run(t, new(ControllerTest))
+}
@@ -2,17 +2,69 @@ package factory
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"github.com/Kapperchino/jet-stream/util"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/hashicorp/go-msgpack/codec"
 	"github.com/hashicorp/raft"
+	"math"
 )
 
+// logKeyPrefix versions the keys of the log entries, the index follows big endian so badger keeps them in index order.
+// The first keys were the little endian index alone
+var logKeyPrefix = []byte("log/v2/")
+
 type BadgerLogStore struct {
 	LogStore *badger.DB
 }
 
+// NewBadgerLogStore returns the log store kept in the db, the entries stored under the old keys are moved first
+func NewBadgerLogStore(db *badger.DB) (BadgerLogStore, error) {
+	b := BadgerLogStore{LogStore: db}
+	return b, b.migrateKeys()
+}
+
+func logKey(index uint64) []byte {
+	key := make([]byte, len(logKeyPrefix)+8)
+	copy(key, logKeyPrefix)
+	binary.BigEndian.PutUint64(key[len(logKeyPrefix):], index)
+	return key
+}
+
+// migrateKeys moves the entries stored under a little endian index to their versioned key
+func (b BadgerLogStore) migrateKeys() error {
+	batch := b.LogStore.NewWriteBatch()
+	defer batch.Cancel()
+	moved := 0
+	err := b.LogStore.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if len(item.Key()) != 8 {
+				continue
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := batch.Set(logKey(util.BytesToULong(item.Key())), val); err != nil {
+				return err
+			}
+			if err := batch.Delete(item.KeyCopy(nil)); err != nil {
+				return err
+			}
+			moved++
+		}
+		return nil
+	})
+	if err != nil || moved == 0 {
+		return err
+	}
+	return batch.Flush()
+}
+
 func (b BadgerLogStore) Set(key []byte, val []byte) error {
 	err := b.LogStore.Update(func(txn *badger.Txn) error {
 		err := txn.Set(key, val)
@@ -68,49 +120,42 @@ func (b BadgerLogStore) GetUint64(key []byte) (uint64, error) {
 }
 
 func (b BadgerLogStore) FirstIndex() (uint64, error) {
-	var num uint64 = 0
-	err := b.LogStore.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
-		defer it.Close()
-		it.Rewind()
-		if it.Valid() {
-			it.Next()
-			num = util.BytesToULong(it.Item().Key())
-		}
-		return nil
-	})
-	if err != nil {
-		return 0, err
-	}
-	return num, nil
+	return b.boundIndex(false)
 }
 
 func (b BadgerLogStore) LastIndex() (uint64, error) {
-	var num uint64 = 0
+	return b.boundIndex(true)
+}
+
+// boundIndex seeks to the first or the last log key, 0 when there is no log
+func (b BadgerLogStore) boundIndex(last bool) (uint64, error) {
+	var index uint64
 	err := b.LogStore.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
-		opts.Reverse = true
+		opts.Prefix = logKeyPrefix
+		opts.Reverse = last
 		it := txn.NewIterator(opts)
 		defer it.Close()
-		it.Rewind()
+		seek := logKeyPrefix
+		if last {
+			seek = logKey(math.MaxUint64)
+		}
+		it.Seek(seek)
 		if it.Valid() {
-			it.Next()
-			num = util.BytesToULong(it.Item().Key())
+			index = binary.BigEndian.Uint64(it.Item().Key()[len(logKeyPrefix):])
 		}
 		return nil
 	})
 	if err != nil {
 		return 0, err
 	}
-	return num, nil
+	return index, nil
 }
 
 func (b BadgerLogStore) GetLog(index uint64, log *raft.Log) error {
 	err := b.LogStore.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(util.ULongToBytes(index))
+		item, err := txn.Get(logKey(index))
 		if err != nil {
 			return err
 		}
@@ -122,6 +167,10 @@ func (b BadgerLogStore) GetLog(index uint64, log *raft.Log) error {
 		}
 		return nil
 	})
+	//raft sends a snapshot to a follower only when the log it needs is reported as not found
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return raft.ErrLogNotFound
+	}
 	if err != nil {
 		return err
 	}
@@ -134,7 +183,7 @@ func (b BadgerLogStore) StoreLog(log *raft.Log) error {
 		if err != nil {
 			return err
 		}
-		err = txn.Set(util.ULongToBytes(log.Index), buf.Bytes())
+		err = txn.Set(logKey(log.Index), buf.Bytes())
 		if err != nil {
 			return err
 		}
@@ -153,7 +202,7 @@ func (b BadgerLogStore) StoreLogs(logs []*raft.Log) error {
 			if err != nil {
 				return err
 			}
-			err = txn.Set(util.ULongToBytes(log.Index), buf.Bytes())
+			err = txn.Set(logKey(log.Index), buf.Bytes())
 			if err != nil {
 				return err
 			}
@@ -169,7 +218,7 @@ func (b BadgerLogStore) StoreLogs(logs []*raft.Log) error {
 func (b BadgerLogStore) DeleteRange(min, max uint64) error {
 	err := b.LogStore.Update(func(txn *badger.Txn) error {
 		for i := min; i <= max; i++ {
-			err := txn.Delete(util.ULongToBytes(i))
+			err := txn.Delete(logKey(i))
 			if err != nil {
 				return err
 			}
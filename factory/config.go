@@ -0,0 +1,131 @@
+package factory
+
+import (
+	"fmt"
+	"github.com/Kapperchino/jet-stream/auth"
+	"github.com/Kapperchino/jet-stream/config"
+	"github.com/Kapperchino/jet-stream/controller"
+	"github.com/Kapperchino/jet-stream/util"
+)
+
+// NewJetConfig maps a validated config file onto the config of a shard node, the Server channel is left to the caller
+func NewJetConfig(file *config.File) (*JetConfig, error) {
+	authConfig, err := newAuthConfig(file.Auth)
+	if err != nil {
+		return nil, err
+	}
+	var gossipKeys [][]byte
+	for _, encoded := range file.Gossip.Keys {
+		key, err := ParseGossipKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gossip key: %w", err)
+		}
+		gossipKeys = append(gossipKeys, key)
+	}
+	var tracing *TracingConfig
+	if file.Tracing.Endpoint != "" {
+		tracing = &TracingConfig{
+			Endpoint:    file.Tracing.Endpoint,
+			Insecure:    file.Tracing.Insecure,
+			SampleRatio: file.Tracing.SampleRatio,
+		}
+	}
+	timings := file.Gossip.Timings
+	return &JetConfig{
+		HostAddr:             listenAddress(file.Node),
+		BadgerDir:            file.Node.DataDir,
+		RaftDir:              file.Node.RaftDir,
+		GlobalAdr:            file.Node.Address,
+		NodeName:             file.Node.Name,
+		GossipAddress:        file.Gossip.Address,
+		RootNode:             file.Gossip.RootNode,
+		ShardId:              file.Node.Shards[0],
+		ShardIds:             file.Node.Shards,
+		ShardGossipAddresses: file.Gossip.ShardAddresses,
+		InMemory:             file.Node.InMemory,
+		DisableForwarding:    !file.Node.ForwardWrites,
+		Controllers:          file.Node.Controllers,
+		GossipKeys:           gossipKeys,
+		GossipKeyringFile:    file.Gossip.KeyringFile,
+		TLS:                  newTLSConfig(file.TLS),
+		Auth:                 authConfig,
+		NodeToken:            file.Auth.NodeToken,
+		ACL:                  file.Auth.ACL,
+		Superusers:           file.Auth.Superusers,
+		MetricsAddress:       file.Metrics.Address,
+		Tracing:              tracing,
+		GossipTimings:        &timings,
+		MaxMessageSize:       file.Node.MaxMessageSize,
+		ReadReplica:          file.Node.ReadReplica,
+		Bootstrap:            file.Node.Bootstrap,
+		BootstrapExpect:      file.Node.BootstrapExpect,
+	}, nil
+}
+
+// NewControllerConfig maps a validated config file onto the config of a controller node
+func NewControllerConfig(file *config.File) (*ControllerConfig, error) {
+	authConfig, err := newAuthConfig(file.Auth)
+	if err != nil {
+		return nil, err
+	}
+	return &ControllerConfig{
+		HostAddr:  listenAddress(file.Node),
+		GlobalAdr: file.Node.Address,
+		NodeName:  file.Node.Name,
+		RaftDir:   file.Node.RaftDir,
+		InMemory:  file.Node.InMemory,
+		Balancer: &controller.BalancerConfig{
+			Enabled:            file.Balancer.Enabled,
+			DryRun:             file.Balancer.DryRun,
+			Interval:           file.Balancer.Interval,
+			MaxConcurrentMoves: file.Balancer.MaxMoves,
+			Threshold:          file.Balancer.Threshold,
+		},
+		TLS:            newTLSConfig(file.TLS),
+		Auth:           authConfig,
+		NodeToken:      file.Auth.NodeToken,
+		ACL:            file.Auth.ACL,
+		Superusers:     file.Auth.Superusers,
+		MaxMessageSize: file.Node.MaxMessageSize,
+	}, nil
+}
+
+func listenAddress(node config.Node) string {
+	if node.ListenAddress == "" {
+		return node.Address
+	}
+	return node.ListenAddress
+}
+
+func newTLSConfig(tls config.TLS) *util.TLSConfig {
+	if tls.Cert == "" {
+		return nil
+	}
+	return &util.TLSConfig{
+		CertFile:   tls.Cert,
+		KeyFile:    tls.Key,
+		CAFile:     tls.CA,
+		ClientAuth: tls.ClientAuth,
+		ServerName: tls.ServerName,
+	}
+}
+
+func newAuthConfig(file config.Auth) (*auth.Config, error) {
+	if !file.Enabled() {
+		return nil, nil
+	}
+	authConfig := &auth.Config{
+		JWKSFile: file.JWKSFile,
+		Issuer:   file.JWTIssuer,
+		Audience: file.JWTAudience,
+		MTLS:     file.MTLS,
+	}
+	if file.TokenFile != "" {
+		tokens, err := auth.LoadTokens(file.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the token file: %w", err)
+		}
+		authConfig.Tokens = tokens
+	}
+	return authConfig, nil
+}
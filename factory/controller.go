@@ -0,0 +1,122 @@
+package factory
+
+import (
+	"context"
+	"fmt"
+	application "github.com/Kapperchino/jet-stream/application"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/auth"
+	"github.com/Kapperchino/jet-stream/controller"
+	controllerPb "github.com/Kapperchino/jet-stream/controller/proto/proto"
+	"github.com/Kapperchino/jet-stream/leader-rpc/leaderhealth"
+	"github.com/Kapperchino/jet-stream/raftadmin"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	"net"
+	"os"
+	"strings"
+)
+
+type ControllerConfig struct {
+	HostAddr  string
+	GlobalAdr string
+	NodeName  string
+	RaftDir   string
+	InMemory  bool
+	Server    chan *Server
+	Balancer  *controller.BalancerConfig
+	// TLS secures the grpc server and the calls to the shards, they are plaintext when nil
+	TLS *util.TLSConfig
+	// Auth checks the identity of the callers, every call is accepted when nil
+	Auth *auth.Config
+	// NodeToken is sent by the controller when it calls the shards, not needed with mutual tls
+	NodeToken string
+	// ACL checks the callers against the acls the shards report, it needs Auth
+	ACL bool
+	// Superusers are the identities that skip the acls, the identity of NodeToken is always one. The shards report
+	// with the identity of their node, it has to be a superuser
+	Superusers []string
+	// MaxMessageSize is the largest grpc message the controller sends and receives, config.DefaultMaxMessageSize when 0
+	MaxMessageSize int
+}
+
+// SetupController runs a node of the metadata controller raft group, more voters are added through raftadmin
+func SetupController(controllerConfig *ControllerConfig) {
+	sock, err := net.Listen("tcp", controllerConfig.HostAddr)
+	if err != nil {
+		log.Fatal().Msgf("failed to listen: %v", err)
+	}
+	output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "2006/01/02 15:04:05"}
+	output.FormatLevel = func(i interface{}) string {
+		return fmt.Sprintf("[%s] ", controllerConfig.NodeName) + strings.ToUpper(fmt.Sprintf("[%-4s]", i))
+	}
+	logger := log.Level(zerolog.TraceLevel).Output(output)
+	serverOptions, dialOptions, err := grpcSecurity(controllerConfig.TLS, controllerConfig.Auth, controllerConfig.NodeToken, controllerConfig.Superusers)
+	if err != nil {
+		log.Fatal().Msgf("failed to load the security config: %v", err)
+	}
+	state := controller.NewState(&logger)
+	//the raft address is advertised to clients as the leader address, so it has to be reachable
+	r, tm, stores, err := NewRaft(controllerConfig.NodeName, controllerConfig.GlobalAdr, state, controllerConfig.RaftDir, controllerConfig.InMemory, dialOptions)
+	if err != nil {
+		log.Fatal().Msgf("failed to start raft: %v", err)
+	}
+	sizeServerOptions, sizeDialOptions := messageSizeOptions(controllerConfig.MaxMessageSize)
+	dialOptions = append(dialOptions, sizeDialOptions...)
+	calls := &callTracker{}
+	s := grpc.NewServer(append(append([]grpc.ServerOption{calls.interceptor()}, serverOptions...), sizeServerOptions...)...)
+	balancer := controller.DefaultBalancerConfig()
+	if controllerConfig.Balancer != nil {
+		balancer = *controllerConfig.Balancer
+	}
+	rpc := controller.RpcInterface{
+		State:    state,
+		Raft:     r,
+		Load:     controller.NewLoadTracker(),
+		Balancer: balancer,
+
+		DialOptions: dialOptions,
+		Acls:        controller.NewAclTracker(),
+	}
+	if controllerConfig.ACL {
+		if controllerConfig.Auth == nil {
+			log.Fatal().Msgf("failed to load the acls: the acls need the callers to be authenticated")
+		}
+		authorizer := application.NewAuthorizer(nodeIdentities(controllerConfig.Superusers, controllerConfig.Auth, controllerConfig.NodeToken)...)
+		rpc.Authorize = func(ctx context.Context, resource pb.AclResource, name string, operation pb.AclOperation) error {
+			return authorizer.Check(ctx, func() ([]*pb.Acl, error) {
+				return rpc.Acls.Acls(), nil
+			}, resource, name, operation)
+		}
+		rpc.Visible = func(ctx context.Context) (func(resource pb.AclResource, name string) bool, error) {
+			return authorizer.Visible(ctx, func() ([]*pb.Acl, error) {
+				return rpc.Acls.Acls(), nil
+			})
+		}
+	}
+	controllerPb.RegisterControllerServiceServer(s, &rpc)
+	tm.Register(s)
+	hs := leaderhealth.Setup(r, s, []string{"controller.ControllerService"})
+	raftadmin.Register(s, r)
+	reflection.Register(s)
+	stop := make(chan struct{})
+	go controller.RunReassignments(rpc, stop)
+	go controller.RunShardOperations(rpc, stop)
+	if balancer.Enabled {
+		go controller.RunBalancer(rpc, stop)
+	}
+	controllerConfig.Server <- &Server{
+		Raft:   r,
+		Grpc:   s,
+		stop:   stop,
+		health: hs,
+		calls:  calls,
+		stores: stores,
+	}
+	if err := s.Serve(sock); err != nil {
+		log.Fatal().Msgf("failed to serve gRPC Server: %v", err)
+	}
+}
@@ -1,36 +1,52 @@
 package factory
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	application "github.com/Kapperchino/jet-stream/application"
-	"github.com/Kapperchino/jet-stream/application/fsm"
-	"github.com/Kapperchino/jet-stream/application/fsm/handlers"
-	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	"github.com/Kapperchino/jet-stream/auth"
 	cluster "github.com/Kapperchino/jet-stream/cluster"
 	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
 	"github.com/Kapperchino/jet-stream/config"
 	_ "github.com/Kapperchino/jet-stream/factory/vtprotoencoding"
 	"github.com/Kapperchino/jet-stream/leader-rpc/leaderhealth"
-	"github.com/Kapperchino/jet-stream/raftadmin"
 	"github.com/Kapperchino/jet-stream/transport"
 	"github.com/Kapperchino/jet-stream/util"
+	"github.com/dgraph-io/badger/v3"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/memberlist"
 	"github.com/hashicorp/raft"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/reflection"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type Server struct {
+	// Raft and MemberList belong to the first shard hosted by the node
 	Raft       *raft.Raft
 	Grpc       *grpc.Server
 	MemberList *memberlist.Memberlist
+	Shards     []*Shard
+	stop       chan struct{}
+	node       *node
+	metrics    *http.Server
+	// flushSpans exports the spans left when the node stops, nil without tracing
+	flushSpans func(context.Context) error
+	// health reports NOT_SERVING once a shutdown starts
+	health *health.Server
+	// calls are the unary calls in flight a shutdown waits for
+	calls *callTracker
+	// stores are the raft stores of a controller, the shards close their own
+	stores []*badger.DB
 }
 
 type JetConfig struct {
@@ -43,18 +59,123 @@ type JetConfig struct {
 	RootNode      string
 	Server        chan *Server
 	ShardId       string
-	InMemory      bool
+	// ShardIds are the shards hosted by the node when it hosts more than ShardId, the first one gossips on
+	// GossipAddress. Shards started through the NodeService have to be added here on restart
+	ShardIds []string
+	// ShardGossipAddresses are the gossip addresses of the shards of ShardIds after the first one, in the same order
+	ShardGossipAddresses []string
+	InMemory             bool
+	// DisableForwarding makes followers reject writes with a redirect instead of forwarding them to the leader
+	DisableForwarding bool
+	// Controllers are the addresses of the metadata controller, topics are placed by it when set
+	Controllers []string
+	// GossipKeys encrypt the gossip when set, the first one is the primary key. They are only used if the keyring
+	// file doesn't exist yet
+	GossipKeys [][]byte
+	// GossipKeyringFile keeps the keys installed through the NodeService across restarts
+	GossipKeyringFile string
+	// TLS secures the grpc server and the calls to other nodes and to the controllers, they are plaintext when nil
+	TLS *util.TLSConfig
+	// Auth checks the identity of the callers, every call is accepted when nil
+	Auth *auth.Config
+	// NodeToken is sent by the node when it calls other nodes and the controllers, not needed with mutual tls
+	NodeToken string
+	// ACL checks the callers against the acls of the shards, it needs Auth
+	ACL bool
+	// Superusers are the identities that skip the acls and may call the raft transport, the identity of NodeToken is
+	// always one. The identity of the node certificate has to be added with mutual tls
+	Superusers []string
+	// MetricsAddress serves the prometheus metrics of the node on /metrics over http, they aren't collected when empty
+	MetricsAddress string
+	// Tracing exports the spans of the calls, the raft writes and the fsm to an otlp collector, nothing is traced when nil
+	Tracing *TracingConfig
+	// GossipTimings tune the memberlist of every shard, the defaults are used when nil
+	GossipTimings *config.GossipTimings
+	// MaxMessageSize is the largest grpc message the node sends and receives, config.DefaultMaxMessageSize when 0
+	MaxMessageSize int
+	// ReadReplica joins the shards as a raft nonvoter, it replicates them and serves reads without growing the quorum.
+	// It needs a RootNode to be added by the leader
+	ReadReplica bool
+	// Bootstrap starts the shards without raft state as single node groups, a node without a RootNode always does.
+	// The other nodes ask the leader of each shard to add them
+	Bootstrap bool
+	// BootstrapExpect starts the shards without raft state with the voters in the gossip once there are this many,
+	// this node included. A shard that gets a leader first is joined instead
+	BootstrapExpect int
+}
+
+// bootstrapsAlone tells if the shards without raft state start as single node groups instead of waiting to join
+func (c *JetConfig) bootstrapsAlone() bool {
+	if c.ReadReplica {
+		return false
+	}
+	return c.Bootstrap || c.RootNode == "" && c.BootstrapExpect == 0
+}
+
+func (c *JetConfig) gossipTimings() config.GossipTimings {
+	if c.GossipTimings == nil {
+		return config.DefaultGossipTimings()
+	}
+	return *c.GossipTimings
+}
+
+// messageSizeOptions set the largest message of the server and of the calls to the other nodes
+func messageSizeOptions(maxSize int) ([]grpc.ServerOption, []grpc.DialOption) {
+	if maxSize == 0 {
+		maxSize = config.DefaultMaxMessageSize
+	}
+	return []grpc.ServerOption{grpc.MaxRecvMsgSize(maxSize), grpc.MaxSendMsgSize(maxSize)},
+		[]grpc.DialOption{grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxSize), grpc.MaxCallSendMsgSize(maxSize))}
 }
 
 func (s *Server) Kill() {
+	close(s.stop)
 	s.Grpc.Stop()
-	if s.MemberList != nil {
-		s.MemberList.Shutdown()
+	if s.metrics != nil {
+		_ = s.metrics.Close()
+	}
+	if s.flushSpans != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = s.flushSpans(ctx)
+		cancel()
+	}
+	if s.node == nil {
+		s.Raft.Shutdown().Error()
+		for _, store := range s.stores {
+			_ = store.Close()
+		}
+		return
+	}
+	s.node.shutdown()
+	if s.node.forwarder != nil {
+		s.node.forwarder.Close()
+	}
+}
+
+// HostedShards are the shards the node runs now, including the ones started or dropped through the NodeService
+func (s *Server) HostedShards() []string {
+	if s.node == nil {
+		return nil
+	}
+	return s.node.HostedShards()
+}
+
+// NewRaft starts a raft group bootstrapped with only this node, the stores it keeps its logs in are closed by the
+// caller once raft is shut down
+func NewRaft(myID, myAddress string, fsm raft.FSM, raftDir string, inMem bool, dialOptions []grpc.DialOption) (*raft.Raft, *transport.Manager, []*badger.DB, error) {
+	tm := transport.New(raft.ServerAddress(myAddress), dialOptions)
+	r, stores, err := newRaft(myID, tm, fsm, filepath.Join(raftDir, myID), inMem, nil, true)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	s.Raft.Shutdown().Error()
+	return r, tm, stores, nil
 }
 
-func NewRaft(myID, myAddress string, fsm raft.FSM, raftDir string, inMem bool) (*raft.Raft, *transport.Manager, error) {
+// newRaft starts a raft group that keeps its logs, stable store and snapshots in baseDir. When bootstrap is set it is
+// bootstrapped with the servers, or with only this node when there are none, otherwise it waits for a leader to add
+// it. A group that already has raft state is never bootstrapped again. The log and stable stores are returned so
+// they are closed after raft shuts down
+func newRaft(myID string, tm *transport.Manager, fsm raft.FSM, baseDir string, inMem bool, servers []raft.Server, bootstrap bool) (*raft.Raft, []*badger.DB, error) {
 	c := raft.DefaultConfig()
 	c.ProtocolVersion = raft.ProtocolVersionMax
 	c.LocalID = raft.ServerID(myID)
@@ -72,20 +193,23 @@ func NewRaft(myID, myAddress string, fsm raft.FSM, raftDir string, inMem bool) (
 		Output:     util.NewRaftLogger(output),
 	})
 
-	baseDir := filepath.Join(raftDir, myID)
 	logDir := filepath.Join(baseDir, "logs")
-	db, err := NewBadger(logDir, inMem)
+	logs, err := NewBadger(logDir, inMem)
 	if err != nil {
 		return nil, nil, fmt.Errorf(`boltdb.NewBoltStore(%q): %v`, filepath.Join(baseDir, "logs.dat"), err)
 	}
-	ldb := BadgerLogStore{LogStore: db}
+	ldb, err := NewBadgerLogStore(logs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("migrating the raft log keys of %q: %v", logDir, err)
+	}
 
 	stableDir := filepath.Join(baseDir, "stable")
-	db, err = NewBadger(stableDir, inMem)
+	stable, err := NewBadger(stableDir, inMem)
 	if err != nil {
 		return nil, nil, fmt.Errorf(`boltdb.NewBoltStore(%q): %v`, filepath.Join(baseDir, "logs.dat"), err)
 	}
-	sdb := BadgerLogStore{LogStore: db}
+	sdb := BadgerLogStore{LogStore: stable}
+	stores := []*badger.DB{logs, stable}
 
 	var fss raft.SnapshotStore
 	if inMem {
@@ -96,27 +220,37 @@ func NewRaft(myID, myAddress string, fsm raft.FSM, raftDir string, inMem bool) (
 			return nil, nil, fmt.Errorf(`raft.NewFileSnapshotStore(%q, ...): %v`, baseDir, err)
 		}
 	}
-	tm := transport.New(raft.ServerAddress(myAddress), []grpc.DialOption{grpc.WithInsecure()})
+	existing, err := raft.HasExistingState(ldb, sdb, fss)
+	if err != nil {
+		return nil, nil, fmt.Errorf("raft.HasExistingState: %v", err)
+	}
 	r, err := raft.NewRaft(c, fsm, ldb, sdb, fss, tm.Transport())
 	if err != nil {
 		return nil, nil, fmt.Errorf("raft.NewRaft: %v", err)
 	}
 
-	cfg := raft.Configuration{
-		Servers: []raft.Server{
+	if existing {
+		log.Info().Msgf("%s restarts from its raft state, it isn't bootstrapped", myID)
+		return r, stores, nil
+	}
+	if !bootstrap {
+		return r, stores, nil
+	}
+	if len(servers) == 0 {
+		servers = []raft.Server{
 			{
 				Suffrage: raft.Voter,
 				ID:       raft.ServerID(myID),
-				Address:  raft.ServerAddress(myAddress),
+				Address:  tm.Transport().LocalAddr(),
 			},
-		},
+		}
 	}
-	f := r.BootstrapCluster(cfg)
+	f := r.BootstrapCluster(raft.Configuration{Servers: servers})
 	if err := f.Error(); err != nil {
 		log.Err(err).Msgf("Bootstrap error")
 	}
 
-	return r, tm, nil
+	return r, stores, nil
 }
 
 func SetupServer(jetConfig *JetConfig) {
@@ -137,57 +271,151 @@ func SetupServer(jetConfig *JetConfig) {
 		return fmt.Sprintf("%s:", i)
 	}
 	log.Logger = log.Output(defaultOutput)
-	outputWithNode := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "2006/01/02 15:04:05"}
-	outputWithNode.FormatLevel = func(i interface{}) string {
-		return fmt.Sprintf("[%s] ", jetConfig.NodeName) + strings.ToUpper(fmt.Sprintf("[%-4s]", i))
+	serverOptions, dialOptions, err := grpcSecurity(jetConfig.TLS, jetConfig.Auth, jetConfig.NodeToken, jetConfig.Superusers)
+	if err != nil {
+		log.Fatal().Msgf("failed to load the security config: %v", err)
 	}
-	outputWithNode.FormatFieldName = func(i interface{}) string {
-		return fmt.Sprintf("%s:", i)
+	var metricsServer *http.Server
+	if jetConfig.MetricsAddress != "" {
+		metricsServer, err = setupMetrics(jetConfig.MetricsAddress)
+		if err != nil {
+			log.Fatal().Msgf("failed to serve the metrics: %v", err)
+		}
+		//the calls rejected by the authentication are counted too
+		serverOptions = append(metricsInterceptors(), serverOptions...)
+	}
+	var flushSpans func(context.Context) error
+	if jetConfig.Tracing != nil {
+		flushSpans, err = setupTracing(jetConfig.Tracing, jetConfig.NodeName)
+		if err != nil {
+			log.Fatal().Msgf("failed to export the traces: %v", err)
+		}
+		serverOptions = append(util.TracingServerOptions(), serverOptions...)
 	}
-	db, _ := NewBadger(jetConfig.BadgerDir+"/"+jetConfig.NodeName+"/Meta", jetConfig.InMemory)
-	messages, _ := NewBadger(jetConfig.BadgerDir+"/"+jetConfig.NodeName+"/Messages", jetConfig.InMemory)
-	nodeLogger := log.Level(config.LOG_LEVEL).Output(outputWithNode)
-	nodeState := &fsm.NodeState{
-		MetaStore:    db,
-		MessageStore: messages,
-		HandlerMap:   handlers.InitHandlers(),
-		Logger:       &nodeLogger,
+	sizeServerOptions, sizeDialOptions := messageSizeOptions(jetConfig.MaxMessageSize)
+	dialOptions = append(dialOptions, sizeDialOptions...)
+	calls := &callTracker{}
+	s := grpc.NewServer(append(append([]grpc.ServerOption{calls.interceptor()}, serverOptions...), sizeServerOptions...)...)
+	raftDialOptions := dialOptions
+	if jetConfig.Tracing != nil {
+		//the writes forwarded to the leader and the calls to the controllers continue the trace, raft isn't traced
+		dialOptions = append(dialOptions[:len(dialOptions):len(dialOptions)], util.TracingDialOptions()...)
 	}
-	r, tm, err := NewRaft(jetConfig.NodeName, jetConfig.HostAddr, nodeState, jetConfig.RaftDir, jetConfig.InMemory)
+	n := &node{
+		config:      jetConfig,
+		mux:         transport.NewMux(raft.ServerAddress(jetConfig.HostAddr), raftDialOptions),
+		router:      newShardRouter(s),
+		shards:      map[string]*Shard{},
+		stop:        make(chan struct{}),
+		dialOptions: dialOptions,
+	}
+	if !jetConfig.DisableForwarding {
+		n.forwarder = application.NewForwarder(dialOptions...)
+	}
+	n.authorizer, err = newAuthorizer(jetConfig)
 	if err != nil {
-		log.Fatal().Msgf("failed to start raft: %v", err)
+		log.Fatal().Msgf("failed to load the acls: %v", err)
 	}
-	s := grpc.NewServer(grpc.MaxRecvMsgSize(1 * 1024 * 1024 * 1024))
-	pb.RegisterMessageServiceServer(s, &application.RpcInterface{
-		NodeState: nodeState,
-		Raft:      r,
-	})
-	clusterLog := log.Level(config.LOG_LEVEL).Output(outputWithNode)
-	clusterRpc := &cluster.RpcInterface{
-		ClusterState: nil,
-		Raft:         r,
-		Logger:       &clusterLog,
-	}
-	clusterRpc.ClusterState = cluster.InitClusterState(clusterRpc, jetConfig.NodeName, jetConfig.GlobalAdr, jetConfig.ShardId, &clusterLog, r)
-	memberListener := cluster.InitClusterListener(clusterRpc.ClusterState)
-	memberList := NewMemberList(MakeConfig(jetConfig.NodeName, jetConfig.ShardId, jetConfig.GossipAddress, memberListener, cluster.ClusterDelegate{
-		ClusterState: clusterRpc.ClusterState,
-	}), jetConfig.RootNode)
-	clusterRpc.MemberList = memberList
-	nodeState.ShardState = clusterRpc.ClusterState.CurShardState
-	clusterPb.RegisterClusterMetaServiceServer(s, clusterRpc)
-	tm.Register(s)
-	leaderhealth.Setup(r, s, []string{"cluster.ClusterMetaService", "", "message.MessageService", "transport.RaftTransport"})
-	raftadmin.Register(s, r)
-	reflection.Register(s)
-	jetConfig.Server <- &Server{
-		Raft:       r,
+	n.keyring, err = newGossipKeyring(jetConfig.GossipKeys, jetConfig.GossipKeyringFile)
+	if err != nil {
+		log.Fatal().Msgf("failed to load the gossip keyring: %v", err)
+	}
+	shardIds := jetConfig.ShardIds
+	if len(shardIds) == 0 {
+		shardIds = []string{jetConfig.ShardId}
+	}
+	if len(shardIds) > 1 && len(jetConfig.ShardGossipAddresses) != len(shardIds)-1 {
+		log.Fatal().Msgf("every shard after the first one needs a gossip address, got %d for %d shards", len(jetConfig.ShardGossipAddresses), len(shardIds))
+	}
+	server := &Server{
 		Grpc:       s,
-		MemberList: memberList,
+		stop:       n.stop,
+		node:       n,
+		metrics:    metricsServer,
+		flushSpans: flushSpans,
+		calls:      calls,
 	}
+	for i, shardId := range shardIds {
+		gossipAddress := jetConfig.GossipAddress
+		if i > 0 {
+			gossipAddress = jetConfig.ShardGossipAddresses[i-1]
+		}
+		shard, err := n.addShard(shardId, nil, gossipAddress)
+		if err != nil {
+			log.Fatal().Msgf("failed to start shard %s: %v", shardId, err)
+		}
+		server.Shards = append(server.Shards, shard)
+	}
+	server.Raft = server.Shards[0].Raft
+	server.MemberList = server.Shards[0].MemberList
+	n.mux.Register(s)
+	nodeRpc := cluster.NodeRpc{Host: n, Authorize: n.authorizeAdmin}
+	if n.keyring != nil {
+		nodeRpc.Keys = n.keyring
+	}
+	clusterPb.RegisterNodeServiceServer(s, nodeRpc)
+	server.health = leaderhealth.Setup(server.Raft, s, []string{"cluster.ClusterMetaService", "", "message.MessageService", "transport.RaftTransport"})
+	reflection.Register(s)
+	jetConfig.Server <- server
 
 	// Serve gRPC and HTTP servers concurrently.
 	if err := s.Serve(sock); err != nil {
 		log.Fatal().Msgf("failed to serve gRPC Server: %v", err)
 	}
 }
+
+// newAuthorizer returns the acl checks of the shards, nil when they are off. The identity of the node token skips them
+// since the writes a node forwards were already checked by it
+func newAuthorizer(jetConfig *JetConfig) (*application.Authorizer, error) {
+	if !jetConfig.ACL {
+		return nil, nil
+	}
+	if jetConfig.Auth == nil {
+		return nil, errors.New("the acls need the callers to be authenticated")
+	}
+	return application.NewAuthorizer(nodeIdentities(jetConfig.Superusers, jetConfig.Auth, jetConfig.NodeToken)...), nil
+}
+
+// nodeIdentities are the superusers and the identity of the node token, the nodes call each other with them
+func nodeIdentities(superusers []string, authConfig *auth.Config, nodeToken string) []string {
+	identities := append([]string{}, superusers...)
+	if authConfig == nil || nodeToken == "" {
+		return identities
+	}
+	if name, ok := authConfig.Tokens[nodeToken]; ok {
+		identities = append(identities, name)
+	}
+	return identities
+}
+
+// grpcSecurity returns the server options and the dial options of the node. The server uses tls and checks the callers
+// when configured, only the node identities call the raft transport. The dial options carry the tls credentials and the
+// token of the node
+func grpcSecurity(tlsConfig *util.TLSConfig, authConfig *auth.Config, nodeToken string, superusers []string) ([]grpc.ServerOption, []grpc.DialOption, error) {
+	dialOption, err := util.DialCredentials(tlsConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	dialOptions := []grpc.DialOption{dialOption}
+	if nodeToken != "" {
+		dialOptions = append(dialOptions, auth.TokenCredentials{Token: nodeToken, AllowInsecure: tlsConfig == nil}.DialOption())
+	}
+	var serverOptions []grpc.ServerOption
+	if tlsConfig != nil {
+		creds, err := tlsConfig.ServerCredentials()
+		if err != nil {
+			return nil, nil, err
+		}
+		serverOptions = append(serverOptions, grpc.Creds(creds))
+	}
+	if authConfig != nil {
+		nodeAuth := *authConfig
+		nodeAuth.Nodes = append(nodeIdentities(superusers, authConfig, nodeToken), authConfig.Nodes...)
+		authenticator, err := auth.New(&nodeAuth)
+		if err != nil {
+			return nil, nil, err
+		}
+		serverOptions = append(serverOptions, authenticator.ServerOptions()...)
+	}
+	return serverOptions, dialOptions, nil
+}
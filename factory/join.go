@@ -0,0 +1,159 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sort"
+	"time"
+)
+
+// joinInterval is how often a node that isn't in the raft group of a shard looks for its leader again
+const joinInterval = time.Second
+
+// joinTimeout bounds a join or a leave call, the leader waits for the raft group to take the change before answering
+const joinTimeout = 30 * time.Second
+
+// decommissionTimeout is how long a decommissioned node keeps looking for the leader of a shard to remove it
+const decommissionTimeout = time.Minute
+
+// joinShard gets the node into the raft group of the shard. It asks the leader seen in the gossip to add it, or with
+// BootstrapExpect bootstraps the group with the voters seen once there are enough of them. It returns once the node is
+// in the configuration or the shard stops
+func (n *node) joinShard(shard *Shard) {
+	ticker := time.NewTicker(joinInterval)
+	defer ticker.Stop()
+	for {
+		if inConfiguration(shard.Raft, n.config.NodeName) {
+			return
+		}
+		leader, voters := shardMembers(shard)
+		if leader != nil {
+			err := n.requestJoin(shard.ShardId, leader)
+			if err == nil {
+				log.Info().Msgf("%s joined shard %s through %s", n.config.NodeName, shard.ShardId, leader.GetNodeId())
+				return
+			}
+			log.Warn().Err(err).Msgf("%s cannot join shard %s yet", n.config.NodeName, shard.ShardId)
+		} else if expect := n.config.BootstrapExpect; expect > 0 && !n.config.ReadReplica && len(voters) >= expect {
+			log.Info().Msgf("%s bootstraps shard %s with %d voters", n.config.NodeName, shard.ShardId, len(voters))
+			err := shard.Raft.BootstrapCluster(raft.Configuration{Servers: voters}).Error()
+			if err == nil || errors.Is(err, raft.ErrCantBootstrap) {
+				return
+			}
+			log.Err(err).Msgf("Bootstrap error")
+		}
+		select {
+		case <-shard.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func inConfiguration(r *raft.Raft, nodeId string) bool {
+	future := r.GetConfiguration()
+	if future.Error() != nil {
+		return false
+	}
+	for _, server := range future.Configuration().Servers {
+		if string(server.ID) == nodeId {
+			return true
+		}
+	}
+	return false
+}
+
+// shardMembers reads the shard states the gossip members advertise, it returns the leader of the shard if one is
+// known and the voters seen sorted by id
+func shardMembers(shard *Shard) (*clusterPb.MemberInfo, []raft.Server) {
+	var leader *clusterPb.MemberInfo
+	var voters []raft.Server
+	for _, member := range shard.MemberList.Members() {
+		var info clusterPb.ShardInfo
+		if err := util.DeserializeMessage(member.Meta, &info); err != nil || info.GetShardId() != shard.ShardId {
+			continue
+		}
+		if found := info.GetMemberAddressMap()[info.GetLeaderId()]; found != nil && leader == nil {
+			leader = found
+		}
+		self := info.GetMemberAddressMap()[info.GetNodeId()]
+		if self != nil && self.GetRole() == clusterPb.MemberRole_VOTER {
+			voters = append(voters, raft.Server{
+				Suffrage: raft.Voter,
+				ID:       raft.ServerID(self.GetNodeId()),
+				Address:  raft.ServerAddress(self.GetAddress()),
+			})
+		}
+	}
+	sort.Slice(voters, func(i, j int) bool {
+		return voters[i].ID < voters[j].ID
+	})
+	return leader, voters
+}
+
+// leaveShard asks the leader of the shard to remove this node, it retries while there is no leader or it changes
+func (n *node) leaveShard(shard *Shard, force bool) error {
+	deadline := time.Now().Add(decommissionTimeout)
+	for {
+		address, leaderId := shard.Raft.LeaderWithID()
+		err := fmt.Errorf("shard %s has no leader", shard.ShardId)
+		if address != "" {
+			err = n.requestLeave(shard.ShardId, string(address), force)
+			if err == nil {
+				log.Info().Msgf("%s left shard %s through %s", n.config.NodeName, shard.ShardId, leaderId)
+				return nil
+			}
+			if status.Code(err) != codes.Unavailable {
+				return err
+			}
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(joinInterval)
+	}
+}
+
+func (n *node) requestLeave(shardId string, address string, force bool) error {
+	conn, err := grpc.Dial(address, n.dialOptions...)
+	if err != nil {
+		return fmt.Errorf("dialing %s: %w", address, err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), joinTimeout)
+	defer cancel()
+	_, err = clusterPb.NewClusterMetaServiceClient(util.ShardConn(conn, shardId)).LeaveShard(ctx, &clusterPb.LeaveShardRequest{
+		NodeId: n.config.NodeName,
+		Force:  force,
+	})
+	return err
+}
+
+// requestJoin calls the leader to add this node to the shard
+func (n *node) requestJoin(shardId string, leader *clusterPb.MemberInfo) error {
+	conn, err := grpc.Dial(leader.GetAddress(), n.dialOptions...)
+	if err != nil {
+		return fmt.Errorf("dialing %s: %w", leader.GetAddress(), err)
+	}
+	defer conn.Close()
+	role := clusterPb.MemberRole_VOTER
+	if n.config.ReadReplica {
+		role = clusterPb.MemberRole_READ_REPLICA
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), joinTimeout)
+	defer cancel()
+	_, err = clusterPb.NewClusterMetaServiceClient(util.ShardConn(conn, shardId)).JoinShard(ctx, &clusterPb.JoinShardRequest{
+		NodeId:  n.config.NodeName,
+		Address: n.config.GlobalAdr,
+		Role:    role,
+	})
+	return err
+}
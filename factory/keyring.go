@@ -0,0 +1,133 @@
+package factory
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/cluster"
+	"github.com/hashicorp/memberlist"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// gossipKeyring is the keyring shared by the memberlists of the shards hosted by the node. Changes are written to the
+// keyring file when there is one, so a restarted node still knows the keys the cluster rotated to
+type gossipKeyring struct {
+	keyring *memberlist.Keyring
+	file    string
+	mutex   sync.Mutex
+}
+
+var _ cluster.KeyManager = &gossipKeyring{}
+
+// ParseGossipKey decodes a base64 gossip key, it has to be 16, 24 or 32 bytes long to pick AES-128, AES-192 or AES-256
+func ParseGossipKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("decoding the gossip key: %w", err)
+	}
+	if err := memberlist.ValidateKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// newGossipKeyring loads the keys of the keyring file, or takes the configured ones if there is no file yet. The first
+// key is the primary one. The gossip isn't encrypted when there are no keys, nil is returned then
+func newGossipKeyring(keys [][]byte, file string) (*gossipKeyring, error) {
+	if file != "" {
+		fileKeys, err := readKeyringFile(file)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if len(fileKeys) > 0 {
+			keys = fileKeys
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	keyring, err := memberlist.NewKeyring(keys[1:], keys[0])
+	if err != nil {
+		return nil, err
+	}
+	k := &gossipKeyring{keyring: keyring, file: file}
+	if err := k.persist(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *gossipKeyring) InstallKey(key []byte) error {
+	return k.change(func() error {
+		return k.keyring.AddKey(key)
+	})
+}
+
+func (k *gossipKeyring) UseKey(key []byte) error {
+	return k.change(func() error {
+		return k.keyring.UseKey(key)
+	})
+}
+
+func (k *gossipKeyring) RemoveKey(key []byte) error {
+	return k.change(func() error {
+		return k.keyring.RemoveKey(key)
+	})
+}
+
+func (k *gossipKeyring) ListKeys() ([]byte, [][]byte) {
+	return k.keyring.GetPrimaryKey(), k.keyring.GetKeys()
+}
+
+func (k *gossipKeyring) change(apply func() error) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if err := apply(); err != nil {
+		return err
+	}
+	return k.persist()
+}
+
+// persist writes the keys with the primary one first, through a temporary file so a crash doesn't leave half of it
+func (k *gossipKeyring) persist() error {
+	if k.file == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, key := range k.keyring.GetKeys() {
+		buf.WriteString(base64.StdEncoding.EncodeToString(key))
+		buf.WriteByte('\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(k.file), 0700); err != nil {
+		return err
+	}
+	tmp := k.file + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, k.file)
+}
+
+// readKeyringFile reads one base64 key per line, the first one is the primary key
+func readKeyringFile(file string) ([][]byte, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var keys [][]byte
+	for i, line := range strings.Split(string(buf), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, err := ParseGossipKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of the keyring file %s: %w", i+1, file, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
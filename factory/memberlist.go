@@ -2,6 +2,7 @@ package factory
 
 import (
 	"fmt"
+	"github.com/Kapperchino/jet-stream/config"
 	"github.com/Kapperchino/jet-stream/util"
 	"github.com/hashicorp/memberlist"
 	"github.com/rs/zerolog"
@@ -31,7 +32,9 @@ func NewMemberList(config *memberlist.Config, rootNode string) *memberlist.Membe
 	return list
 }
 
-func MakeConfig(nodeName string, shardName string, gossipAddress string, eventDelegate memberlist.EventDelegate, delegate memberlist.Delegate) *memberlist.Config {
+// MakeConfig builds the memberlist config of a shard with the timings, the gossip is encrypted and authenticated with the keyring unless
+// it is nil
+func MakeConfig(nodeName string, shardName string, gossipAddress string, eventDelegate memberlist.EventDelegate, delegate memberlist.Delegate, keyring *memberlist.Keyring, timings config.GossipTimings) *memberlist.Config {
 	host, port, _ := net.SplitHostPort(gossipAddress)
 	portInt, _ := strconv.Atoi(port)
 	output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "2006/01/02 15:04:05"}
@@ -54,19 +57,19 @@ func MakeConfig(nodeName string, shardName string, gossipAddress string, eventDe
 		AdvertiseAddr:           ips[0].String(),
 		AdvertisePort:           portInt,
 		ProtocolVersion:         memberlist.ProtocolVersion2Compatible,
-		TCPTimeout:              30 * time.Second,       // Timeout after 10 seconds
-		IndirectChecks:          3,                      // Use 3 nodes for the indirect ping
-		RetransmitMult:          4,                      // Retransmit a message 4 * log(N+1) nodes
-		SuspicionMult:           6,                      // Suspect a node for 4 * log(N+1) * Interval
-		SuspicionMaxTimeoutMult: 6,                      // For 10k nodes this will give a max timeout of 120 seconds
-		PushPullInterval:        60 * time.Second,       // Low frequency
-		ProbeTimeout:            3 * time.Second,        // Reasonable RTT time for LAN
-		ProbeInterval:           5 * time.Second,        // Failure check every second
-		DisableTcpPings:         false,                  // TCP pings are safe, even with mixed versions
-		AwarenessMaxMultiplier:  8,                      // Probe interval backs off to 8 seconds
-		GossipNodes:             4,                      // Gossip to 3 nodes
-		GossipInterval:          500 * time.Millisecond, // Gossip more rapidly
-		GossipToTheDeadTime:     60 * time.Second,       // Same as push/pull
+		TCPTimeout:              timings.TCPTimeout,
+		IndirectChecks:          3, // Use 3 nodes for the indirect ping
+		RetransmitMult:          4, // Retransmit a message 4 * log(N+1) nodes
+		SuspicionMult:           timings.SuspicionMult,
+		SuspicionMaxTimeoutMult: 6, // For 10k nodes this will give a max timeout of 120 seconds
+		PushPullInterval:        timings.PushPullInterval,
+		ProbeTimeout:            timings.ProbeTimeout,
+		ProbeInterval:           timings.ProbeInterval,
+		DisableTcpPings:         false, // TCP pings are safe, even with mixed versions
+		AwarenessMaxMultiplier:  8,     // Probe interval backs off to 8 seconds
+		GossipNodes:             timings.GossipNodes,
+		GossipInterval:          timings.GossipInterval,
+		GossipToTheDeadTime:     timings.GossipToTheDeadTime,
 		GossipVerifyIncoming:    true,
 		GossipVerifyOutgoing:    true,
 		Logger:                  stdLogger,
@@ -74,7 +77,7 @@ func MakeConfig(nodeName string, shardName string, gossipAddress string, eventDe
 		EnableCompression: true, // Enable compression by default
 
 		SecretKey: nil,
-		Keyring:   nil,
+		Keyring:   keyring,
 
 		DNSConfigPath: "/etc/resolv.conf",
 
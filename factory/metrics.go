@@ -0,0 +1,135 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"github.com/armon/go-metrics"
+	metricsPrometheus "github.com/armon/go-metrics/prometheus"
+	"github.com/hashicorp/raft"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// metricsInterval is how often the gauges of the shards are updated, well under the expiration of the sink
+const metricsInterval = 5 * time.Second
+
+var (
+	// the prometheus sink is registered once per process, the nodes started by the tests share it
+	sinkOnce sync.Once
+	sinkErr  error
+)
+
+// setupMetrics sends the go-metrics of the process, including the ones of raft and memberlist, to prometheus and
+// serves them on /metrics of the address
+func setupMetrics(address string) (*http.Server, error) {
+	sinkOnce.Do(func() {
+		var sink *metricsPrometheus.PrometheusSink
+		sink, sinkErr = metricsPrometheus.NewPrometheusSink()
+		if sinkErr != nil {
+			return
+		}
+		conf := metrics.DefaultConfig("")
+		conf.EnableHostname = false
+		//the default registry already collects the go runtime
+		conf.EnableRuntimeMetrics = false
+		_, sinkErr = metrics.NewGlobal(conf, sink)
+	})
+	if sinkErr != nil {
+		return nil, sinkErr
+	}
+	sock, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(sock); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msgf("Error serving the metrics on %s", address)
+		}
+	}()
+	return server, nil
+}
+
+// metricsInterceptors count the grpc calls of the server by method and code and measure their latency
+func metricsInterceptors() []grpc.ServerOption {
+	record := func(method string, start time.Time, err error) {
+		labels := []metrics.Label{{Name: "method", Value: method}}
+		metrics.MeasureSinceWithLabels([]string{"jet", "grpc", "latency"}, start, labels)
+		metrics.IncrCounterWithLabels([]string{"jet", "grpc", "requests"}, 1,
+			append(labels, metrics.Label{Name: "code", Value: status.Code(err).String()}))
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			start := time.Now()
+			res, err := handler(ctx, req)
+			record(info.FullMethod, start, err)
+			return res, err
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			start := time.Now()
+			err := handler(srv, stream)
+			record(info.FullMethod, start, err)
+			return err
+		}),
+	}
+}
+
+// reportMetrics updates the gauges of the raft group, the stores and the gossip of the shard until it stops, and counts
+// the leader changes
+func (s *Shard) reportMetrics() {
+	shard := []metrics.Label{{Name: "shard", Value: s.ShardId}}
+	observations := make(chan raft.Observation, 16)
+	observer := raft.NewObserver(observations, false, func(o *raft.Observation) bool {
+		_, ok := o.Data.(raft.LeaderObservation)
+		return ok
+	})
+	s.Raft.RegisterObserver(observer)
+	defer s.Raft.DeregisterObserver(observer)
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-observations:
+			metrics.IncrCounterWithLabels([]string{"jet", "raft", "leader_changes"}, 1, shard)
+		case <-ticker.C:
+			s.setGauges(shard)
+		}
+	}
+}
+
+func (s *Shard) setGauges(shard []metrics.Label) {
+	stats := s.Raft.Stats()
+	for _, stat := range []string{"commit_index", "applied_index", "last_log_index", "term", "num_peers"} {
+		value, err := strconv.ParseUint(stats[stat], 10, 64)
+		if err != nil {
+			continue
+		}
+		metrics.SetGaugeWithLabels([]string{"jet", "raft", stat}, float32(value), shard)
+	}
+	leader := float32(0)
+	if s.Raft.State() == raft.Leader {
+		leader = 1
+	}
+	metrics.SetGaugeWithLabels([]string{"jet", "raft", "is_leader"}, leader, shard)
+	for i, name := range []string{"meta", "messages"} {
+		lsm, vlog := s.stores[i].Size()
+		labels := append([]metrics.Label{{Name: "store", Value: name}}, shard...)
+		metrics.SetGaugeWithLabels([]string{"jet", "badger", "lsm_size"}, float32(lsm), labels)
+		metrics.SetGaugeWithLabels([]string{"jet", "badger", "vlog_size"}, float32(vlog), labels)
+	}
+	if s.MemberList != nil {
+		metrics.SetGaugeWithLabels([]string{"jet", "gossip", "members"}, float32(s.MemberList.NumMembers()), shard)
+		metrics.SetGaugeWithLabels([]string{"jet", "gossip", "health_score"}, float32(s.MemberList.GetHealthScore()), shard)
+	}
+}
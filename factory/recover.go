@@ -0,0 +1,169 @@
+package factory
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/application/fsm"
+	"github.com/Kapperchino/jet-stream/application/fsm/handlers"
+	"github.com/Kapperchino/jet-stream/controller"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotConfirmed is returned by RecoverRaft when it only printed the change it would make
+var ErrNotConfirmed = errors.New("the configuration is not rewritten without confirmation")
+
+// RecoverRaft rewrites the configuration of the raft group kept in dir, the logs, stable store and snapshots newRaft
+// creates, with raft.RecoverCluster semantics: the entries after the last snapshot are replayed into the state and the
+// log is compacted into a snapshot holding the new configuration. The node has to be stopped. It prints the current
+// and the new configuration to out and only writes with confirm. The shards keep their state in the Meta and Messages
+// badger stores under dataDir, the entries are applied to them. The controller state is kept in the snapshot
+func RecoverRaft(dir string, dataDir string, configuration raft.Configuration, isController bool, confirm bool, out io.Writer) error {
+	if _, err := os.Stat(filepath.Join(dir, "logs")); err != nil {
+		return fmt.Errorf("%s has no raft logs: %w", dir, err)
+	}
+	if !isController {
+		for _, store := range []string{"Meta", "Messages"} {
+			if _, err := os.Stat(filepath.Join(dataDir, store)); err != nil {
+				return fmt.Errorf("%s has no %s store of the shard: %w", dataDir, store, err)
+			}
+		}
+	}
+	logDb, err := NewBadger(filepath.Join(dir, "logs"), false)
+	if err != nil {
+		return fmt.Errorf("opening the logs of %s, is the node stopped: %w", dir, err)
+	}
+	defer logDb.Close()
+	stableDb, err := NewBadger(filepath.Join(dir, "stable"), false)
+	if err != nil {
+		return fmt.Errorf("opening the stable store of %s, is the node stopped: %w", dir, err)
+	}
+	defer stableDb.Close()
+	logs, err := NewBadgerLogStore(logDb)
+	if err != nil {
+		return fmt.Errorf("migrating the raft log keys of %s: %v", dir, err)
+	}
+	stable := BadgerLogStore{LogStore: stableDb}
+	snaps, err := raft.NewFileSnapshotStore(dir, 3, io.Discard)
+	if err != nil {
+		return fmt.Errorf(`raft.NewFileSnapshotStore(%q, ...): %v`, dir, err)
+	}
+
+	existing, err := raft.HasExistingState(logs, stable, snaps)
+	if err != nil {
+		return fmt.Errorf("raft.HasExistingState: %v", err)
+	}
+	if !existing {
+		return fmt.Errorf("%s has no raft state", dir)
+	}
+	current, lastIndex, err := ReadConfiguration(logs, snaps)
+	if err != nil {
+		return err
+	}
+	snapshotIndex, err := lastSnapshotIndex(snaps)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "raft state in %s, last index %d\n", dir, lastIndex)
+	_, _ = fmt.Fprintf(out, "%d entries after the snapshot at index %d are replayed into the state\n", lastIndex-snapshotIndex, snapshotIndex)
+	printConfiguration(out, "current configuration", current)
+	printConfiguration(out, "new configuration", configuration)
+	if !confirm {
+		return ErrNotConfirmed
+	}
+
+	logger := zerolog.New(os.Stderr)
+	var state raft.FSM
+	if isController {
+		state = controller.NewState(&logger)
+	} else {
+		meta, err := NewBadger(filepath.Join(dataDir, "Meta"), false)
+		if err != nil {
+			return fmt.Errorf("opening the Meta store of %s, is the node stopped: %w", dataDir, err)
+		}
+		defer meta.Close()
+		messages, err := NewBadger(filepath.Join(dataDir, "Messages"), false)
+		if err != nil {
+			return fmt.Errorf("opening the Messages store of %s, is the node stopped: %w", dataDir, err)
+		}
+		defer messages.Close()
+		state = &fsm.NodeState{
+			MetaStore:    meta,
+			MessageStore: messages,
+			HandlerMap:   handlers.InitHandlers(),
+			Logger:       &logger,
+		}
+	}
+	c := raft.DefaultConfig()
+	c.LocalID = "recover"
+	_, trans := raft.NewInmemTransport("")
+	if err := raft.RecoverCluster(c, state, logs, stable, snaps, trans, configuration); err != nil {
+		return fmt.Errorf("raft.RecoverCluster: %v", err)
+	}
+	_, _ = fmt.Fprintf(out, "rewrote the configuration of %s\n", dir)
+	return nil
+}
+
+// ReadConfiguration is the latest configuration of the raft state, the one of the last snapshot or of a configuration
+// entry logged after it, with the last index of the state
+func ReadConfiguration(logs raft.LogStore, snaps raft.SnapshotStore) (raft.Configuration, uint64, error) {
+	var configuration raft.Configuration
+	var lastIndex uint64
+	metas, err := snaps.List()
+	if err != nil {
+		return configuration, 0, fmt.Errorf("listing the snapshots: %v", err)
+	}
+	if len(metas) > 0 {
+		configuration = metas[0].Configuration
+		lastIndex = metas[0].Index
+	}
+	first, err := logs.FirstIndex()
+	if err != nil {
+		return configuration, 0, fmt.Errorf("reading the first index: %v", err)
+	}
+	last, err := logs.LastIndex()
+	if err != nil {
+		return configuration, 0, fmt.Errorf("reading the last index: %v", err)
+	}
+	if first <= lastIndex {
+		first = lastIndex + 1
+	}
+	for index := first; first > 0 && index <= last; index++ {
+		var entry raft.Log
+		if err := logs.GetLog(index, &entry); err != nil {
+			return configuration, 0, fmt.Errorf("reading the log at %d: %v", index, err)
+		}
+		if entry.Type == raft.LogConfiguration {
+			configuration = raft.DecodeConfiguration(entry.Data)
+		}
+	}
+	if last > lastIndex {
+		lastIndex = last
+	}
+	return configuration, lastIndex, nil
+}
+
+// lastSnapshotIndex is the index of the latest snapshot, 0 without one
+func lastSnapshotIndex(snaps raft.SnapshotStore) (uint64, error) {
+	metas, err := snaps.List()
+	if err != nil {
+		return 0, fmt.Errorf("listing the snapshots: %v", err)
+	}
+	if len(metas) == 0 {
+		return 0, nil
+	}
+	return metas[0].Index, nil
+}
+
+func printConfiguration(out io.Writer, title string, configuration raft.Configuration) {
+	_, _ = fmt.Fprintf(out, "%s:\n", title)
+	if len(configuration.Servers) == 0 {
+		_, _ = fmt.Fprintln(out, "  none")
+	}
+	for _, server := range configuration.Servers {
+		_, _ = fmt.Fprintf(out, "  %-20s %-8s %s\n", server.ID, server.Suffrage, server.Address)
+	}
+}
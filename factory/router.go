@@ -0,0 +1,110 @@
+package factory
+
+import (
+	"context"
+	"github.com/Kapperchino/jet-stream/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+// shardRouter serves a grpc service for every shard hosted by the node, calls are routed by the shard id in their
+// metadata. Calls without one go to the first shard, so clients of a single shard node keep working
+type shardRouter struct {
+	server *grpc.Server
+	// services maps the service name to the implementation of each shard
+	services     map[string]map[string]interface{}
+	defaultShard string
+	mutex        sync.RWMutex
+}
+
+func newShardRouter(server *grpc.Server) *shardRouter {
+	return &shardRouter{
+		server:   server,
+		services: map[string]map[string]interface{}{},
+	}
+}
+
+// register adds the implementation of the shard, the routed service is registered on the server for the first shard
+// so later shards can be added after the server started
+func (r *shardRouter) register(desc *grpc.ServiceDesc, shardId string, impl interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.defaultShard == "" {
+		r.defaultShard = shardId
+	}
+	impls, ok := r.services[desc.ServiceName]
+	if !ok {
+		impls = map[string]interface{}{}
+		r.services[desc.ServiceName] = impls
+		r.server.RegisterService(r.route(desc), impl)
+	}
+	impls[shardId] = impl
+}
+
+// remove stops routing calls to the shard, calls without a shard id go to another shard if it was the first
+func (r *shardRouter) remove(shardId string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, impls := range r.services {
+		delete(impls, shardId)
+	}
+	if r.defaultShard != shardId {
+		return
+	}
+	r.defaultShard = ""
+	for _, impls := range r.services {
+		for id := range impls {
+			if r.defaultShard == "" || id < r.defaultShard {
+				r.defaultShard = id
+			}
+		}
+	}
+}
+
+func (r *shardRouter) get(serviceName string, ctx context.Context) (interface{}, error) {
+	shardId := util.ShardIdFromContext(ctx)
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if shardId == "" {
+		shardId = r.defaultShard
+	}
+	impl, ok := r.services[serviceName][shardId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "shard %s is not hosted on this node", shardId)
+	}
+	return impl, nil
+}
+
+// route copies the service description with handlers that look up the implementation of the shard first
+func (r *shardRouter) route(desc *grpc.ServiceDesc) *grpc.ServiceDesc {
+	routed := *desc
+	routed.Methods = make([]grpc.MethodDesc, len(desc.Methods))
+	for i, method := range desc.Methods {
+		handler := method.Handler
+		routed.Methods[i] = grpc.MethodDesc{
+			MethodName: method.MethodName,
+			Handler: func(_ interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				impl, err := r.get(desc.ServiceName, ctx)
+				if err != nil {
+					return nil, err
+				}
+				return handler(impl, ctx, dec, interceptor)
+			},
+		}
+	}
+	routed.Streams = make([]grpc.StreamDesc, len(desc.Streams))
+	for i, stream := range desc.Streams {
+		handler := stream.Handler
+		routed.Streams[i] = stream
+		routed.Streams[i].Handler = func(_ interface{}, serverStream grpc.ServerStream) error {
+			impl, err := r.get(desc.ServiceName, serverStream.Context())
+			if err != nil {
+				return err
+			}
+			return handler(impl, serverStream)
+		}
+	}
+	return &routed
+}
@@ -0,0 +1,318 @@
+package factory
+
+import (
+	"context"
+	"fmt"
+	application "github.com/Kapperchino/jet-stream/application"
+	"github.com/Kapperchino/jet-stream/application/fsm"
+	"github.com/Kapperchino/jet-stream/application/fsm/handlers"
+	pb "github.com/Kapperchino/jet-stream/application/proto/proto"
+	cluster "github.com/Kapperchino/jet-stream/cluster"
+	clusterPb "github.com/Kapperchino/jet-stream/cluster/proto/proto"
+	"github.com/Kapperchino/jet-stream/controller"
+	"github.com/Kapperchino/jet-stream/raftadmin"
+	raftadminPb "github.com/Kapperchino/jet-stream/raftadmin/proto/proto"
+	"github.com/Kapperchino/jet-stream/transport"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/hashicorp/memberlist"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// leaveTimeout is how long a dropped shard waits for its leave to be gossiped
+const leaveTimeout = 5 * time.Second
+
+// Shard is a raft group hosted by the node
+type Shard struct {
+	ShardId    string
+	Raft       *raft.Raft
+	MemberList *memberlist.Memberlist
+	messageRpc *application.RpcInterface
+	stores     []*badger.DB
+	dirs       []string
+	stop       chan struct{}
+}
+
+// node holds what the shards hosted by one process share, they are served by the same grpc server and raft transport
+type node struct {
+	config    *JetConfig
+	mux       *transport.Mux
+	router    *shardRouter
+	forwarder *application.Forwarder
+	// authorizer checks the acls of every shard, nil when they are off
+	authorizer *application.Authorizer
+	// keyring encrypts the gossip of every shard, nil when it isn't encrypted
+	keyring *gossipKeyring
+	// dialOptions carry the credentials of the calls to the controllers
+	dialOptions []grpc.DialOption
+	stop        chan struct{}
+	shards      map[string]*Shard
+	// first is the first shard started, it keeps its data directly under the node name and the gossip address of
+	// the node
+	first string
+	mutex sync.Mutex
+}
+
+var _ cluster.ShardHost = &node{}
+
+// addShard starts the raft group of the shard with its own state, then joins it to the gossip cluster. The group is
+// bootstrapped with the servers, or with only this node when the config asks for it, otherwise the node joins it
+// through the leader
+func (n *node) addShard(shardId string, servers []raft.Server, gossipAddress string) (*Shard, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	jetConfig := n.config
+	dir := filepath.Join(jetConfig.NodeName, shardId)
+	//the other shards join the gossip of the first one when the node is the first of the cluster
+	rootNode := jetConfig.RootNode
+	if n.first == "" {
+		n.first = shardId
+		dir = jetConfig.NodeName
+	} else if rootNode == "" {
+		rootNode = jetConfig.GossipAddress
+	}
+	//a shard hosted without a gossip address binds a free port on the host of the node
+	if gossipAddress == "" {
+		host, _, err := net.SplitHostPort(jetConfig.GossipAddress)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the gossip address %q: %w", jetConfig.GossipAddress, err)
+		}
+		gossipAddress = net.JoinHostPort(host, "0")
+	}
+	output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "2006/01/02 15:04:05"}
+	output.FormatLevel = func(i interface{}) string {
+		return fmt.Sprintf("[%s] ", jetConfig.NodeName) + strings.ToUpper(fmt.Sprintf("[%-4s]", i))
+	}
+	output.FormatFieldName = func(i interface{}) string {
+		return fmt.Sprintf("%s:", i)
+	}
+	db, err := NewBadger(filepath.Join(jetConfig.BadgerDir, dir, "Meta"), jetConfig.InMemory)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := NewBadger(filepath.Join(jetConfig.BadgerDir, dir, "Messages"), jetConfig.InMemory)
+	if err != nil {
+		return nil, err
+	}
+	nodeLogger := log.Level(zerolog.TraceLevel).Output(output)
+	nodeState := &fsm.NodeState{
+		MetaStore:    db,
+		MessageStore: messages,
+		HandlerMap:   handlers.InitHandlers(),
+		Logger:       &nodeLogger,
+	}
+	raftDir := filepath.Join(jetConfig.RaftDir, dir)
+	//the node is advertised with its global address, the leaders of the shard are called on it
+	join := len(servers) == 0 && !jetConfig.bootstrapsAlone()
+	if len(servers) == 0 && !join {
+		servers = []raft.Server{{Suffrage: raft.Voter, ID: raft.ServerID(jetConfig.NodeName), Address: raft.ServerAddress(jetConfig.GlobalAdr)}}
+	}
+	r, raftStores, err := newRaft(jetConfig.NodeName, n.mux.Add(shardId), nodeState, raftDir, jetConfig.InMemory, servers, !join)
+	if err != nil {
+		n.mux.Remove(shardId)
+		return nil, err
+	}
+	messageRpc := application.RpcInterface{
+		NodeState:  nodeState,
+		Raft:       r,
+		Forwarder:  n.forwarder,
+		Authorizer: n.authorizer,
+		Quotas:     application.NewQuotaLimiter(nodeIdentities(n.config.Superusers, n.config.Auth, n.config.NodeToken)...),
+	}
+	clusterLog := log.Level(zerolog.TraceLevel).Output(output)
+	clusterRpc := &cluster.RpcInterface{
+		ClusterState: nil,
+		Raft:         r,
+		Logger:       &clusterLog,
+		Controllers:  jetConfig.Controllers,
+		Authorize: func(ctx context.Context) error {
+			return messageRpc.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN)
+		},
+	}
+	clusterRpc.ClusterState = cluster.InitClusterState(clusterRpc, jetConfig.NodeName, jetConfig.GlobalAdr, shardId, &clusterLog, r)
+	if jetConfig.ReadReplica {
+		clusterRpc.ClusterState.MarkReadReplica()
+	}
+	memberListener := cluster.InitClusterListener(clusterRpc.ClusterState)
+	var keyring *memberlist.Keyring
+	if n.keyring != nil {
+		keyring = n.keyring.keyring
+	}
+	memberList := NewMemberList(MakeConfig(jetConfig.NodeName, shardId, gossipAddress, memberListener, cluster.ClusterDelegate{
+		ClusterState: clusterRpc.ClusterState,
+	}, keyring, jetConfig.gossipTimings()), rootNode)
+	clusterRpc.MemberList = memberList
+	clusterRpc.ClusterState.Gossip.SetMemberList(memberList)
+	nodeState.ShardState = clusterRpc.ClusterState.CurShardState
+	nodeState.ClusterState = clusterRpc.ClusterState
+	n.router.register(&pb.MessageService_ServiceDesc, shardId, &messageRpc)
+	n.router.register(&clusterPb.ClusterMetaService_ServiceDesc, shardId, clusterRpc)
+	n.router.register(&raftadminPb.RaftAdmin_ServiceDesc, shardId, raftadmin.GetAuthorized(r, func(ctx context.Context, method string, readOnly bool) error {
+		if readOnly {
+			return messageRpc.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_READ)
+		}
+		return messageRpc.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN)
+	}))
+	shard := &Shard{
+		ShardId:    shardId,
+		Raft:       r,
+		MemberList: memberList,
+		messageRpc: &messageRpc,
+		stores:     append([]*badger.DB{db, messages}, raftStores...),
+		dirs:       []string{filepath.Join(jetConfig.BadgerDir, dir, "Meta"), filepath.Join(jetConfig.BadgerDir, dir, "Messages"), raftDir},
+		stop:       make(chan struct{}),
+	}
+	if len(jetConfig.Controllers) > 0 {
+		go application.SyncWithController(messageRpc, controller.NewClient(jetConfig.Controllers, n.dialOptions...), shard.stop)
+	}
+	if jetConfig.MetricsAddress != "" {
+		go shard.reportMetrics()
+	}
+	if join {
+		go n.joinShard(shard)
+	}
+	n.shards[shardId] = shard
+	return shard, nil
+}
+
+// authorizeAdmin checks that the caller may administer the cluster against the acls of a hosted shard, they are
+// replicated in all of them. Only the superusers may when the node hosts no shard
+func (n *node) authorizeAdmin(ctx context.Context) error {
+	if n.authorizer == nil {
+		return nil
+	}
+	n.mutex.Lock()
+	var messageRpc *application.RpcInterface
+	for _, shard := range n.shards {
+		messageRpc = shard.messageRpc
+		break
+	}
+	n.mutex.Unlock()
+	if messageRpc != nil {
+		return messageRpc.Authorize(ctx, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN)
+	}
+	return n.authorizer.Check(ctx, func() ([]*pb.Acl, error) {
+		return nil, nil
+	}, pb.AclResource_CLUSTER, "", pb.AclOperation_ADMIN)
+}
+
+// HostShard starts a shard that was created after the node started, like the new shard of a split
+func (n *node) HostShard(shardId string, members map[string]string, gossipAddress string) error {
+	n.mutex.Lock()
+	hosted := n.shards[shardId] != nil
+	n.mutex.Unlock()
+	if hosted {
+		return nil
+	}
+	if _, ok := members[n.config.NodeName]; !ok {
+		return fmt.Errorf("node %s is not a member of shard %s", n.config.NodeName, shardId)
+	}
+	var servers []raft.Server
+	for nodeId, address := range members {
+		servers = append(servers, raft.Server{
+			Suffrage: raft.Voter,
+			ID:       raft.ServerID(nodeId),
+			Address:  raft.ServerAddress(address),
+		})
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].ID < servers[j].ID
+	})
+	_, err := n.addShard(shardId, servers, gossipAddress)
+	return err
+}
+
+// DropShard stops the shard and deletes its data, a node left without shards keeps serving the node service so it
+// can host the next one
+func (n *node) DropShard(shardId string) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	shard := n.shards[shardId]
+	if shard == nil {
+		return nil
+	}
+	n.router.remove(shardId)
+	n.mux.Remove(shardId)
+	delete(n.shards, shardId)
+	shard.shutdown()
+	if n.config.InMemory {
+		return nil
+	}
+	for _, dir := range shard.dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecommissionShard hands the leadership of the shard over and has the new leader remove the node from the raft group
+// and the shard info, then leaves the gossip and stops the shard. The data is kept
+func (n *node) DecommissionShard(shardId string, force bool) error {
+	n.mutex.Lock()
+	shard := n.shards[shardId]
+	n.mutex.Unlock()
+	if shard == nil {
+		return fmt.Errorf("node %s doesn't host shard %s", n.config.NodeName, shardId)
+	}
+	transferLeadership(shard.Raft)
+	if shard.Raft.State() == raft.Leader {
+		return fmt.Errorf("node %s is still the leader of shard %s, no other voter took the leadership", n.config.NodeName, shardId)
+	}
+	if err := n.leaveShard(shard, force); err != nil {
+		return err
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.router.remove(shardId)
+	n.mux.Remove(shardId)
+	delete(n.shards, shardId)
+	shard.shutdown()
+	return nil
+}
+
+func (n *node) HostedShards() []string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	var res []string
+	for shardId := range n.shards {
+		res = append(res, shardId)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (n *node) shutdown() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for _, shard := range n.shards {
+		shard.shutdown()
+	}
+}
+
+// shutdown leaves the gossip before stopping so the other nodes drop the member right away
+func (s *Shard) shutdown() {
+	close(s.stop)
+	if s.MemberList != nil {
+		if err := s.MemberList.Leave(leaveTimeout); err != nil {
+			log.Warn().Err(err).Msgf("Error leaving the gossip of shard %s", s.ShardId)
+		}
+		s.MemberList.Shutdown()
+	}
+	s.Raft.Shutdown().Error()
+	//closing flushes the memtables of the stores to disk
+	for _, store := range s.stores {
+		if err := store.Close(); err != nil {
+			log.Warn().Err(err).Msgf("Error closing a store of shard %s", s.ShardId)
+		}
+	}
+}
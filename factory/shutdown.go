@@ -0,0 +1,130 @@
+package factory
+
+import (
+	"context"
+	"github.com/hashicorp/raft"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// drainPoll is how often a shutdown checks whether the calls in flight are done
+const drainPoll = 50 * time.Millisecond
+
+// transferTimeout is how long a leader waits to be replaced after handing its leadership over
+const transferTimeout = 5 * time.Second
+
+// callTracker counts the unary calls in flight so a shutdown can wait for them. The raft and watch streams are long
+// lived, they are cut once the unary calls are done
+type callTracker struct {
+	calls    atomic.Int64
+	stopping atomic.Bool
+}
+
+// errStopping is retriable, the clients go to another node of the shard
+var errStopping = status.Error(codes.Unavailable, "the node is shutting down")
+
+// keepServing are the calls still taken while the node stops, raft needs them to hand the leadership over
+var keepServing = []string{"/transport.RaftTransport/", "/grpc.health.v1.Health/"}
+
+func (t *callTracker) interceptor() grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if t.stopping.Load() && !isKeptServing(info.FullMethod) {
+			return nil, errStopping
+		}
+		t.calls.Add(1)
+		defer t.calls.Add(-1)
+		return handler(ctx, req)
+	})
+}
+
+func isKeptServing(method string) bool {
+	for _, prefix := range keepServing {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// stop rejects the calls that come after it
+func (t *callTracker) stop() {
+	t.stopping.Store(true)
+}
+
+// drain waits for the calls in flight to finish, it gives up when ctx is done
+func (t *callTracker) drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPoll)
+	defer ticker.Stop()
+	for t.calls.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Shutdown stops the node without failing the calls it accepted. The node reports NOT_SERVING and rejects the new
+// calls as unavailable so the clients retry elsewhere, hands the leadership of its raft groups over and waits for the
+// calls in flight, then it leaves the gossip and closes the stores like Kill. The calls still running when ctx is done
+// are cancelled
+func (s *Server) Shutdown(ctx context.Context) {
+	if s.health != nil {
+		s.health.Shutdown()
+	}
+	if s.calls != nil {
+		s.calls.stop()
+	}
+	for _, r := range s.rafts() {
+		transferLeadership(r)
+	}
+	if s.calls != nil {
+		if err := s.calls.drain(ctx); err != nil {
+			log.Warn().Msgf("Stopping with %d calls in flight: %v", s.calls.calls.Load(), err)
+		}
+	}
+	go s.Grpc.GracefulStop()
+	s.Kill()
+}
+
+// rafts are the raft groups of the node, one per hosted shard or the one of the controller
+func (s *Server) rafts() []*raft.Raft {
+	if s.node == nil {
+		return []*raft.Raft{s.Raft}
+	}
+	s.node.mutex.Lock()
+	defer s.node.mutex.Unlock()
+	var rafts []*raft.Raft
+	for _, shard := range s.node.shards {
+		rafts = append(rafts, shard.Raft)
+	}
+	return rafts
+}
+
+// transferLeadership hands the leadership to the most up-to-date voter, the writes then go to it while the node stops
+func transferLeadership(r *raft.Raft) {
+	if r.State() != raft.Leader {
+		return
+	}
+	future := r.GetConfiguration()
+	if err := future.Error(); err != nil || len(future.Configuration().Servers) < 2 {
+		return
+	}
+	if err := r.LeadershipTransfer().Error(); err != nil {
+		log.Warn().Err(err).Msg("Error transferring the leadership")
+		return
+	}
+	//the transfer returns once the target is told to run, the node steps down when it wins
+	deadline := time.Now().Add(transferTimeout)
+	for r.State() == raft.Leader && time.Now().Before(deadline) {
+		time.Sleep(drainPoll)
+	}
+	_, leader := r.LeaderWithID()
+	log.Info().Msgf("Transferred the leadership to %s", leader)
+}
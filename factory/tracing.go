@@ -0,0 +1,49 @@
+package factory
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// TracingConfig exports the spans of the node to an otlp collector
+type TracingConfig struct {
+	// Endpoint is the host:port of the grpc receiver of the collector, the node uses the tracer provider and the
+	// propagator set by the application embedding it when empty
+	Endpoint string
+	// Insecure sends the spans without tls
+	Insecure bool
+	// SampleRatio is the share of the traces started by the node that are kept, the calls of traced clients follow
+	// their decision
+	SampleRatio float64
+}
+
+// setupTracing sets the global tracer provider and the w3c propagator, the returned function flushes the spans
+func setupTracing(config *TracingConfig, nodeName string) (func(context.Context) error, error) {
+	if config.Endpoint == "" {
+		return nil, nil
+	}
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.Endpoint)}
+	if config.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName("jet-stream"),
+			semconv.ServiceInstanceID(nodeName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
@@ -3,16 +3,20 @@ module github.com/Kapperchino/jet-stream
 go 1.20
 
 require (
+	github.com/Kapperchino/jet-stream/config v0.0.0-20230225202306-4020fc0a51bf
 	github.com/Kapperchino/jet-stream/factory v0.0.0-20230225202306-4020fc0a51bf
+	github.com/hashicorp/raft v1.3.11
 	github.com/rs/zerolog v1.29.0
 )
 
 require (
 	github.com/Kapperchino/jet-stream/application v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/application/proto v0.0.0-20230225202306-4020fc0a51bf // indirect
+	github.com/Kapperchino/jet-stream/auth v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/cluster v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/cluster/proto v0.0.0-20230225202306-4020fc0a51bf // indirect
-	github.com/Kapperchino/jet-stream/config v0.0.0-20230225202306-4020fc0a51bf // indirect
+	github.com/Kapperchino/jet-stream/controller v0.0.0-20230225202306-4020fc0a51bf // indirect
+	github.com/Kapperchino/jet-stream/controller/proto v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/leader-rpc v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/raftadmin v0.0.0-20230225202306-4020fc0a51bf // indirect
 	github.com/Kapperchino/jet-stream/transport v0.0.0-20230225202306-4020fc0a51bf // indirect
@@ -41,7 +45,6 @@ require (
 	github.com/hashicorp/go-sockaddr v1.0.0 // indirect
 	github.com/hashicorp/golang-lru v0.5.1 // indirect
 	github.com/hashicorp/memberlist v0.5.0 // indirect
-	github.com/hashicorp/raft v1.3.11 // indirect
 	github.com/klauspost/compress v1.15.9 // indirect
 	github.com/miekg/dns v1.1.26 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
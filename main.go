@@ -1,67 +1,239 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"github.com/Kapperchino/jet-stream/config"
 	"github.com/Kapperchino/jet-stream/factory"
 	"github.com/rs/zerolog/log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 var (
+	configFile = flag.String("config", "", "Yaml config file of the node, the other flags are ignored when it is set. JET_<SECTION>_<FIELD> environment variables override its fields and SIGHUP reloads its runtime section")
+	logLevel   = flag.String("log_level", "debug", "Lowest level logged: trace, debug, info, warn or error")
+	devMode    = flag.Bool("dev_mode", false, "Store the messages as json, the stores can't be read once it changed")
+
+	autoRemove          = flag.Bool("auto_remove", true, "Let the leader of a shard remove the peers it can't reach, as long as the reachable voters keep a quorum")
+	autoRemoveAfter     = flag.Duration("auto_remove_after", 10*time.Second, "Time a peer is unreachable before the leader removes it")
+	autoRemoveMinVoters = flag.Int("auto_remove_min_voters", 1, "Fewest voters the automatic removal shrinks a shard to")
+
+	shutdownTimeout = flag.Duration("shutdown_timeout", 30*time.Second, "Time a node stopping on SIGTERM waits for the calls in flight after handing its leadership over")
+
 	myAddr        = flag.String("address", "", "Where this node is hosted in a global context")
 	hostAddr      = flag.String("hostedAddr", "", "Where this node is hosted in a local context")
-	gossipAddress = flag.String("gossip_address", "", "address for gossip")
+	gossipAddress = flag.String("gossip_address", "", "address for gossip, comma separated addresses gossip for each shard of --shard_id")
 	raftId        = flag.String("raft_id", "", "Node id used by Raft")
 
 	raftDir  = flag.String("raft_data_dir", "data/", "Raft data dir")
 	dataDir  = flag.String("data_dir", "", "Local store for the partitions")
 	rootNode = flag.String("root_node", "", "Root node for gossip membership")
-	shardId  = flag.String("shard_id", "", "Shard id for the shard group")
+	shardId  = flag.String("shard_id", "", "Shard id for the shard group, comma separated ids host several shards on the node")
+
+	gossipKey         = flag.String("gossip_key", "", "Comma separated base64 keys encrypting the gossip, the first one is used to encrypt")
+	gossipKeyringFile = flag.String("gossip_keyring_file", "", "File keeping the gossip keys installed at runtime, it takes over gossip_key once written")
+
+	tlsCert       = flag.String("tls_cert", "", "PEM certificate of the node, grpc is plaintext without it. The files are reloaded when they change")
+	tlsKey        = flag.String("tls_key", "", "PEM key of the tls certificate")
+	tlsCA         = flag.String("tls_ca", "", "PEM certificates verifying the other nodes and the clients, the system roots are used if empty")
+	tlsClientAuth = flag.String("tls_client_auth", "none", "Client certificates the node asks for: none, request (verified if sent) or require")
+	tlsServerName = flag.String("tls_server_name", "", "Name the certificates of the other nodes are checked against instead of their host")
+
+	authTokenFile   = flag.String("auth_token_file", "", "File of static tokens that authenticate callers, one name:token per line")
+	authJWKSFile    = flag.String("auth_jwks_file", "", "JWKS file with the keys of the jwt bearer tokens, it is reloaded when it changes")
+	authJWTIssuer   = flag.String("auth_jwt_issuer", "", "Issuer the jwt bearer tokens need")
+	authJWTAudience = flag.String("auth_jwt_audience", "", "Audience the jwt bearer tokens need")
+	authMTLS        = flag.Bool("auth_mtls", false, "Authenticate callers with their verified tls client certificate")
+	nodeToken       = flag.String("node_token", "", "Token the node sends to the other nodes and the controllers when they check callers")
+	authACL         = flag.Bool("auth_acl", false, "Check the authenticated callers against the acls of the shards")
+	authSuperusers  = flag.String("auth_superusers", "", "Comma separated identities that skip the acls, the node token's one always does")
+
+	forwardWrites = flag.Bool("forward_writes", true, "Forward writes received by followers to the shard leader")
+
+	metricsAddress = flag.String("metrics_address", "", "Address serving the prometheus metrics on /metrics, they aren't collected if empty")
+
+	tracingEndpoint    = flag.String("tracing_endpoint", "", "Host:port of the otlp grpc collector the spans are exported to, nothing is traced if empty")
+	tracingInsecure    = flag.Bool("tracing_insecure", false, "Export the spans to the collector without tls")
+	tracingSampleRatio = flag.Float64("tracing_sample_ratio", 1, "Share of the traces started by the node that are kept, traced clients decide for their calls")
+
+	readReplica = flag.Bool("read_replica", false, "Join the shards as a raft nonvoter that replicates them and serves reads without counting in the quorum, it needs root_node")
+
+	bootstrap       = flag.Bool("bootstrap", false, "Start the shards without raft state as single node groups instead of joining their leaders, a node without root_node always does")
+	bootstrapExpect = flag.Int("bootstrap_expect", 0, "Start the shards without raft state once this many of their voters are in the gossip")
+
+	controllerMode = flag.Bool("controller", false, "Run this node as a metadata controller instead of a shard member")
+	controllers    = flag.String("controllers", "", "Comma separated addresses of the metadata controllers")
+
+	balance          = flag.Bool("balance", false, "Let the controller move partitions to even out the shard load")
+	balanceDryRun    = flag.Bool("balance_dry_run", false, "Only log the moves the balancer would make")
+	balanceInterval  = flag.Duration("balance_interval", time.Minute, "Time between two balancing rounds")
+	balanceMaxMoves  = flag.Int("balance_max_moves", 1, "Number of partition moves the balancer runs at once")
+	balanceThreshold = flag.Float64("balance_threshold", 0.1, "Load score difference between shards the balancer tolerates")
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "recover" {
+		os.Exit(runRecover(os.Args[2:]))
+	}
 	flag.Parse()
-	if *raftId == "" {
-		name := os.Getenv("HOSTNAME")
-		if name == "" {
-			log.Fatal().Msg("Cannot have null raftid")
+	var file *config.File
+	var err error
+	if *configFile != "" {
+		file, err = config.Load(*configFile)
+	} else {
+		file = fromFlags()
+		err = file.Validate()
+	}
+	if err != nil {
+		log.Fatal().Msgf("Invalid config: %v", err)
+	}
+	settings, err := file.Runtime.Settings()
+	if err != nil {
+		log.Fatal().Msgf("Invalid config: %v", err)
+	}
+	config.Set(settings)
+	config.SetDevMode(file.Node.DevMode)
+	if *configFile != "" {
+		go reloadOnHangup(*configFile, file)
+	}
+	channel := make(chan *factory.Server, 5)
+	if file.Node.Controller {
+		controllerConfig, err := factory.NewControllerConfig(file)
+		if err != nil {
+			log.Fatal().Msgf("Invalid config: %v", err)
+		}
+		controllerConfig.Server = channel
+		go factory.SetupController(controllerConfig)
+	} else {
+		jetConfig, err := factory.NewJetConfig(file)
+		if err != nil {
+			log.Fatal().Msgf("Invalid config: %v", err)
 		}
-		*raftId = name
+		jetConfig.Server = channel
+		go factory.SetupServer(jetConfig)
 	}
-	if *myAddr == "" {
-		addr := os.Getenv("POD_IP")
-		if addr == "" {
-			log.Fatal().Msg("Cannot have null myAddr")
+	shutdownOnTerm(<-channel, file.Node.ShutdownTimeout)
+}
+
+// shutdownOnTerm blocks until SIGTERM or SIGINT, then stops the node gracefully. A second signal kills it
+func shutdownOnTerm(server *factory.Server, timeout time.Duration) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	<-signals
+	log.Info().Msgf("Shutting down, waiting up to %s for the calls in flight", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		<-signals
+		log.Warn().Msg("Killing the node")
+		cancel()
+	}()
+	server.Shutdown(ctx)
+	log.Info().Msg("Shut down")
+}
+
+// reloadOnHangup applies the runtime section of the config file again on every SIGHUP
+func reloadOnHangup(path string, running *config.File) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		restart, err := config.Reload(path, running)
+		if err != nil {
+			log.Error().Msgf("Cannot reload the config: %v", err)
+			continue
+		}
+		log.Info().Msgf("Reloaded the runtime settings of %s", path)
+		if len(restart) > 0 {
+			log.Warn().Msgf("The changes to %s only apply after a restart", strings.Join(restart, ", "))
 		}
-		*myAddr = addr + ":8080"
 	}
-	if *hostAddr == "" {
-		*hostAddr = "0.0.0.0:8080"
+}
+
+// fromFlags builds the config of the node from the flags, the name and the addresses default to the pod's
+func fromFlags() *config.File {
+	file := config.Default()
+	file.Node = config.Node{
+		Name:            *raftId,
+		Address:         *myAddr,
+		ListenAddress:   *hostAddr,
+		DataDir:         *dataDir,
+		RaftDir:         *raftDir,
+		Shards:          splitList(*shardId),
+		Controller:      *controllerMode,
+		ReadReplica:     *readReplica,
+		Bootstrap:       *bootstrap,
+		BootstrapExpect: *bootstrapExpect,
+		Controllers:     splitList(*controllers),
+		ForwardWrites:   *forwardWrites,
+		MaxMessageSize:  config.DefaultMaxMessageSize,
+		DevMode:         *devMode,
+		ShutdownTimeout: *shutdownTimeout,
 	}
-	if *gossipAddress == "" {
-		addr := os.Getenv("POD_IP")
-		if addr == "" {
-			log.Fatal().Msg("Cannot have null myAddr")
-		}
-		*gossipAddress = addr + ":8081"
+	if file.Node.Name == "" {
+		file.Node.Name = os.Getenv("HOSTNAME")
 	}
-	if *shardId == "" {
-		log.Fatal().Msgf("Cannot have null shardId")
+	if file.Node.Address == "" && os.Getenv("POD_IP") != "" {
+		file.Node.Address = os.Getenv("POD_IP") + ":8080"
 	}
-	if *dataDir == "" {
-		log.Fatal().Msgf("Cannot have null data_dir")
+	if file.Node.ListenAddress == "" {
+		file.Node.ListenAddress = "0.0.0.0:8080"
 	}
-	channel := make(chan *factory.Server, 5)
-	factory.SetupServer(&factory.JetConfig{
-		HostAddr:      *hostAddr,
-		BadgerDir:     *dataDir,
-		RaftDir:       *raftDir,
-		GlobalAdr:     *myAddr,
-		NodeName:      *raftId,
-		GossipAddress: *gossipAddress,
-		RootNode:      *rootNode,
-		Server:        channel,
-		ShardId:       *shardId,
-		InMemory:      false,
-	})
+	if gossipAddresses := splitList(*gossipAddress); len(gossipAddresses) > 0 {
+		file.Gossip.Address = gossipAddresses[0]
+		file.Gossip.ShardAddresses = gossipAddresses[1:]
+	}
+	if file.Gossip.Address == "" && os.Getenv("POD_IP") != "" {
+		file.Gossip.Address = os.Getenv("POD_IP") + ":8081"
+	}
+	file.Gossip.RootNode = *rootNode
+	file.Gossip.Keys = splitList(*gossipKey)
+	file.Gossip.KeyringFile = *gossipKeyringFile
+	file.TLS = config.TLS{
+		Cert:       *tlsCert,
+		Key:        *tlsKey,
+		CA:         *tlsCA,
+		ClientAuth: *tlsClientAuth,
+		ServerName: *tlsServerName,
+	}
+	file.Auth = config.Auth{
+		TokenFile:   *authTokenFile,
+		JWKSFile:    *authJWKSFile,
+		JWTIssuer:   *authJWTIssuer,
+		JWTAudience: *authJWTAudience,
+		MTLS:        *authMTLS,
+		NodeToken:   *nodeToken,
+		ACL:         *authACL,
+		Superusers:  splitList(*authSuperusers),
+	}
+	file.Metrics.Address = *metricsAddress
+	file.Tracing = config.Tracing{
+		Endpoint:    *tracingEndpoint,
+		Insecure:    *tracingInsecure,
+		SampleRatio: *tracingSampleRatio,
+	}
+	file.Balancer = config.Balancer{
+		Enabled:   *balance,
+		DryRun:    *balanceDryRun,
+		Interval:  *balanceInterval,
+		MaxMoves:  *balanceMaxMoves,
+		Threshold: *balanceThreshold,
+	}
+	file.Runtime.LogLevel = *logLevel
+	file.Runtime.Removal = config.RemovalPolicy{
+		Enabled:   *autoRemove,
+		After:     *autoRemoveAfter,
+		MinVoters: *autoRemoveMinVoters,
+	}
+	return file
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
 }
@@ -14,14 +14,43 @@ import (
 )
 
 type admin struct {
-	r *raft.Raft
+	r         *raft.Raft
+	authorize Authorizer
 	pb.UnimplementedRaftAdminServer
 }
 
+// Authorizer is asked before every call if the caller may use the method, readOnly is set for the methods that only
+// describe the raft group
+type Authorizer func(ctx context.Context, method string, readOnly bool) error
+
+// readOnly are the methods that don't change the raft group
+var readOnly = map[string]bool{
+	"AppliedIndex":     true,
+	"Await":            true,
+	"GetConfiguration": true,
+	"LastContact":      true,
+	"LastIndex":        true,
+	"Leader":           true,
+	"State":            true,
+	"Stats":            true,
+}
+
 func Get(r *raft.Raft) pb.RaftAdminServer {
 	return &admin{r: r}
 }
 
+// GetAuthorized returns a server that checks the callers with authorize first
+func GetAuthorized(r *raft.Raft, authorize Authorizer) pb.RaftAdminServer {
+	return &admin{r: r, authorize: authorize}
+}
+
+func (a *admin) check(ctx context.Context, method string) error {
+	if a.authorize == nil {
+		return nil
+	}
+	return a.authorize(ctx, method, readOnly[method])
+}
+
 func Register(s *grpc.Server, r *raft.Raft) {
 	pb.RegisterRaftAdminServer(s, Get(r))
 }
@@ -54,6 +83,9 @@ func toFuture(f raft.Future) (*pb.Future, error) {
 }
 
 func (a *admin) Await(ctx context.Context, req *pb.Future) (*pb.AwaitResponse, error) {
+	if err := a.check(ctx, "Await"); err != nil {
+		return nil, err
+	}
 	mtx.Lock()
 	f, ok := operations[req.GetOperationToken()]
 	mtx.Unlock()
@@ -76,6 +108,9 @@ func (a *admin) Await(ctx context.Context, req *pb.Future) (*pb.AwaitResponse, e
 }
 
 func (a *admin) Forget(ctx context.Context, req *pb.Future) (*pb.ForgetResponse, error) {
+	if err := a.check(ctx, "Forget"); err != nil {
+		return nil, err
+	}
 	mtx.Lock()
 	delete(operations, req.GetOperationToken())
 	mtx.Unlock()
@@ -83,32 +118,53 @@ func (a *admin) Forget(ctx context.Context, req *pb.Future) (*pb.ForgetResponse,
 }
 
 func (a *admin) AddNonvoter(ctx context.Context, req *pb.AddNonvoterRequest) (*pb.Future, error) {
+	if err := a.check(ctx, "AddNonvoter"); err != nil {
+		return nil, err
+	}
 	return toFuture(a.r.AddNonvoter(raft.ServerID(req.GetId()), raft.ServerAddress(req.GetAddress()), req.GetPreviousIndex(), timeout(ctx)))
 }
 
 func (a *admin) AddVoter(ctx context.Context, req *pb.AddVoterRequest) (*pb.Future, error) {
+	if err := a.check(ctx, "AddVoter"); err != nil {
+		return nil, err
+	}
 	return toFuture(a.r.AddVoter(raft.ServerID(req.GetId()), raft.ServerAddress(req.GetAddress()), req.GetPreviousIndex(), timeout(ctx)))
 }
 
 func (a *admin) AppliedIndex(ctx context.Context, req *pb.AppliedIndexRequest) (*pb.AppliedIndexResponse, error) {
+	if err := a.check(ctx, "AppliedIndex"); err != nil {
+		return nil, err
+	}
 	return &pb.AppliedIndexResponse{
 		Index: a.r.AppliedIndex(),
 	}, nil
 }
 
 func (a *admin) ApplyLog(ctx context.Context, req *pb.ApplyLogRequest) (*pb.Future, error) {
+	if err := a.check(ctx, "ApplyLog"); err != nil {
+		return nil, err
+	}
 	return toFuture(a.r.ApplyLog(raft.Log{Data: req.GetData(), Extensions: req.GetExtensions()}, timeout(ctx)))
 }
 
 func (a *admin) Barrier(ctx context.Context, req *pb.BarrierRequest) (*pb.Future, error) {
+	if err := a.check(ctx, "Barrier"); err != nil {
+		return nil, err
+	}
 	return toFuture(a.r.Barrier(timeout(ctx)))
 }
 
 func (a *admin) DemoteVoter(ctx context.Context, req *pb.DemoteVoterRequest) (*pb.Future, error) {
+	if err := a.check(ctx, "DemoteVoter"); err != nil {
+		return nil, err
+	}
 	return toFuture(a.r.DemoteVoter(raft.ServerID(req.GetId()), req.GetPreviousIndex(), timeout(ctx)))
 }
 
 func (a *admin) GetConfiguration(ctx context.Context, req *pb.GetConfigurationRequest) (*pb.GetConfigurationResponse, error) {
+	if err := a.check(ctx, "GetConfiguration"); err != nil {
+		return nil, err
+	}
 	f := a.r.GetConfiguration()
 	if err := f.Error(); err != nil {
 		return nil, err
@@ -135,6 +191,9 @@ func (a *admin) GetConfiguration(ctx context.Context, req *pb.GetConfigurationRe
 }
 
 func (a *admin) LastContact(ctx context.Context, req *pb.LastContactRequest) (*pb.LastContactResponse, error) {
+	if err := a.check(ctx, "LastContact"); err != nil {
+		return nil, err
+	}
 	t := a.r.LastContact()
 	return &pb.LastContactResponse{
 		UnixNano: t.UnixNano(),
@@ -142,12 +201,18 @@ func (a *admin) LastContact(ctx context.Context, req *pb.LastContactRequest) (*p
 }
 
 func (a *admin) LastIndex(ctx context.Context, req *pb.LastIndexRequest) (*pb.LastIndexResponse, error) {
+	if err := a.check(ctx, "LastIndex"); err != nil {
+		return nil, err
+	}
 	return &pb.LastIndexResponse{
 		Index: a.r.LastIndex(),
 	}, nil
 }
 
 func (a *admin) Leader(ctx context.Context, req *pb.LeaderRequest) (*pb.LeaderResponse, error) {
+	if err := a.check(ctx, "Leader"); err != nil {
+		return nil, err
+	}
 	leader, _ := a.r.LeaderWithID()
 	return &pb.LeaderResponse{
 		Address: string(leader),
@@ -155,26 +220,44 @@ func (a *admin) Leader(ctx context.Context, req *pb.LeaderRequest) (*pb.LeaderRe
 }
 
 func (a *admin) LeadershipTransfer(ctx context.Context, req *pb.LeadershipTransferRequest) (*pb.Future, error) {
+	if err := a.check(ctx, "LeadershipTransfer"); err != nil {
+		return nil, err
+	}
 	return toFuture(a.r.LeadershipTransfer())
 }
 
 func (a *admin) LeadershipTransferToServer(ctx context.Context, req *pb.LeadershipTransferToServerRequest) (*pb.Future, error) {
+	if err := a.check(ctx, "LeadershipTransferToServer"); err != nil {
+		return nil, err
+	}
 	return toFuture(a.r.LeadershipTransferToServer(raft.ServerID(req.GetId()), raft.ServerAddress(req.GetAddress())))
 }
 
 func (a *admin) RemoveServer(ctx context.Context, req *pb.RemoveServerRequest) (*pb.Future, error) {
+	if err := a.check(ctx, "RemoveServer"); err != nil {
+		return nil, err
+	}
 	return toFuture(a.r.RemoveServer(raft.ServerID(req.GetId()), req.GetPreviousIndex(), timeout(ctx)))
 }
 
 func (a *admin) Shutdown(ctx context.Context, req *pb.ShutdownRequest) (*pb.Future, error) {
+	if err := a.check(ctx, "Shutdown"); err != nil {
+		return nil, err
+	}
 	return toFuture(a.r.Shutdown())
 }
 
 func (a *admin) Snapshot(ctx context.Context, req *pb.SnapshotRequest) (*pb.Future, error) {
+	if err := a.check(ctx, "Snapshot"); err != nil {
+		return nil, err
+	}
 	return toFuture(a.r.Snapshot())
 }
 
 func (a *admin) State(ctx context.Context, req *pb.StateRequest) (*pb.StateResponse, error) {
+	if err := a.check(ctx, "State"); err != nil {
+		return nil, err
+	}
 	switch s := a.r.State(); s {
 	case raft.Follower:
 		return &pb.StateResponse{State: pb.StateResponse_FOLLOWER}, nil
@@ -190,6 +273,9 @@ func (a *admin) State(ctx context.Context, req *pb.StateRequest) (*pb.StateRespo
 }
 
 func (a *admin) Stats(ctx context.Context, req *pb.StatsRequest) (*pb.StatsResponse, error) {
+	if err := a.check(ctx, "Stats"); err != nil {
+		return nil, err
+	}
 	ret := &pb.StatsResponse{}
 	ret.Stats = map[string]string{}
 	for k, v := range a.r.Stats() {
@@ -199,5 +285,8 @@ func (a *admin) Stats(ctx context.Context, req *pb.StatsRequest) (*pb.StatsRespo
 }
 
 func (a *admin) VerifyLeader(ctx context.Context, req *pb.VerifyLeaderRequest) (*pb.Future, error) {
+	if err := a.check(ctx, "VerifyLeader"); err != nil {
+		return nil, err
+	}
 	return toFuture(a.r.VerifyLeader())
 }
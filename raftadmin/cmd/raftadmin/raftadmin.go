@@ -13,6 +13,8 @@ import (
 	pb "github.com/Kapperchino/jet-stream/raftadmin/proto/proto"
 	"github.com/iancoleman/strcase"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/reflect/protoreflect"
 
@@ -73,6 +75,9 @@ func do() error {
 	methods := pb.File_raftadmin_proto.Services().ByName("RaftAdmin").Methods()
 	leader := flag.Bool("leader", false, "Whether to dial to the leader (requires https://github.com/Jille/raft-grpc-leader-rpc)")
 	healthCheckService := flag.String("health_check_service", "quis.RaftLeader", "Which gRPC service to health check when searching for the leader")
+	tlsCA := flag.String("tls_ca", "", "Dial with TLS, verifying the server with the PEM certificates in this file")
+	tlsServerName := flag.String("tls_server_name", "", "Name the server certificate is checked against instead of the target host")
+	token := flag.String("token", "", "Bearer token sent with the calls when the server checks callers")
 	flag.Parse()
 
 	if flag.NArg() < 2 {
@@ -138,7 +143,18 @@ func do() error {
 	if *leader {
 		o = grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"healthCheckConfig": {"serviceName": "%s"}, "loadBalancingConfig": [ { "round_robin": {} } ]}`, *healthCheckService))
 	}
-	conn, err := grpc.Dial(target, grpc.WithInsecure(), grpc.WithBlock(), o)
+	creds := grpc.WithInsecure()
+	if *tlsCA != "" {
+		tlsCreds, err := credentials.NewClientTLSFromFile(*tlsCA, *tlsServerName)
+		if err != nil {
+			return err
+		}
+		creds = grpc.WithTransportCredentials(tlsCreds)
+	}
+	if *token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+*token)
+	}
+	conn, err := grpc.Dial(target, creds, grpc.WithBlock(), o)
 	if err != nil {
 		return err
 	}
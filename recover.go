@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/Kapperchino/jet-stream/factory"
+	"github.com/hashicorp/raft"
+	"os"
+	"strings"
+)
+
+// runRecover is the offline `jet recover` command, it rewrites the raft configuration of a group that lost its quorum
+// and returns the exit code
+func runRecover(args []string) int {
+	flags := flag.NewFlagSet("recover", flag.ContinueOnError)
+	dir := flags.String("dir", "", "Raft directory of the group, raft_data_dir/<node> for the first shard of the node, raft_data_dir/<node>/<shard> for the others")
+	dataDir := flags.String("data_dir", "", "Store directory of the shard, data_dir/<node> for the first shard of the node, data_dir/<node>/<shard> for the others, the entries after the last snapshot are applied to it")
+	peers := flags.String("peers", "", "Comma separated id=address voters of the new configuration")
+	readReplicas := flags.String("read_replicas", "", "Comma separated id=address nonvoters of the new configuration")
+	isController := flags.Bool("controller", false, "The directory is the one of a metadata controller, its state is replayed into the new snapshot")
+	confirm := flags.Bool("confirm", false, "Rewrite the configuration, without it the change is only printed")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(flags.Output(), "Usage: jet recover --dir <raft dir> [--data_dir <store dir>] --peers id=address,... [--read_replicas id=address,...] [--controller] [--confirm]")
+		_, _ = fmt.Fprintln(flags.Output(), "Rewrites the raft configuration of a stopped node, run it with the same peers on every node left in the group")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *dir == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "--dir is required")
+		return 2
+	}
+	if *dataDir == "" && !*isController {
+		_, _ = fmt.Fprintln(os.Stderr, "--data_dir is required for a shard")
+		return 2
+	}
+	configuration, err := recoveryConfiguration(*peers, *readReplicas)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	err = factory.RecoverRaft(*dir, *dataDir, configuration, *isController, *confirm, os.Stdout)
+	if errors.Is(err, factory.ErrNotConfirmed) {
+		_, _ = fmt.Fprintln(os.Stderr, "Nothing was written, run it again with --confirm to apply the new configuration")
+		return 1
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// recoveryConfiguration builds the configuration from the id=address lists of the voters and the nonvoters
+func recoveryConfiguration(voters string, nonvoters string) (raft.Configuration, error) {
+	var configuration raft.Configuration
+	if voters == "" {
+		return configuration, errors.New("--peers needs at least one voter")
+	}
+	seen := map[string]bool{}
+	for _, list := range []struct {
+		peers    string
+		suffrage raft.ServerSuffrage
+	}{{voters, raft.Voter}, {nonvoters, raft.Nonvoter}} {
+		for _, peer := range splitList(list.peers) {
+			id, address, found := strings.Cut(peer, "=")
+			if !found || id == "" || address == "" {
+				return configuration, fmt.Errorf("peer %q is not id=address", peer)
+			}
+			if seen[id] {
+				return configuration, fmt.Errorf("peer %s is listed twice", id)
+			}
+			seen[id] = true
+			configuration.Servers = append(configuration.Servers, raft.Server{
+				Suffrage: list.suffrage,
+				ID:       raft.ServerID(id),
+				Address:  raft.ServerAddress(address),
+			})
+		}
+	}
+	return configuration, nil
+}
@@ -12,12 +12,25 @@ import (
 
 type gRPCAPI struct {
 	manager *Manager
+	// mux routes the RPCs to the manager of their shard instead, when set
+	mux *Mux
 
 	// "Unsafe" to ensure compilation fails if new methods are added but not implemented
 	pb.UnsafeRaftTransportServer
 }
 
-func (g gRPCAPI) handleRPC(command interface{}, data io.Reader) (interface{}, error) {
+func (g gRPCAPI) getManager(ctx context.Context) (*Manager, error) {
+	if g.mux == nil {
+		return g.manager, nil
+	}
+	return g.mux.get(ctx)
+}
+
+func (g gRPCAPI) handleRPC(ctx context.Context, command interface{}, data io.Reader) (interface{}, error) {
+	manager, err := g.getManager(ctx)
+	if err != nil {
+		return nil, err
+	}
 	ch := make(chan raft.RPCResponse, 1)
 	rpc := raft.RPC{
 		Command:  command,
@@ -25,16 +38,16 @@ func (g gRPCAPI) handleRPC(command interface{}, data io.Reader) (interface{}, er
 		Reader:   data,
 	}
 	if isHeartbeat(command) {
-		// We can take the fast path and use the heartbeat callback and skip the queue in g.manager.rpcChan.
-		g.manager.heartbeatFuncMtx.Lock()
-		fn := g.manager.heartbeatFunc
-		g.manager.heartbeatFuncMtx.Unlock()
+		// We can take the fast path and use the heartbeat callback and skip the queue in manager.rpcChan.
+		manager.heartbeatFuncMtx.Lock()
+		fn := manager.heartbeatFunc
+		manager.heartbeatFuncMtx.Unlock()
 		if fn != nil {
 			fn(rpc)
 			goto wait
 		}
 	}
-	g.manager.rpcChan <- rpc
+	manager.rpcChan <- rpc
 wait:
 	resp := <-ch
 	if resp.Error != nil {
@@ -44,7 +57,7 @@ wait:
 }
 
 func (g gRPCAPI) AppendEntries(ctx context.Context, req *pb.AppendEntriesRequest) (*pb.AppendEntriesResponse, error) {
-	resp, err := g.handleRPC(decodeAppendEntriesRequest(req), nil)
+	resp, err := g.handleRPC(ctx, decodeAppendEntriesRequest(req), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +65,7 @@ func (g gRPCAPI) AppendEntries(ctx context.Context, req *pb.AppendEntriesRequest
 }
 
 func (g gRPCAPI) RequestVote(ctx context.Context, req *pb.RequestVoteRequest) (*pb.RequestVoteResponse, error) {
-	resp, err := g.handleRPC(decodeRequestVoteRequest(req), nil)
+	resp, err := g.handleRPC(ctx, decodeRequestVoteRequest(req), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +73,7 @@ func (g gRPCAPI) RequestVote(ctx context.Context, req *pb.RequestVoteRequest) (*
 }
 
 func (g gRPCAPI) TimeoutNow(ctx context.Context, req *pb.TimeoutNowRequest) (*pb.TimeoutNowResponse, error) {
-	resp, err := g.handleRPC(decodeTimeoutNowRequest(req), nil)
+	resp, err := g.handleRPC(ctx, decodeTimeoutNowRequest(req), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +85,7 @@ func (g gRPCAPI) InstallSnapshot(s pb.RaftTransport_InstallSnapshotServer) error
 	if err != nil {
 		return err
 	}
-	resp, err := g.handleRPC(decodeInstallSnapshotRequest(isr), &snapshotStream{s, isr.GetData()})
+	resp, err := g.handleRPC(s.Context(), decodeInstallSnapshotRequest(isr), &snapshotStream{s, isr.GetData()})
 	if err != nil {
 		return err
 	}
@@ -108,7 +121,7 @@ func (g gRPCAPI) AppendEntriesPipeline(s pb.RaftTransport_AppendEntriesPipelineS
 		if err != nil {
 			return err
 		}
-		resp, err := g.handleRPC(decodeAppendEntriesRequest(msg), nil)
+		resp, err := g.handleRPC(s.Context(), decodeAppendEntriesRequest(msg), nil)
 		if err != nil {
 			// TODO(quis): One failure doesn't have to break the entire stream?
 			// Or does it all go wrong when it's out of order anyway?
@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"context"
+	"sync"
+
+	pb "github.com/Kapperchino/jet-stream/transport/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
+	"github.com/hashicorp/raft"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Mux serves the raft groups of every shard hosted by a node on a single RaftTransport service. Incoming RPCs are
+// routed by the shard id in their metadata and the groups share the connections to the other nodes.
+type Mux struct {
+	localAddress raft.ServerAddress
+	dialOptions  []grpc.DialOption
+	connections  *connPool
+
+	managersMtx  sync.RWMutex
+	managers     map[string]*Manager
+	defaultShard string
+}
+
+// NewMux creates a Mux, groups are added to it with Add.
+func NewMux(localAddress raft.ServerAddress, dialOptions []grpc.DialOption) *Mux {
+	return &Mux{
+		localAddress: localAddress,
+		dialOptions:  dialOptions,
+		connections:  newConnPool(),
+		managers:     map[string]*Manager{},
+	}
+}
+
+// Add creates the Manager of a shard, its Transport sends the shard id with every RPC.
+// RPCs without a shard id go to the first shard that was added.
+func (m *Mux) Add(shardId string) *Manager {
+	manager := newManager(m.localAddress, m.dialOptions, shardId, m.connections)
+	m.managersMtx.Lock()
+	defer m.managersMtx.Unlock()
+	m.managers[shardId] = manager
+	if m.defaultShard == "" {
+		m.defaultShard = shardId
+	}
+	return manager
+}
+
+// Remove stops routing RPCs to the shard, RPCs without a shard id go to another shard if it was the first.
+func (m *Mux) Remove(shardId string) {
+	m.managersMtx.Lock()
+	defer m.managersMtx.Unlock()
+	delete(m.managers, shardId)
+	if m.defaultShard != shardId {
+		return
+	}
+	m.defaultShard = ""
+	for id := range m.managers {
+		if m.defaultShard == "" || id < m.defaultShard {
+			m.defaultShard = id
+		}
+	}
+}
+
+// Register the RaftTransport gRPC service on a gRPC server.
+func (m *Mux) Register(s *grpc.Server) {
+	pb.RegisterRaftTransportServer(s, gRPCAPI{mux: m})
+}
+
+func (m *Mux) get(ctx context.Context) (*Manager, error) {
+	shardId := util.ShardIdFromContext(ctx)
+	m.managersMtx.RLock()
+	defer m.managersMtx.RUnlock()
+	if shardId == "" {
+		shardId = m.defaultShard
+	}
+	manager, ok := m.managers[shardId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "shard %s is not hosted on %s", shardId, m.localAddress)
+	}
+	return manager, nil
+}
@@ -164,3 +164,51 @@ func TestSnapshot(t *testing.T) {
 
 	close(stop)
 }
+
+func TestMux(t *testing.T) {
+	listen := bufconn.Listen(1024)
+	server := transport.NewMux("t2", []grpc.DialOption{grpc.WithInsecure()})
+	s := grpc.NewServer()
+	server.Register(s)
+	go func() {
+		if err := s.Serve(listen); err != nil {
+			log.Fatalf("t2 exited with error: %v", err)
+		}
+	}()
+	defer s.Stop()
+	serverA := server.Add("shardA").Transport()
+	serverB := server.Add("shardB").Transport()
+
+	client := transport.NewMux("t1", []grpc.DialOption{grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listen.Dial()
+	})})
+	clientB := client.Add("shardB").Transport()
+	clientC := client.Add("shardC").Transport()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case rpc := <-serverA.Consumer():
+				t.Errorf("shardA got %v, want the rpc on shardB", rpc.Command)
+				rpc.Respond(nil, nil)
+			case rpc := <-serverB.Consumer():
+				rpc.Respond(&raft.RequestVoteResponse{Granted: true}, nil)
+			}
+		}
+	}()
+
+	var resp raft.RequestVoteResponse
+	if err := clientB.RequestVote("t2", "t2", &raft.RequestVoteRequest{Term: 2}, &resp); err != nil {
+		t.Errorf("RequestVote() failed: %v", err)
+	}
+	if !resp.Granted {
+		t.Errorf("resp.Granted = %v, want true", resp.Granted)
+	}
+	if err := clientC.RequestVote("t2", "t2", &raft.RequestVoteRequest{Term: 2}, &resp); err == nil {
+		t.Errorf("RequestVote() to a shard that isn't hosted succeeded")
+	}
+}
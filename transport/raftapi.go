@@ -33,15 +33,26 @@ func (r raftAPI) LocalAddr() raft.ServerAddress {
 	return r.manager.localAddress
 }
 
+// connPool holds one connection per target address, a Mux shares it between the raft groups.
+type connPool struct {
+	mtx         sync.Mutex
+	connections map[raft.ServerAddress]*conn
+}
+
+func newConnPool() *connPool {
+	return &connPool{connections: map[raft.ServerAddress]*conn{}}
+}
+
 func (r raftAPI) getPeer(id raft.ServerID, target raft.ServerAddress) (pb.RaftTransportClient, error) {
-	r.manager.connectionsMtx.Lock()
-	c, ok := r.manager.connections[id]
+	pool := r.manager.connections
+	pool.mtx.Lock()
+	c, ok := pool.connections[target]
 	if !ok {
 		c = &conn{}
 		c.mtx.Lock()
-		r.manager.connections[id] = c
+		pool.connections[target] = c
 	}
-	r.manager.connectionsMtx.Unlock()
+	pool.mtx.Unlock()
 	if ok {
 		c.mtx.Lock()
 	}
@@ -63,7 +74,7 @@ func (r raftAPI) AppendEntries(id raft.ServerID, target raft.ServerAddress, args
 	if err != nil {
 		return err
 	}
-	ret, err := c.AppendEntries(context.TODO(), encodeAppendEntriesRequest(args))
+	ret, err := c.AppendEntries(r.manager.context(), encodeAppendEntriesRequest(args))
 	if err != nil {
 		return err
 	}
@@ -77,7 +88,7 @@ func (r raftAPI) RequestVote(id raft.ServerID, target raft.ServerAddress, args *
 	if err != nil {
 		return err
 	}
-	ret, err := c.RequestVote(context.TODO(), encodeRequestVoteRequest(args))
+	ret, err := c.RequestVote(r.manager.context(), encodeRequestVoteRequest(args))
 	if err != nil {
 		return err
 	}
@@ -91,7 +102,7 @@ func (r raftAPI) TimeoutNow(id raft.ServerID, target raft.ServerAddress, args *r
 	if err != nil {
 		return err
 	}
-	ret, err := c.TimeoutNow(context.TODO(), encodeTimeoutNowRequest(args))
+	ret, err := c.TimeoutNow(r.manager.context(), encodeTimeoutNowRequest(args))
 	if err != nil {
 		return err
 	}
@@ -106,7 +117,7 @@ func (r raftAPI) InstallSnapshot(id raft.ServerID, target raft.ServerAddress, re
 	if err != nil {
 		return err
 	}
-	stream, err := c.InstallSnapshot(context.TODO())
+	stream, err := c.InstallSnapshot(r.manager.context())
 	if err != nil {
 		return err
 	}
@@ -143,8 +154,7 @@ func (r raftAPI) AppendEntriesPipeline(id raft.ServerID, target raft.ServerAddre
 	if err != nil {
 		return nil, err
 	}
-	ctx := context.TODO()
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := context.WithCancel(r.manager.context())
 	stream, err := c.AppendEntriesPipeline(ctx)
 	if err != nil {
 		cancel()
@@ -2,9 +2,11 @@
 package transport
 
 import (
+	"context"
 	"sync"
 
 	pb "github.com/Kapperchino/jet-stream/transport/proto/proto"
+	"github.com/Kapperchino/jet-stream/util"
 	"github.com/hashicorp/raft"
 	"google.golang.org/grpc"
 )
@@ -12,23 +14,29 @@ import (
 type Manager struct {
 	localAddress raft.ServerAddress
 	dialOptions  []grpc.DialOption
+	// shardId is sent with every RPC when the manager belongs to a Mux
+	shardId string
 
 	rpcChan          chan raft.RPC
 	heartbeatFunc    func(raft.RPC)
 	heartbeatFuncMtx sync.Mutex
 
-	connectionsMtx sync.Mutex
-	connections    map[raft.ServerID]*conn
+	connections *connPool
 }
 
 // New creates both components of raft-grpc-transport: a gRPC service and a Raft Transport.
 func New(localAddress raft.ServerAddress, dialOptions []grpc.DialOption) *Manager {
+	return newManager(localAddress, dialOptions, "", newConnPool())
+}
+
+func newManager(localAddress raft.ServerAddress, dialOptions []grpc.DialOption, shardId string, connections *connPool) *Manager {
 	return &Manager{
 		localAddress: localAddress,
 		dialOptions:  dialOptions,
+		shardId:      shardId,
 
 		rpcChan:     make(chan raft.RPC),
-		connections: map[raft.ServerID]*conn{},
+		connections: connections,
 	}
 }
 
@@ -41,3 +49,8 @@ func (m *Manager) Register(s *grpc.Server) {
 func (m *Manager) Transport() raft.Transport {
 	return raftAPI{m}
 }
+
+// context returns the context of outgoing RPCs, it carries the shard id so the receiving Mux can route them.
+func (m *Manager) context() context.Context {
+	return util.WithShardId(context.TODO(), m.shardId)
+}
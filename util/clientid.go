@@ -0,0 +1,30 @@
+package util
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ClientIdKey is the grpc metadata key clients name themselves with, the quotas of the client id apply to their calls
+const ClientIdKey = "jet-client-id"
+
+// ClientIdDialOption sends the client id with every unary call of the connection
+func ClientIdDialOption(clientId string) grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(metadata.AppendToOutgoingContext(ctx, ClientIdKey, clientId), method, req, reply, cc, opts...)
+	})
+}
+
+// ClientIdFromContext returns the client id of an incoming call, empty when the caller didn't send one
+func ClientIdFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	ids := md.Get(ClientIdKey)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
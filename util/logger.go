@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"github.com/Kapperchino/jet-stream/config"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"io"
@@ -33,9 +34,10 @@ func CreateLogger() Logger {
 		return strings.ToUpper(fmt.Sprintf("%s", i))
 	}
 	l := Logger{Writer: output}
-	l.Level = zerolog.DebugLevel
+	//the global level set through the config filters the logs
+	l.Level = zerolog.TraceLevel
 	l.Logger = zerolog.New(l.Writer).With().Timestamp().Logger().Level(l.Level)
-	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	zerolog.SetGlobalLevel(config.Current().LogLevel)
 	log.Logger = l.Logger
 	return l
 }
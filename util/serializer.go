@@ -9,14 +9,14 @@ import (
 )
 
 func SerializeMessage(m proto.Message) ([]byte, error) {
-	if config.DEV_MODE {
+	if config.DevMode() {
 		return protojson.Marshal(m)
 	}
 	return proto.Marshal(m)
 }
 
 func DeserializeMessage(b []byte, m proto.Message) error {
-	if config.DEV_MODE {
+	if config.DevMode() {
 		return protojson.Unmarshal(b, m)
 	}
 	return proto.Unmarshal(b, m)
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ShardIdKey is the grpc metadata key that routes a call to one of the shards hosted by a node
+const ShardIdKey = "jet-shard-id"
+
+// WithShardId routes the calls made with the context to the shard
+func WithShardId(ctx context.Context, shardId string) context.Context {
+	if shardId == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, ShardIdKey, shardId)
+}
+
+// ShardIdFromContext returns the shard an incoming call is for, empty when the caller didn't set one
+func ShardIdFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	ids := md.Get(ShardIdKey)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
+
+type shardConn struct {
+	grpc.ClientConnInterface
+	shardId string
+}
+
+// ShardConn routes every call made on the connection to the shard, so clients of several shards hosted by the same
+// node can share the connection
+func ShardConn(conn grpc.ClientConnInterface, shardId string) grpc.ClientConnInterface {
+	return shardConn{ClientConnInterface: conn, shardId: shardId}
+}
+
+func (c shardConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	return c.ClientConnInterface.Invoke(WithShardId(ctx, c.shardId), method, args, reply, opts...)
+}
+
+func (c shardConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return c.ClientConnInterface.NewStream(WithShardId(ctx, c.shardId), desc, method, opts...)
+}
@@ -0,0 +1,229 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// ClientAuthNone doesn't ask clients for a certificate
+	ClientAuthNone = "none"
+	// ClientAuthRequest verifies the certificate of the clients that send one, so brokers can use mutual tls while
+	// clients without certificates still connect
+	ClientAuthRequest = "request"
+	// ClientAuthRequire rejects clients without a valid certificate
+	ClientAuthRequire = "require"
+)
+
+// TLSConfig points to the PEM files used for tls. The files are checked on every handshake and read again when they
+// changed, so certificates are rotated by replacing the files without a restart
+type TLSConfig struct {
+	// CertFile and KeyFile are the certificate of the server, clients present it for mutual tls
+	CertFile string
+	KeyFile  string
+	// CAFile verifies the certificates of the other side, the system roots are used when it is empty
+	CAFile string
+	// ClientAuth is ClientAuthNone, ClientAuthRequest or ClientAuthRequire, the servers don't ask for certificates
+	// when it is empty
+	ClientAuth string
+	// ServerName is the name the server certificate is checked against instead of the host dialed
+	ServerName string
+}
+
+// DialCredentials is the dial option for the config, the connection is plaintext when it is nil
+func DialCredentials(config *TLSConfig) (grpc.DialOption, error) {
+	if config == nil {
+		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
+	creds, err := config.ClientCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return grpc.WithTransportCredentials(creds), nil
+}
+
+// ServerCredentials are the credentials of a grpc server, it needs a certificate
+func (c *TLSConfig) ServerCredentials() (credentials.TransportCredentials, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("a tls server needs a certificate and a key")
+	}
+	clientAuth, err := c.clientAuth()
+	if err != nil {
+		return nil, err
+	}
+	files := &tlsFiles{config: c}
+	if _, _, err := files.load(); err != nil {
+		return nil, err
+	}
+	return &reloadingCredentials{
+		files: files,
+		build: func(cert *tls.Certificate, pool *x509.CertPool) *tls.Config {
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+				ClientAuth:   clientAuth,
+			}
+		},
+	}, nil
+}
+
+// ClientCredentials are the credentials to dial with, the certificate is only sent when there is one
+func (c *TLSConfig) ClientCredentials() (credentials.TransportCredentials, error) {
+	files := &tlsFiles{config: c}
+	if _, _, err := files.load(); err != nil {
+		return nil, err
+	}
+	return &reloadingCredentials{
+		files: files,
+		build: func(cert *tls.Certificate, pool *x509.CertPool) *tls.Config {
+			config := &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				RootCAs:    pool,
+				ServerName: c.ServerName,
+			}
+			if cert != nil {
+				config.Certificates = []tls.Certificate{*cert}
+			}
+			return config
+		},
+	}, nil
+}
+
+func (c *TLSConfig) clientAuth() (tls.ClientAuthType, error) {
+	switch c.ClientAuth {
+	case "", ClientAuthNone:
+		return tls.NoClientCert, nil
+	case ClientAuthRequest:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("unknown client auth %q, it is %s, %s or %s", c.ClientAuth, ClientAuthNone,
+		ClientAuthRequest, ClientAuthRequire)
+}
+
+// reloadingCredentials build the tls config with the current files on every handshake
+type reloadingCredentials struct {
+	files *tlsFiles
+	build func(cert *tls.Certificate, pool *x509.CertPool) *tls.Config
+}
+
+func (r *reloadingCredentials) current() (credentials.TransportCredentials, error) {
+	cert, pool, err := r.files.load()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(r.build(cert, pool)), nil
+}
+
+func (r *reloadingCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	creds, err := r.current()
+	if err != nil {
+		return nil, nil, err
+	}
+	return creds.ClientHandshake(ctx, authority, conn)
+}
+
+func (r *reloadingCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	creds, err := r.current()
+	if err != nil {
+		return nil, nil, err
+	}
+	return creds.ServerHandshake(conn)
+}
+
+func (r *reloadingCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{
+		SecurityProtocol: "tls",
+		SecurityVersion:  "1.2",
+		ServerName:       r.files.config.ServerName,
+	}
+}
+
+func (r *reloadingCredentials) Clone() credentials.TransportCredentials {
+	return &reloadingCredentials{files: r.files, build: r.build}
+}
+
+func (r *reloadingCredentials) OverrideServerName(name string) error {
+	config := *r.files.config
+	config.ServerName = name
+	r.files = &tlsFiles{config: &config}
+	return nil
+}
+
+// tlsFiles caches the certificate and the ca pool until their files are modified
+type tlsFiles struct {
+	config   *TLSConfig
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	certTime time.Time
+	keyTime  time.Time
+	caTime   time.Time
+	mutex    sync.Mutex
+}
+
+// load returns the certificate and the pool, a file that can't be read again keeps the last good version so a
+// rotation caught half written doesn't break the handshakes
+func (f *tlsFiles) load() (*tls.Certificate, *x509.CertPool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.config.CertFile != "" {
+		certTime, keyTime := modTime(f.config.CertFile), modTime(f.config.KeyFile)
+		if f.cert == nil || !certTime.Equal(f.certTime) || !keyTime.Equal(f.keyTime) {
+			cert, err := tls.LoadX509KeyPair(f.config.CertFile, f.config.KeyFile)
+			if err == nil {
+				f.cert, f.certTime, f.keyTime = &cert, certTime, keyTime
+			} else if f.cert == nil {
+				return nil, nil, fmt.Errorf("loading the tls certificate %s: %w", f.config.CertFile, err)
+			} else {
+				log.Warn().Err(err).Msgf("Keeping the previous tls certificate, %s can't be loaded", f.config.CertFile)
+			}
+		}
+	}
+	if f.config.CAFile != "" {
+		caTime := modTime(f.config.CAFile)
+		if f.pool == nil || !caTime.Equal(f.caTime) {
+			pool, err := loadPool(f.config.CAFile)
+			if err == nil {
+				f.pool, f.caTime = pool, caTime
+			} else if f.pool == nil {
+				return nil, nil, err
+			} else {
+				log.Warn().Err(err).Msgf("Keeping the previous tls ca, %s can't be loaded", f.config.CAFile)
+			}
+		}
+	}
+	return f.cert, f.pool, nil
+}
+
+func loadPool(file string) (*x509.CertPool, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("loading the tls ca %s: %w", file, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(buf) {
+		return nil, fmt.Errorf("no certificate found in the tls ca %s", file)
+	}
+	return pool, nil
+}
+
+func modTime(file string) time.Time {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
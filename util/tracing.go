@@ -0,0 +1,54 @@
+package util
+
+import (
+	"context"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// TracerName names the spans of the clients and the nodes
+const TracerName = "github.com/Kapperchino/jet-stream"
+
+// Tracer returns the tracer of the global provider, the spans are dropped until the application sets one
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// TracingDialOptions start a span for every call of the connection and send the trace context in the grpc metadata
+func TracingDialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(otelgrpc.StreamClientInterceptor()),
+	}
+}
+
+// TracingServerOptions continue the trace context of the calls in a span of the server, the raft traffic and the health
+// checks aren't traced
+func TracingServerOptions() []grpc.ServerOption {
+	filter := otelgrpc.WithInterceptorFilter(filters.None(filters.ServiceName("transport.RaftTransport"), filters.HealthCheck()))
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(otelgrpc.UnaryServerInterceptor(filter)),
+		grpc.ChainStreamInterceptor(otelgrpc.StreamServerInterceptor(filter)),
+	}
+}
+
+// InjectTraceContext writes the trace context of ctx in the headers, they are created when nil
+func InjectTraceContext(ctx context.Context, headers map[string]string) map[string]string {
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+// ExtractTraceContext returns ctx with the trace context of the headers, like the one of the producer of a message
+func ExtractTraceContext(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}